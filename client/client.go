@@ -0,0 +1,289 @@
+// Package client is a Go client for the Branchlore HTTP API.
+//
+//	c, err := client.NewClient("http://localhost:8080")
+//	rows, err := c.Query(ctx, "myapp", "feature-x", "SELECT id, name FROM users WHERE id = ?", 42)
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+type Client struct {
+	baseURL      string
+	httpClient   *http.Client
+	queryTimeout time.Duration
+	token        string
+}
+
+type Option func(*Client)
+
+// WithHTTPClient sets the HTTP client used for requests. It defaults to
+// http.DefaultClient.
+func WithHTTPClient(hc *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = hc
+	}
+}
+
+// WithQueryTimeout asks the server to cancel each statement after d instead
+// of using the server's default timeout.
+func WithQueryTimeout(d time.Duration) Option {
+	return func(c *Client) {
+		c.queryTimeout = d
+	}
+}
+
+// WithToken authenticates every request with an API token, for servers
+// started with --auth.
+func WithToken(token string) Option {
+	return func(c *Client) {
+		c.token = token
+	}
+}
+
+// NewClient returns a client for the server at baseURL, e.g.
+// "http://localhost:8080".
+func NewClient(baseURL string, opts ...Option) (*Client, error) {
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid server URL: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("invalid server URL %q: scheme must be http or https", baseURL)
+	}
+
+	c := &Client{
+		baseURL:    strings.TrimSuffix(baseURL, "/"),
+		httpClient: http.DefaultClient,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c, nil
+}
+
+// Rows is the result of a row returning statement.
+type Rows struct {
+	Columns []string
+	// ColumnTypes holds the declared type of each column, "" for
+	// expressions.
+	ColumnTypes []string
+	// Values holds one slice per row. Each value is an int64, float64,
+	// string, []byte or nil for NULL.
+	Values [][]interface{}
+	// Truncated is set when the server cut the result off at its row limit.
+	Truncated bool
+}
+
+// Result is the outcome of a statement that does not return rows.
+type Result struct {
+	RowsAffected int64
+	LastInsertID int64
+}
+
+// Response holds either Rows or Result, depending on the statement.
+type Response struct {
+	Rows   *Rows
+	Result *Result
+}
+
+// Run executes a statement whose kind is not known in advance, such as one
+// typed by a user.
+func (c *Client) Run(ctx context.Context, db, branch, query string, args ...interface{}) (*Response, error) {
+	return c.run(ctx, branchPath(db, branch)+"/query", query, args)
+}
+
+// Query runs a row returning statement.
+func (c *Client) Query(ctx context.Context, db, branch, query string, args ...interface{}) (*Rows, error) {
+	resp, err := c.Run(ctx, db, branch, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	return resp.rows(), nil
+}
+
+// Exec runs a statement that does not return rows.
+func (c *Client) Exec(ctx context.Context, db, branch, query string, args ...interface{}) (*Result, error) {
+	resp, err := c.Run(ctx, db, branch, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	return resp.result(), nil
+}
+
+func (c *Client) ListDatabases(ctx context.Context) ([]string, error) {
+	var out struct {
+		Databases []string `json:"databases"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/v1/databases", nil, &out); err != nil {
+		return nil, err
+	}
+	return out.Databases, nil
+}
+
+func (c *Client) CreateDatabase(ctx context.Context, db string) error {
+	return c.do(ctx, http.MethodPost, "/v1/databases", map[string]string{"name": db}, nil)
+}
+
+func (c *Client) DeleteDatabase(ctx context.Context, db string) error {
+	return c.do(ctx, http.MethodDelete, "/v1/databases/"+url.PathEscape(db), nil, nil)
+}
+
+func (c *Client) ListBranches(ctx context.Context, db string) ([]string, error) {
+	var out struct {
+		Branches []string `json:"branches"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/v1/databases/"+url.PathEscape(db)+"/branches", nil, &out); err != nil {
+		return nil, err
+	}
+	return out.Branches, nil
+}
+
+func (c *Client) CreateBranch(ctx context.Context, db, branch string) error {
+	return c.do(ctx, http.MethodPost, "/v1/databases/"+url.PathEscape(db)+"/branches", map[string]string{"name": branch}, nil)
+}
+
+func (c *Client) DeleteBranch(ctx context.Context, db, branch string) error {
+	return c.do(ctx, http.MethodDelete, branchPath(db, branch), nil, nil)
+}
+
+func branchPath(db, branch string) string {
+	return "/v1/databases/" + url.PathEscape(db) + "/branches/" + url.PathEscape(branch)
+}
+
+type queryRequest struct {
+	Query    string        `json:"query"`
+	Format   string        `json:"format"`
+	Timeout  string        `json:"timeout,omitempty"`
+	PageSize int           `json:"page_size,omitempty"`
+	Args     []interface{} `json:"args,omitempty"`
+}
+
+type queryResponse struct {
+	Columns      []string        `json:"columns"`
+	ColumnTypes  []string        `json:"column_types"`
+	Rows         [][]interface{} `json:"rows"`
+	Truncated    bool            `json:"truncated"`
+	Cursor       string          `json:"cursor"`
+	RowsAffected *int64          `json:"rows_affected"`
+	LastInsertID int64           `json:"last_insert_id"`
+}
+
+func (c *Client) run(ctx context.Context, path, query string, args []interface{}) (*Response, error) {
+	req, err := c.newQuery(query, args)
+	if err != nil {
+		return nil, err
+	}
+
+	var out queryResponse
+	if err := c.do(ctx, http.MethodPost, path, req, &out); err != nil {
+		return nil, err
+	}
+	return out.response()
+}
+
+func (c *Client) newQuery(query string, args []interface{}) (queryRequest, error) {
+	req := queryRequest{Query: query, Format: "typed"}
+	if c.queryTimeout > 0 {
+		req.Timeout = c.queryTimeout.String()
+	}
+	for _, arg := range args {
+		v, err := encodeArg(arg)
+		if err != nil {
+			return queryRequest{}, err
+		}
+		req.Args = append(req.Args, v)
+	}
+	return req, nil
+}
+
+func (out *queryResponse) response() (*Response, error) {
+	if out.RowsAffected != nil {
+		return &Response{Result: &Result{RowsAffected: *out.RowsAffected, LastInsertID: out.LastInsertID}}, nil
+	}
+
+	rows := &Rows{
+		Columns:     out.Columns,
+		ColumnTypes: out.ColumnTypes,
+		Values:      make([][]interface{}, len(out.Rows)),
+		Truncated:   out.Truncated,
+	}
+	for i, raw := range out.Rows {
+		row := make([]interface{}, len(raw))
+		for j, cell := range raw {
+			v, err := decodeValue(cell)
+			if err != nil {
+				return nil, fmt.Errorf("row %d column %d: %w", i, j, err)
+			}
+			row[j] = v
+		}
+		rows.Values[i] = row
+	}
+	return &Response{Rows: rows}, nil
+}
+
+func (r *Response) rows() *Rows {
+	if r.Rows == nil {
+		return &Rows{}
+	}
+	return r.Rows
+}
+
+func (r *Response) result() *Result {
+	if r.Result == nil {
+		return &Result{}
+	}
+	return r.Result
+}
+
+// do sends a JSON request and decodes the JSON response into out. Error
+// responses are returned as *Error.
+func (c *Client) do(ctx context.Context, method, path string, body, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("failed to encode request: %w", err)
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, reader)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		return decodeError(resp)
+	}
+	if out == nil {
+		return nil
+	}
+
+	dec := json.NewDecoder(resp.Body)
+	dec.UseNumber()
+	if err := dec.Decode(out); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,285 @@
+package client_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/bxrne/branchlore/client"
+	"github.com/bxrne/branchlore/internal/auth"
+	"github.com/bxrne/branchlore/internal/server"
+)
+
+// startServer serves config, with a fresh data directory, on a free port
+// and returns its URL.
+func startServer(t *testing.T, config *server.Config) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := ln.Addr().(*net.TCPAddr).Port
+	ln.Close()
+
+	config.Port, config.LogLevel = strconv.Itoa(port), "error"
+	srv, err := server.New(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go srv.Start()
+	t.Cleanup(func() { srv.Shutdown(context.Background()) })
+
+	url := "http://127.0.0.1:" + config.Port
+	for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(10 * time.Millisecond) {
+		resp, err := http.Get(url + "/healthz")
+		if err == nil {
+			resp.Body.Close()
+			return url
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("server did not start: %v", err)
+		}
+	}
+}
+
+// newTestClient returns a client of a new server holding the database
+// "db".
+func newTestClient(t *testing.T) *client.Client {
+	t.Helper()
+	c, err := client.NewClient(startServer(t, &server.Config{DataDir: t.TempDir()}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.CreateDatabase(context.Background(), "db"); err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestNewClientRejectsInvalidURLs(t *testing.T) {
+	for _, url := range []string{"localhost:8080", "ftp://localhost", "http://[::1"} {
+		if _, err := client.NewClient(url); err == nil {
+			t.Errorf("NewClient(%q) succeeded", url)
+		}
+	}
+}
+
+func TestValuesRoundTrip(t *testing.T) {
+	c := newTestClient(t)
+	ctx := context.Background()
+
+	if _, err := c.Exec(ctx, "db", "main", "CREATE TABLE t (i INTEGER, r REAL, s TEXT, b BLOB, n, flag INTEGER, at TEXT)"); err != nil {
+		t.Fatal(err)
+	}
+	at := time.Date(2024, 1, 2, 3, 4, 5, 6, time.UTC)
+	res, err := c.Exec(ctx, "db", "main", "INSERT INTO t VALUES (?, ?, ?, ?, ?, ?, ?)",
+		int64(math.MaxInt64), math.Inf(-1), "héllo", []byte{0, 1, 255}, nil, true, at)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.RowsAffected != 1 || res.LastInsertID != 1 {
+		t.Errorf("result %+v", res)
+	}
+
+	rows, err := c.Query(ctx, "db", "main", "SELECT i, r, s, b, n, flag, at FROM t WHERE i = ?", uint64(math.MaxInt64))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows.Values) != 1 {
+		t.Fatalf("got %d rows, want 1", len(rows.Values))
+	}
+	row := rows.Values[0]
+	if row[0] != int64(math.MaxInt64) || row[1] != math.Inf(-1) || row[2] != "héllo" ||
+		!bytes.Equal(row[3].([]byte), []byte{0, 1, 255}) || row[4] != nil || row[5] != int64(1) ||
+		row[6] != "2024-01-02 03:04:05.000000006+00:00" {
+		t.Errorf("got %#v", row)
+	}
+	if rows.ColumnTypes[0] != "INTEGER" || rows.Columns[6] != "at" {
+		t.Errorf("columns %q, types %q", rows.Columns, rows.ColumnTypes)
+	}
+
+	if _, err := c.Query(ctx, "db", "main", "SELECT ?", uint64(math.MaxUint64)); err == nil {
+		t.Error("an argument overflowing int64 was sent")
+	}
+}
+
+func TestRun(t *testing.T) {
+	c := newTestClient(t)
+	ctx := context.Background()
+
+	resp, err := c.Run(ctx, "db", "main", "CREATE TABLE t (a)")
+	if err != nil || resp.Result == nil || resp.Rows != nil {
+		t.Fatalf("CREATE TABLE: %+v, %v", resp, err)
+	}
+	resp, err = c.Run(ctx, "db", "main", "SELECT * FROM t")
+	if err != nil || resp.Rows == nil || resp.Result != nil {
+		t.Fatalf("SELECT: %+v, %v", resp, err)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	c := newTestClient(t)
+	ctx := context.Background()
+
+	_, err := c.Query(ctx, "missing", "main", "SELECT 1")
+	var e *client.Error
+	if !errors.As(err, &e) || e.Code != client.CodeDBNotFound || e.StatusCode != http.StatusNotFound {
+		t.Errorf("query on a missing database: %v", err)
+	}
+
+	c.Exec(ctx, "db", "main", "CREATE TABLE t (id INTEGER PRIMARY KEY)")
+	c.Exec(ctx, "db", "main", "INSERT INTO t VALUES (1)")
+	if _, err := c.Exec(ctx, "db", "main", "INSERT INTO t VALUES (1)"); !client.IsCode(err, client.CodeSQLiteConstraint) {
+		t.Errorf("duplicate key: %v, want %s", err, client.CodeSQLiteConstraint)
+	}
+
+	slow, err := client.NewClient(startServer(t, &server.Config{DataDir: t.TempDir()}), client.WithQueryTimeout(50*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	slow.CreateDatabase(ctx, "db")
+	_, err = slow.Query(ctx, "db", "main", "WITH RECURSIVE c(x) AS (SELECT 1 UNION ALL SELECT x + 1 FROM c) SELECT count(*) FROM (SELECT x FROM c LIMIT 1000000000)")
+	if !errors.As(err, &e) || e.Code != client.CodeQueryTimeout || !e.Retryable {
+		t.Errorf("slow query: %v, want a retryable %s", err, client.CodeQueryTimeout)
+	}
+}
+
+func TestBranches(t *testing.T) {
+	c := newTestClient(t)
+	ctx := context.Background()
+
+	if err := c.CreateBranch(ctx, "db", "dev"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.CreateBranch(ctx, "db", "dev"); !client.IsCode(err, client.CodeBranchExists) {
+		t.Errorf("creating it again: %v, want %s", err, client.CodeBranchExists)
+	}
+	branches, err := c.ListBranches(ctx, "db")
+	if err != nil || len(branches) != 2 {
+		t.Fatalf("branches %q, %v, want dev and main", branches, err)
+	}
+	if err := c.DeleteBranch(ctx, "db", "dev"); err != nil {
+		t.Fatal(err)
+	}
+
+	databases, err := c.ListDatabases(ctx)
+	if err != nil || len(databases) != 1 || databases[0] != "db" {
+		t.Fatalf("databases %q, %v, want [db]", databases, err)
+	}
+	if err := c.DeleteDatabase(ctx, "db"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTransactions(t *testing.T) {
+	c := newTestClient(t)
+	ctx := context.Background()
+	c.Exec(ctx, "db", "main", "CREATE TABLE t (a INTEGER)")
+
+	count := func() int64 {
+		t.Helper()
+		rows, err := c.Query(ctx, "db", "main", "SELECT count(*) FROM t")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return rows.Values[0][0].(int64)
+	}
+
+	tx, err := c.BeginTx(ctx, "db", "main")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tx.Exec(ctx, "INSERT INTO t VALUES (?)", 1); err != nil {
+		t.Fatal(err)
+	}
+	if rows, err := tx.Query(ctx, "SELECT count(*) FROM t"); err != nil || rows.Values[0][0] != int64(1) {
+		t.Fatalf("count inside the transaction: %v, %v", rows, err)
+	}
+	if err := tx.Rollback(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if n := count(); n != 0 {
+		t.Fatalf("%d rows after rollback", n)
+	}
+	if err := tx.Commit(ctx); !client.IsCode(err, client.CodeTxNotFound) {
+		t.Errorf("commit after rollback: %v, want %s", err, client.CodeTxNotFound)
+	}
+
+	tx, err = c.BeginTx(ctx, "db", "main")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx.Exec(ctx, "INSERT INTO t VALUES (1), (2)")
+	if err := tx.Commit(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if n := count(); n != 2 {
+		t.Fatalf("%d rows after commit, want 2", n)
+	}
+}
+
+func TestQueryPages(t *testing.T) {
+	c := newTestClient(t)
+	ctx := context.Background()
+
+	cur, err := c.QueryPages(ctx, "db", "main", 2, "VALUES (1), (2), (3), (4), (5)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var values []interface{}
+	for {
+		rows, err := cur.Next(ctx)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, row := range rows.Values {
+			values = append(values, row[0])
+		}
+	}
+	if len(values) != 5 || values[4] != int64(5) {
+		t.Errorf("got %v over all pages, want 1 to 5", values)
+	}
+	if err := cur.Close(ctx); err != nil {
+		t.Errorf("close after the last page: %v", err)
+	}
+
+	cur, err = c.QueryPages(ctx, "db", "main", 1, "VALUES (1), (2)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cur.Close(ctx); err != nil {
+		t.Errorf("close before the last page: %v", err)
+	}
+	if _, err := cur.Next(ctx); err != io.EOF {
+		t.Errorf("next after close: %v, want io.EOF", err)
+	}
+}
+
+func TestWithToken(t *testing.T) {
+	dataDir := t.TempDir()
+	url := startServer(t, &server.Config{DataDir: dataDir, Auth: true})
+	secret, _, err := auth.NewStore(dataDir).Create("app", []auth.Scope{auth.ScopeAdmin}, nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	anonymous, _ := client.NewClient(url)
+	if _, err := anonymous.ListDatabases(ctx); !client.IsCode(err, client.CodeUnauthenticated) {
+		t.Errorf("without a token: %v, want %s", err, client.CodeUnauthenticated)
+	}
+	c, _ := client.NewClient(url, client.WithToken(secret))
+	if err := c.CreateDatabase(ctx, "db"); err != nil {
+		t.Errorf("with a token: %v", err)
+	}
+}
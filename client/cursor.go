@@ -0,0 +1,79 @@
+package client
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+)
+
+// Cursor pages through the result of a SELECT too large to fetch at once.
+// The server closes cursors left idle for longer than its cursor idle
+// timeout.
+type Cursor struct {
+	c       *Client
+	id      string
+	pending *Rows
+}
+
+// QueryPages runs a row returning statement and returns a cursor over its
+// result, pageSize rows at a time.
+func (c *Client) QueryPages(ctx context.Context, db, branch string, pageSize int, query string, args ...interface{}) (*Cursor, error) {
+	req, err := c.newQuery(query, args)
+	if err != nil {
+		return nil, err
+	}
+	req.PageSize = pageSize
+
+	var out queryResponse
+	if err := c.do(ctx, http.MethodPost, branchPath(db, branch)+"/query", req, &out); err != nil {
+		return nil, err
+	}
+	resp, err := out.response()
+	if err != nil {
+		return nil, err
+	}
+	return &Cursor{c: c, id: out.Cursor, pending: resp.rows()}, nil
+}
+
+// Next returns the next page of rows, or io.EOF once the result is
+// exhausted.
+func (cur *Cursor) Next(ctx context.Context) (*Rows, error) {
+	if rows := cur.pending; rows != nil {
+		cur.pending = nil
+		return rows, nil
+	}
+	if cur.id == "" {
+		return nil, io.EOF
+	}
+
+	var out queryResponse
+	if err := cur.c.do(ctx, http.MethodGet, cur.path(), nil, &out); err != nil {
+		cur.id = ""
+		return nil, err
+	}
+	cur.id = out.Cursor
+
+	resp, err := out.response()
+	if err != nil {
+		return nil, err
+	}
+	return resp.rows(), nil
+}
+
+// Close releases the cursor on the server. It is a no-op once Next has
+// returned io.EOF.
+func (cur *Cursor) Close(ctx context.Context) error {
+	cur.pending = nil
+	if cur.id == "" {
+		return nil
+	}
+
+	path := cur.path()
+	cur.id = ""
+	return cur.c.do(ctx, http.MethodDelete, path, nil, nil)
+}
+
+func (cur *Cursor) path() string {
+	return "/v1/cursors/" + url.PathEscape(cur.id)
+}
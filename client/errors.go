@@ -0,0 +1,71 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// Error codes reported by the server. SQLite failures use "SQLITE_" followed
+// by the primary result code name, e.g. "SQLITE_CONSTRAINT".
+const (
+	CodeInvalidArgument  = "INVALID_ARGUMENT"
+	CodeNotFound         = "NOT_FOUND"
+	CodeMethodNotAllowed = "METHOD_NOT_ALLOWED"
+	CodeDBNotFound       = "DB_NOT_FOUND"
+	CodeDBExists         = "DB_EXISTS"
+	CodeBranchNotFound   = "BRANCH_NOT_FOUND"
+	CodeBranchExists     = "BRANCH_EXISTS"
+	CodeBranchProtected  = "BRANCH_PROTECTED"
+	CodeMergeConflict    = "MERGE_CONFLICT"
+	CodeCheckFailed      = "CHECK_FAILED"
+	CodeCursorNotFound   = "CURSOR_NOT_FOUND"
+	CodeTxNotFound       = "TX_NOT_FOUND"
+	CodeQueryTimeout     = "QUERY_TIMEOUT"
+	CodeQueryCancelled   = "QUERY_CANCELLED"
+	CodeUnauthenticated  = "UNAUTHENTICATED"
+	CodePermissionDenied = "PERMISSION_DENIED"
+	CodeInternal         = "INTERNAL"
+	CodeSQLiteConstraint = "SQLITE_CONSTRAINT"
+	CodeSQLiteBusy       = "SQLITE_BUSY"
+)
+
+// Error is an error response from the server.
+type Error struct {
+	StatusCode         int
+	Code               string `json:"code"`
+	Message            string `json:"message"`
+	SQLiteCode         int    `json:"sqlite_code"`
+	SQLiteExtendedCode int    `json:"sqlite_extended_code"`
+	// Retryable is set for transient failures such as SQLITE_BUSY.
+	Retryable bool `json:"retryable"`
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s: %s", e.Code, e.Message)
+}
+
+// IsCode reports whether err is an *Error with the given code.
+func IsCode(err error, code string) bool {
+	var e *Error
+	return errors.As(err, &e) && e.Code == code
+}
+
+func decodeError(resp *http.Response) error {
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response: %w", err)
+	}
+
+	var envelope struct {
+		Error *Error `json:"error"`
+	}
+	if err := json.Unmarshal(body, &envelope); err != nil || envelope.Error == nil || envelope.Error.Code == "" {
+		return &Error{StatusCode: resp.StatusCode, Code: CodeInternal, Message: string(body)}
+	}
+
+	envelope.Error.StatusCode = resp.StatusCode
+	return envelope.Error
+}
@@ -0,0 +1,56 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+)
+
+// Tx is a server-side transaction on one branch. The server rolls it back if
+// it sits idle for longer than its transaction idle timeout.
+type Tx struct {
+	c  *Client
+	id string
+}
+
+func (c *Client) BeginTx(ctx context.Context, db, branch string) (*Tx, error) {
+	var out struct {
+		ID string `json:"id"`
+	}
+	if err := c.do(ctx, http.MethodPost, branchPath(db, branch)+"/transactions", nil, &out); err != nil {
+		return nil, err
+	}
+	return &Tx{c: c, id: out.ID}, nil
+}
+
+func (t *Tx) Run(ctx context.Context, query string, args ...interface{}) (*Response, error) {
+	return t.c.run(ctx, t.path()+"/query", query, args)
+}
+
+func (t *Tx) Query(ctx context.Context, query string, args ...interface{}) (*Rows, error) {
+	resp, err := t.Run(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	return resp.rows(), nil
+}
+
+func (t *Tx) Exec(ctx context.Context, query string, args ...interface{}) (*Result, error) {
+	resp, err := t.Run(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	return resp.result(), nil
+}
+
+func (t *Tx) Commit(ctx context.Context) error {
+	return t.c.do(ctx, http.MethodPost, t.path()+"/commit", nil, nil)
+}
+
+func (t *Tx) Rollback(ctx context.Context) error {
+	return t.c.do(ctx, http.MethodPost, t.path()+"/rollback", nil, nil)
+}
+
+func (t *Tx) path() string {
+	return "/v1/transactions/" + url.PathEscape(t.id)
+}
@@ -0,0 +1,127 @@
+package client
+
+import (
+	"database/sql/driver"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+)
+
+// typedValue mirrors the server's lossless value encoding.
+type typedValue struct {
+	Type  string      `json:"type"`
+	Value interface{} `json:"value"`
+}
+
+const timestampFormat = "2006-01-02 15:04:05.999999999-07:00"
+
+func encodeArg(arg interface{}) (interface{}, error) {
+	switch v := arg.(type) {
+	case nil:
+		return nil, nil
+	case int:
+		return integerValue(int64(v)), nil
+	case int8:
+		return integerValue(int64(v)), nil
+	case int16:
+		return integerValue(int64(v)), nil
+	case int32:
+		return integerValue(int64(v)), nil
+	case int64:
+		return integerValue(v), nil
+	case uint:
+		return unsigned(uint64(v))
+	case uint8:
+		return integerValue(int64(v)), nil
+	case uint16:
+		return integerValue(int64(v)), nil
+	case uint32:
+		return integerValue(int64(v)), nil
+	case uint64:
+		return unsigned(v)
+	case bool:
+		if v {
+			return integerValue(1), nil
+		}
+		return integerValue(0), nil
+	case float32:
+		return realValue(float64(v)), nil
+	case float64:
+		return realValue(v), nil
+	case string:
+		return typedValue{Type: "TEXT", Value: v}, nil
+	case []byte:
+		return typedValue{Type: "BLOB", Value: base64.StdEncoding.EncodeToString(v)}, nil
+	case time.Time:
+		return typedValue{Type: "TEXT", Value: v.Format(timestampFormat)}, nil
+	case driver.Valuer:
+		val, err := v.Value()
+		if err != nil {
+			return nil, err
+		}
+		return encodeArg(val)
+	}
+	return nil, fmt.Errorf("unsupported argument type %T", arg)
+}
+
+func integerValue(n int64) typedValue {
+	return typedValue{Type: "INTEGER", Value: strconv.FormatInt(n, 10)}
+}
+
+func unsigned(n uint64) (interface{}, error) {
+	if n > math.MaxInt64 {
+		return nil, fmt.Errorf("argument %d overflows a 64-bit signed integer", n)
+	}
+	return integerValue(int64(n)), nil
+}
+
+func realValue(f float64) typedValue {
+	switch {
+	case math.IsInf(f, 1):
+		return typedValue{Type: "REAL", Value: "Infinity"}
+	case math.IsInf(f, -1):
+		return typedValue{Type: "REAL", Value: "-Infinity"}
+	}
+	return typedValue{Type: "REAL", Value: f}
+}
+
+// decodeValue converts a typed result cell, as decoded with UseNumber, into
+// an int64, float64, string, []byte or nil.
+func decodeValue(cell interface{}) (interface{}, error) {
+	if cell == nil {
+		return nil, nil
+	}
+
+	m, ok := cell.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("unexpected value %v", cell)
+	}
+
+	switch v := m["value"].(type) {
+	case string:
+		switch m["type"] {
+		case "INTEGER":
+			return strconv.ParseInt(v, 10, 64)
+		case "REAL":
+			switch v {
+			case "Infinity":
+				return math.Inf(1), nil
+			case "-Infinity":
+				return math.Inf(-1), nil
+			}
+			return strconv.ParseFloat(v, 64)
+		case "TEXT":
+			return v, nil
+		case "BLOB":
+			return base64.StdEncoding.DecodeString(v)
+		}
+	case json.Number:
+		if m["type"] == "REAL" {
+			return v.Float64()
+		}
+	}
+	return nil, fmt.Errorf("unexpected %v value %v", m["type"], m["value"])
+}
@@ -22,6 +22,11 @@ func init() {
 	rootCmd.AddCommand(cli.NewBranchCmd())
 	rootCmd.AddCommand(cli.NewConnectCmd())
 	rootCmd.AddCommand(cli.NewInitCmd())
+	rootCmd.AddCommand(cli.NewTokenCmd())
+	rootCmd.AddCommand(cli.NewRoleCmd())
+	rootCmd.AddCommand(cli.NewCertCmd())
+	rootCmd.AddCommand(cli.NewAuditCmd())
+	rootCmd.AddCommand(cli.NewDuCmd())
 }
 
 func main() {
@@ -0,0 +1,125 @@
+// Package embedded runs Branchlore's branching engine in-process, without a
+// server.
+//
+//	s, err := embedded.Open("./data")
+//	defer s.Close()
+//
+//	err = s.CreateDatabase("myapp")
+//	err = s.CreateBranch(ctx, "myapp", "feature-x")
+//	db, err := s.DB("myapp", "feature-x")
+//	_, err = db.ExecContext(ctx, "CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT)")
+//	hash, err := s.Commit(ctx, "myapp", "feature-x", "Add users table")
+//	diff, err := s.Diff(ctx, "myapp", "main", "feature-x")
+//
+// A data directory must not be served by a branchlore server while a Store
+// has it open.
+package embedded
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/bxrne/branchlore/internal/database"
+	"github.com/bxrne/branchlore/internal/git"
+)
+
+// Errors returned by Store methods, for use with errors.Is.
+var (
+	ErrInvalidName      = git.ErrInvalidName
+	ErrDatabaseNotFound = git.ErrDatabaseNotFound
+	ErrDatabaseExists   = git.ErrDatabaseExists
+	ErrBranchNotFound   = git.ErrBranchNotFound
+	ErrBranchExists     = git.ErrBranchExists
+	ErrBranchProtected  = git.ErrBranchProtected
+	ErrMergeConflict    = git.ErrMergeConflict
+	ErrCheckFailed      = database.ErrCheckFailed
+)
+
+type (
+	Diff      = database.Diff
+	TableDiff = database.TableDiff
+)
+
+// Store is a data directory of branchable databases. It is safe for
+// concurrent use.
+type Store struct {
+	gitMgr *git.Manager
+	dbMgr  *database.Manager
+}
+
+// Open opens the data directory at dataDir, creating it if needed.
+func Open(dataDir string) (*Store, error) {
+	gitMgr, err := git.NewManager(dataDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create git manager: %w", err)
+	}
+
+	dbMgr, err := database.NewManager(dataDir, gitMgr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create database manager: %w", err)
+	}
+
+	return &Store{gitMgr: gitMgr, dbMgr: dbMgr}, nil
+}
+
+// Close closes every connection handed out by DB.
+func (s *Store) Close() error {
+	return s.dbMgr.Close()
+}
+
+func (s *Store) CreateDatabase(name string) error {
+	return s.gitMgr.InitDatabase(name)
+}
+
+func (s *Store) DeleteDatabase(name string) error {
+	return s.dbMgr.DeleteDatabase(name)
+}
+
+func (s *Store) ListDatabases() ([]string, error) {
+	return s.gitMgr.ListDatabases()
+}
+
+// CreateBranch creates branch as a copy of main.
+func (s *Store) CreateBranch(ctx context.Context, db, branch string) error {
+	return s.dbMgr.CreateBranch(ctx, db, branch)
+}
+
+// ForkBranch creates branch as a copy of from.
+func (s *Store) ForkBranch(ctx context.Context, db, branch, from string) error {
+	return s.dbMgr.ForkBranch(ctx, db, branch, from, git.BranchMeta{})
+}
+
+// DeleteBranch deletes branch and closes its connection. The main branch
+// cannot be deleted.
+func (s *Store) DeleteBranch(db, branch string) error {
+	return s.dbMgr.DeleteBranch(db, branch)
+}
+
+func (s *Store) ListBranches(db string) ([]string, error) {
+	return s.gitMgr.ListBranches(db)
+}
+
+// DB returns a connection pool for db@branch. The Store owns it: do not
+// close it, it is closed by DeleteBranch, DeleteDatabase and Close.
+func (s *Store) DB(db, branch string) (*sql.DB, error) {
+	return s.dbMgr.DB(db, branch)
+}
+
+// Commit records the current state of db@branch in its history and returns
+// the commit hash. Committing an unchanged branch returns the previous hash.
+func (s *Store) Commit(ctx context.Context, db, branch, message string) (string, error) {
+	return s.dbMgr.CommitBranch(ctx, db, branch, message)
+}
+
+// Merge merges db@from into db@branch and returns the merge commit hash. If
+// branch is protected its checks must pass on the result. Merges fail with
+// ErrMergeConflict when branch has changed since from branched from it.
+func (s *Store) Merge(ctx context.Context, db, branch, from, message string) (string, error) {
+	return s.dbMgr.MergeBranch(ctx, db, from, branch, message)
+}
+
+// Diff reports the tables of db@head that differ from db@base.
+func (s *Store) Diff(ctx context.Context, db, base, head string) (*Diff, error) {
+	return s.dbMgr.DiffBranches(ctx, db, base, head)
+}
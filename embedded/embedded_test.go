@@ -0,0 +1,160 @@
+package embedded_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/bxrne/branchlore/embedded"
+)
+
+// openStore opens a store in a fresh directory holding the database "db"
+// with a table t committed on main.
+func openStore(t *testing.T) *embedded.Store {
+	t.Helper()
+	s, err := embedded.Open(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+	if err := s.CreateDatabase("db"); err != nil {
+		t.Fatal(err)
+	}
+	exec(t, s, "main", "CREATE TABLE t (a INTEGER)")
+	if _, err := s.Commit(context.Background(), "db", "main", "add t"); err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func exec(t *testing.T, s *embedded.Store, branch, query string) {
+	t.Helper()
+	db, err := s.DB("db", branch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(query); err != nil {
+		t.Fatalf("%s: %v", query, err)
+	}
+}
+
+func count(t *testing.T, s *embedded.Store, branch string) int {
+	t.Helper()
+	db, err := s.DB("db", branch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var n int
+	if err := db.QueryRow("SELECT count(*) FROM t").Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+func TestBranchDiffAndMerge(t *testing.T) {
+	s := openStore(t)
+	ctx := context.Background()
+
+	if err := s.CreateBranch(ctx, "db", "dev"); err != nil {
+		t.Fatal(err)
+	}
+	exec(t, s, "dev", "INSERT INTO t VALUES (1)")
+	if n := count(t, s, "main"); n != 0 {
+		t.Fatalf("main sees %d rows written on dev", n)
+	}
+
+	diff, err := s.Diff(ctx, "db", "main", "dev")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []embedded.TableDiff{{Name: "t", RowsAdded: 1}}
+	if !reflect.DeepEqual(diff.Tables, want) {
+		t.Errorf("diff %+v, want %+v", diff.Tables, want)
+	}
+
+	if _, err := s.Merge(ctx, "db", "main", "dev", "merge dev"); err != nil {
+		t.Fatal(err)
+	}
+	if n := count(t, s, "main"); n != 1 {
+		t.Fatalf("main has %d rows after the merge, want 1", n)
+	}
+}
+
+func TestMergeConflict(t *testing.T) {
+	s := openStore(t)
+	ctx := context.Background()
+
+	s.CreateBranch(ctx, "db", "dev")
+	exec(t, s, "dev", "INSERT INTO t VALUES (1)")
+	exec(t, s, "main", "INSERT INTO t VALUES (2)")
+	if _, err := s.Commit(ctx, "db", "main", "diverge"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Merge(ctx, "db", "main", "dev", ""); !errors.Is(err, embedded.ErrMergeConflict) {
+		t.Fatalf("merge after main changed: %v, want ErrMergeConflict", err)
+	}
+}
+
+func TestCommitUnchangedReturnsPreviousHash(t *testing.T) {
+	s := openStore(t)
+	ctx := context.Background()
+
+	exec(t, s, "main", "INSERT INTO t VALUES (1)")
+	first, err := s.Commit(ctx, "db", "main", "one row")
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, err := s.Commit(ctx, "db", "main", "nothing new")
+	if err != nil || again != first {
+		t.Fatalf("commit without changes = %s, %v, want %s", again, err, first)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	s := openStore(t)
+	ctx := context.Background()
+
+	for _, tt := range []struct {
+		name string
+		err  error
+		want error
+	}{
+		{"create database twice", s.CreateDatabase("db"), embedded.ErrDatabaseExists},
+		{"invalid database name", s.CreateDatabase("../x"), embedded.ErrInvalidName},
+		{"branch of a missing database", s.CreateBranch(ctx, "missing", "dev"), embedded.ErrDatabaseNotFound},
+		{"fork a missing branch", s.ForkBranch(ctx, "db", "dev", "missing"), embedded.ErrBranchNotFound},
+		{"delete a missing branch", s.DeleteBranch("db", "missing"), embedded.ErrBranchNotFound},
+	} {
+		if !errors.Is(tt.err, tt.want) {
+			t.Errorf("%s: %v, want %v", tt.name, tt.err, tt.want)
+		}
+	}
+	if err := s.DeleteBranch("db", "main"); err == nil {
+		t.Error("deleted main")
+	}
+}
+
+func TestReopen(t *testing.T) {
+	dir := t.TempDir()
+	s, err := embedded.Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.CreateDatabase("db")
+	exec(t, s, "main", "CREATE TABLE t (a INTEGER)")
+	exec(t, s, "main", "INSERT INTO t VALUES (1)")
+	s.Close()
+
+	s, err = embedded.Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if databases, err := s.ListDatabases(); err != nil || !reflect.DeepEqual(databases, []string{"db"}) {
+		t.Fatalf("databases %q, %v", databases, err)
+	}
+	if n := count(t, s, "main"); n != 1 {
+		t.Fatalf("%d rows after reopening, want 1", n)
+	}
+}
@@ -0,0 +1,335 @@
+// Package audit keeps an append-only log of the statements and branch
+// operations run against each database. Every entry carries the hash of the
+// one before it, so editing or removing an entry breaks the chain.
+package audit
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+
+	"github.com/bxrne/branchlore/internal/database"
+)
+
+// Action names what an entry records.
+type Action string
+
+const (
+	ActionQuery          Action = "query"
+	ActionDatabaseCreate Action = "database.create"
+	ActionDatabaseDelete Action = "database.delete"
+	ActionBranchCreate   Action = "branch.create"
+	ActionBranchDelete   Action = "branch.delete"
+	ActionBranchCommit   Action = "branch.commit"
+	ActionBranchMerge    Action = "branch.merge"
+)
+
+const (
+	OutcomeOK    = "ok"
+	OutcomeError = "error"
+)
+
+var (
+	ErrNotFound = errors.New("no audit log")
+	// ErrTampered is returned by Verify when the hash chain is broken.
+	ErrTampered = errors.New("audit log has been tampered with")
+)
+
+// Entry is one audited operation.
+type Entry struct {
+	Seq      int64     `json:"seq"`
+	Time     time.Time `json:"time"`
+	User     string    `json:"user,omitempty"`
+	Client   string    `json:"client,omitempty"`
+	Protocol string    `json:"protocol,omitempty"`
+	Database string    `json:"database"`
+	Branch   string    `json:"branch,omitempty"`
+	Action   Action    `json:"action"`
+	// Detail is the statement for queries, the source branch for creates
+	// and merges, and the commit hash for commits.
+	Detail       string        `json:"detail,omitempty"`
+	Outcome      string        `json:"outcome"`
+	Error        string        `json:"error,omitempty"`
+	Duration     time.Duration `json:"duration_ns"`
+	RowsAffected int64         `json:"rows_affected,omitempty"`
+	PrevHash     string        `json:"prev_hash"`
+	Hash         string        `json:"hash"`
+}
+
+// hash computes the entry's hash from its fields and the previous hash.
+func (e *Entry) hash() string {
+	fields, _ := json.Marshal([]interface{}{
+		e.PrevHash, e.Seq, e.Time.UnixNano(), e.User, e.Client, e.Protocol,
+		e.Database, e.Branch, e.Action, e.Detail, e.Outcome, e.Error,
+		int64(e.Duration), e.RowsAffected,
+	})
+	sum := sha256.Sum256(fields)
+	return hex.EncodeToString(sum[:])
+}
+
+// Filter selects entries. Zero fields match everything.
+type Filter struct {
+	Since  time.Time
+	Until  time.Time
+	User   string
+	Branch string
+	Action Action
+	// Limit keeps only the most recent entries when > 0.
+	Limit int
+}
+
+// The triggers only stop accidental changes; the hash chain is what
+// reveals deliberate ones.
+const schema = `
+CREATE TABLE IF NOT EXISTS entries (
+	seq           INTEGER PRIMARY KEY,
+	time_ns       INTEGER NOT NULL,
+	user          TEXT NOT NULL,
+	client        TEXT NOT NULL,
+	protocol      TEXT NOT NULL,
+	db            TEXT NOT NULL,
+	branch        TEXT NOT NULL,
+	action        TEXT NOT NULL,
+	detail        TEXT NOT NULL,
+	outcome       TEXT NOT NULL,
+	error         TEXT NOT NULL,
+	duration_ns   INTEGER NOT NULL,
+	rows_affected INTEGER NOT NULL,
+	prev_hash     TEXT NOT NULL,
+	hash          TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS entries_time ON entries (time_ns);
+CREATE TRIGGER IF NOT EXISTS entries_no_update BEFORE UPDATE ON entries
+BEGIN SELECT RAISE(ABORT, 'audit log is append-only'); END;
+CREATE TRIGGER IF NOT EXISTS entries_no_delete BEFORE DELETE ON entries
+BEGIN SELECT RAISE(ABORT, 'audit log is append-only'); END;
+`
+
+const columns = `seq, time_ns, user, client, protocol, db, branch, action, detail,
+	outcome, error, duration_ns, rows_affected, prev_hash, hash`
+
+// Log stores one SQLite file per database under the data directory. The
+// files outlive the databases they describe, so deletions stay on record.
+type Log struct {
+	dir string
+	mu  sync.Mutex
+	dbs map[string]*sql.DB
+}
+
+// Open returns the audit log of dataDir. Database names cannot start with a
+// dot, so its directory never collides with a database.
+func Open(dataDir string) *Log {
+	return &Log{
+		dir: filepath.Join(dataDir, ".branchlore", "audit"),
+		dbs: make(map[string]*sql.DB),
+	}
+}
+
+// db returns the connection to dbName's log, creating the file when create
+// is set.
+func (l *Log) db(dbName string, create bool) (*sql.DB, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if db, ok := l.dbs[dbName]; ok {
+		return db, nil
+	}
+	if dbName == "" || strings.ContainsAny(dbName, `/\`) || strings.HasPrefix(dbName, ".") {
+		return nil, fmt.Errorf("invalid database name %q", dbName)
+	}
+
+	path := filepath.Join(l.dir, dbName+".db")
+	if create {
+		if err := os.MkdirAll(l.dir, 0o700); err != nil {
+			return nil, fmt.Errorf("failed to create audit directory: %w", err)
+		}
+	} else if _, err := os.Stat(path); err != nil {
+		return nil, fmt.Errorf("%w for %s", ErrNotFound, dbName)
+	}
+
+	// Immediate transactions serialize appends from several processes.
+	db, err := sql.Open("sqlite3", database.FileURI(path)+"?_journal_mode=WAL&_synchronous=NORMAL&_busy_timeout=5000&_txlock=immediate")
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit log: %w", err)
+	}
+	if _, err := db.Exec(schema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to initialize audit log: %w", err)
+	}
+	l.dbs[dbName] = db
+	return db, nil
+}
+
+// Append adds e to the log of e.Database, filling in its sequence number
+// and hashes.
+func (l *Log) Append(e *Entry) error {
+	db, err := l.db(e.Database, true)
+	if err != nil {
+		return err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to append audit entry: %w", err)
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRow("SELECT seq, hash FROM entries ORDER BY seq DESC LIMIT 1").Scan(&e.Seq, &e.PrevHash)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("failed to read audit log: %w", err)
+	}
+	e.Seq++
+	e.Time = e.Time.UTC()
+	e.Hash = e.hash()
+
+	_, err = tx.Exec("INSERT INTO entries ("+columns+") VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		e.Seq, e.Time.UnixNano(), e.User, e.Client, e.Protocol, e.Database, e.Branch, e.Action,
+		e.Detail, e.Outcome, e.Error, int64(e.Duration), e.RowsAffected, e.PrevHash, e.Hash)
+	if err != nil {
+		return fmt.Errorf("failed to append audit entry: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to append audit entry: %w", err)
+	}
+	return nil
+}
+
+// Databases lists the databases that have a log.
+func (l *Log) Databases() ([]string, error) {
+	entries, err := os.ReadDir(l.dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read audit directory: %w", err)
+	}
+
+	var databases []string
+	for _, entry := range entries {
+		if name, ok := strings.CutSuffix(entry.Name(), ".db"); ok && !entry.IsDir() {
+			databases = append(databases, name)
+		}
+	}
+	return databases, nil
+}
+
+// Entries returns the entries of dbName matching f, oldest first.
+func (l *Log) Entries(dbName string, f Filter) ([]Entry, error) {
+	db, err := l.db(dbName, false)
+	if err != nil {
+		return nil, err
+	}
+
+	var where []string
+	var args []interface{}
+	if !f.Since.IsZero() {
+		where, args = append(where, "time_ns >= ?"), append(args, f.Since.UnixNano())
+	}
+	if !f.Until.IsZero() {
+		where, args = append(where, "time_ns < ?"), append(args, f.Until.UnixNano())
+	}
+	if f.User != "" {
+		where, args = append(where, "user = ?"), append(args, f.User)
+	}
+	if f.Branch != "" {
+		where, args = append(where, "branch = ?"), append(args, f.Branch)
+	}
+	if f.Action != "" {
+		where, args = append(where, "action = ?"), append(args, f.Action)
+	}
+
+	query := "SELECT " + columns + " FROM entries"
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	query += " ORDER BY seq DESC"
+	if f.Limit > 0 {
+		query += fmt.Sprintf(" LIMIT %d", f.Limit)
+	}
+
+	var entries []Entry
+	err = scan(db, query, args, func(e Entry) error {
+		entries = append(entries, e)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	slices.Reverse(entries)
+	return entries, nil
+}
+
+// Verify walks dbName's log and checks every entry against the hash chain.
+// It returns the number of entries and the hash of the last one. Removing
+// entries from the end leaves a valid chain, so keep the returned hash
+// somewhere else to detect that.
+func (l *Log) Verify(dbName string) (int64, string, error) {
+	db, err := l.db(dbName, false)
+	if err != nil {
+		return 0, "", err
+	}
+
+	var count int64
+	var head string
+	err = scan(db, "SELECT "+columns+" FROM entries ORDER BY seq", nil, func(e Entry) error {
+		switch {
+		case e.Seq != count+1:
+			return fmt.Errorf("%w: entry %d follows entry %d", ErrTampered, e.Seq, count)
+		case e.PrevHash != head:
+			return fmt.Errorf("%w: entry %d does not follow the previous entry's hash", ErrTampered, e.Seq)
+		case e.Hash != e.hash():
+			return fmt.Errorf("%w: entry %d does not match its hash", ErrTampered, e.Seq)
+		}
+		count, head = e.Seq, e.Hash
+		return nil
+	})
+	return count, head, err
+}
+
+func scan(db *sql.DB, query string, args []interface{}, fn func(Entry) error) error {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return fmt.Errorf("failed to read audit log: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var e Entry
+		var timeNs, duration int64
+		err := rows.Scan(&e.Seq, &timeNs, &e.User, &e.Client, &e.Protocol, &e.Database, &e.Branch,
+			&e.Action, &e.Detail, &e.Outcome, &e.Error, &duration, &e.RowsAffected, &e.PrevHash, &e.Hash)
+		if err != nil {
+			return fmt.Errorf("failed to read audit log: %w", err)
+		}
+		e.Time, e.Duration = time.Unix(0, timeNs).UTC(), time.Duration(duration)
+		if err := fn(e); err != nil {
+			return err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to read audit log: %w", err)
+	}
+	return nil
+}
+
+// Close closes the open log files.
+func (l *Log) Close() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for dbName, db := range l.dbs {
+		db.Close()
+		delete(l.dbs, dbName)
+	}
+}
@@ -0,0 +1,95 @@
+package audit
+
+import (
+	"database/sql"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/bxrne/branchlore/internal/database"
+)
+
+func appendEntries(t *testing.T, l *Log, n int) {
+	t.Helper()
+	for i := 0; i < n; i++ {
+		e := &Entry{Time: time.Now(), User: "alice", Database: "db", Branch: "main",
+			Action: ActionQuery, Detail: "SELECT 1", Outcome: OutcomeOK}
+		if err := l.Append(e); err != nil {
+			t.Fatal(err)
+		}
+		if e.Seq != int64(i+1) {
+			t.Fatalf("entry %d got seq %d", i+1, e.Seq)
+		}
+	}
+}
+
+func TestLogInDirectoryWithURICharacters(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "data#1?x=%41")
+	l := Open(dir)
+	defer l.Close()
+
+	appendEntries(t, l, 2)
+	if _, err := os.Stat(filepath.Join(dir, ".branchlore", "audit", "db.db")); err != nil {
+		t.Fatalf("audit log not at its path: %v", err)
+	}
+	entries, err := l.Entries("db", Filter{})
+	if err != nil || len(entries) != 2 {
+		t.Fatalf("Entries = %d, %v, want 2", len(entries), err)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	dir := t.TempDir()
+	l := Open(dir)
+	defer l.Close()
+
+	if _, _, err := l.Verify("db"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Verify of a missing log: %v, want %v", err, ErrNotFound)
+	}
+
+	appendEntries(t, l, 3)
+	n, head, err := l.Verify("db")
+	if err != nil || n != 3 {
+		t.Fatalf("Verify = %d, %v, want 3 entries", n, err)
+	}
+	entries, _ := l.Entries("db", Filter{})
+	if head != entries[2].Hash {
+		t.Errorf("Verify head %s, want the last entry's hash %s", head, entries[2].Hash)
+	}
+
+	// Rewrite the middle entry behind the log's back.
+	db, err := sql.Open("sqlite3", database.FileURI(filepath.Join(dir, ".branchlore", "audit", "db.db")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	for _, stmt := range []string{
+		"DROP TRIGGER entries_no_update",
+		"UPDATE entries SET detail = 'DROP TABLE users' WHERE seq = 2",
+	} {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, _, err := l.Verify("db"); !errors.Is(err, ErrTampered) {
+		t.Fatalf("Verify of an edited log: %v, want %v", err, ErrTampered)
+	}
+}
+
+func TestEntriesAreAppendOnly(t *testing.T) {
+	dir := t.TempDir()
+	l := Open(dir)
+	defer l.Close()
+	appendEntries(t, l, 1)
+
+	db, err := sql.Open("sqlite3", database.FileURI(filepath.Join(dir, ".branchlore", "audit", "db.db")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if _, err := db.Exec("DELETE FROM entries"); err == nil {
+		t.Error("deleting audit entries succeeded")
+	}
+}
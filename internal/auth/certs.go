@@ -0,0 +1,95 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+)
+
+var ErrCertificateNotFound = errors.New("certificate identity not found")
+
+// Certificate grants permissions to clients presenting a verified client
+// certificate whose subject common name is Name, so mutual TLS can replace
+// tokens.
+type Certificate struct {
+	Name      string    `json:"name"`
+	Scopes    []Scope   `json:"scopes,omitempty"`
+	Roles     []string  `json:"roles,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// AllowCertificate grants scopes and roles to certificates with the common
+// name name, replacing what they were granted before.
+func (s *Store) AllowCertificate(name string, scopes []Scope, roles []string) (*Certificate, error) {
+	if name == "" {
+		return nil, errors.New("certificate common name required")
+	}
+	if len(scopes) == 0 && len(roles) == 0 {
+		return nil, fmt.Errorf("%w: a scope or role is required", ErrInvalidScope)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.certs.load(); err != nil {
+		return nil, err
+	}
+	if err := s.checkRoles(roles); err != nil {
+		return nil, err
+	}
+
+	cert := Certificate{Name: name, Scopes: scopes, Roles: roles, CreatedAt: time.Now().UTC()}
+	certs := slices.DeleteFunc(slices.Clone(s.certs.value), func(c Certificate) bool {
+		return c.Name == name
+	})
+	if err := s.certs.save(append(certs, cert)); err != nil {
+		return nil, err
+	}
+	return &cert, nil
+}
+
+// Certificates returns the certificate identities.
+func (s *Store) Certificates() ([]Certificate, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.certs.load(); err != nil {
+		return nil, err
+	}
+	return slices.Clone(s.certs.value), nil
+}
+
+// RevokeCertificate removes the certificate identity name. Certificates
+// with that common name can still connect, but are no longer authenticated.
+func (s *Store) RevokeCertificate(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.certs.load(); err != nil {
+		return err
+	}
+	i := slices.IndexFunc(s.certs.value, func(c Certificate) bool { return c.Name == name })
+	if i < 0 {
+		return fmt.Errorf("%w: %s", ErrCertificateNotFound, name)
+	}
+	return s.certs.save(slices.Delete(slices.Clone(s.certs.value), i, i+1))
+}
+
+// AuthenticateCertificate returns the identity of a verified client
+// certificate with the common name name. It fails with ErrUnauthenticated
+// for names that were not allowed.
+func (s *Store) AuthenticateCertificate(name string) (*Identity, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.certs.load(); err != nil {
+		return nil, err
+	}
+	i := slices.IndexFunc(s.certs.value, func(c Certificate) bool { return c.Name == name })
+	if name == "" || i < 0 {
+		return nil, ErrUnauthenticated
+	}
+	cert := s.certs.value[i]
+	return s.identity(cert.Name, cert.Scopes, cert.Roles)
+}
@@ -0,0 +1,52 @@
+package auth
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestCertificateIdentities(t *testing.T) {
+	s := NewStore(t.TempDir())
+	if _, err := s.AuthenticateCertificate("ci"); !errors.Is(err, ErrUnauthenticated) {
+		t.Fatalf("unknown certificate: %v, want ErrUnauthenticated", err)
+	}
+
+	if _, err := s.AllowCertificate("ci", []Scope{ScopeRead}, nil); err != nil {
+		t.Fatal(err)
+	}
+	id, err := s.AuthenticateCertificate("ci")
+	if err != nil || id.Name != "ci" || !id.Allows(PermRead, "db", "main") || id.Allows(PermWrite, "db", "main") {
+		t.Fatalf("read certificate: %+v, %v", id, err)
+	}
+
+	// Allowing the name again replaces its grants.
+	if _, err := s.AllowCertificate("ci", []Scope{ScopeWrite}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if certs, err := s.Certificates(); err != nil || len(certs) != 1 {
+		t.Errorf("certificates after allowing ci twice: %v, %v", certs, err)
+	}
+	if id, err := s.AuthenticateCertificate("ci"); err != nil || !id.Allows(PermWrite, "db", "main") {
+		t.Errorf("write certificate: %+v, %v", id, err)
+	}
+
+	if err := s.RevokeCertificate("ci"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.AuthenticateCertificate("ci"); !errors.Is(err, ErrUnauthenticated) {
+		t.Errorf("revoked certificate: %v, want ErrUnauthenticated", err)
+	}
+	if err := s.RevokeCertificate("ci"); !errors.Is(err, ErrCertificateNotFound) {
+		t.Errorf("revoking twice: %v, want ErrCertificateNotFound", err)
+	}
+
+	if _, err := s.AllowCertificate("", []Scope{ScopeRead}, nil); err == nil {
+		t.Error("allowed a certificate without a name")
+	}
+	if _, err := s.AllowCertificate("ci", nil, nil); !errors.Is(err, ErrInvalidScope) {
+		t.Errorf("without scopes or roles: %v, want ErrInvalidScope", err)
+	}
+	if _, err := s.AllowCertificate("ci", nil, []string{"nope"}); !errors.Is(err, ErrRoleNotFound) {
+		t.Errorf("unknown role: %v, want ErrRoleNotFound", err)
+	}
+}
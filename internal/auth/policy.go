@@ -0,0 +1,202 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"path"
+	"slices"
+	"strings"
+)
+
+// Permission is an action a grant allows on the branches it covers.
+type Permission string
+
+const (
+	// PermRead allows seeing a branch and running statements on it that do
+	// not write.
+	PermRead Permission = "read"
+	// PermWrite allows writing to a branch and committing it. It includes
+	// read.
+	PermWrite Permission = "write"
+	// PermBranchCreate allows creating branches, from branches the token
+	// can read.
+	PermBranchCreate Permission = "branch-create"
+	// PermBranchDelete allows deleting branches.
+	PermBranchDelete Permission = "branch-delete"
+	// PermMerge allows merging other branches into a branch.
+	PermMerge Permission = "merge"
+	// PermAdmin allows everything, including creating and deleting the
+	// databases a grant covers.
+	PermAdmin Permission = "admin"
+)
+
+var permissions = []Permission{PermRead, PermWrite, PermBranchCreate, PermBranchDelete, PermMerge, PermAdmin}
+
+var (
+	ErrRoleNotFound      = errors.New("role not found")
+	ErrInvalidPermission = errors.New("invalid permission")
+)
+
+// ParsePermission parses a permission name.
+func ParsePermission(s string) (Permission, error) {
+	p := Permission(strings.ToLower(strings.TrimSpace(s)))
+	if !slices.Contains(permissions, p) {
+		return "", fmt.Errorf("%w: %q (want read, write, branch-create, branch-delete, merge or admin)", ErrInvalidPermission, s)
+	}
+	return p, nil
+}
+
+// Grant gives permissions on the branches of the databases it covers.
+// Database and Branches are patterns: "*" matches everything, and other
+// patterns follow path.Match, so "dev/*" matches "dev/anna" but not "dev".
+type Grant struct {
+	Database    string       `json:"database"`
+	Branches    string       `json:"branches"`
+	Permissions []Permission `json:"permissions"`
+}
+
+// allows reports whether g grants perm on dbName@branch. An empty branch
+// asks about the database itself, which only grants on every branch cover.
+func (g Grant) allows(perm Permission, dbName, branch string) bool {
+	if !match(g.Database, dbName) {
+		return false
+	}
+	if branch == "" {
+		if g.Branches != "*" {
+			return false
+		}
+	} else if !match(g.Branches, branch) {
+		return false
+	}
+
+	for _, p := range g.Permissions {
+		if p == perm || p == PermAdmin || (p == PermWrite && perm == PermRead) {
+			return true
+		}
+	}
+	return false
+}
+
+func match(pattern, name string) bool {
+	if pattern == "*" {
+		return true
+	}
+	ok, err := path.Match(pattern, name)
+	return err == nil && ok
+}
+
+// Identity is an authenticated token and everything it is granted.
+type Identity struct {
+	Name   string
+	Roles  []string
+	Grants []Grant
+}
+
+// Allows reports whether the identity has perm on dbName@branch, or on the
+// database dbName itself when branch is empty.
+func (id *Identity) Allows(perm Permission, dbName, branch string) bool {
+	for _, g := range id.Grants {
+		if g.allows(perm, dbName, branch) {
+			return true
+		}
+	}
+	return false
+}
+
+// Sees reports whether any grant covers dbName, so the identity may know
+// the database exists.
+func (id *Identity) Sees(dbName string) bool {
+	for _, g := range id.Grants {
+		if match(g.Database, dbName) {
+			return true
+		}
+	}
+	return false
+}
+
+// Roles returns every role and its grants.
+func (s *Store) Roles() (map[string][]Grant, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.roles.load(); err != nil {
+		return nil, err
+	}
+	return maps.Clone(s.roles.value), nil
+}
+
+// Grant adds g to role, creating the role if needed. A grant on the same
+// database and branch patterns is replaced.
+func (s *Store) Grant(role string, g Grant) error {
+	if role == "" {
+		return errors.New("role name required")
+	}
+	if g.Database == "" || g.Branches == "" {
+		return errors.New("grant needs a database and branch pattern")
+	}
+	for _, p := range []string{g.Database, g.Branches} {
+		if _, err := path.Match(p, ""); err != nil {
+			return fmt.Errorf("invalid pattern %q: %w", p, err)
+		}
+	}
+	if len(g.Permissions) == 0 {
+		return fmt.Errorf("%w: at least one permission required", ErrInvalidPermission)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.roles.load(); err != nil {
+		return err
+	}
+	roles := maps.Clone(s.roles.value)
+	if roles == nil {
+		roles = make(map[string][]Grant)
+	}
+	grants := slices.DeleteFunc(slices.Clone(roles[role]), func(old Grant) bool {
+		return old.Database == g.Database && old.Branches == g.Branches
+	})
+	roles[role] = append(grants, g)
+	return s.roles.save(roles)
+}
+
+// Ungrant removes role's grant on the given database and branch patterns.
+func (s *Store) Ungrant(role, database, branches string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.roles.load(); err != nil {
+		return err
+	}
+	grants, ok := s.roles.value[role]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrRoleNotFound, role)
+	}
+	kept := slices.DeleteFunc(slices.Clone(grants), func(g Grant) bool {
+		return g.Database == database && g.Branches == branches
+	})
+	if len(kept) == len(grants) {
+		return fmt.Errorf("role %s has no grant on %s@%s", role, database, branches)
+	}
+
+	roles := maps.Clone(s.roles.value)
+	roles[role] = kept
+	return s.roles.save(roles)
+}
+
+// DeleteRole deletes role. Tokens holding it lose its grants.
+func (s *Store) DeleteRole(role string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.roles.load(); err != nil {
+		return err
+	}
+	if _, ok := s.roles.value[role]; !ok {
+		return fmt.Errorf("%w: %s", ErrRoleNotFound, role)
+	}
+	roles := maps.Clone(s.roles.value)
+	delete(roles, role)
+	return s.roles.save(roles)
+}
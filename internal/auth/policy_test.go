@@ -0,0 +1,140 @@
+package auth
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestIdentityAllows(t *testing.T) {
+	id := &Identity{Name: "dev", Grants: []Grant{
+		{Database: "*", Branches: "main", Permissions: []Permission{PermRead}},
+		{Database: "app", Branches: "dev/*", Permissions: []Permission{PermWrite, PermBranchCreate}},
+		{Database: "scratch", Branches: "*", Permissions: []Permission{PermAdmin}},
+	}}
+
+	tests := []struct {
+		perm   Permission
+		db     string
+		branch string
+		want   bool
+	}{
+		{PermRead, "app", "main", true},
+		{PermRead, "other", "main", true},
+		{PermWrite, "app", "main", false},
+		{PermWrite, "app", "dev/anna", true},
+		{PermRead, "app", "dev/anna", true},
+		{PermBranchCreate, "app", "dev/anna", true},
+		{PermMerge, "app", "dev/anna", false},
+		{PermWrite, "app", "dev", false},
+		{PermWrite, "app", "dev/anna/x", false},
+		{PermRead, "app", "feature", false},
+		// Only grants on every branch cover the database itself.
+		{PermAdmin, "app", "", false},
+		{PermRead, "app", "", false},
+		{PermAdmin, "scratch", "", true},
+		{PermMerge, "scratch", "anything", true},
+	}
+	for _, tt := range tests {
+		if got := id.Allows(tt.perm, tt.db, tt.branch); got != tt.want {
+			t.Errorf("Allows(%s, %s, %q) = %v, want %v", tt.perm, tt.db, tt.branch, got, tt.want)
+		}
+	}
+
+	narrow := &Identity{Grants: []Grant{{Database: "app", Branches: "dev/*", Permissions: []Permission{PermRead}}}}
+	if !narrow.Sees("app") || narrow.Sees("other") {
+		t.Error("Sees should cover exactly the databases of the grants")
+	}
+}
+
+func TestParsePermission(t *testing.T) {
+	for in, want := range map[string]Permission{"read": PermRead, " Branch-Create ": PermBranchCreate, "ADMIN": PermAdmin} {
+		if got, err := ParsePermission(in); err != nil || got != want {
+			t.Errorf("ParsePermission(%q) = %q, %v, want %q", in, got, err, want)
+		}
+	}
+	for _, in := range []string{"", "delete", "read,write"} {
+		if _, err := ParsePermission(in); !errors.Is(err, ErrInvalidPermission) {
+			t.Errorf("ParsePermission(%q): %v, want ErrInvalidPermission", in, err)
+		}
+	}
+}
+
+func TestRoles(t *testing.T) {
+	s := NewStore(t.TempDir())
+	read := Grant{Database: "app", Branches: "*", Permissions: []Permission{PermRead}}
+	if err := s.Grant("dev", read); err != nil {
+		t.Fatal(err)
+	}
+	secret, _, err := s.Create("anna", nil, []string{"dev"}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := s.Authenticate(secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !id.Allows(PermRead, "app", "main") || id.Allows(PermWrite, "app", "main") {
+		t.Errorf("identity with a read grant: %+v", id)
+	}
+
+	// A grant on the same patterns replaces the old one, and tokens see
+	// the change on their next request.
+	if err := s.Grant("dev", Grant{Database: "app", Branches: "*", Permissions: []Permission{PermWrite}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Grant("dev", Grant{Database: "logs", Branches: "*", Permissions: []Permission{PermRead}}); err != nil {
+		t.Fatal(err)
+	}
+	roles, err := s.Roles()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(roles["dev"]) != 2 {
+		t.Errorf("grants after replacing one: %v", roles["dev"])
+	}
+	if id, err = s.Authenticate(secret); err != nil || !id.Allows(PermWrite, "app", "main") {
+		t.Errorf("identity after the new grant: %+v, %v", id, err)
+	}
+
+	if err := s.Ungrant("dev", "app", "*"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Ungrant("dev", "app", "*"); err == nil {
+		t.Error("removed a grant twice")
+	}
+	if id, err = s.Authenticate(secret); err != nil || id.Allows(PermRead, "app", "main") || !id.Allows(PermRead, "logs", "main") {
+		t.Errorf("identity after ungranting: %+v, %v", id, err)
+	}
+
+	if err := s.DeleteRole("dev"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.DeleteRole("dev"); !errors.Is(err, ErrRoleNotFound) {
+		t.Errorf("deleting twice: %v, want ErrRoleNotFound", err)
+	}
+	if id, err = s.Authenticate(secret); err != nil || len(id.Grants) != 0 {
+		t.Errorf("identity after deleting its role: %+v, %v", id, err)
+	}
+}
+
+func TestGrantRejectsInvalidGrants(t *testing.T) {
+	s := NewStore(t.TempDir())
+	perms := []Permission{PermRead}
+	for _, tt := range []struct {
+		role string
+		g    Grant
+	}{
+		{"", Grant{Database: "*", Branches: "*", Permissions: perms}},
+		{"dev", Grant{Branches: "*", Permissions: perms}},
+		{"dev", Grant{Database: "*", Permissions: perms}},
+		{"dev", Grant{Database: "[", Branches: "*", Permissions: perms}},
+		{"dev", Grant{Database: "*", Branches: "*"}},
+	} {
+		if err := s.Grant(tt.role, tt.g); err == nil {
+			t.Errorf("Grant(%q, %+v) succeeded", tt.role, tt.g)
+		}
+	}
+	if roles, err := s.Roles(); err != nil || len(roles) != 0 {
+		t.Errorf("roles after invalid grants: %v, %v", roles, err)
+	}
+}
@@ -0,0 +1,311 @@
+// Package auth manages the API tokens clients present to the server and the
+// roles that decide what each token may do.
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Scope is a shorthand for permissions on every database and branch. Each
+// scope includes the ones below it: admin includes write, and write includes
+// read.
+type Scope string
+
+const (
+	// ScopeRead allows listing databases and branches and running
+	// statements that do not write.
+	ScopeRead Scope = "read"
+	// ScopeWrite also allows writing, and creating, committing, merging and
+	// deleting branches.
+	ScopeWrite Scope = "write"
+	// ScopeAdmin also allows creating and deleting databases.
+	ScopeAdmin Scope = "admin"
+)
+
+var scopePermissions = map[Scope][]Permission{
+	ScopeRead:  {PermRead},
+	ScopeWrite: {PermWrite, PermBranchCreate, PermBranchDelete, PermMerge},
+	ScopeAdmin: {PermAdmin},
+}
+
+var (
+	ErrUnauthenticated  = errors.New("missing or invalid token")
+	ErrPermissionDenied = errors.New("permission denied")
+	ErrTokenNotFound    = errors.New("token not found")
+	ErrTokenExists      = errors.New("token already exists")
+	ErrInvalidScope     = errors.New("invalid scope")
+)
+
+// tokenPrefix marks Branchlore tokens so they are easy to spot in configs
+// and secret scanners.
+const tokenPrefix = "blt_"
+
+// ParseScope parses a scope name.
+func ParseScope(s string) (Scope, error) {
+	scope := Scope(strings.ToLower(strings.TrimSpace(s)))
+	if _, ok := scopePermissions[scope]; !ok {
+		return "", fmt.Errorf("%w: %q (want read, write or admin)", ErrInvalidScope, s)
+	}
+	return scope, nil
+}
+
+// Token is a stored token. The secret itself is never stored, only its
+// SHA-256 hash.
+type Token struct {
+	ID        string     `json:"id"`
+	Name      string     `json:"name"`
+	Scopes    []Scope    `json:"scopes,omitempty"`
+	Roles     []string   `json:"roles,omitempty"`
+	Hash      string     `json:"hash"`
+	CreatedAt time.Time  `json:"created_at"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+// Expired reports whether the token has passed its expiry time.
+func (t *Token) Expired(now time.Time) bool {
+	return t.ExpiresAt != nil && !now.Before(*t.ExpiresAt)
+}
+
+// Store keeps tokens, certificate identities and roles in files under the
+// data directory. The server and the CLI share them: the server rereads a
+// file whenever it changes, so changes take effect without a restart.
+type Store struct {
+	mu     sync.Mutex
+	tokens jsonFile[[]Token]
+	certs  jsonFile[[]Certificate]
+	roles  jsonFile[map[string][]Grant]
+}
+
+// NewStore returns the store of dataDir. Database names cannot start with a
+// dot, so its directory never collides with a database.
+func NewStore(dataDir string) *Store {
+	dir := filepath.Join(dataDir, ".branchlore")
+	return &Store{
+		tokens: jsonFile[[]Token]{path: filepath.Join(dir, "tokens.json")},
+		certs:  jsonFile[[]Certificate]{path: filepath.Join(dir, "certificates.json")},
+		roles:  jsonFile[map[string][]Grant]{path: filepath.Join(dir, "roles.json")},
+	}
+}
+
+// Create stores a new token and returns its secret, which cannot be
+// recovered later. The token is granted scopes everywhere plus the grants
+// of roles. A ttl of zero creates a token that never expires.
+func (s *Store) Create(name string, scopes []Scope, roles []string, ttl time.Duration) (string, *Token, error) {
+	if name == "" {
+		return "", nil, errors.New("token name required")
+	}
+	if len(scopes) == 0 && len(roles) == 0 {
+		return "", nil, fmt.Errorf("%w: a scope or role is required", ErrInvalidScope)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.tokens.load(); err != nil {
+		return "", nil, err
+	}
+	if err := s.checkRoles(roles); err != nil {
+		return "", nil, err
+	}
+	for _, t := range s.tokens.value {
+		if t.Name == name {
+			return "", nil, fmt.Errorf("%w: %s", ErrTokenExists, name)
+		}
+	}
+
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", nil, fmt.Errorf("failed to generate token: %w", err)
+	}
+	secret := tokenPrefix + base64.RawURLEncoding.EncodeToString(b)
+	hash := hashSecret(secret)
+
+	token := Token{
+		ID:        hash[:12],
+		Name:      name,
+		Scopes:    scopes,
+		Roles:     roles,
+		Hash:      hash,
+		CreatedAt: time.Now().UTC(),
+	}
+	if ttl > 0 {
+		expires := token.CreatedAt.Add(ttl)
+		token.ExpiresAt = &expires
+	}
+
+	if err := s.tokens.save(append(slices.Clone(s.tokens.value), token)); err != nil {
+		return "", nil, err
+	}
+	return secret, &token, nil
+}
+
+// List returns the stored tokens.
+func (s *Store) List() ([]Token, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.tokens.load(); err != nil {
+		return nil, err
+	}
+	return slices.Clone(s.tokens.value), nil
+}
+
+// Revoke deletes the token with the given name or ID.
+func (s *Store) Revoke(nameOrID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.tokens.load(); err != nil {
+		return err
+	}
+	i := slices.IndexFunc(s.tokens.value, func(t Token) bool {
+		return t.Name == nameOrID || t.ID == nameOrID
+	})
+	if i < 0 {
+		return fmt.Errorf("%w: %s", ErrTokenNotFound, nameOrID)
+	}
+	return s.tokens.save(slices.Delete(slices.Clone(s.tokens.value), i, i+1))
+}
+
+// Authenticate returns the identity of the token whose secret is secret,
+// with the grants of its scopes and roles as they are now. It fails with
+// ErrUnauthenticated for unknown and expired tokens.
+func (s *Store) Authenticate(secret string) (*Identity, error) {
+	if !strings.HasPrefix(secret, tokenPrefix) {
+		return nil, ErrUnauthenticated
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.tokens.load(); err != nil {
+		return nil, err
+	}
+	hash := hashSecret(secret)
+	i := slices.IndexFunc(s.tokens.value, func(t Token) bool {
+		// The hash of an unknown secret reveals nothing, so comparing
+		// hashes need not be constant time.
+		return t.Hash == hash
+	})
+	if i < 0 || s.tokens.value[i].Expired(time.Now()) {
+		return nil, ErrUnauthenticated
+	}
+	token := s.tokens.value[i]
+	return s.identity(token.Name, token.Scopes, token.Roles)
+}
+
+// identity returns the identity granted scopes everywhere plus the grants of
+// roles. The caller must hold s.mu.
+func (s *Store) identity(name string, scopes []Scope, roles []string) (*Identity, error) {
+	if err := s.roles.load(); err != nil {
+		return nil, err
+	}
+	id := &Identity{Name: name, Roles: roles}
+	for _, scope := range scopes {
+		id.Grants = append(id.Grants, Grant{Database: "*", Branches: "*", Permissions: scopePermissions[scope]})
+	}
+	for _, role := range roles {
+		// A deleted role simply grants nothing.
+		id.Grants = append(id.Grants, s.roles.value[role]...)
+	}
+	return id, nil
+}
+
+// checkRoles returns ErrRoleNotFound unless every role exists. The caller
+// must hold s.mu.
+func (s *Store) checkRoles(roles []string) error {
+	if err := s.roles.load(); err != nil {
+		return err
+	}
+	for _, role := range roles {
+		if _, ok := s.roles.value[role]; !ok {
+			return fmt.Errorf("%w: %s", ErrRoleNotFound, role)
+		}
+	}
+	return nil
+}
+
+// jsonFile caches the decoded contents of a JSON file, rereading it only
+// when it changes. Every save replaces the file, so a file with the same
+// modification time may still be new: within the clock's resolution, only
+// its size or inode tells.
+type jsonFile[T any] struct {
+	path  string
+	info  os.FileInfo
+	value T
+}
+
+func (f *jsonFile[T]) load() error {
+	info, err := os.Stat(f.path)
+	if errors.Is(err, os.ErrNotExist) {
+		var zero T
+		f.value, f.info = zero, nil
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to stat %s: %w", filepath.Base(f.path), err)
+	}
+	if f.info != nil && os.SameFile(info, f.info) && info.ModTime().Equal(f.info.ModTime()) && info.Size() == f.info.Size() {
+		return nil
+	}
+
+	data, err := os.ReadFile(f.path)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", filepath.Base(f.path), err)
+	}
+	var value T
+	if err := json.Unmarshal(data, &value); err != nil {
+		return fmt.Errorf("failed to parse %s: %w", filepath.Base(f.path), err)
+	}
+	f.value, f.info = value, info
+	return nil
+}
+
+// save replaces the file atomically, so the server never reads a partial
+// write.
+func (f *jsonFile[T]) save(value T) error {
+	name := filepath.Base(f.path)
+	if err := os.MkdirAll(filepath.Dir(f.path), 0o700); err != nil {
+		return fmt.Errorf("failed to create %s directory: %w", name, err)
+	}
+	data, err := json.MarshalIndent(value, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode %s: %w", name, err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(f.path), name+".*")
+	if err != nil {
+		return fmt.Errorf("failed to write %s: %w", name, err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(append(data, '\n')); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write %s: %w", name, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write %s: %w", name, err)
+	}
+	if err := os.Rename(tmp.Name(), f.path); err != nil {
+		return fmt.Errorf("failed to write %s: %w", name, err)
+	}
+
+	f.value, f.info = value, nil
+	return nil
+}
+
+func hashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,173 @@
+package auth
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCreateAndAuthenticate(t *testing.T) {
+	dir := t.TempDir()
+	s := NewStore(dir)
+
+	secret, token, err := s.Create("ci", []Scope{ScopeRead}, nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(secret, tokenPrefix) {
+		t.Errorf("secret %q lacks the %s prefix", secret, tokenPrefix)
+	}
+	if token.Name != "ci" || token.ExpiresAt != nil || token.ID != token.Hash[:12] {
+		t.Errorf("token: %+v", token)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, ".branchlore", "tokens.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), secret) {
+		t.Error("tokens.json holds the secret")
+	}
+
+	id, err := s.Authenticate(secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id.Name != "ci" || !id.Allows(PermRead, "db", "main") || id.Allows(PermWrite, "db", "main") {
+		t.Errorf("identity: %+v", id)
+	}
+
+	for _, wrong := range []string{"", "ci", tokenPrefix + "wrong", strings.TrimPrefix(secret, tokenPrefix)} {
+		if _, err := s.Authenticate(wrong); !errors.Is(err, ErrUnauthenticated) {
+			t.Errorf("Authenticate(%q): %v, want ErrUnauthenticated", wrong, err)
+		}
+	}
+}
+
+func TestCreateRejectsInvalidTokens(t *testing.T) {
+	s := NewStore(t.TempDir())
+	if _, _, err := s.Create("ci", []Scope{ScopeRead}, nil, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, err := s.Create("", []Scope{ScopeRead}, nil, 0); err == nil {
+		t.Error("created a token without a name")
+	}
+	if _, _, err := s.Create("none", nil, nil, 0); !errors.Is(err, ErrInvalidScope) {
+		t.Errorf("without scopes or roles: %v, want ErrInvalidScope", err)
+	}
+	if _, _, err := s.Create("ci", []Scope{ScopeWrite}, nil, 0); !errors.Is(err, ErrTokenExists) {
+		t.Errorf("duplicate name: %v, want ErrTokenExists", err)
+	}
+	if _, _, err := s.Create("ops", nil, []string{"nope"}, 0); !errors.Is(err, ErrRoleNotFound) {
+		t.Errorf("unknown role: %v, want ErrRoleNotFound", err)
+	}
+}
+
+func TestExpiredTokens(t *testing.T) {
+	s := NewStore(t.TempDir())
+	secret, token, err := s.Create("ci", []Scope{ScopeRead}, nil, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token.ExpiresAt == nil || token.Expired(time.Now()) || !token.Expired(time.Now().Add(time.Hour)) {
+		t.Errorf("token expiring in an hour: %+v", token)
+	}
+	if _, err := s.Authenticate(secret); err != nil {
+		t.Fatal(err)
+	}
+
+	secret, _, err = s.Create("old", []Scope{ScopeRead}, nil, time.Nanosecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(time.Millisecond)
+	if _, err := s.Authenticate(secret); !errors.Is(err, ErrUnauthenticated) {
+		t.Errorf("expired token: %v, want ErrUnauthenticated", err)
+	}
+}
+
+func TestRevoke(t *testing.T) {
+	s := NewStore(t.TempDir())
+	byName, _, err := s.Create("a", []Scope{ScopeRead}, nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	byID, token, err := s.Create("b", []Scope{ScopeRead}, nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Revoke("a"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Revoke(token.ID); err != nil {
+		t.Fatal(err)
+	}
+	for _, secret := range []string{byName, byID} {
+		if _, err := s.Authenticate(secret); !errors.Is(err, ErrUnauthenticated) {
+			t.Errorf("revoked token: %v, want ErrUnauthenticated", err)
+		}
+	}
+	if err := s.Revoke("a"); !errors.Is(err, ErrTokenNotFound) {
+		t.Errorf("revoking twice: %v, want ErrTokenNotFound", err)
+	}
+	if tokens, err := s.List(); err != nil || len(tokens) != 0 {
+		t.Errorf("List after revoking all: %v, %v", tokens, err)
+	}
+}
+
+// The CLI and the server each have their own Store on the same files.
+func TestStoreSeesChangesFromOtherStores(t *testing.T) {
+	dir := t.TempDir()
+	server, cli := NewStore(dir), NewStore(dir)
+
+	if _, err := server.Authenticate(tokenPrefix + "x"); !errors.Is(err, ErrUnauthenticated) {
+		t.Fatalf("empty store: %v", err)
+	}
+	secret, _, err := cli.Create("ci", []Scope{ScopeRead}, nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := server.Authenticate(secret); err != nil {
+		t.Fatalf("token created by another store: %v", err)
+	}
+	if err := cli.Revoke("ci"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := server.Authenticate(secret); !errors.Is(err, ErrUnauthenticated) {
+		t.Errorf("token revoked by another store: %v, want ErrUnauthenticated", err)
+	}
+
+	// A change within the resolution of the file's modification time.
+	path := filepath.Join(dir, ".branchlore", "tokens.json")
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if secret, _, err = cli.Create("ci", []Scope{ScopeRead}, nil, 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, info.ModTime(), info.ModTime()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := server.Authenticate(secret); err != nil {
+		t.Errorf("token created by another store in the same tick: %v", err)
+	}
+}
+
+func TestParseScope(t *testing.T) {
+	for in, want := range map[string]Scope{"read": ScopeRead, " Write ": ScopeWrite, "ADMIN": ScopeAdmin} {
+		if got, err := ParseScope(in); err != nil || got != want {
+			t.Errorf("ParseScope(%q) = %q, %v, want %q", in, got, err, want)
+		}
+	}
+	for _, in := range []string{"", "root", "read,write"} {
+		if _, err := ParseScope(in); !errors.Is(err, ErrInvalidScope) {
+			t.Errorf("ParseScope(%q): %v, want ErrInvalidScope", in, err)
+		}
+	}
+}
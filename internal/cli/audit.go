@@ -0,0 +1,177 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/bxrne/branchlore/internal/audit"
+	"github.com/spf13/cobra"
+)
+
+func NewAuditCmd() *cobra.Command {
+	var dataDir, since, until, user, branch, action string
+	var limit int
+	var asJSON bool
+
+	cmd := &cobra.Command{
+		Use:   "audit [database-name]",
+		Short: "Show the audit log",
+		Long: `Show who ran which statements and branch operations, as recorded by a server
+started with --audit. Without a database name every database's log is shown.`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			filter := audit.Filter{User: user, Branch: branch, Action: audit.Action(action)}
+			var err error
+			if filter.Since, err = parseTime(since); err != nil {
+				return fmt.Errorf("invalid --since: %w", err)
+			}
+			if filter.Until, err = parseTime(until); err != nil {
+				return fmt.Errorf("invalid --until: %w", err)
+			}
+
+			log := audit.Open(dataDir)
+			defer log.Close()
+
+			databases, err := auditDatabases(log, args)
+			if err != nil {
+				return err
+			}
+
+			var entries []audit.Entry
+			for _, dbName := range databases {
+				found, err := log.Entries(dbName, filter)
+				if err != nil {
+					return fmt.Errorf("failed to read audit log: %w", err)
+				}
+				entries = append(entries, found...)
+			}
+			slices.SortStableFunc(entries, func(a, b audit.Entry) int {
+				return a.Time.Compare(b.Time)
+			})
+			if limit > 0 && len(entries) > limit {
+				entries = entries[len(entries)-limit:]
+			}
+
+			if asJSON {
+				enc := json.NewEncoder(os.Stdout)
+				for _, e := range entries {
+					if err := enc.Encode(e); err != nil {
+						return err
+					}
+				}
+				return nil
+			}
+			if len(entries) == 0 {
+				fmt.Println("No audit entries")
+				return nil
+			}
+			for _, e := range entries {
+				fmt.Println(formatEntry(e))
+			}
+			return nil
+		},
+	}
+
+	verifyCmd := &cobra.Command{
+		Use:   "verify [database-name]",
+		Short: "Check the audit log's hash chain",
+		Long: `Check that no audit entry was changed or removed. Removing entries from the
+end cannot be detected from the log alone, so compare the printed head hash
+with one recorded earlier.`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			log := audit.Open(dataDir)
+			defer log.Close()
+
+			databases, err := auditDatabases(log, args)
+			if err != nil {
+				return err
+			}
+
+			var failed bool
+			for _, dbName := range databases {
+				count, head, err := log.Verify(dbName)
+				if err != nil {
+					fmt.Printf("  %-20s FAILED: %v\n", dbName, err)
+					failed = true
+					continue
+				}
+				fmt.Printf("  %-20s ok, %d entries, head %s\n", dbName, count, head)
+			}
+			if failed {
+				return audit.ErrTampered
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&since, "since", "", "Only entries at or after this time: RFC 3339, a date, or a duration ago like 24h")
+	cmd.Flags().StringVar(&until, "until", "", "Only entries before this time, in the same forms as --since")
+	cmd.Flags().StringVar(&user, "user", "", "Only entries by this token, certificate or client user name")
+	cmd.Flags().StringVar(&branch, "branch", "", "Only entries on this branch")
+	cmd.Flags().StringVar(&action, "action", "", "Only entries of this action, e.g. query, branch.create or branch.merge")
+	cmd.Flags().IntVarP(&limit, "limit", "n", 100, "Show at most this many of the most recent entries (0 shows all)")
+	cmd.Flags().BoolVar(&asJSON, "json", false, "Print entries as JSON lines, including their hashes")
+
+	cmd.AddCommand(verifyCmd)
+	cmd.PersistentFlags().StringVarP(&dataDir, "data-dir", "d", "./data", "Directory to store database files")
+
+	return cmd
+}
+
+// auditDatabases returns the database named in args, or every database
+// with an audit log.
+func auditDatabases(log *audit.Log, args []string) ([]string, error) {
+	if len(args) > 0 {
+		return args, nil
+	}
+	databases, err := log.Databases()
+	if err != nil {
+		return nil, err
+	}
+	if len(databases) == 0 {
+		return nil, fmt.Errorf("%w in this data directory", audit.ErrNotFound)
+	}
+	return databases, nil
+}
+
+// parseTime parses an RFC 3339 time, a date, or a duration before now. An
+// empty string is the zero time.
+func parseTime(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if d, err := time.ParseDuration(s); err == nil {
+		return time.Now().Add(-d), nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	return time.ParseInLocation(time.DateOnly, s, time.Local)
+}
+
+func formatEntry(e audit.Entry) string {
+	user := e.User
+	if user == "" {
+		user = "-"
+	}
+	target := e.Database
+	if e.Branch != "" {
+		target += "@" + e.Branch
+	}
+	outcome := e.Outcome
+	if e.RowsAffected > 0 {
+		outcome += fmt.Sprintf(", %d rows", e.RowsAffected)
+	}
+	if e.Error != "" {
+		outcome += ": " + e.Error
+	}
+	detail := strings.Join(strings.Fields(e.Detail), " ")
+	return fmt.Sprintf("  %s  %-12s %-24s %-15s %-8s %-8s %s  (%s)",
+		e.Time.Local().Format(time.DateTime), user, target, e.Action,
+		e.Duration.Round(time.Microsecond), e.Protocol, detail, outcome)
+}
@@ -1,8 +1,12 @@
 package cli
 
 import (
+	"errors"
 	"fmt"
+	"strings"
+	"time"
 
+	"github.com/bxrne/branchlore/internal/database"
 	"github.com/bxrne/branchlore/internal/git"
 	"github.com/spf13/cobra"
 )
@@ -13,29 +17,50 @@ func NewBranchCmd() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "branch",
 		Short: "Manage database branches",
-		Long:  "Create, delete, and list database branches",
+		Long:  "Create, delete, list, merge and protect database branches",
 	}
 
+	var ttl time.Duration
+	var ephemeral bool
+
 	createCmd := &cobra.Command{
 		Use:   "create [database-name] [branch-name]",
 		Short: "Create a new branch",
-		Args:  cobra.ExactArgs(2),
+		Long: `Create a new branch as a copy of main. With --ttl or --ephemeral a running
+server deletes the branch once it expires; ephemeral branches without a TTL
+live for a day.`,
+		Args: cobra.ExactArgs(2),
 		RunE: func(cmd *cobra.Command, args []string) error {
 			dbName, branchName := args[0], args[1]
+			if ttl < 0 {
+				return errors.New("--ttl must not be negative")
+			}
 
 			gitMgr, err := git.NewManager(dataDir)
 			if err != nil {
 				return fmt.Errorf("failed to create git manager: %w", err)
 			}
 
-			if err := gitMgr.CreateBranch(dbName, branchName); err != nil {
+			dbMgr, err := database.NewManager(dataDir, gitMgr)
+			if err != nil {
+				return fmt.Errorf("failed to create database manager: %w", err)
+			}
+			defer dbMgr.Close()
+
+			meta := git.NewBranchMeta(time.Now(), ttl, ephemeral)
+			if err := dbMgr.ForkBranch(cmd.Context(), dbName, branchName, "main", meta); err != nil {
 				return fmt.Errorf("failed to create branch: %w", err)
 			}
 
 			fmt.Printf("Created branch '%s' for database '%s'\n", branchName, dbName)
+			if !meta.ExpiresAt.IsZero() {
+				fmt.Printf("Expires at %s\n", meta.ExpiresAt.Format(time.RFC3339))
+			}
 			return nil
 		},
 	}
+	createCmd.Flags().DurationVar(&ttl, "ttl", 0, "Delete the branch after this long, e.g. 24h")
+	createCmd.Flags().BoolVar(&ephemeral, "ephemeral", false, "Mark the branch as throwaway; it expires after a day unless --ttl is set")
 
 	deleteCmd := &cobra.Command{
 		Use:   "delete [database-name] [branch-name]",
@@ -77,13 +102,166 @@ func NewBranchCmd() *cobra.Command {
 
 			fmt.Printf("Branches for database '%s':\n", dbName)
 			for _, branch := range branches {
-				fmt.Printf("  %s\n", branch)
+				meta, err := gitMgr.BranchMeta(dbName, branch)
+				if err != nil {
+					return err
+				}
+				if meta.ExpiresAt.IsZero() {
+					fmt.Printf("  %s\n", branch)
+				} else {
+					fmt.Printf("  %-20s expires %s\n", branch, meta.ExpiresAt.Format(time.RFC3339))
+				}
+			}
+			return nil
+		},
+	}
+
+	var message string
+	mergeCmd := &cobra.Command{
+		Use:   "merge [database-name] [from-branch] [into-branch]",
+		Short: "Merge a branch into another",
+		Long:  "Merge a branch into another. Merges into protected branches must pass the branch's checks.",
+		Args:  cobra.ExactArgs(3),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			dbName, from, into := args[0], args[1], args[2]
+
+			gitMgr, err := git.NewManager(dataDir)
+			if err != nil {
+				return fmt.Errorf("failed to create git manager: %w", err)
+			}
+
+			dbMgr, err := database.NewManager(dataDir, gitMgr)
+			if err != nil {
+				return fmt.Errorf("failed to create database manager: %w", err)
+			}
+			defer dbMgr.Close()
+
+			hash, err := dbMgr.MergeBranch(cmd.Context(), dbName, from, into, message)
+			if err != nil {
+				return fmt.Errorf("failed to merge branch: %w", err)
+			}
+
+			fmt.Printf("Merged '%s' into '%s' (%s)\n", from, into, hash)
+			return nil
+		},
+	}
+	mergeCmd.Flags().StringVarP(&message, "message", "m", "", "Merge commit message")
+
+	var checks git.Checks
+	var assertions []string
+	protectCmd := &cobra.Command{
+		Use:   "protect [database-name] [branch-pattern]",
+		Short: "Protect branches from direct writes and deletion",
+		Long: `Protect the branches matching a name or pattern such as 'release/*'. Protected
+branches cannot be written to or deleted, and only change by merging other
+branches into them. Checks given here must pass on the result of each merge.`,
+		Example: `  branchlore branch protect myproject main --integrity-check --foreign-key-check \
+    --assert "no-orphans=SELECT * FROM orders WHERE customer_id NOT IN (SELECT id FROM customers)"`,
+		Args: cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			dbName, pattern := args[0], args[1]
+
+			protection := git.Protection{Branches: pattern, Checks: checks}
+			for _, a := range assertions {
+				name, query, ok := strings.Cut(a, "=")
+				if !ok {
+					return fmt.Errorf("invalid assertion %q: want name=query", a)
+				}
+				protection.Checks.Assertions = append(protection.Checks.Assertions, git.Assertion{Name: name, Query: query})
+			}
+
+			gitMgr, err := git.NewManager(dataDir)
+			if err != nil {
+				return fmt.Errorf("failed to create git manager: %w", err)
+			}
+
+			config, err := gitMgr.Config(dbName)
+			if err != nil {
+				return fmt.Errorf("failed to read config: %w", err)
+			}
+			if err := config.Protect(protection); err != nil {
+				return fmt.Errorf("failed to protect branches: %w", err)
+			}
+			if err := gitMgr.SaveConfig(dbName, config); err != nil {
+				return fmt.Errorf("failed to save config: %w", err)
+			}
+
+			fmt.Printf("Protected branches '%s' of database '%s'\n", pattern, dbName)
+			return nil
+		},
+	}
+	protectCmd.Flags().BoolVar(&checks.IntegrityCheck, "integrity-check", false, "Require PRAGMA integrity_check to pass before merging")
+	protectCmd.Flags().BoolVar(&checks.ForeignKeyCheck, "foreign-key-check", false, "Require PRAGMA foreign_key_check to pass before merging")
+	protectCmd.Flags().StringArrayVar(&assertions, "assert", nil, "Require a query to return no rows before merging, as name=query (repeatable)")
+
+	unprotectCmd := &cobra.Command{
+		Use:   "unprotect [database-name] [branch-pattern]",
+		Short: "Remove a branch protection rule",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			dbName, pattern := args[0], args[1]
+
+			gitMgr, err := git.NewManager(dataDir)
+			if err != nil {
+				return fmt.Errorf("failed to create git manager: %w", err)
+			}
+
+			config, err := gitMgr.Config(dbName)
+			if err != nil {
+				return fmt.Errorf("failed to read config: %w", err)
+			}
+			if err := config.Unprotect(pattern); err != nil {
+				return err
+			}
+			if err := gitMgr.SaveConfig(dbName, config); err != nil {
+				return fmt.Errorf("failed to save config: %w", err)
+			}
+
+			fmt.Printf("Unprotected branches '%s' of database '%s'\n", pattern, dbName)
+			return nil
+		},
+	}
+
+	protectedCmd := &cobra.Command{
+		Use:   "protected [database-name]",
+		Short: "List branch protection rules",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			dbName := args[0]
+
+			gitMgr, err := git.NewManager(dataDir)
+			if err != nil {
+				return fmt.Errorf("failed to create git manager: %w", err)
+			}
+
+			config, err := gitMgr.Config(dbName)
+			if err != nil {
+				return fmt.Errorf("failed to read config: %w", err)
+			}
+			if len(config.Protected) == 0 {
+				fmt.Printf("No protected branches in database '%s'\n", dbName)
+				return nil
+			}
+
+			fmt.Printf("Protected branches of database '%s':\n", dbName)
+			for _, p := range config.Protected {
+				var checks []string
+				if p.Checks.IntegrityCheck {
+					checks = append(checks, "integrity_check")
+				}
+				if p.Checks.ForeignKeyCheck {
+					checks = append(checks, "foreign_key_check")
+				}
+				for _, a := range p.Checks.Assertions {
+					checks = append(checks, a.Name)
+				}
+				fmt.Printf("  %-20s %s\n", p.Branches, strings.Join(checks, ", "))
 			}
 			return nil
 		},
 	}
 
-	cmd.AddCommand(createCmd, deleteCmd, listCmd)
+	cmd.AddCommand(createCmd, deleteCmd, listCmd, mergeCmd, protectCmd, unprotectCmd, protectedCmd)
 	cmd.PersistentFlags().StringVarP(&dataDir, "data-dir", "d", "./data", "Directory to store database files")
 
 	return cmd
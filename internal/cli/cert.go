@@ -0,0 +1,90 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/bxrne/branchlore/internal/auth"
+	"github.com/spf13/cobra"
+)
+
+func NewCertCmd() *cobra.Command {
+	var dataDir string
+
+	cmd := &cobra.Command{
+		Use:   "cert",
+		Short: "Manage client certificate identities",
+		Long: `Grant permissions to client certificates by their subject common name. A server
+started with --auth and --tls-client-ca accepts a certificate of a known
+identity in place of an API token.`,
+	}
+
+	var scopes, roles []string
+	allowCmd := &cobra.Command{
+		Use:   "allow [common-name]",
+		Short: "Grant a certificate identity scopes and roles",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(scopes) == 0 && len(roles) == 0 {
+				scopes = []string{string(auth.ScopeRead)}
+			}
+			var parsed []auth.Scope
+			for _, s := range scopes {
+				scope, err := auth.ParseScope(s)
+				if err != nil {
+					return err
+				}
+				parsed = append(parsed, scope)
+			}
+
+			cert, err := auth.NewStore(dataDir).AllowCertificate(args[0], parsed, roles)
+			if err != nil {
+				return fmt.Errorf("failed to allow certificate: %w", err)
+			}
+
+			fmt.Printf("Allowed certificate '%s' with %s\n", cert.Name, describeGrants(cert.Scopes, cert.Roles))
+			return nil
+		},
+	}
+	allowCmd.Flags().StringSliceVar(&scopes, "scope", nil, "Scopes to grant on every database: read, write or admin (default read without --role)")
+	allowCmd.Flags().StringSliceVar(&roles, "role", nil, "Roles to grant, see 'branchlore role'")
+
+	listCmd := &cobra.Command{
+		Use:   "list",
+		Short: "List certificate identities",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			certs, err := auth.NewStore(dataDir).Certificates()
+			if err != nil {
+				return fmt.Errorf("failed to list certificates: %w", err)
+			}
+			if len(certs) == 0 {
+				fmt.Println("No certificate identities")
+				return nil
+			}
+
+			for _, c := range certs {
+				fmt.Printf("  %-30s %s\n", c.Name, describeGrants(c.Scopes, c.Roles))
+			}
+			return nil
+		},
+	}
+
+	revokeCmd := &cobra.Command{
+		Use:   "revoke [common-name]",
+		Short: "Revoke a certificate identity",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := auth.NewStore(dataDir).RevokeCertificate(args[0]); err != nil {
+				return fmt.Errorf("failed to revoke certificate: %w", err)
+			}
+
+			fmt.Printf("Revoked certificate '%s'\n", args[0])
+			return nil
+		},
+	}
+
+	cmd.AddCommand(allowCmd, listCmd, revokeCmd)
+	cmd.PersistentFlags().StringVarP(&dataDir, "data-dir", "d", "./data", "Directory to store database files")
+
+	return cmd
+}
@@ -3,19 +3,19 @@ package cli
 import (
 	"bufio"
 	"bytes"
-	"encoding/json"
+	"context"
 	"fmt"
-	"io"
-	"net/http"
-	"net/url"
 	"os"
 	"strings"
+	"time"
 
+	"github.com/bxrne/branchlore/client"
 	"github.com/spf13/cobra"
 )
 
 func NewConnectCmd() *cobra.Command {
-	var serverURL string
+	var serverURL, token, caFile, certFile, keyFile string
+	var timeout time.Duration
 
 	cmd := &cobra.Command{
 		Use:   "connect [database@branch]",
@@ -34,6 +34,25 @@ If no branch is specified, defaults to 'main'`,
 				branch = parts[1]
 			}
 
+			var opts []client.Option
+			if timeout > 0 {
+				opts = append(opts, client.WithQueryTimeout(timeout))
+			}
+			if token != "" {
+				opts = append(opts, client.WithToken(token))
+			}
+			if caFile != "" || certFile != "" {
+				tlsConfig, err := client.LoadTLSConfig(caFile, certFile, keyFile)
+				if err != nil {
+					return err
+				}
+				opts = append(opts, client.WithTLSConfig(tlsConfig))
+			}
+			c, err := client.NewClient(serverURL, opts...)
+			if err != nil {
+				return err
+			}
+
 			fmt.Printf("Connected to %s@%s\n", dbName, branch)
 			fmt.Printf("Server: %s\n", serverURL)
 			fmt.Println("Type 'exit' or 'quit' to exit")
@@ -56,7 +75,7 @@ If no branch is specified, defaults to 'main'`,
 					break
 				}
 
-				if err := executeQuery(serverURL, dbName, branch, query); err != nil {
+				if err := executeQuery(c, dbName, branch, query); err != nil {
 					fmt.Printf("Error: %v\n", err)
 				}
 			}
@@ -66,60 +85,38 @@ If no branch is specified, defaults to 'main'`,
 	}
 
 	cmd.Flags().StringVarP(&serverURL, "server", "s", "http://localhost:8080", "BranchLore server URL")
+	cmd.Flags().StringVar(&token, "token", os.Getenv("BRANCHLORE_TOKEN"), "API token for servers started with --auth (default $BRANCHLORE_TOKEN)")
+	cmd.Flags().DurationVarP(&timeout, "timeout", "t", 0, "Per-query timeout (defaults to the server setting)")
+	cmd.Flags().StringVar(&caFile, "ca", "", "PEM CA to trust for https servers (defaults to the system roots)")
+	cmd.Flags().StringVar(&certFile, "cert", "", "PEM client certificate for servers requiring mutual TLS")
+	cmd.Flags().StringVar(&keyFile, "key", "", "PEM private key for --cert")
 
 	return cmd
 }
 
-func executeQuery(serverURL, dbName, branch, query string) error {
-	data := url.Values{}
-	data.Set("query", query)
-
-	queryURL := fmt.Sprintf("%s/query?db=%s&branch=%s", serverURL, dbName, branch)
-
-	resp, err := http.Post(queryURL, "application/x-www-form-urlencoded", strings.NewReader(data.Encode()))
-	if err != nil {
-		return fmt.Errorf("failed to execute query: %w", err)
-	}
-	defer resp.Body.Close()
-
-	body, err := io.ReadAll(resp.Body)
+func executeQuery(c *client.Client, dbName, branch, query string) error {
+	resp, err := c.Run(context.Background(), dbName, branch, query)
 	if err != nil {
-		return fmt.Errorf("failed to read response: %w", err)
+		return err
 	}
 
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("server error: %s", string(body))
-	}
-
-	var result map[string]interface{}
-	if err := json.Unmarshal(body, &result); err != nil {
-		return fmt.Errorf("failed to parse response: %w", err)
-	}
-
-	if errorMsg, exists := result["error"]; exists {
-		return fmt.Errorf("query error: %v", errorMsg)
-	}
-
-	if _, exists := result["columns"]; exists {
-		printQueryResult(result)
+	if resp.Rows != nil {
+		printQueryResult(resp.Rows)
 	} else {
-		printModifyResult(result)
+		printModifyResult(resp.Result)
 	}
 
 	return nil
 }
 
-func printQueryResult(result map[string]interface{}) {
-	columns := result["columns"].([]interface{})
-	rows := result["rows"].([]interface{})
-
-	if len(rows) == 0 {
+func printQueryResult(result *client.Rows) {
+	if len(result.Values) == 0 {
 		fmt.Println("No results")
 		return
 	}
 
 	var buf bytes.Buffer
-	for i, col := range columns {
+	for i, col := range result.Columns {
 		if i > 0 {
 			buf.WriteString(" | ")
 		}
@@ -128,7 +125,7 @@ func printQueryResult(result map[string]interface{}) {
 	fmt.Println(buf.String())
 
 	buf.Reset()
-	for i := range columns {
+	for i := range result.Columns {
 		if i > 0 {
 			buf.WriteString("-+-")
 		}
@@ -136,28 +133,39 @@ func printQueryResult(result map[string]interface{}) {
 	}
 	fmt.Println(buf.String())
 
-	for _, row := range rows {
-		rowData := row.([]interface{})
+	for _, row := range result.Values {
 		buf.Reset()
-		for i, val := range rowData {
+		for i, val := range row {
 			if i > 0 {
 				buf.WriteString(" | ")
 			}
-			buf.WriteString(fmt.Sprintf("%-15v", val))
+			buf.WriteString(fmt.Sprintf("%-15s", formatValue(val)))
 		}
 		fmt.Println(buf.String())
 	}
 
-	fmt.Printf("\n%d rows returned\n", len(rows))
+	fmt.Printf("\n%d rows returned\n", len(result.Values))
+	if result.Truncated {
+		fmt.Println("Result truncated by the server row limit")
+	}
 }
 
-func printModifyResult(result map[string]interface{}) {
-	if rowsAffected, exists := result["rows_affected"]; exists {
-		fmt.Printf("Rows affected: %v\n", rowsAffected)
+func printModifyResult(result *client.Result) {
+	fmt.Printf("Rows affected: %d\n", result.RowsAffected)
+	if result.LastInsertID > 0 {
+		fmt.Printf("Last insert ID: %d\n", result.LastInsertID)
 	}
-	if lastInsertId, exists := result["last_insert_id"]; exists {
-		if id := lastInsertId.(float64); id > 0 {
-			fmt.Printf("Last insert ID: %.0f\n", id)
-		}
+}
+
+// formatValue renders a result value: NULL, BLOBs as hex literals and
+// everything else as its exact textual value.
+func formatValue(val interface{}) string {
+	switch v := val.(type) {
+	case nil:
+		return "NULL"
+	case []byte:
+		return fmt.Sprintf("x'%x'", v)
+	default:
+		return fmt.Sprint(v)
 	}
 }
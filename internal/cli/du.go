@@ -0,0 +1,93 @@
+package cli
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+
+	"github.com/bxrne/branchlore/internal/database"
+	"github.com/bxrne/branchlore/internal/git"
+	"github.com/spf13/cobra"
+)
+
+func NewDuCmd() *cobra.Command {
+	var dataDir string
+	var asJSON bool
+
+	cmd := &cobra.Command{
+		Use:   "du [database-name]",
+		Short: "Show the disk space databases and branches take",
+		Long: `Show the disk space each branch's database file, write-ahead log and Git
+history take. Shared history is also reachable from other branches; unique
+bytes are what deleting the branch and pruning its history would free.
+Branches are listed largest first. Without a database name every database is
+shown.`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			gitMgr, err := git.NewManager(dataDir)
+			if err != nil {
+				return fmt.Errorf("failed to create git manager: %w", err)
+			}
+
+			dbMgr, err := database.NewManager(dataDir, gitMgr)
+			if err != nil {
+				return fmt.Errorf("failed to create database manager: %w", err)
+			}
+			defer dbMgr.Close()
+
+			databases := args
+			if len(databases) == 0 {
+				if databases, err = gitMgr.ListDatabases(); err != nil {
+					return fmt.Errorf("failed to list databases: %w", err)
+				}
+			}
+
+			enc := json.NewEncoder(os.Stdout)
+			for _, dbName := range databases {
+				usage, err := dbMgr.Usage(dbName)
+				if err != nil {
+					return fmt.Errorf("failed to measure %s: %w", dbName, err)
+				}
+				slices.SortStableFunc(usage.Branches, func(a, b database.BranchUsage) int {
+					return cmp.Compare(b.UniqueBytes, a.UniqueBytes)
+				})
+
+				if asJSON {
+					if err := enc.Encode(usage); err != nil {
+						return err
+					}
+					continue
+				}
+
+				fmt.Printf("Database '%s': %s (Git repository %s)\n", dbName, formatBytes(usage.TotalBytes), formatBytes(usage.RepositoryBytes))
+				fmt.Printf("  %-24s %10s %10s %10s %10s %10s\n", "BRANCH", "DATABASE", "WAL", "HISTORY", "SHARED", "UNIQUE")
+				for _, b := range usage.Branches {
+					fmt.Printf("  %-24s %10s %10s %10s %10s %10s\n", b.Branch, formatBytes(b.DatabaseBytes), formatBytes(b.WALBytes),
+						formatBytes(b.HistoryBytes), formatBytes(b.SharedBytes), formatBytes(b.UniqueBytes))
+				}
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&dataDir, "data-dir", "d", "./data", "Directory to store database files")
+	cmd.Flags().BoolVar(&asJSON, "json", false, "Print each database's usage as a JSON line")
+
+	return cmd
+}
+
+// formatBytes formats n in the largest binary unit it reaches.
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	value, exp := float64(n)/unit, 0
+	for value >= unit && exp < 4 {
+		value /= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", value, "KMGTP"[exp])
+}
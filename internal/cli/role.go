@@ -0,0 +1,123 @@
+package cli
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/bxrne/branchlore/internal/auth"
+	"github.com/spf13/cobra"
+)
+
+func NewRoleCmd() *cobra.Command {
+	var dataDir string
+
+	cmd := &cobra.Command{
+		Use:   "role",
+		Short: "Manage roles",
+		Long:  "Grant roles permissions on databases and branches; tokens created with --role hold their grants",
+	}
+
+	var database, branches string
+	var perms []string
+	grantCmd := &cobra.Command{
+		Use:   "grant [role]",
+		Short: "Grant a role permissions, creating the role if needed",
+		Example: `  branchlore role grant dev --database app --branches 'dev/*' --permission write,branch-create,branch-delete
+  branchlore role grant dev --database app --branches main --permission read`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			grant := auth.Grant{Database: database, Branches: branches}
+			for _, p := range perms {
+				perm, err := auth.ParsePermission(p)
+				if err != nil {
+					return err
+				}
+				grant.Permissions = append(grant.Permissions, perm)
+			}
+
+			if err := auth.NewStore(dataDir).Grant(args[0], grant); err != nil {
+				return fmt.Errorf("failed to grant role: %w", err)
+			}
+
+			fmt.Printf("Granted role '%s' %s on %s@%s\n", args[0], joinPermissions(grant.Permissions), database, branches)
+			return nil
+		},
+	}
+	grantCmd.Flags().StringVar(&database, "database", "*", "Database name or pattern")
+	grantCmd.Flags().StringVar(&branches, "branches", "*", "Branch name or pattern, e.g. 'dev/*'")
+	grantCmd.Flags().StringSliceVar(&perms, "permission", nil, "Permissions: read, write, branch-create, branch-delete, merge or admin")
+	grantCmd.MarkFlagRequired("permission")
+
+	revokeCmd := &cobra.Command{
+		Use:   "revoke [role]",
+		Short: "Remove a role's grant on a database and branch pattern",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := auth.NewStore(dataDir).Ungrant(args[0], database, branches); err != nil {
+				return fmt.Errorf("failed to revoke grant: %w", err)
+			}
+
+			fmt.Printf("Revoked role '%s' grant on %s@%s\n", args[0], database, branches)
+			return nil
+		},
+	}
+	revokeCmd.Flags().StringVar(&database, "database", "*", "Database name or pattern")
+	revokeCmd.Flags().StringVar(&branches, "branches", "*", "Branch name or pattern")
+
+	listCmd := &cobra.Command{
+		Use:   "list",
+		Short: "List roles and their grants",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			roles, err := auth.NewStore(dataDir).Roles()
+			if err != nil {
+				return fmt.Errorf("failed to list roles: %w", err)
+			}
+			if len(roles) == 0 {
+				fmt.Println("No roles")
+				return nil
+			}
+
+			names := make([]string, 0, len(roles))
+			for name := range roles {
+				names = append(names, name)
+			}
+			slices.Sort(names)
+			for _, name := range names {
+				fmt.Printf("  %s\n", name)
+				for _, g := range roles[name] {
+					fmt.Printf("    %-30s %s\n", g.Database+"@"+g.Branches, joinPermissions(g.Permissions))
+				}
+			}
+			return nil
+		},
+	}
+
+	deleteCmd := &cobra.Command{
+		Use:   "delete [role]",
+		Short: "Delete a role",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := auth.NewStore(dataDir).DeleteRole(args[0]); err != nil {
+				return fmt.Errorf("failed to delete role: %w", err)
+			}
+
+			fmt.Printf("Deleted role '%s'\n", args[0])
+			return nil
+		},
+	}
+
+	cmd.AddCommand(grantCmd, revokeCmd, listCmd, deleteCmd)
+	cmd.PersistentFlags().StringVarP(&dataDir, "data-dir", "d", "./data", "Directory to store database files")
+
+	return cmd
+}
+
+func joinPermissions(perms []auth.Permission) string {
+	names := make([]string, len(perms))
+	for i, p := range perms {
+		names[i] = string(p)
+	}
+	return strings.Join(names, ",")
+}
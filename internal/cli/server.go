@@ -1,57 +1,43 @@
 package cli
 
 import (
-	"fmt"
-	"log"
-	"os"
-	"os/signal"
-	"syscall"
-
-	"github.com/bxrne/branchlore/internal/server"
+	"github.com/bxrne/branchlore/internal/config"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 )
 
+// serverShorthands are the single-letter forms of the server's most used
+// flags.
+var serverShorthands = map[string]string{"port": "p", "data-dir": "d", "log-level": "l"}
+
+// shorthandFlags registers string flags with their shorthand, if any.
+type shorthandFlags struct {
+	*pflag.FlagSet
+}
+
+func (f shorthandFlags) StringVar(p *string, name, value, usage string) {
+	f.StringVarP(p, name, serverShorthands[name], value, usage)
+}
+
 func NewServerCmd() *cobra.Command {
-	var port, dataDir, logLevel string
+	var loader *config.Loader
 
 	cmd := &cobra.Command{
 		Use:   "server",
 		Short: "Start the BranchLore database server",
-		Long:  "Start the BranchLore database server with Git-like branching capabilities",
+		Long: `Start the BranchLore database server with Git-like branching capabilities.
+
+Settings come from the --config YAML file, then BRANCHLORE_* environment
+variables named after the flags (BRANCHLORE_QUERY_TIMEOUT for
+--query-timeout), then the flags themselves. On SIGHUP the server reloads
+them and applies those that can change while it runs.`,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			config := &server.Config{
-				Port:     port,
-				DataDir:  dataDir,
-				LogLevel: logLevel,
-			}
-
-			srv, err := server.New(config)
-			if err != nil {
-				return fmt.Errorf("failed to create server: %w", err)
-			}
-
-			go func() {
-				if err := srv.Start(); err != nil {
-					log.Fatalf("Server failed to start: %v", err)
-				}
-			}()
-
-			fmt.Printf("BranchLore server starting on port %s\n", port)
-			fmt.Printf("Data directory: %s\n", dataDir)
-
-			c := make(chan os.Signal, 1)
-			signal.Notify(c, os.Interrupt, syscall.SIGTERM)
-			<-c
-
-			fmt.Println("\nShutting down server...")
-			srv.Shutdown()
-			return nil
+			cmd.SilenceUsage = true
+			return loader.Run(cmd.Flags().Changed)
 		},
 	}
 
-	cmd.Flags().StringVarP(&port, "port", "p", "8080", "Port to listen on")
-	cmd.Flags().StringVarP(&dataDir, "data-dir", "d", "./data", "Directory to store database files")
-	cmd.Flags().StringVarP(&logLevel, "log-level", "l", "info", "Log level (debug, info, warn, error)")
+	loader = config.NewLoader(shorthandFlags{cmd.Flags()})
 
 	return cmd
 }
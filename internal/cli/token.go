@@ -0,0 +1,122 @@
+package cli
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/bxrne/branchlore/internal/auth"
+	"github.com/spf13/cobra"
+)
+
+func NewTokenCmd() *cobra.Command {
+	var dataDir string
+
+	cmd := &cobra.Command{
+		Use:   "token",
+		Short: "Manage API tokens",
+		Long:  "Create, list and revoke the API tokens a server started with --auth accepts",
+	}
+
+	var name string
+	var scopes, roles []string
+	var expires time.Duration
+	createCmd := &cobra.Command{
+		Use:   "create",
+		Short: "Create a token and print it",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(scopes) == 0 && len(roles) == 0 {
+				scopes = []string{string(auth.ScopeRead)}
+			}
+			var parsed []auth.Scope
+			for _, s := range scopes {
+				scope, err := auth.ParseScope(s)
+				if err != nil {
+					return err
+				}
+				parsed = append(parsed, scope)
+			}
+
+			secret, token, err := auth.NewStore(dataDir).Create(name, parsed, roles, expires)
+			if err != nil {
+				return fmt.Errorf("failed to create token: %w", err)
+			}
+
+			fmt.Printf("Created token '%s' (%s) with %s\n", token.Name, token.ID, describeGrants(token.Scopes, token.Roles))
+			fmt.Println("Store it now, it cannot be shown again:")
+			fmt.Println(secret)
+			return nil
+		},
+	}
+	createCmd.Flags().StringVar(&name, "name", "", "Token name")
+	createCmd.Flags().StringSliceVar(&scopes, "scope", nil, "Scopes to grant on every database: read, write or admin (default read without --role)")
+	createCmd.Flags().StringSliceVar(&roles, "role", nil, "Roles to grant, see 'branchlore role'")
+	createCmd.Flags().DurationVar(&expires, "expires", 0, "Expire the token after this long (0 never expires)")
+	createCmd.MarkFlagRequired("name")
+
+	listCmd := &cobra.Command{
+		Use:   "list",
+		Short: "List tokens",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			tokens, err := auth.NewStore(dataDir).List()
+			if err != nil {
+				return fmt.Errorf("failed to list tokens: %w", err)
+			}
+			if len(tokens) == 0 {
+				fmt.Println("No tokens")
+				return nil
+			}
+
+			now := time.Now()
+			for _, t := range tokens {
+				expiry := "never expires"
+				if t.ExpiresAt != nil {
+					expiry = "expires " + t.ExpiresAt.Format(time.RFC3339)
+					if t.Expired(now) {
+						expiry = "expired " + t.ExpiresAt.Format(time.RFC3339)
+					}
+				}
+				fmt.Printf("  %s  %-20s %-30s %s\n", t.ID, t.Name, describeGrants(t.Scopes, t.Roles), expiry)
+			}
+			return nil
+		},
+	}
+
+	revokeCmd := &cobra.Command{
+		Use:   "revoke [name-or-id]",
+		Short: "Revoke a token",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := auth.NewStore(dataDir).Revoke(args[0]); err != nil {
+				return fmt.Errorf("failed to revoke token: %w", err)
+			}
+
+			fmt.Printf("Revoked token '%s'\n", args[0])
+			return nil
+		},
+	}
+
+	cmd.AddCommand(createCmd, listCmd, revokeCmd)
+	cmd.PersistentFlags().StringVarP(&dataDir, "data-dir", "d", "./data", "Directory to store database files")
+
+	return cmd
+}
+
+// describeGrants summarises what a token or certificate is granted, e.g.
+// "scopes read, roles dev,ci".
+func describeGrants(scopes []auth.Scope, roles []string) string {
+	var parts []string
+	if len(scopes) > 0 {
+		names := make([]string, len(scopes))
+		for i, s := range scopes {
+			names[i] = string(s)
+		}
+		parts = append(parts, "scopes "+strings.Join(names, ","))
+	}
+	if len(roles) > 0 {
+		parts = append(parts, "roles "+strings.Join(roles, ","))
+	}
+	return strings.Join(parts, ", ")
+}
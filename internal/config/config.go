@@ -0,0 +1,505 @@
+// Package config builds the server's configuration from a YAML file,
+// BRANCHLORE_* environment variables and command-line flags, each taking
+// precedence over the one before. Both server binaries load it the same way.
+package config
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bxrne/branchlore/internal/database"
+	"github.com/bxrne/branchlore/internal/git"
+	"github.com/bxrne/branchlore/internal/server"
+	"go.yaml.in/yaml/v2"
+)
+
+// Config is the server's configuration as laid out in the file.
+type Config struct {
+	DataDir   string              `yaml:"data_dir"`
+	Listen    Listen              `yaml:"listen"`
+	Log       Log                 `yaml:"log"`
+	TLS       TLS                 `yaml:"tls"`
+	Auth      Auth                `yaml:"auth"`
+	Audit     bool                `yaml:"audit"`
+	Limits    Limits              `yaml:"limits"`
+	Quotas    Quotas              `yaml:"quotas"`
+	Pool      Pool                `yaml:"pool"`
+	Health    Health              `yaml:"health"`
+	Shutdown  Shutdown            `yaml:"shutdown"`
+	Janitor   Janitor             `yaml:"janitor"`
+	Retention Retention           `yaml:"retention"`
+	Databases map[string]Database `yaml:"databases"`
+	Roles     map[string]Role     `yaml:"roles"`
+}
+
+// Listen holds the HTTP port and the addresses of the optional listeners,
+// which are disabled when empty.
+type Listen struct {
+	Port     string `yaml:"port"`
+	Postgres string `yaml:"postgres"`
+	MySQL    string `yaml:"mysql"`
+	GRPC     string `yaml:"grpc"`
+}
+
+type Log struct {
+	Level  string `yaml:"level"`
+	Format string `yaml:"format"`
+}
+
+type TLS struct {
+	Cert     string `yaml:"cert"`
+	Key      string `yaml:"key"`
+	ClientCA string `yaml:"client_ca"`
+}
+
+type Auth struct {
+	Enabled          bool   `yaml:"enabled"`
+	PostgresPassword string `yaml:"postgres_password"`
+	MySQLPassword    string `yaml:"mysql_password"`
+}
+
+type Limits struct {
+	QueryTimeout      time.Duration `yaml:"query_timeout"`
+	MaxRows           int           `yaml:"max_rows"`
+	CursorIdleTimeout time.Duration `yaml:"cursor_idle_timeout"`
+	TxIdleTimeout     time.Duration `yaml:"tx_idle_timeout"`
+	// TokenRate and IPRate are requests per second, refilling bursts of
+	// TokenBurst and IPBurst. Zero rates set no limit.
+	TokenRate            float64 `yaml:"token_rate"`
+	TokenBurst           int     `yaml:"token_burst"`
+	IPRate               float64 `yaml:"ip_rate"`
+	IPBurst              int     `yaml:"ip_burst"`
+	MaxConcurrentQueries int     `yaml:"max_concurrent_queries"`
+	// MySQLMaxPacketMB caps a MySQL protocol packet, as MySQL's
+	// max_allowed_packet does.
+	MySQLMaxPacketMB int `yaml:"mysql_max_packet_mb"`
+}
+
+// Quotas cap the disk space of each database and each branch, in MiB.
+// Zero sets no quota.
+type Quotas struct {
+	DatabaseMB int `yaml:"database_mb"`
+	BranchMB   int `yaml:"branch_mb"`
+}
+
+// Pool bounds the connection pool of each branch. Zero means no limit on
+// open connections, database/sql's default of two idle ones and no idle
+// timeout.
+type Pool struct {
+	MaxOpenConns    int           `yaml:"max_open_conns"`
+	MaxIdleConns    int           `yaml:"max_idle_conns"`
+	ConnMaxIdleTime time.Duration `yaml:"conn_max_idle_time"`
+}
+
+type Health struct {
+	MinFreeDiskMB int `yaml:"min_free_disk_mb"`
+	CheckSample   int `yaml:"check_sample"`
+}
+
+type Shutdown struct {
+	Timeout time.Duration `yaml:"timeout"`
+	Commit  bool          `yaml:"commit"`
+}
+
+// Janitor deletes expired branches and prunes the history deleted
+// branches leave behind every Interval. Zero disables it.
+type Janitor struct {
+	Interval time.Duration `yaml:"interval"`
+}
+
+// Retention sets how long ephemeral branches created without a TTL live,
+// and how long the history a deleted branch leaves behind is kept before
+// the janitor prunes it.
+type Retention struct {
+	EphemeralTTL time.Duration `yaml:"ephemeral_ttl"`
+	PruneGrace   time.Duration `yaml:"prune_grace"`
+}
+
+// Database overrides the limits of one database. Zero fields keep the
+// server-wide limit.
+type Database struct {
+	QueryTimeout         time.Duration     `yaml:"query_timeout"`
+	MaxRows              int               `yaml:"max_rows"`
+	MaxConcurrentQueries int               `yaml:"max_concurrent_queries"`
+	QuotaMB              int               `yaml:"quota_mb"`
+	BranchQuotaMB        int               `yaml:"branch_quota_mb"`
+	Branches             map[string]Branch `yaml:"branches"`
+}
+
+// Branch overrides the quota of one branch.
+type Branch struct {
+	QuotaMB int `yaml:"quota_mb"`
+}
+
+// Role limits the tokens holding an auth role. Zero fields set no limit.
+type Role struct {
+	MaxQueryDuration time.Duration `yaml:"max_query_duration"`
+	MaxRows          int           `yaml:"max_rows"`
+	MaxResultBytes   int64         `yaml:"max_result_bytes"`
+	Rate             float64       `yaml:"rate"`
+	Burst            int           `yaml:"burst"`
+}
+
+// Default returns the configuration used when nothing is set.
+func Default() *Config {
+	return &Config{
+		DataDir: "./data",
+		Listen:  Listen{Port: "8080"},
+		Log:     Log{Level: "info", Format: "text"},
+		Limits: Limits{
+			QueryTimeout:      30 * time.Second,
+			MaxRows:           10000,
+			CursorIdleTimeout: 5 * time.Minute,
+			TxIdleTimeout:     time.Minute,
+			MySQLMaxPacketMB:  64,
+		},
+		Health:   Health{MinFreeDiskMB: 512, CheckSample: 3},
+		Shutdown: Shutdown{Timeout: 30 * time.Second},
+		Janitor:  Janitor{Interval: time.Minute},
+		Retention: Retention{
+			EphemeralTTL: git.DefaultEphemeralTTL,
+			PruneGrace:   time.Minute,
+		},
+	}
+}
+
+// Server converts c to the server's configuration.
+func (c *Config) Server() *server.Config {
+	databases := make(map[string]server.DatabaseConfig, len(c.Databases))
+	for name, db := range c.Databases {
+		branchQuotas := make(map[string]int64, len(db.Branches))
+		for branch, settings := range db.Branches {
+			branchQuotas[branch] = megabytes(settings.QuotaMB)
+		}
+		databases[name] = server.DatabaseConfig{
+			QueryTimeout:         db.QueryTimeout,
+			MaxRows:              db.MaxRows,
+			MaxConcurrentQueries: db.MaxConcurrentQueries,
+			Quota:                megabytes(db.QuotaMB),
+			BranchQuota:          megabytes(db.BranchQuotaMB),
+			BranchQuotas:         branchQuotas,
+		}
+	}
+	roles := make(map[string]server.RoleLimits, len(c.Roles))
+	for name, role := range c.Roles {
+		roles[name] = server.RoleLimits(role)
+	}
+	return &server.Config{
+		Port:                 c.Listen.Port,
+		DataDir:              c.DataDir,
+		LogLevel:             c.Log.Level,
+		LogFormat:            c.Log.Format,
+		QueryTimeout:         c.Limits.QueryTimeout,
+		MaxRows:              c.Limits.MaxRows,
+		CursorIdleTimeout:    c.Limits.CursorIdleTimeout,
+		TxIdleTimeout:        c.Limits.TxIdleTimeout,
+		TokenRate:            c.Limits.TokenRate,
+		TokenBurst:           c.Limits.TokenBurst,
+		IPRate:               c.Limits.IPRate,
+		IPBurst:              c.Limits.IPBurst,
+		MaxConcurrentQueries: c.Limits.MaxConcurrentQueries,
+		DatabaseQuota:        megabytes(c.Quotas.DatabaseMB),
+		BranchQuota:          megabytes(c.Quotas.BranchMB),
+		PostgresAddr:         c.Listen.Postgres,
+		PostgresPassword:     c.Auth.PostgresPassword,
+		MySQLAddr:            c.Listen.MySQL,
+		MySQLPassword:        c.Auth.MySQLPassword,
+		MySQLMaxPacket:       megabytes(c.Limits.MySQLMaxPacketMB),
+		GRPCAddr:             c.Listen.GRPC,
+		Auth:                 c.Auth.Enabled,
+		TLSCert:              c.TLS.Cert,
+		TLSKey:               c.TLS.Key,
+		TLSClientCA:          c.TLS.ClientCA,
+		Audit:                c.Audit,
+		MinFreeDisk:          megabytes(c.Health.MinFreeDiskMB),
+		HealthCheckSample:    c.Health.CheckSample,
+		CommitOnShutdown:     c.Shutdown.Commit,
+		JanitorInterval:      c.Janitor.Interval,
+		EphemeralTTL:         c.Retention.EphemeralTTL,
+		PruneGrace:           c.Retention.PruneGrace,
+		Pool: database.PoolConfig{
+			MaxOpenConns:    c.Pool.MaxOpenConns,
+			MaxIdleConns:    c.Pool.MaxIdleConns,
+			ConnMaxIdleTime: c.Pool.ConnMaxIdleTime,
+		},
+		Databases: databases,
+		Roles:     roles,
+	}
+}
+
+// megabytes converts a size in MiB to bytes.
+func megabytes(mb int) int64 {
+	return int64(mb) << 20
+}
+
+// validate checks c, naming each problem setting by its file key, or by
+// what name returns for the key.
+func (c *Config) validate(name func(key string) string) error {
+	var errs []error
+	check := func(ok bool, key, format string, args ...interface{}) {
+		if !ok {
+			errs = append(errs, fmt.Errorf("%s: %s", name(key), fmt.Sprintf(format, args...)))
+		}
+	}
+	nonNegative := func(key string, v int64) {
+		check(v >= 0, key, "must not be negative, got %d", v)
+	}
+	nonNegativeRate := func(key string, v float64) {
+		check(v >= 0, key, "must not be negative, got %g", v)
+	}
+	address := func(key, addr string) {
+		if addr != "" {
+			_, port, err := net.SplitHostPort(addr)
+			check(err == nil && validPort(port), key, "%q is not a host:port address", addr)
+		}
+	}
+
+	check(c.DataDir != "", "data_dir", "must be set")
+	check(validPort(c.Listen.Port), "listen.port", "%q is not a port number", c.Listen.Port)
+	address("listen.postgres", c.Listen.Postgres)
+	address("listen.mysql", c.Listen.MySQL)
+	address("listen.grpc", c.Listen.GRPC)
+
+	switch strings.ToLower(c.Log.Level) {
+	case "", "debug", "info", "warn", "error":
+	default:
+		check(false, "log.level", "%q is not debug, info, warn or error", c.Log.Level)
+	}
+	switch c.Log.Format {
+	case "", "text", "json":
+	default:
+		check(false, "log.format", "%q is not text or json", c.Log.Format)
+	}
+
+	check(c.TLS.Cert == "" || c.TLS.Key != "", "tls.key", "must be set with tls.cert")
+	check(c.TLS.Key == "" || c.TLS.Cert != "", "tls.cert", "must be set with tls.key")
+	check(c.TLS.ClientCA == "" || c.TLS.Cert != "", "tls.client_ca", "requires tls.cert and tls.key")
+
+	nonNegative("limits.query_timeout", int64(c.Limits.QueryTimeout))
+	nonNegative("limits.max_rows", int64(c.Limits.MaxRows))
+	nonNegative("limits.cursor_idle_timeout", int64(c.Limits.CursorIdleTimeout))
+	nonNegative("limits.tx_idle_timeout", int64(c.Limits.TxIdleTimeout))
+	nonNegativeRate("limits.token_rate", c.Limits.TokenRate)
+	nonNegative("limits.token_burst", int64(c.Limits.TokenBurst))
+	nonNegativeRate("limits.ip_rate", c.Limits.IPRate)
+	nonNegative("limits.ip_burst", int64(c.Limits.IPBurst))
+	nonNegative("limits.max_concurrent_queries", int64(c.Limits.MaxConcurrentQueries))
+	nonNegative("limits.mysql_max_packet_mb", int64(c.Limits.MySQLMaxPacketMB))
+	nonNegative("quotas.database_mb", int64(c.Quotas.DatabaseMB))
+	nonNegative("quotas.branch_mb", int64(c.Quotas.BranchMB))
+	nonNegative("pool.max_open_conns", int64(c.Pool.MaxOpenConns))
+	nonNegative("pool.max_idle_conns", int64(c.Pool.MaxIdleConns))
+	nonNegative("pool.conn_max_idle_time", int64(c.Pool.ConnMaxIdleTime))
+	nonNegative("health.min_free_disk_mb", int64(c.Health.MinFreeDiskMB))
+	nonNegative("health.check_sample", int64(c.Health.CheckSample))
+	check(c.Shutdown.Timeout > 0, "shutdown.timeout", "must be positive, got %s", c.Shutdown.Timeout)
+	nonNegative("janitor.interval", int64(c.Janitor.Interval))
+	check(c.Retention.EphemeralTTL > 0, "retention.ephemeral_ttl", "must be positive, got %s", c.Retention.EphemeralTTL)
+	nonNegative("retention.prune_grace", int64(c.Retention.PruneGrace))
+	for db, settings := range c.Databases {
+		nonNegative("databases."+db+".query_timeout", int64(settings.QueryTimeout))
+		nonNegative("databases."+db+".max_rows", int64(settings.MaxRows))
+		nonNegative("databases."+db+".max_concurrent_queries", int64(settings.MaxConcurrentQueries))
+		nonNegative("databases."+db+".quota_mb", int64(settings.QuotaMB))
+		nonNegative("databases."+db+".branch_quota_mb", int64(settings.BranchQuotaMB))
+		for branch, b := range settings.Branches {
+			nonNegative("databases."+db+".branches."+branch+".quota_mb", int64(b.QuotaMB))
+		}
+	}
+	for role, limits := range c.Roles {
+		nonNegative("roles."+role+".max_query_duration", int64(limits.MaxQueryDuration))
+		nonNegative("roles."+role+".max_rows", int64(limits.MaxRows))
+		nonNegative("roles."+role+".max_result_bytes", limits.MaxResultBytes)
+		nonNegativeRate("roles."+role+".rate", limits.Rate)
+		nonNegative("roles."+role+".burst", int64(limits.Burst))
+	}
+
+	return errors.Join(errs...)
+}
+
+func validPort(s string) bool {
+	n, err := strconv.Atoi(s)
+	return err == nil && n >= 0 && n <= 65535
+}
+
+// FlagSet is the part of flag.FlagSet and pflag.FlagSet the loader uses, so
+// either binary can register the server's flags.
+type FlagSet interface {
+	StringVar(p *string, name, value, usage string)
+	BoolVar(p *bool, name string, value bool, usage string)
+	IntVar(p *int, name string, value int, usage string)
+	Float64Var(p *float64, name string, value float64, usage string)
+	DurationVar(p *time.Duration, name string, value time.Duration, usage string)
+	Set(name, value string) error
+}
+
+// binding ties a flag to its file key and environment variable.
+type binding struct {
+	flag string
+	key  string
+	env  string
+	// value formats the flag's current value so it can be set again.
+	value func() string
+}
+
+// Loader registers the server's flags and loads the configuration they,
+// the environment and the file combine to. It can load again to pick up
+// changes to the file.
+type Loader struct {
+	fs       FlagSet
+	path     string
+	current  Config
+	bindings []binding
+	// overrides holds the flags given on the command line, captured by the
+	// first Load.
+	overrides map[string]string
+}
+
+// NewLoader registers the server's flags, and --config, on fs.
+func NewLoader(fs FlagSet) *Loader {
+	l := &Loader{fs: fs, current: *Default()}
+	c := &l.current
+
+	fs.StringVar(&l.path, "config", os.Getenv("BRANCHLORE_CONFIG"), "YAML configuration file; flags and BRANCHLORE_* variables override it (default $BRANCHLORE_CONFIG)")
+
+	l.stringVar(&c.Listen.Port, "port", "listen.port", "Port to listen on")
+	l.stringVar(&c.DataDir, "data-dir", "data_dir", "Directory to store database files")
+	l.stringVar(&c.Log.Level, "log-level", "log.level", "Log level (debug, info, warn, error)")
+	l.stringVar(&c.Log.Format, "log-format", "log.format", "Log format (text, json)")
+	l.durationVar(&c.Limits.QueryTimeout, "query-timeout", "limits.query_timeout", "Default query timeout (0 disables)")
+	l.intVar(&c.Limits.MaxRows, "max-rows", "limits.max_rows", "Maximum rows in a buffered query response (0 disables)")
+	l.durationVar(&c.Limits.CursorIdleTimeout, "cursor-idle-timeout", "limits.cursor_idle_timeout", "Close paged query cursors after this much inactivity")
+	l.durationVar(&c.Limits.TxIdleTimeout, "tx-idle-timeout", "limits.tx_idle_timeout", "Roll back transactions after this much inactivity")
+	l.stringVar(&c.Listen.Postgres, "postgres-addr", "listen.postgres", "Serve the PostgreSQL wire protocol on this address, e.g. :5432")
+	l.stringVar(&c.Auth.PostgresPassword, "postgres-password", "auth.postgres_password", "Password PostgreSQL clients must send")
+	l.stringVar(&c.Listen.MySQL, "mysql-addr", "listen.mysql", "Serve the MySQL wire protocol on this address, e.g. :3306")
+	l.stringVar(&c.Auth.MySQLPassword, "mysql-password", "auth.mysql_password", "Password MySQL clients must send")
+	l.intVar(&c.Limits.MySQLMaxPacketMB, "mysql-max-packet-mb", "limits.mysql_max_packet_mb", "Close MySQL connections that send a packet larger than this many MiB (0 keeps the default of 64)")
+	l.stringVar(&c.Listen.GRPC, "grpc-addr", "listen.grpc", "Serve the gRPC API on this address, e.g. :9090")
+	l.boolVar(&c.Auth.Enabled, "auth", "auth.enabled", "Require an API token on every request (see branchlore token)")
+	l.stringVar(&c.TLS.Cert, "tls-cert", "tls.cert", "Serve every listener over TLS with this PEM certificate")
+	l.stringVar(&c.TLS.Key, "tls-key", "tls.key", "PEM private key for --tls-cert")
+	l.stringVar(&c.TLS.ClientCA, "tls-client-ca", "tls.client_ca", "Require client certificates signed by this PEM CA (mutual TLS)")
+	l.boolVar(&c.Audit, "audit", "audit", "Record every statement and branch operation in the audit log (see branchlore audit)")
+	l.float64Var(&c.Limits.TokenRate, "token-rate", "limits.token_rate", "Requests per second allowed per API token (0 disables)")
+	l.intVar(&c.Limits.TokenBurst, "token-burst", "limits.token_burst", "Requests an API token may make at once above --token-rate")
+	l.float64Var(&c.Limits.IPRate, "ip-rate", "limits.ip_rate", "Requests per second allowed per client IP address (0 disables)")
+	l.intVar(&c.Limits.IPBurst, "ip-burst", "limits.ip_burst", "Requests a client IP address may make at once above --ip-rate")
+	l.intVar(&c.Limits.MaxConcurrentQueries, "max-concurrent-queries", "limits.max_concurrent_queries", "Maximum requests and statements running at once per database (0 disables)")
+	l.intVar(&c.Quotas.DatabaseMB, "database-quota-mb", "quotas.database_mb", "Reject writes and new branches once a database takes this many MiB (0 disables)")
+	l.intVar(&c.Quotas.BranchMB, "branch-quota-mb", "quotas.branch_mb", "Reject writes once a branch's database file takes this many MiB (0 disables)")
+	l.intVar(&c.Pool.MaxOpenConns, "pool-max-open-conns", "pool.max_open_conns", "Maximum open connections per branch (0 is unlimited)")
+	l.intVar(&c.Pool.MaxIdleConns, "pool-max-idle-conns", "pool.max_idle_conns", "Maximum idle connections kept per branch (0 keeps the default of 2)")
+	l.durationVar(&c.Pool.ConnMaxIdleTime, "pool-conn-max-idle-time", "pool.conn_max_idle_time", "Close pooled connections idle this long (0 disables)")
+	l.intVar(&c.Health.MinFreeDiskMB, "min-free-disk-mb", "health.min_free_disk_mb", "Report not ready on /readyz below this much free disk space in MiB (0 disables)")
+	l.intVar(&c.Health.CheckSample, "health-check-sample", "health.check_sample", "Branches /readyz runs PRAGMA quick_check on per request (0 disables)")
+	l.durationVar(&c.Shutdown.Timeout, "shutdown-timeout", "shutdown.timeout", "How long shutdown waits for requests and transactions before rolling them back")
+	l.boolVar(&c.Shutdown.Commit, "commit-on-shutdown", "shutdown.commit", "Commit every branch written to when shutting down")
+	l.durationVar(&c.Janitor.Interval, "janitor-interval", "janitor.interval", "How often expired branches are deleted and unreachable history pruned (0 disables)")
+	l.durationVar(&c.Retention.EphemeralTTL, "ephemeral-ttl", "retention.ephemeral_ttl", "How long ephemeral branches created without a TTL live")
+	l.durationVar(&c.Retention.PruneGrace, "prune-grace", "retention.prune_grace", "How long the history of a deleted branch is kept before it is pruned")
+
+	return l
+}
+
+func (l *Loader) stringVar(p *string, flag, key, usage string) {
+	l.fs.StringVar(p, flag, *p, usage)
+	l.bind(flag, key, func() string { return *p })
+}
+
+func (l *Loader) boolVar(p *bool, flag, key, usage string) {
+	l.fs.BoolVar(p, flag, *p, usage)
+	l.bind(flag, key, func() string { return strconv.FormatBool(*p) })
+}
+
+func (l *Loader) intVar(p *int, flag, key, usage string) {
+	l.fs.IntVar(p, flag, *p, usage)
+	l.bind(flag, key, func() string { return strconv.Itoa(*p) })
+}
+
+func (l *Loader) float64Var(p *float64, flag, key, usage string) {
+	l.fs.Float64Var(p, flag, *p, usage)
+	l.bind(flag, key, func() string { return strconv.FormatFloat(*p, 'g', -1, 64) })
+}
+
+func (l *Loader) durationVar(p *time.Duration, flag, key, usage string) {
+	l.fs.DurationVar(p, flag, *p, usage)
+	l.bind(flag, key, func() string { return p.String() })
+}
+
+func (l *Loader) bind(flag, key string, value func() string) {
+	env := "BRANCHLORE_" + strings.ToUpper(strings.ReplaceAll(flag, "-", "_"))
+	l.bindings = append(l.bindings, binding{flag: flag, key: key, env: env, value: value})
+}
+
+// Path returns the configuration file, or "" when there is none.
+func (l *Loader) Path() string {
+	return l.path
+}
+
+// Load reads the configuration file, applies the environment and then the
+// flags the command line set, as reported by changed, and validates the
+// result. It must be called after the flags are parsed. Every problem is
+// reported in the returned error.
+func (l *Loader) Load(changed func(flag string) bool) (*Config, error) {
+	if l.overrides == nil {
+		l.overrides = make(map[string]string)
+		for _, b := range l.bindings {
+			if changed(b.flag) {
+				l.overrides[b.flag] = b.value()
+			}
+		}
+	}
+
+	next := Default()
+	if l.path != "" {
+		data, err := os.ReadFile(l.path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read config file: %w", err)
+		}
+		if err := yaml.UnmarshalStrict(data, next); err != nil {
+			return nil, fmt.Errorf("invalid config file %s: %w", l.path, err)
+		}
+	}
+
+	// The flags point into l.current, so setting them applies the
+	// environment and command line on top of the file.
+	l.current = *next
+	var errs []error
+	for _, b := range l.bindings {
+		if v, ok := l.overrides[b.flag]; ok {
+			errs = append(errs, l.fs.Set(b.flag, v))
+		} else if v, ok := os.LookupEnv(b.env); ok {
+			if err := l.fs.Set(b.flag, v); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", b.env, err))
+			}
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+
+	config := l.current
+	config.Databases = maps.Clone(config.Databases)
+	config.Roles = maps.Clone(config.Roles)
+	if err := config.validate(l.describe); err != nil {
+		return nil, fmt.Errorf("invalid configuration:\n%w", err)
+	}
+	return &config, nil
+}
+
+// describe names the setting at key along with its flag, if it has one.
+func (l *Loader) describe(key string) string {
+	for _, b := range l.bindings {
+		if b.key == key {
+			return fmt.Sprintf("%s (--%s)", key, b.flag)
+		}
+	}
+	return key
+}
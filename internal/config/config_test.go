@@ -0,0 +1,88 @@
+package config
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// load writes yaml to a config file and loads it with args on the command
+// line.
+func load(t *testing.T, yaml string, args ...string) (*Config, error) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "branchlore.yaml")
+	if err := os.WriteFile(path, []byte(yaml), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	l := NewLoader(fs)
+	if err := fs.Parse(append([]string{"--config", path}, args...)); err != nil {
+		t.Fatal(err)
+	}
+	set := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) { set[f.Name] = true })
+	return l.Load(func(name string) bool { return set[name] })
+}
+
+func TestLoadRetention(t *testing.T) {
+	cfg, err := load(t, "retention:\n  ephemeral_ttl: 2h\n  prune_grace: 10m\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := cfg.Server()
+	if s.EphemeralTTL != 2*time.Hour || s.PruneGrace != 10*time.Minute {
+		t.Errorf("retention = %s, %s, want 2h, 10m", s.EphemeralTTL, s.PruneGrace)
+	}
+
+	cfg, err = load(t, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := cfg.Server(); s.EphemeralTTL != 24*time.Hour || s.PruneGrace != time.Minute {
+		t.Errorf("default retention = %s, %s, want 24h, 1m", s.EphemeralTTL, s.PruneGrace)
+	}
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	t.Setenv("BRANCHLORE_MAX_ROWS", "20")
+	t.Setenv("BRANCHLORE_QUERY_TIMEOUT", "1m")
+	cfg, err := load(t, "limits:\n  max_rows: 10\n  query_timeout: 5s\n  tx_idle_timeout: 2m\n", "--query-timeout", "3s")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Limits.TxIdleTimeout != 2*time.Minute {
+		t.Errorf("tx_idle_timeout = %s, want the file's 2m", cfg.Limits.TxIdleTimeout)
+	}
+	if cfg.Limits.MaxRows != 20 {
+		t.Errorf("max_rows = %d, want the environment's 20", cfg.Limits.MaxRows)
+	}
+	if cfg.Limits.QueryTimeout != 3*time.Second {
+		t.Errorf("query_timeout = %s, want the flag's 3s", cfg.Limits.QueryTimeout)
+	}
+}
+
+func TestLoadRejectsInvalidSettings(t *testing.T) {
+	tests := []struct {
+		name string
+		yaml string
+		want string
+	}{
+		{"unknown key", "limits:\n  max_rowz: 1\n", "max_rowz"},
+		{"zero shutdown timeout", "shutdown:\n  timeout: 0s\n", "shutdown.timeout (--shutdown-timeout): must be positive"},
+		{"zero ephemeral ttl", "retention:\n  ephemeral_ttl: 0s\n", "retention.ephemeral_ttl (--ephemeral-ttl): must be positive"},
+		{"negative prune grace", "retention:\n  prune_grace: -1m\n", "retention.prune_grace (--prune-grace): must not be negative"},
+		{"negative rows", "limits:\n  max_rows: -1\n", "limits.max_rows (--max-rows): must not be negative"},
+		{"bad address", "listen:\n  mysql: nowhere\n", "listen.mysql"},
+		{"key without cert", "tls:\n  key: k.pem\n", "tls.cert"},
+	}
+	for _, tt := range tests {
+		_, err := load(t, tt.yaml)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: %v, want an error mentioning %q", tt.name, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/bxrne/branchlore/internal/server"
+)
+
+// Run loads the configuration and serves it until SIGINT or SIGTERM, then
+// shuts the server down within shutdown.timeout; a second signal cuts the
+// shutdown short. SIGHUP loads the configuration again and applies it. A
+// reload that fails is logged and the running configuration kept. changed
+// reports the flags set on the command line, as for Load.
+func (l *Loader) Run(changed func(flag string) bool) error {
+	cfg, err := l.Load(changed)
+	if err != nil {
+		return err
+	}
+
+	srv, err := server.New(cfg.Server())
+	if err != nil {
+		return fmt.Errorf("failed to create server: %w", err)
+	}
+
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(hup)
+	defer signal.Stop(c)
+
+	started := make(chan error, 1)
+	go func() {
+		started <- srv.Start()
+	}()
+	l.printStartup(cfg)
+
+wait:
+	for {
+		select {
+		case err := <-started:
+			// Start only returns early when it failed to listen.
+			srv.Shutdown(context.Background())
+			return fmt.Errorf("server failed to start: %w", err)
+		case <-hup:
+			next, err := l.Load(changed)
+			if err == nil {
+				err = srv.Reload(next.Server())
+			}
+			if err != nil {
+				log.Printf("Failed to reload configuration: %v", err)
+				continue
+			}
+			cfg = next
+		case <-c:
+			break wait
+		}
+	}
+
+	fmt.Println("\nShutting down server (interrupt again to stop immediately)...")
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Shutdown.Timeout)
+	defer cancel()
+	go func() {
+		<-c
+		cancel()
+	}()
+	if err := srv.Shutdown(ctx); err != nil {
+		return fmt.Errorf("failed to shut down cleanly: %w", err)
+	}
+	return nil
+}
+
+// printStartup reports what the server serves.
+func (l *Loader) printStartup(cfg *Config) {
+	fmt.Printf("BranchLore server starting on port %s\n", cfg.Listen.Port)
+	if l.path != "" {
+		fmt.Printf("Configuration loaded from %s (send SIGHUP to reload)\n", l.path)
+	}
+	if cfg.Listen.Postgres != "" {
+		fmt.Printf("PostgreSQL protocol listening on %s\n", cfg.Listen.Postgres)
+	}
+	if cfg.Listen.MySQL != "" {
+		fmt.Printf("MySQL protocol listening on %s\n", cfg.Listen.MySQL)
+	}
+	if cfg.Listen.GRPC != "" {
+		fmt.Printf("gRPC API listening on %s\n", cfg.Listen.GRPC)
+	}
+	if cfg.Auth.Enabled {
+		fmt.Println("Token authentication enabled")
+	}
+	if cfg.TLS.ClientCA != "" {
+		fmt.Println("Mutual TLS enabled")
+	} else if cfg.TLS.Cert != "" {
+		fmt.Println("TLS enabled")
+	}
+	if cfg.Audit {
+		fmt.Println("Audit log enabled")
+	}
+	fmt.Printf("Data directory: %s\n", cfg.DataDir)
+}
@@ -0,0 +1,232 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/bxrne/branchlore/internal/git"
+)
+
+// CreateBranch creates branch as a copy of main.
+func (m *Manager) CreateBranch(ctx context.Context, dbName, branch string) error {
+	return m.ForkBranch(ctx, dbName, branch, "main", git.BranchMeta{})
+}
+
+// ForkBranch creates branch as a copy of from. The copy is taken under a
+// write lock on from, so it is consistent even while from is being written.
+// It fails with ErrQuotaExceeded when the copy would take the database or
+// the new branch over its quota. meta sets when the new branch expires.
+func (m *Manager) ForkBranch(ctx context.Context, dbName, branch, from string, meta git.BranchMeta) error {
+	return m.withWriteLock(ctx, dbName, from, func() error {
+		if err := m.checkFork(dbName, branch, from); err != nil {
+			return err
+		}
+		return m.gitMgr.ForkBranch(dbName, branch, from, meta)
+	})
+}
+
+// CommitBranch commits the current state of dbName@branch to its git branch
+// and returns the commit hash.
+func (m *Manager) CommitBranch(ctx context.Context, dbName, branch, message string) (string, error) {
+	var hash string
+	err := m.withWriteLock(ctx, dbName, branch, func() error {
+		var err error
+		hash, err = m.gitMgr.CommitBranch(dbName, branch, message)
+		return err
+	})
+	return hash, err
+}
+
+// withWriteLock runs fn while holding SQLite's reserved lock on
+// dbName@branch. Readers carry on, but no writer can change the file until
+// fn returns.
+func (m *Manager) withWriteLock(ctx context.Context, dbName, branch string, fn func() error) error {
+	// The lock needs a writable connection even for callers that may only
+	// read the branch's data.
+	db, err := m.conn(context.WithValue(ctx, readOnlyKey{}, false), dbName, branch)
+	if err != nil {
+		return err
+	}
+
+	c, err := db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get connection: %w", err)
+	}
+	defer c.Close()
+
+	if err := beginCheckpointed(ctx, c, map[string]string{"main": m.gitMgr.GetBranchPath(dbName, branch)}); err != nil {
+		return err
+	}
+	defer c.ExecContext(context.Background(), "ROLLBACK")
+
+	return fn()
+}
+
+// beginCheckpointed begins an immediate transaction on c once the
+// write-ahead logs of files, keyed by schema name, are empty. A branch put
+// in WAL mode keeps its latest writes in the -wal file beside it, where
+// copies and commits of the file alone would miss them.
+func beginCheckpointed(ctx context.Context, c *sql.Conn, files map[string]string) error {
+	for {
+		// A checkpoint cannot run inside a transaction, so a write can land
+		// between it and BEGIN. Then the log is checkpointed again.
+		for schema := range files {
+			if _, err := c.ExecContext(ctx, "PRAGMA "+schema+".wal_checkpoint(TRUNCATE)"); err != nil {
+				return fmt.Errorf("failed to checkpoint %s: %w", schema, err)
+			}
+		}
+		if _, err := c.ExecContext(ctx, "BEGIN IMMEDIATE"); err != nil {
+			return err
+		}
+		checkpointed := true
+		for _, path := range files {
+			if info, err := os.Stat(path + "-wal"); err == nil && info.Size() > 0 {
+				checkpointed = false
+			}
+		}
+		if checkpointed {
+			return nil
+		}
+		c.ExecContext(context.Background(), "ROLLBACK")
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+	}
+}
+
+// Diff summarises how the head branch differs from the base branch.
+type Diff struct {
+	Base   string      `json:"base"`
+	Head   string      `json:"head"`
+	Tables []TableDiff `json:"tables"`
+}
+
+// TableDiff describes one table that differs between two branches. Rows are
+// compared as distinct values, so a changed row counts as one removed and
+// one added. Row counts are not given for tables whose schema changed.
+type TableDiff struct {
+	Name string `json:"name"`
+	// Change is "added", "removed" or "schema_changed", or empty when only
+	// rows differ.
+	Change      string `json:"change,omitempty"`
+	RowsAdded   int64  `json:"rows_added"`
+	RowsRemoved int64  `json:"rows_removed"`
+}
+
+// DiffBranches compares the tables of dbName@head against dbName@base.
+func (m *Manager) DiffBranches(ctx context.Context, dbName, base, head string) (*Diff, error) {
+	branches, err := m.gitMgr.ListBranches(dbName)
+	if err != nil {
+		return nil, err
+	}
+	if !slices.Contains(branches, base) {
+		return nil, fmt.Errorf("%w: %s", git.ErrBranchNotFound, base)
+	}
+
+	db, err := m.internalConn(ctx, dbName, head)
+	if err != nil {
+		return nil, err
+	}
+
+	c, err := db.Conn(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get connection: %w", err)
+	}
+	defer c.Close()
+
+	if _, err := c.ExecContext(ctx, "ATTACH DATABASE ? AS diff_base", m.gitMgr.GetBranchPath(dbName, base)); err != nil {
+		return nil, fmt.Errorf("failed to attach base branch: %w", err)
+	}
+	defer c.ExecContext(context.Background(), "DETACH DATABASE diff_base")
+
+	tables, err := diffTables(ctx, c, "main", "diff_base")
+	if err != nil {
+		return nil, err
+	}
+	return &Diff{Base: base, Head: head, Tables: tables}, nil
+}
+
+// diffTables compares the tables of the head and base schemas attached to c.
+func diffTables(ctx context.Context, c *sql.Conn, head, base string) ([]TableDiff, error) {
+	headTables, err := tableSchemas(ctx, c, head)
+	if err != nil {
+		return nil, err
+	}
+	baseTables, err := tableSchemas(ctx, c, base)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(headTables)+len(baseTables))
+	for name := range headTables {
+		names = append(names, name)
+	}
+	for name := range baseTables {
+		if _, ok := headTables[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+
+	diffs := []TableDiff{}
+	for _, name := range names {
+		headSQL, inHead := headTables[name]
+		baseSQL, inBase := baseTables[name]
+		headTable, baseTable := head+"."+quoteIdent(name), base+"."+quoteIdent(name)
+
+		td := TableDiff{Name: name}
+		switch {
+		case !inBase:
+			td.Change = "added"
+			err = countRows(ctx, c, &td.RowsAdded, "SELECT count(*) FROM "+headTable)
+		case !inHead:
+			td.Change = "removed"
+			err = countRows(ctx, c, &td.RowsRemoved, "SELECT count(*) FROM "+baseTable)
+		case headSQL != baseSQL:
+			td.Change = "schema_changed"
+		default:
+			err = countRows(ctx, c, &td.RowsAdded, "SELECT count(*) FROM (SELECT * FROM "+headTable+" EXCEPT SELECT * FROM "+baseTable+")")
+			if err == nil {
+				err = countRows(ctx, c, &td.RowsRemoved, "SELECT count(*) FROM (SELECT * FROM "+baseTable+" EXCEPT SELECT * FROM "+headTable+")")
+			}
+			if err == nil && td.RowsAdded == 0 && td.RowsRemoved == 0 {
+				continue
+			}
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to compare table %s: %w", name, err)
+		}
+		diffs = append(diffs, td)
+	}
+	return diffs, nil
+}
+
+func tableSchemas(ctx context.Context, c *sql.Conn, schema string) (map[string]string, error) {
+	rows, err := c.QueryContext(ctx, "SELECT name, sql FROM "+schema+".sqlite_master WHERE type = 'table' AND name NOT LIKE 'sqlite_%'")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s schema: %w", schema, err)
+	}
+	defer rows.Close()
+
+	tables := make(map[string]string)
+	for rows.Next() {
+		var name, schemaSQL string
+		if err := rows.Scan(&name, &schemaSQL); err != nil {
+			return nil, err
+		}
+		tables[name] = schemaSQL
+	}
+	return tables, rows.Err()
+}
+
+func countRows(ctx context.Context, c *sql.Conn, n *int64, query string) error {
+	return c.QueryRowContext(ctx, query).Scan(n)
+}
+
+func quoteIdent(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
@@ -0,0 +1,83 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+)
+
+// Statement describes a prepared statement without running it.
+type Statement struct {
+	NumParams int
+	Columns   []string
+	// ColumnTypes holds the declared type of each column, "" for
+	// expressions.
+	ColumnTypes []string
+}
+
+// Describe prepares query against dbName@branch and reports its parameters
+// and, for row returning statements, its result columns.
+func (m *Manager) Describe(ctx context.Context, dbName, branch, query string) (*Statement, error) {
+	db, err := m.conn(ctx, dbName, branch)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	return describe(ctx, conn, query)
+}
+
+// describe relies on the SQLite driver binding a statement on Query but only
+// stepping it on the first Next, so reading the columns runs nothing.
+func describe(ctx context.Context, conn *sql.Conn, query string) (*Statement, error) {
+	stmt := &Statement{}
+	err := conn.Raw(func(dc interface{}) error {
+		prep, ok := dc.(driver.ConnPrepareContext)
+		if !ok {
+			return fmt.Errorf("driver cannot prepare statements")
+		}
+		ds, err := prep.PrepareContext(ctx, query)
+		if err != nil {
+			return err
+		}
+		defer ds.Close()
+
+		stmt.NumParams = ds.NumInput()
+		if !IsSelect(query) {
+			return nil
+		}
+
+		q, ok := ds.(driver.StmtQueryContext)
+		if !ok {
+			return fmt.Errorf("driver cannot describe statements")
+		}
+		args := make([]driver.NamedValue, stmt.NumParams)
+		for i := range args {
+			args[i].Ordinal = i + 1
+		}
+		rows, err := q.QueryContext(ctx, args)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		stmt.Columns = rows.Columns()
+		stmt.ColumnTypes = make([]string, len(stmt.Columns))
+		if typed, ok := rows.(driver.RowsColumnTypeDatabaseTypeName); ok {
+			for i := range stmt.ColumnTypes {
+				stmt.ColumnTypes[i] = typed.ColumnTypeDatabaseTypeName(i)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return stmt, nil
+}
@@ -0,0 +1,33 @@
+package database
+
+import (
+	"database/sql"
+
+	sqlite3 "github.com/mattn/go-sqlite3"
+)
+
+// userDriver opens the connections that run clients' statements. Access
+// control is per branch, so those connections cannot attach other files:
+// ATTACH would reach other databases and branches, and VACUUM INTO, which
+// attaches its target, would write a copy anywhere the server can.
+const userDriver = "sqlite3_branchlore"
+
+func init() {
+	sql.Register(userDriver, &sqlite3.SQLiteDriver{
+		ConnectHook: func(conn *sqlite3.SQLiteConn) error {
+			conn.RegisterAuthorizer(authorize)
+			return nil
+		},
+	})
+}
+
+// authorize denies every ATTACH. The file name it is given is empty when
+// the statement computes it, so no attachment can be told safe, including
+// the temporary database a plain VACUUM attaches. Those run on the
+// manager's own pool instead; see isVacuum.
+func authorize(action int, arg1, arg2, dbName string) int {
+	if action == sqlite3.SQLITE_ATTACH {
+		return sqlite3.SQLITE_DENY
+	}
+	return sqlite3.SQLITE_OK
+}
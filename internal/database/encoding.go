@@ -0,0 +1,202 @@
+package database
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	sqlite3 "github.com/mattn/go-sqlite3"
+)
+
+var ErrInvalidArgument = errors.New("invalid argument")
+
+// Result formats understood by ExecuteQuery and OpenCursor.
+const (
+	// FormatSimple encodes values as plain JSON scalars. BLOBs are rendered
+	// as strings and integers as JSON numbers.
+	FormatSimple = "simple"
+	// FormatTyped encodes every non-NULL value as a TypedValue so BLOBs and
+	// 64-bit integers survive the round trip.
+	FormatTyped = "typed"
+)
+
+// SQLite storage classes reported in TypedValue.Type.
+const (
+	TypeInteger = "INTEGER"
+	TypeReal    = "REAL"
+	TypeText    = "TEXT"
+	TypeBlob    = "BLOB"
+)
+
+// TypedValue is the lossless encoding of a single non-NULL SQLite value.
+// INTEGER values are decimal strings, REAL values JSON numbers (or the strings
+// "Infinity" and "-Infinity"), TEXT values strings and BLOB values standard
+// base64. NULL is encoded as a bare JSON null instead of a TypedValue.
+type TypedValue struct {
+	Type  string      `json:"type"`
+	Value interface{} `json:"value"`
+}
+
+func ValidFormat(format string) bool {
+	return format == "" || format == FormatSimple || format == FormatTyped
+}
+
+func encodeValue(val interface{}, format string) interface{} {
+	if format == FormatTyped {
+		return encodeTyped(val)
+	}
+
+	switch v := val.(type) {
+	case []byte:
+		return string(v)
+	case float64:
+		if math.IsInf(v, 0) {
+			return encodeTyped(v).(TypedValue).Value
+		}
+		return v
+	default:
+		return v
+	}
+}
+
+func encodeTyped(val interface{}) interface{} {
+	switch v := val.(type) {
+	case nil:
+		return nil
+	case int64:
+		return TypedValue{Type: TypeInteger, Value: strconv.FormatInt(v, 10)}
+	case bool:
+		if v {
+			return TypedValue{Type: TypeInteger, Value: "1"}
+		}
+		return TypedValue{Type: TypeInteger, Value: "0"}
+	case float64:
+		switch {
+		case math.IsInf(v, 1):
+			return TypedValue{Type: TypeReal, Value: "Infinity"}
+		case math.IsInf(v, -1):
+			return TypedValue{Type: TypeReal, Value: "-Infinity"}
+		}
+		return TypedValue{Type: TypeReal, Value: v}
+	case string:
+		return TypedValue{Type: TypeText, Value: v}
+	case []byte:
+		return TypedValue{Type: TypeBlob, Value: base64.StdEncoding.EncodeToString(v)}
+	case time.Time:
+		// Typed cursors read converted columns raw (see rawValuesQuery), so
+		// this is only reached when that was not possible.
+		return TypedValue{Type: TypeText, Value: v.Format(sqlite3.SQLiteTimestampFormats[0])}
+	default:
+		return TypedValue{Type: TypeText, Value: fmt.Sprint(v)}
+	}
+}
+
+// convertedTypes are the declared column types whose values the SQLite
+// driver converts: INTEGER and TEXT values of dates and times to time.Time,
+// INTEGER values of booleans to bool.
+var convertedTypes = []string{"DATE", "DATETIME", "TIMESTAMP", "BOOLEAN"}
+
+// rawValuesQuery wraps query so that each of its columns is an expression,
+// which SQLite reports without a declared type, so the driver hands back
+// the values as stored. The unary plus changes neither value nor storage
+// class, and the columns keep their names.
+func rawValuesQuery(query string, columns []string) string {
+	var b strings.Builder
+	b.WriteString("WITH branchlore_raw(")
+	for i := range columns {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		fmt.Fprintf(&b, "c%d", i)
+	}
+	// The newline ends a trailing line comment in query.
+	b.WriteString(") AS (\n")
+	b.WriteString(strings.TrimRight(query, "; \t\r\n"))
+	b.WriteString("\n) SELECT ")
+	for i, name := range columns {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		fmt.Fprintf(&b, "+c%d AS \"%s\"", i, strings.ReplaceAll(name, `"`, `""`))
+	}
+	b.WriteString(" FROM branchlore_raw")
+	return b.String()
+}
+
+// DecodeArgs converts statement arguments decoded from JSON (with UseNumber)
+// into values the SQLite driver can bind. Each argument is either a plain
+// JSON scalar or a TypedValue object.
+func DecodeArgs(raw []interface{}) ([]interface{}, error) {
+	args := make([]interface{}, len(raw))
+	for i, v := range raw {
+		arg, err := decodeArg(v)
+		if err != nil {
+			return nil, fmt.Errorf("%w: argument %d: %v", ErrInvalidArgument, i+1, err)
+		}
+		args[i] = arg
+	}
+	return args, nil
+}
+
+func decodeArg(v interface{}) (interface{}, error) {
+	switch v := v.(type) {
+	case nil, bool, string, float64:
+		return v, nil
+	case json.Number:
+		if n, err := v.Int64(); err == nil {
+			return n, nil
+		}
+		return v.Float64()
+	case map[string]interface{}:
+		return decodeTyped(v)
+	}
+	return nil, fmt.Errorf("unsupported value %v", v)
+}
+
+func decodeTyped(v map[string]interface{}) (interface{}, error) {
+	if len(v) != 2 {
+		return nil, fmt.Errorf("typed value needs exactly \"type\" and \"value\"")
+	}
+
+	value := v["value"]
+	switch v["type"] {
+	case TypeInteger:
+		switch n := value.(type) {
+		case string:
+			return strconv.ParseInt(n, 10, 64)
+		case json.Number:
+			return n.Int64()
+		}
+	case TypeReal:
+		switch n := value.(type) {
+		case string:
+			switch n {
+			case "Infinity":
+				return math.Inf(1), nil
+			case "-Infinity":
+				return math.Inf(-1), nil
+			}
+			return strconv.ParseFloat(n, 64)
+		case json.Number:
+			return n.Float64()
+		case float64:
+			return n, nil
+		}
+	case TypeText:
+		if s, ok := value.(string); ok {
+			return s, nil
+		}
+	case TypeBlob:
+		if s, ok := value.(string); ok {
+			return base64.StdEncoding.DecodeString(s)
+		}
+	default:
+		return nil, fmt.Errorf("unknown type %v", v["type"])
+	}
+	return nil, fmt.Errorf("invalid %v value %v", v["type"], value)
+}
@@ -0,0 +1,146 @@
+package database
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// typedQuery runs query on db@main in FormatTyped and returns its rows.
+func typedQuery(t *testing.T, m *Manager, query string, args ...interface{}) QueryResult {
+	t.Helper()
+	out, err := m.ExecuteQuery(context.Background(), "db", "main", query, args, QueryOptions{Format: FormatTyped})
+	if err != nil {
+		t.Fatalf("%s: %v", query, err)
+	}
+	var result QueryResult
+	if err := json.Unmarshal(out, &result); err != nil {
+		t.Fatal(err)
+	}
+	return result
+}
+
+// typed is the JSON decoding of a TypedValue.
+func typed(typ string, value interface{}) interface{} {
+	return map[string]interface{}{"type": typ, "value": value}
+}
+
+func TestTypedValuesRoundTrip(t *testing.T) {
+	m := newTestManager(t)
+	mustExec(t, m, "main", "CREATE TABLE t (i INTEGER, r REAL, s TEXT, b BLOB, n)")
+
+	decoder := json.NewDecoder(strings.NewReader(`[
+		{"type": "INTEGER", "value": "9223372036854775807"},
+		{"type": "REAL", "value": "-Infinity"},
+		{"type": "TEXT", "value": "héllo"},
+		{"type": "BLOB", "value": "AAH/"},
+		null
+	]`))
+	decoder.UseNumber()
+	var raw []interface{}
+	if err := decoder.Decode(&raw); err != nil {
+		t.Fatal(err)
+	}
+	args, err := DecodeArgs(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Exec(context.Background(), "db", "main", "INSERT INTO t VALUES (?, ?, ?, ?, ?)", args); err != nil {
+		t.Fatal(err)
+	}
+
+	result := typedQuery(t, m, "SELECT i, r, s, b, n FROM t")
+	want := []interface{}{
+		typed(TypeInteger, "9223372036854775807"),
+		typed(TypeReal, "-Infinity"),
+		typed(TypeText, "héllo"),
+		typed(TypeBlob, "AAH/"),
+		nil,
+	}
+	if len(result.Rows) != 1 || !reflect.DeepEqual(result.Rows[0], want) {
+		t.Fatalf("got %v, want %v", result.Rows, want)
+	}
+	if want := []string{"INTEGER", "REAL", "TEXT", "BLOB", ""}; !reflect.DeepEqual(result.ColumnTypes, want) {
+		t.Errorf("column types %q, want %q", result.ColumnTypes, want)
+	}
+}
+
+func TestTypedValuesOfConvertedColumnsAsStored(t *testing.T) {
+	m := newTestManager(t)
+	mustExec(t, m, "main",
+		"CREATE TABLE t (d DATE, ts TIMESTAMP, ok BOOLEAN)",
+		"INSERT INTO t VALUES ('2024-02-30', 1700000000, 2)",
+		"INSERT INTO t VALUES ('2024-01-02 03:04:05.123456789+02:00', '2024-01-02', 0)",
+	)
+
+	want := [][]interface{}{
+		{typed(TypeText, "2024-02-30"), typed(TypeInteger, "1700000000"), typed(TypeInteger, "2")},
+		{typed(TypeText, "2024-01-02 03:04:05.123456789+02:00"), typed(TypeText, "2024-01-02"), typed(TypeInteger, "0")},
+	}
+	for _, query := range []string{
+		"SELECT d, ts, ok FROM t ORDER BY rowid",
+		"-- trailing comment\nSELECT d, ts, ok FROM t ORDER BY rowid -- done",
+	} {
+		result := typedQuery(t, m, query)
+		if !reflect.DeepEqual(result.Rows, want) {
+			t.Errorf("%q: got %v, want %v", query, result.Rows, want)
+		}
+		if want := []string{"DATE", "TIMESTAMP", "BOOLEAN"}; !reflect.DeepEqual(result.ColumnTypes, want) {
+			t.Errorf("%q: column types %q, want %q", query, result.ColumnTypes, want)
+		}
+	}
+}
+
+func TestSimpleValues(t *testing.T) {
+	for _, tt := range []struct {
+		in   interface{}
+		want interface{}
+	}{
+		{int64(7), int64(7)},
+		{[]byte("abc"), "abc"},
+		{math.Inf(1), "Infinity"},
+		{1.5, 1.5},
+		{nil, nil},
+	} {
+		if got := encodeValue(tt.in, FormatSimple); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("encodeValue(%v) = %#v, want %#v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestDecodeArgs(t *testing.T) {
+	args, err := DecodeArgs([]interface{}{json.Number("3"), json.Number("2.5"), "s", true, nil,
+		map[string]interface{}{"type": TypeInteger, "value": json.Number("42")},
+		map[string]interface{}{"type": TypeBlob, "value": "AQI="},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []interface{}{int64(3), 2.5, "s", true, nil, int64(42), []byte{1, 2}}
+	for i := range want {
+		if b, ok := want[i].([]byte); ok {
+			if got, _ := args[i].([]byte); !bytes.Equal(got, b) {
+				t.Errorf("argument %d = %v, want %v", i+1, args[i], b)
+			}
+		} else if args[i] != want[i] {
+			t.Errorf("argument %d = %#v, want %#v", i+1, args[i], want[i])
+		}
+	}
+
+	for _, bad := range []interface{}{
+		map[string]interface{}{"type": "DATE", "value": "2024-01-01"},
+		map[string]interface{}{"type": TypeInteger, "value": "1.5"},
+		map[string]interface{}{"type": TypeBlob, "value": "not base64!"},
+		map[string]interface{}{"type": TypeText},
+		[]interface{}{1},
+	} {
+		if _, err := DecodeArgs([]interface{}{bad}); !errors.Is(err, ErrInvalidArgument) {
+			t.Errorf("DecodeArgs(%v) = %v, want ErrInvalidArgument", bad, err)
+		}
+	}
+}
@@ -4,128 +4,675 @@ import (
 	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log/slog"
+	"slices"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/bxrne/branchlore/internal/git"
 	_ "github.com/mattn/go-sqlite3"
 )
 
+// readOnlySuffix keys the read-only pool of a branch, and internalSuffix
+// the pool the manager itself uses for merges and diffs. Branch names
+// cannot contain a colon, so they never collide with another branch.
+const (
+	readOnlySuffix = ":ro"
+	internalSuffix = ":internal"
+)
+
+// defaultMaxIdleConns is database/sql's default idle connection limit.
+const defaultMaxIdleConns = 2
+
+// ErrCorrupt is returned when a branch's database file fails an integrity
+// check.
+var ErrCorrupt = errors.New("database file is corrupt")
+
 type Manager struct {
-	gitMgr *git.Manager
-	conns  map[string]*sql.DB
+	gitMgr    *git.Manager
+	logger    *slog.Logger
+	mu        sync.Mutex
+	conns     map[string]*sql.DB
+	pool      PoolConfig
+	observers []func(context.Context, Execution)
+	quota     func(dbName, branch string) Quota
 }
 
 func NewManager(dataDir string, gitMgr *git.Manager) (*Manager, error) {
 	return &Manager{
 		gitMgr: gitMgr,
+		logger: slog.New(slog.DiscardHandler),
 		conns:  make(map[string]*sql.DB),
 	}, nil
 }
 
+// SetLogger makes the manager log connection pools and merges with logger.
+// By default it logs nothing.
+func (m *Manager) SetLogger(logger *slog.Logger) {
+	m.logger = logger
+}
+
+// PoolConfig bounds the connection pool of each branch. Zero fields leave
+// database/sql's defaults: unlimited open connections, two idle ones and no
+// idle timeout.
+type PoolConfig struct {
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxIdleTime time.Duration
+}
+
+// SetPool applies pool to every open connection pool and those opened later.
+func (m *Manager) SetPool(pool PoolConfig) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.pool = pool
+	for _, db := range m.conns {
+		m.configurePool(db)
+	}
+}
+
+// configurePool applies m.pool to db. m.mu must be held.
+func (m *Manager) configurePool(db *sql.DB) {
+	idle := m.pool.MaxIdleConns
+	if idle == 0 {
+		idle = defaultMaxIdleConns
+	}
+	db.SetMaxOpenConns(m.pool.MaxOpenConns)
+	db.SetMaxIdleConns(idle)
+	db.SetConnMaxIdleTime(m.pool.ConnMaxIdleTime)
+}
+
+// Execution describes a statement run through the manager or one of its
+// transactions.
+type Execution struct {
+	Database string
+	Branch   string
+	// Query is the statement, or BEGIN, COMMIT or ROLLBACK for the
+	// transaction boundaries.
+	Query        string
+	Start        time.Time
+	Duration     time.Duration
+	RowsAffected int64
+	Err          error
+}
+
+// Observe registers fn to be called with the context of every statement
+// after it runs. For cursors that is once the first row is ready. It must
+// be called before the manager is used.
+func (m *Manager) Observe(fn func(context.Context, Execution)) {
+	m.observers = append(m.observers, fn)
+}
+
+// observe reports a statement that started at start to the observers.
+func (m *Manager) observe(ctx context.Context, dbName, branch, query string, start time.Time, rowsAffected int64, err error) {
+	if len(m.observers) == 0 {
+		return
+	}
+	e := Execution{
+		Database:     dbName,
+		Branch:       branch,
+		Query:        query,
+		Start:        start,
+		Duration:     time.Since(start),
+		RowsAffected: rowsAffected,
+		Err:          err,
+	}
+	for _, fn := range m.observers {
+		fn(ctx, e)
+	}
+}
+
 type QueryResult struct {
-	Columns []string        `json:"columns"`
-	Rows    [][]interface{} `json:"rows"`
-	Error   string          `json:"error,omitempty"`
+	Columns     []string        `json:"columns"`
+	ColumnTypes []string        `json:"column_types,omitempty"`
+	Rows        [][]interface{} `json:"rows"`
+	Truncated   bool            `json:"truncated,omitempty"`
+	Cursor      string          `json:"cursor,omitempty"`
+}
+
+type ModifyResult struct {
+	RowsAffected int64 `json:"rows_affected"`
+	LastInsertID int64 `json:"last_insert_id"`
+}
+
+type QueryOptions struct {
+	// MaxRows cuts SELECT results off after this many rows when > 0.
+	MaxRows int
+	// MaxBytes cuts SELECT results off once their values take up this many
+	// bytes when > 0.
+	MaxBytes int64
+	// Format is FormatSimple (the default) or FormatTyped.
+	Format string
+}
+
+// queryer is satisfied by both *sql.DB and *sql.Tx.
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+// ExecuteQuery runs query with args bound to its placeholders against
+// dbName@branch and returns the JSON encoded result. SQLite failures are
+// returned as *sqlite3.Error values.
+func (m *Manager) ExecuteQuery(ctx context.Context, dbName, branch, query string, args []interface{}, opts QueryOptions) ([]byte, error) {
+	start := time.Now()
+	db, err := m.execConn(ctx, dbName, branch, query)
+	if err != nil {
+		return nil, err
+	}
+	if err := m.checkQuota(ctx, dbName, branch, query); err != nil {
+		m.observe(ctx, dbName, branch, query, start, 0, err)
+		return nil, err
+	}
+	result, rowsAffected, err := execute(ctx, db, query, args, opts)
+	m.observe(ctx, dbName, branch, query, start, rowsAffected, err)
+	return result, err
 }
 
-func (m *Manager) ExecuteQuery(ctx context.Context, dbName, branch, query string) ([]byte, error) {
-	if !m.gitMgr.BranchExists(dbName, branch) {
-		return nil, fmt.Errorf("branch %s does not exist", branch)
+// execute runs query and returns its JSON encoded result and, for
+// statements that modify, the number of rows affected.
+func execute(ctx context.Context, q queryer, query string, args []interface{}, opts QueryOptions) ([]byte, int64, error) {
+	query = strings.TrimSpace(query)
+	if IsSelect(query) {
+		result, err := executeSelect(ctx, q, query, args, opts)
+		return result, 0, err
+	} else {
+		return executeModify(ctx, q, query, args)
 	}
+}
+
+// IsSelect reports whether query is a row returning statement: a SELECT,
+// VALUES, PRAGMA or EXPLAIN, a WITH whose main statement is a SELECT or
+// VALUES, or a write with a RETURNING clause. Leading comments and the
+// whitespace between words are ignored.
+func IsSelect(query string) bool {
+	return reads(query) || returning(query)
+}
+
+// DB returns the pooled connection to dbName@branch. It is owned by the
+// manager and must not be closed.
+func (m *Manager) DB(dbName, branch string) (*sql.DB, error) {
+	return m.conn(context.Background(), dbName, branch)
+}
+
+type readOnlyKey struct{}
 
-	dbPath := m.gitMgr.GetBranchPath(dbName, branch)
+// WithReadOnly marks ctx so that statements run with it use a read-only
+// connection, on which any write fails with SQLITE_READONLY.
+func WithReadOnly(ctx context.Context) context.Context {
+	return context.WithValue(ctx, readOnlyKey{}, true)
+}
+
+// IsReadOnly reports whether ctx was marked by WithReadOnly.
+func IsReadOnly(ctx context.Context) bool {
+	readOnly, _ := ctx.Value(readOnlyKey{}).(bool)
+	return readOnly
+}
+
+// sqliteURIEscaper escapes the characters a file name cannot hold in a
+// SQLite URI.
+var sqliteURIEscaper = strings.NewReplacer("%", "%25", "?", "%3f", "#", "%23")
+
+// FileURI returns the SQLite URI of the file at path, to which query
+// parameters can be appended after a '?'.
+func FileURI(path string) string {
+	return "file:" + sqliteURIEscaper.Replace(path)
+}
+
+// conn returns the pool that runs clients' statements on dbName@branch,
+// read-only if ctx is marked so. Its connections cannot attach other files.
+func (m *Manager) conn(ctx context.Context, dbName, branch string) (*sql.DB, error) {
+	return m.open(ctx, dbName, branch, false)
+}
+
+// internalConn returns the pool the manager uses for statements of its own
+// on dbName@branch, which may attach other branches.
+func (m *Manager) internalConn(ctx context.Context, dbName, branch string) (*sql.DB, error) {
+	return m.open(ctx, dbName, branch, true)
+}
+
+// execConn returns the pool to run query on: the internal one for a plain
+// VACUUM, which user connections cannot run, and conn's otherwise.
+func (m *Manager) execConn(ctx context.Context, dbName, branch, query string) (*sql.DB, error) {
+	return m.open(ctx, dbName, branch, !IsReadOnly(ctx) && isVacuum(query))
+}
+
+// open returns a pool on dbName@branch. The branch is looked up under m.mu,
+// which DeleteBranch holds throughout, so no pool outlives its branch.
+func (m *Manager) open(ctx context.Context, dbName, branch string, internal bool) (*sql.DB, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	branches, err := m.gitMgr.ListBranches(dbName)
+	if err != nil {
+		return nil, err
+	}
+	if !slices.Contains(branches, branch) {
+		return nil, fmt.Errorf("%w: %s", git.ErrBranchNotFound, branch)
+	}
 
 	connKey := fmt.Sprintf("%s@%s", dbName, branch)
+	driver, dsn := userDriver, m.gitMgr.GetBranchPath(dbName, branch)
+	switch {
+	case internal:
+		connKey += internalSuffix
+		driver = "sqlite3"
+	case IsReadOnly(ctx):
+		connKey += readOnlySuffix
+		dsn = FileURI(dsn) + "?mode=ro"
+	}
 	db, exists := m.conns[connKey]
 	if !exists {
-		var err error
-		db, err = sql.Open("sqlite3", dbPath)
+		db, err = sql.Open(driver, dsn)
 		if err != nil {
 			return nil, fmt.Errorf("failed to open database: %w", err)
 		}
+		m.configurePool(db)
 		m.conns[connKey] = db
+		m.logger.DebugContext(ctx, "Opened connection pool", slog.String("db", dbName), slog.String("branch", branch),
+			slog.Bool("read_only", IsReadOnly(ctx) && !internal), slog.Bool("internal", internal))
 	}
+	return db, nil
+}
 
-	query = strings.TrimSpace(query)
-	if strings.ToUpper(strings.Split(query, " ")[0]) == "SELECT" {
-		return m.executeSelect(db, query)
-	} else {
-		return m.executeModify(db, query)
-	}
+// Cursor iterates over the rows of a SELECT without buffering them, so large
+// results can be streamed or paged to the client.
+type Cursor struct {
+	rows        *sql.Rows
+	format      string
+	columns     []string
+	columnTypes []string
+	values      []interface{}
+	ptrs        []interface{}
+
+	maxRows   int
+	maxBytes  int64
+	rowsRead  int
+	bytesRead int64
+	stopped   bool
+	truncated bool
 }
 
-func (m *Manager) executeSelect(db *sql.DB, query string) ([]byte, error) {
-	rows, err := db.Query(query)
+// OpenCursor starts query against dbName@branch. The returned cursor holds a
+// connection until it is closed or ctx is cancelled.
+func (m *Manager) OpenCursor(ctx context.Context, dbName, branch, query string, args []interface{}, format string) (*Cursor, error) {
+	start := time.Now()
+	db, err := m.conn(ctx, dbName, branch)
 	if err != nil {
-		result := QueryResult{Error: err.Error()}
-		return json.Marshal(result)
+		return nil, err
+	}
+	if err := m.checkQuota(ctx, dbName, branch, query); err != nil {
+		m.observe(ctx, dbName, branch, query, start, 0, err)
+		return nil, err
 	}
-	defer rows.Close()
 
-	columns, err := rows.Columns()
+	cursor, err := queryCursor(ctx, db, query, args, format)
+	m.observe(ctx, dbName, branch, query, start, 0, err)
+	return cursor, err
+}
+
+// queryCursor starts query on q. In FormatTyped the values of columns the
+// driver would convert, such as dates, come back as SQLite stores them.
+func queryCursor(ctx context.Context, q queryer, query string, args []interface{}, format string) (*Cursor, error) {
+	rows, err := q.QueryContext(ctx, query, args...)
 	if err != nil {
-		result := QueryResult{Error: err.Error()}
-		return json.Marshal(result)
+		return nil, err
+	}
+	c, err := newCursor(rows, format)
+	if err != nil || format != FormatTyped || !c.converts() {
+		return c, err
 	}
 
-	var resultRows [][]interface{}
-	for rows.Next() {
-		values := make([]interface{}, len(columns))
-		valuePtrs := make([]interface{}, len(columns))
-		for i := range values {
-			valuePtrs[i] = &values[i]
+	// The driver binds a statement on Query but only steps it on the first
+	// Next, so nothing has run yet. The wrapped query keeps the columns
+	// and declared types read above. Statements it cannot wrap, such as
+	// several separated by semicolons, run as given.
+	rows.Close()
+	if c.rows, err = q.QueryContext(ctx, rawValuesQuery(query, c.columns), args...); err != nil {
+		if c.rows, err = q.QueryContext(ctx, query, args...); err != nil {
+			return nil, err
 		}
+	}
+	return c, nil
+}
+
+func newCursor(rows *sql.Rows, format string) (*Cursor, error) {
+	columnTypes, err := rows.ColumnTypes()
+	if err != nil {
+		rows.Close()
+		return nil, err
+	}
+
+	c := &Cursor{
+		rows:        rows,
+		format:      format,
+		columns:     make([]string, len(columnTypes)),
+		columnTypes: make([]string, len(columnTypes)),
+		values:      make([]interface{}, len(columnTypes)),
+		ptrs:        make([]interface{}, len(columnTypes)),
+	}
+	for i, ct := range columnTypes {
+		c.columns[i] = ct.Name()
+		c.columnTypes[i] = ct.DatabaseTypeName()
+	}
+	for i := range c.values {
+		c.ptrs[i] = &c.values[i]
+	}
+	return c, nil
+}
 
-		if err := rows.Scan(valuePtrs...); err != nil {
-			result := QueryResult{Error: err.Error()}
-			return json.Marshal(result)
+// converts reports whether the driver converts the values of any of the
+// cursor's columns.
+func (c *Cursor) converts() bool {
+	for _, t := range c.columnTypes {
+		if slices.Contains(convertedTypes, t) {
+			return true
 		}
+	}
+	return false
+}
 
-		row := make([]interface{}, len(columns))
-		for i, val := range values {
-			if val == nil {
-				row[i] = nil
-			} else {
-				switch v := val.(type) {
-				case []byte:
-					row[i] = string(v)
-				default:
-					row[i] = v
-				}
-			}
+func (c *Cursor) Columns() []string {
+	return c.columns
+}
+
+// ColumnTypes returns the declared type of each column, or nil unless the
+// cursor uses FormatTyped. Expressions without a declared type report "".
+func (c *Cursor) ColumnTypes() []string {
+	if c.format != FormatTyped {
+		return nil
+	}
+	return c.columnTypes
+}
+
+// Limit stops the cursor after maxRows rows, or once the rows read hold
+// maxBytes bytes of values. Zero leaves either unlimited.
+func (c *Cursor) Limit(maxRows int, maxBytes int64) {
+	c.maxRows, c.maxBytes = maxRows, maxBytes
+}
+
+func (c *Cursor) Next() bool {
+	if c.stopped {
+		return false
+	}
+	if (c.maxRows > 0 && c.rowsRead >= c.maxRows) || (c.maxBytes > 0 && c.bytesRead >= c.maxBytes) {
+		c.stopped = true
+		c.truncated = c.rows.Next()
+		return false
+	}
+	return c.rows.Next()
+}
+
+// Truncated reports whether the cursor stopped at its limit with rows left.
+func (c *Cursor) Truncated() bool {
+	return c.truncated
+}
+
+// scan reads the current row into c.values and counts it against the
+// cursor's limit.
+func (c *Cursor) scan() error {
+	if err := c.rows.Scan(c.ptrs...); err != nil {
+		return err
+	}
+	c.rowsRead++
+	for _, v := range c.values {
+		c.bytesRead += valueSize(v)
+	}
+	return nil
+}
+
+// valueSize approximates the bytes v takes up in a result.
+func valueSize(v interface{}) int64 {
+	switch v := v.(type) {
+	case nil:
+		return 0
+	case string:
+		return int64(len(v))
+	case []byte:
+		return int64(len(v))
+	}
+	return 8
+}
+
+// Row scans the current row into a freshly allocated slice.
+func (c *Cursor) Row() ([]interface{}, error) {
+	if err := c.scan(); err != nil {
+		return nil, err
+	}
+
+	row := make([]interface{}, len(c.columns))
+	for i, val := range c.values {
+		row[i] = encodeValue(val, c.format)
+	}
+	return row, nil
+}
+
+// Values scans the current row as the driver returns it: int64, float64,
+// bool, string, []byte, time.Time or nil.
+func (c *Cursor) Values() ([]interface{}, error) {
+	if err := c.scan(); err != nil {
+		return nil, err
+	}
+
+	row := make([]interface{}, len(c.values))
+	copy(row, c.values)
+	return row, nil
+}
+
+func (c *Cursor) Err() error {
+	return c.rows.Err()
+}
+
+func (c *Cursor) Close() error {
+	return c.rows.Close()
+}
+
+// Page reads up to n rows from the cursor. done is true once the cursor is
+// exhausted.
+func (c *Cursor) Page(n int) (rows [][]interface{}, done bool, err error) {
+	for n <= 0 || len(rows) < n {
+		if !c.Next() {
+			return rows, true, c.Err()
+		}
+		row, err := c.Row()
+		if err != nil {
+			return rows, true, err
 		}
-		resultRows = append(resultRows, row)
+		rows = append(rows, row)
+	}
+	return rows, false, nil
+}
+
+func executeSelect(ctx context.Context, q queryer, query string, args []interface{}, opts QueryOptions) ([]byte, error) {
+	cursor, err := queryCursor(ctx, q, query, args, opts.Format)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close()
+
+	cursor.Limit(opts.MaxRows, opts.MaxBytes)
+	resultRows, _, err := cursor.Page(0)
+	if err != nil {
+		return nil, err
 	}
 
 	result := QueryResult{
-		Columns: columns,
-		Rows:    resultRows,
+		Columns:     cursor.Columns(),
+		ColumnTypes: cursor.ColumnTypes(),
+		Rows:        resultRows,
+		Truncated:   cursor.Truncated(),
 	}
 
 	return json.Marshal(result)
 }
 
-func (m *Manager) executeModify(db *sql.DB, query string) ([]byte, error) {
-	result, err := db.Exec(query)
+func executeModify(ctx context.Context, q queryer, query string, args []interface{}) ([]byte, int64, error) {
+	response, err := modify(ctx, q, query, args)
 	if err != nil {
-		queryResult := QueryResult{Error: err.Error()}
-		return json.Marshal(queryResult)
+		return nil, 0, err
+	}
+
+	result, err := json.Marshal(response)
+	return result, response.RowsAffected, err
+}
+
+func modify(ctx context.Context, q queryer, query string, args []interface{}) (ModifyResult, error) {
+	result, err := q.ExecContext(ctx, query, args...)
+	if err != nil {
+		return ModifyResult{}, err
 	}
 
 	rowsAffected, _ := result.RowsAffected()
 	lastInsertId, _ := result.LastInsertId()
 
-	response := map[string]interface{}{
-		"rows_affected":  rowsAffected,
-		"last_insert_id": lastInsertId,
+	return ModifyResult{
+		RowsAffected: rowsAffected,
+		LastInsertID: lastInsertId,
+	}, nil
+}
+
+// Exec runs a statement that returns no rows against dbName@branch.
+func (m *Manager) Exec(ctx context.Context, dbName, branch, query string, args []interface{}) (ModifyResult, error) {
+	start := time.Now()
+	db, err := m.execConn(ctx, dbName, branch, query)
+	if err != nil {
+		return ModifyResult{}, err
 	}
+	if err := m.checkQuota(ctx, dbName, branch, query); err != nil {
+		m.observe(ctx, dbName, branch, query, start, 0, err)
+		return ModifyResult{}, err
+	}
+	result, err := modify(ctx, db, query, args)
+	m.observe(ctx, dbName, branch, query, start, result.RowsAffected, err)
+	return result, err
+}
 
-	return json.Marshal(response)
+// PoolStats describes one pooled connection to a branch.
+type PoolStats struct {
+	Database string
+	Branch   string
+	ReadOnly bool
+	sql.DBStats
 }
 
-func (m *Manager) Close() {
-	for _, db := range m.conns {
-		db.Close()
+// Stats returns the statistics of every connection pool open for clients.
+func (m *Manager) Stats() []PoolStats {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stats := make([]PoolStats, 0, len(m.conns))
+	for connKey, db := range m.conns {
+		if strings.HasSuffix(connKey, internalSuffix) {
+			continue
+		}
+		target, readOnly := strings.CutSuffix(connKey, readOnlySuffix)
+		dbName, branch, _ := strings.Cut(target, "@")
+		stats = append(stats, PoolStats{Database: dbName, Branch: branch, ReadOnly: readOnly, DBStats: db.Stats()})
+	}
+	return stats
+}
+
+// QuickCheck runs PRAGMA quick_check on dbName@branch, failing with
+// ErrCorrupt if it finds a problem.
+func (m *Manager) QuickCheck(ctx context.Context, dbName, branch string) error {
+	db, err := m.conn(WithReadOnly(ctx), dbName, branch)
+	if err != nil {
+		return err
+	}
+	c, err := db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	problems, err := pragmaStrings(ctx, c, "PRAGMA quick_check")
+	if err != nil {
+		return err
+	}
+	if len(problems) != 1 || problems[0] != "ok" {
+		return fmt.Errorf("%w: %s", ErrCorrupt, strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// DeleteBranch deletes dbName@branch and closes its connection pools.
+// Statements already running finish on the deleted file; later ones find
+// no branch, or a new one of the same name.
+func (m *Manager) DeleteBranch(dbName, branch string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.gitMgr.DeleteBranch(dbName, branch); err != nil {
+		return err
+	}
+	m.closePools(dbName + "@" + branch)
+	return nil
+}
+
+// DeleteDatabase deletes dbName and closes the connection pools of all its
+// branches, as DeleteBranch does for one.
+func (m *Manager) DeleteDatabase(dbName string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.gitMgr.DeleteDatabase(dbName); err != nil {
+		return err
+	}
+	m.closeDatabase(dbName)
+	return nil
+}
+
+// closePools closes the pools of target, "db@branch". m.mu must be held.
+func (m *Manager) closePools(target string) {
+	for _, key := range []string{target, target + readOnlySuffix, target + internalSuffix} {
+		if db, exists := m.conns[key]; exists {
+			db.Close()
+			delete(m.conns, key)
+			m.logger.Debug("Closed connection pool", slog.String("pool", key))
+		}
+	}
+}
+
+// CloseDatabase closes the pooled connections to every branch of dbName.
+func (m *Manager) CloseDatabase(dbName string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.closeDatabase(dbName)
+}
+
+// closeDatabase is CloseDatabase with m.mu held.
+func (m *Manager) closeDatabase(dbName string) {
+	for connKey, db := range m.conns {
+		if strings.HasPrefix(connKey, dbName+"@") {
+			db.Close()
+			delete(m.conns, connKey)
+			m.logger.Debug("Closed connection pool", slog.String("pool", connKey))
+		}
+	}
+}
+
+// Close checkpoints the write-ahead log, if any, of every branch opened for
+// writing, so its database file holds everything committed, and closes
+// every pooled connection.
+func (m *Manager) Close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var errs []error
+	for connKey, db := range m.conns {
+		if !strings.HasSuffix(connKey, readOnlySuffix) {
+			if _, err := db.Exec("PRAGMA wal_checkpoint(TRUNCATE)"); err != nil {
+				errs = append(errs, fmt.Errorf("failed to checkpoint %s: %w", connKey, err))
+			}
+		}
+		if err := db.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("failed to close %s: %w", connKey, err))
+		}
+		delete(m.conns, connKey)
 	}
+	return errors.Join(errs...)
 }
@@ -0,0 +1,54 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/bxrne/branchlore/internal/git"
+)
+
+// newTestManager returns a manager over a fresh data directory holding the
+// database "db".
+func newTestManager(t *testing.T) *Manager {
+	t.Helper()
+	dir := t.TempDir()
+	gitMgr, err := git.NewManager(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := gitMgr.InitDatabase("db"); err != nil {
+		t.Fatal(err)
+	}
+	m, err := NewManager(dir, gitMgr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { m.Close() })
+	return m
+}
+
+// mustExec runs each statement on dbName@branch.
+func mustExec(t *testing.T, m *Manager, branch string, stmts ...string) {
+	t.Helper()
+	for _, stmt := range stmts {
+		if _, err := m.Exec(context.Background(), "db", branch, stmt, nil); err != nil {
+			t.Fatalf("%s: %v", stmt, err)
+		}
+	}
+}
+
+// count returns the number of rows in table of the SQLite file at path.
+func count(t *testing.T, path, table string) int {
+	t.Helper()
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	var n int
+	if err := db.QueryRow("SELECT count(*) FROM " + table).Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
@@ -0,0 +1,364 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/bxrne/branchlore/internal/git"
+)
+
+var ErrCheckFailed = errors.New("merge check failed")
+
+// MergeBranch merges dbName@from into dbName@into and returns the hash of
+// the merge commit. Merges fast-forward: into takes on the schema and rows of
+// from, so it must not have changed since the two branches last met, or the
+// merge fails with git.ErrMergeConflict. When into is protected the checks
+// of its protection rule run on the result, and nothing changes unless they
+// pass.
+func (m *Manager) MergeBranch(ctx context.Context, dbName, from, into, message string) (string, error) {
+	if from == "" || into == "" || from == into {
+		return "", fmt.Errorf("%w: merge needs two different branches", ErrInvalidArgument)
+	}
+	if err := m.checkQuota(ctx, dbName, into, ""); err != nil {
+		return "", err
+	}
+
+	var checks git.Checks
+	protection, err := m.gitMgr.Protection(dbName, into)
+	if err != nil {
+		return "", err
+	}
+	if protection != nil {
+		checks = protection.Checks
+	}
+
+	base, err := m.gitMgr.MergeBase(dbName, from, into)
+	if err != nil {
+		return "", err
+	}
+	baseFile, err := os.CreateTemp("", "branchlore-merge-*.db")
+	if err != nil {
+		return "", fmt.Errorf("failed to create merge base file: %w", err)
+	}
+	baseFile.Close()
+	defer os.Remove(baseFile.Name())
+	if err := m.gitMgr.CheckoutFile(dbName, base, baseFile.Name()); err != nil {
+		return "", err
+	}
+
+	if message == "" {
+		message = fmt.Sprintf("Merge %s into %s", from, into)
+	}
+	hash, err := m.applyMerge(ctx, dbName, from, into, base, baseFile.Name(), checks, message)
+	if errors.Is(err, git.ErrMergeConflict) || errors.Is(err, ErrCheckFailed) {
+		m.logger.InfoContext(ctx, "Merge rejected", slog.String("db", dbName), slog.String("from", from),
+			slog.String("into", into), slog.Any("error", err))
+	}
+	if err != nil {
+		return "", err
+	}
+	if hash == "" {
+		// from has nothing into does not already have.
+		return m.gitMgr.Head(dbName, into)
+	}
+
+	m.logger.InfoContext(ctx, "Merged branch", slog.String("db", dbName), slog.String("from", from),
+		slog.String("into", into), slog.String("commit", hash))
+	return hash, nil
+}
+
+// applyMerge replaces the contents of into with those of from in a single
+// transaction, records the result as a merge commit and returns its hash,
+// or "" when there was nothing to merge. into stays locked until the commit
+// is recorded, so the commit holds exactly the merged contents.
+func (m *Manager) applyMerge(ctx context.Context, dbName, from, into, base, basePath string, checks git.Checks, message string) (string, error) {
+	db, err := m.internalConn(ctx, dbName, into)
+	if err != nil {
+		return "", err
+	}
+
+	c, err := db.Conn(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to get connection: %w", err)
+	}
+	defer c.Close()
+
+	if _, err := c.ExecContext(ctx, "ATTACH DATABASE ? AS merge_from", m.gitMgr.GetBranchPath(dbName, from)); err != nil {
+		return "", fmt.Errorf("failed to attach %s: %w", from, err)
+	}
+	defer c.ExecContext(context.Background(), "DETACH DATABASE merge_from")
+	if _, err := c.ExecContext(ctx, "ATTACH DATABASE ? AS merge_base", basePath); err != nil {
+		return "", fmt.Errorf("failed to attach merge base: %w", err)
+	}
+	defer c.ExecContext(context.Background(), "DETACH DATABASE merge_base")
+
+	// In exclusive locking mode the lock the transaction takes on into is
+	// kept after COMMIT, until the git commit has read the file. Readers
+	// wait for it for that long.
+	if _, err := c.ExecContext(ctx, "PRAGMA main.locking_mode = EXCLUSIVE"); err != nil {
+		return "", err
+	}
+	defer func() {
+		// The lock goes with the next read after returning to normal mode.
+		c.ExecContext(context.Background(), "PRAGMA main.locking_mode = NORMAL")
+		c.ExecContext(context.Background(), "SELECT count(*) FROM main.sqlite_master")
+	}()
+
+	// BEGIN IMMEDIATE locks the attached databases for writing too, so
+	// neither branch can change until the merge is committed.
+	files := map[string]string{
+		"main":       m.gitMgr.GetBranchPath(dbName, into),
+		"merge_from": m.gitMgr.GetBranchPath(dbName, from),
+	}
+	if err := beginCheckpointed(ctx, c, files); err != nil {
+		return "", err
+	}
+	defer c.ExecContext(context.Background(), "ROLLBACK")
+
+	merged, err := m.gitMgr.CommitBranch(dbName, from, "Commit "+from)
+	if err != nil {
+		return "", err
+	}
+	if merged == base {
+		return "", nil
+	}
+
+	changed, err := diffTables(ctx, c, "main", "merge_base")
+	if err != nil {
+		return "", err
+	}
+	if len(changed) > 0 {
+		names := make([]string, len(changed))
+		for i, td := range changed {
+			names[i] = td.Name
+		}
+		return "", fmt.Errorf("%w: %s has changed since %s branched from it (tables %s)", git.ErrMergeConflict, into, from, strings.Join(names, ", "))
+	}
+
+	if err := replaceContents(ctx, c, "merge_from"); err != nil {
+		return "", fmt.Errorf("failed to merge %s: %w", from, err)
+	}
+	if err := runChecks(ctx, c, checks); err != nil {
+		return "", err
+	}
+
+	if _, err := c.ExecContext(ctx, "COMMIT"); err != nil {
+		return "", err
+	}
+	// The exclusive lock keeps other connections out while the merged
+	// contents move from the log into the file the commit records.
+	if _, err := c.ExecContext(ctx, "PRAGMA main.wal_checkpoint(TRUNCATE)"); err != nil {
+		return "", fmt.Errorf("failed to checkpoint %s: %w", into, err)
+	}
+	return m.gitMgr.CommitMerge(dbName, into, merged, message)
+}
+
+type schemaObject struct {
+	kind, name, sql string
+}
+
+// replaceContents replaces the schema and rows of main with those of the
+// attached schema src.
+func replaceContents(ctx context.Context, c *sql.Conn, src string) error {
+	old, err := schemaObjects(ctx, c, "main", "type IN ('trigger', 'view', 'table') ORDER BY type = 'table'")
+	if err != nil {
+		return err
+	}
+	for _, o := range old {
+		// Dropping a table drops its indexes and triggers.
+		if _, err := c.ExecContext(ctx, "DROP "+strings.ToUpper(o.kind)+" IF EXISTS main."+quoteIdent(o.name)); err != nil {
+			return err
+		}
+	}
+
+	objects, err := schemaObjects(ctx, c, src, "sql IS NOT NULL ORDER BY CASE type WHEN 'table' THEN 0 WHEN 'index' THEN 1 ELSE 2 END, rowid")
+	if err != nil {
+		return err
+	}
+	for _, o := range objects {
+		if o.kind == "table" && strings.HasPrefix(strings.ToUpper(o.sql), "CREATE VIRTUAL") {
+			return fmt.Errorf("virtual table %s cannot be merged", o.name)
+		}
+	}
+
+	// Create the tables, copy their rows, then add indexes, triggers and
+	// views so they are neither maintained nor fired by the copy.
+	for _, o := range objects {
+		if o.kind != "table" {
+			continue
+		}
+		if _, err := c.ExecContext(ctx, o.sql); err != nil {
+			return err
+		}
+		if err := copyRows(ctx, c, src, o.name); err != nil {
+			return fmt.Errorf("failed to copy table %s: %w", o.name, err)
+		}
+	}
+
+	var hasSequence bool
+	if err := c.QueryRowContext(ctx, "SELECT count(*) > 0 FROM "+src+".sqlite_master WHERE name = 'sqlite_sequence'").Scan(&hasSequence); err != nil {
+		return err
+	}
+	if hasSequence {
+		if _, err := c.ExecContext(ctx, "DELETE FROM main.sqlite_sequence"); err != nil {
+			return err
+		}
+		if _, err := c.ExecContext(ctx, "INSERT INTO main.sqlite_sequence SELECT * FROM "+src+".sqlite_sequence"); err != nil {
+			return err
+		}
+	}
+
+	for _, o := range objects {
+		if o.kind == "table" {
+			continue
+		}
+		if _, err := c.ExecContext(ctx, o.sql); err != nil {
+			return err
+		}
+	}
+
+	var userVersion int64
+	if err := c.QueryRowContext(ctx, "PRAGMA "+src+".user_version").Scan(&userVersion); err != nil {
+		return err
+	}
+	_, err = c.ExecContext(ctx, fmt.Sprintf("PRAGMA main.user_version = %d", userVersion))
+	return err
+}
+
+func schemaObjects(ctx context.Context, c *sql.Conn, schema, where string) ([]schemaObject, error) {
+	rows, err := c.QueryContext(ctx, "SELECT type, name, coalesce(sql, '') FROM "+schema+".sqlite_master WHERE name NOT LIKE 'sqlite_%' AND "+where)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s schema: %w", schema, err)
+	}
+	defer rows.Close()
+
+	var objects []schemaObject
+	for rows.Next() {
+		var o schemaObject
+		if err := rows.Scan(&o.kind, &o.name, &o.sql); err != nil {
+			return nil, err
+		}
+		objects = append(objects, o)
+	}
+	return objects, rows.Err()
+}
+
+// copyRows copies the rows of src.table into main.table, keeping their
+// rowids.
+func copyRows(ctx context.Context, c *sql.Conn, src, table string) error {
+	rows, err := c.QueryContext(ctx, "SELECT name, hidden FROM pragma_table_xinfo(?, ?)", table, src)
+	if err != nil {
+		return err
+	}
+	var columns []string
+	hasRowid := true
+	for rows.Next() {
+		var name string
+		var hidden int
+		if err := rows.Scan(&name, &hidden); err != nil {
+			rows.Close()
+			return err
+		}
+		// Generated columns cannot be inserted into.
+		if hidden == 0 {
+			columns = append(columns, quoteIdent(name))
+		}
+		if strings.EqualFold(name, "rowid") {
+			hasRowid = false
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	if hasRowid {
+		// WITHOUT ROWID tables have no rowid to keep.
+		probe, err := c.QueryContext(ctx, "SELECT rowid FROM "+src+"."+quoteIdent(table)+" LIMIT 0")
+		if err != nil {
+			hasRowid = false
+		} else {
+			probe.Close()
+		}
+	}
+	if hasRowid {
+		columns = append([]string{"rowid"}, columns...)
+	}
+
+	list := strings.Join(columns, ", ")
+	_, err = c.ExecContext(ctx, "INSERT INTO main."+quoteIdent(table)+" ("+list+") SELECT "+list+" FROM "+src+"."+quoteIdent(table))
+	return err
+}
+
+// runChecks runs checks against main, failing with ErrCheckFailed on the
+// first that does not pass.
+func runChecks(ctx context.Context, c *sql.Conn, checks git.Checks) error {
+	if checks.IntegrityCheck {
+		problems, err := pragmaStrings(ctx, c, "PRAGMA main.integrity_check")
+		if err != nil {
+			return err
+		}
+		if len(problems) != 1 || problems[0] != "ok" {
+			return fmt.Errorf("%w: integrity_check: %s", ErrCheckFailed, strings.Join(problems, "; "))
+		}
+	}
+
+	if checks.ForeignKeyCheck {
+		var table, parent string
+		var rowid sql.NullInt64
+		err := c.QueryRowContext(ctx, `SELECT "table", rowid, parent FROM pragma_foreign_key_check(NULL, 'main')`).Scan(&table, &rowid, &parent)
+		if err == nil {
+			return fmt.Errorf("%w: foreign_key_check: row %d of %s references a missing %s row", ErrCheckFailed, rowid.Int64, table, parent)
+		}
+		if !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+	}
+
+	if len(checks.Assertions) == 0 {
+		return nil
+	}
+	if _, err := c.ExecContext(ctx, "PRAGMA query_only = ON"); err != nil {
+		return err
+	}
+	defer c.ExecContext(context.Background(), "PRAGMA query_only = OFF")
+	for _, a := range checks.Assertions {
+		rows, err := c.QueryContext(ctx, a.Query)
+		if err != nil {
+			return fmt.Errorf("%w: assertion %s: %v", ErrCheckFailed, a.Name, err)
+		}
+		found := rows.Next()
+		err = rows.Err()
+		rows.Close()
+		if err != nil {
+			return fmt.Errorf("%w: assertion %s: %v", ErrCheckFailed, a.Name, err)
+		}
+		if found {
+			return fmt.Errorf("%w: assertion %s returned rows", ErrCheckFailed, a.Name)
+		}
+	}
+	return nil
+}
+
+func pragmaStrings(ctx context.Context, c *sql.Conn, query string) ([]string, error) {
+	rows, err := c.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var values []string
+	for rows.Next() {
+		var v string
+		if err := rows.Scan(&v); err != nil {
+			return nil, err
+		}
+		values = append(values, v)
+	}
+	return values, rows.Err()
+}
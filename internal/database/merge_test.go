@@ -0,0 +1,203 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bxrne/branchlore/internal/git"
+)
+
+func TestMergeBranchCommitsTheMergedContents(t *testing.T) {
+	m := newTestManager(t)
+	ctx := context.Background()
+
+	mustExec(t, m, "main", "CREATE TABLE t (a INTEGER)")
+	if _, err := m.CommitBranch(ctx, "db", "main", "schema"); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.ForkBranch(ctx, "db", "dev", "main", git.BranchMeta{}); err != nil {
+		t.Fatal(err)
+	}
+	mustExec(t, m, "dev", "INSERT INTO t VALUES (1)", "INSERT INTO t VALUES (2)")
+
+	hash, err := m.MergeBranch(ctx, "db", "dev", "main", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := count(t, m.gitMgr.GetBranchPath("db", "main"), "t"); n != 2 {
+		t.Errorf("main has %d rows after the merge, want 2", n)
+	}
+	merged := filepath.Join(t.TempDir(), "merged.db")
+	if err := m.gitMgr.CheckoutFile("db", hash, merged); err != nil {
+		t.Fatal(err)
+	}
+	if n := count(t, merged, "t"); n != 2 {
+		t.Errorf("merge commit has %d rows, want 2", n)
+	}
+
+	// The merge gives back its lock on main.
+	ctx, cancel := context.WithTimeout(ctx, time.Second)
+	defer cancel()
+	if _, err := m.Exec(ctx, "db", "main", "INSERT INTO t VALUES (3)", nil); err != nil {
+		t.Fatalf("write after the merge: %v", err)
+	}
+	if head, err := m.gitMgr.Head("db", "main"); err != nil || head != hash {
+		t.Errorf("head of main = %s, %v, want the merge commit %s", head, err, hash)
+	}
+}
+
+func TestMergeBranchConflicts(t *testing.T) {
+	m := newTestManager(t)
+	ctx := context.Background()
+
+	mustExec(t, m, "main", "CREATE TABLE t (a INTEGER)")
+	if _, err := m.CommitBranch(ctx, "db", "main", "schema"); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.ForkBranch(ctx, "db", "dev", "main", git.BranchMeta{}); err != nil {
+		t.Fatal(err)
+	}
+	mustExec(t, m, "dev", "INSERT INTO t VALUES (1)")
+	mustExec(t, m, "main", "INSERT INTO t VALUES (2)")
+
+	if _, err := m.MergeBranch(ctx, "db", "dev", "main", ""); !errors.Is(err, git.ErrMergeConflict) {
+		t.Fatalf("merge into a changed branch: %v, want ErrMergeConflict", err)
+	}
+	if n := count(t, m.gitMgr.GetBranchPath("db", "main"), "t"); n != 1 {
+		t.Errorf("main has %d rows after the rejected merge, want 1", n)
+	}
+
+	for _, branches := range [][2]string{{"", "main"}, {"dev", ""}, {"main", "main"}} {
+		if _, err := m.MergeBranch(ctx, "db", branches[0], branches[1], ""); !errors.Is(err, ErrInvalidArgument) {
+			t.Errorf("merge %q into %q: %v, want ErrInvalidArgument", branches[0], branches[1], err)
+		}
+	}
+}
+
+func TestMergeBranchWithNothingToMerge(t *testing.T) {
+	m := newTestManager(t)
+	ctx := context.Background()
+
+	mustExec(t, m, "main", "CREATE TABLE t (a INTEGER)")
+	head, err := m.CommitBranch(ctx, "db", "main", "schema")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := m.ForkBranch(ctx, "db", "dev", "main", git.BranchMeta{}); err != nil {
+		t.Fatal(err)
+	}
+	if hash, err := m.MergeBranch(ctx, "db", "dev", "main", ""); err != nil || hash != head {
+		t.Errorf("merge of an unchanged branch = %s, %v, want the head %s", hash, err, head)
+	}
+}
+
+func TestMergeBranchRunsChecks(t *testing.T) {
+	m := newTestManager(t)
+	ctx := context.Background()
+
+	mustExec(t, m, "main",
+		"CREATE TABLE customers (id INTEGER PRIMARY KEY)",
+		"CREATE TABLE orders (id INTEGER PRIMARY KEY, customer INTEGER REFERENCES customers (id), total REAL)")
+	if _, err := m.CommitBranch(ctx, "db", "main", "schema"); err != nil {
+		t.Fatal(err)
+	}
+	config := &git.Config{}
+	err := config.Protect(git.Protection{Branches: "main", Checks: git.Checks{
+		IntegrityCheck:  true,
+		ForeignKeyCheck: true,
+		Assertions:      []git.Assertion{{Name: "positive totals", Query: "SELECT id FROM orders WHERE total <= 0"}},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := m.gitMgr.SaveConfig("db", config); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		stmts []string
+		want  string
+	}{
+		{"foreign key", []string{"INSERT INTO orders VALUES (1, 7, 10)"}, "foreign_key_check"},
+		{"assertion", []string{"INSERT INTO customers VALUES (7)", "INSERT INTO orders VALUES (1, 7, 0)"}, "positive totals"},
+	}
+	for i, tt := range tests {
+		branch := fmt.Sprintf("dev%d", i)
+		if err := m.ForkBranch(ctx, "db", branch, "main", git.BranchMeta{}); err != nil {
+			t.Fatal(err)
+		}
+		mustExec(t, m, branch, tt.stmts...)
+		_, err := m.MergeBranch(ctx, "db", branch, "main", "")
+		if !errors.Is(err, ErrCheckFailed) || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: %v, want ErrCheckFailed about %s", tt.name, err, tt.want)
+		}
+		if n := count(t, m.gitMgr.GetBranchPath("db", "main"), "orders"); n != 0 {
+			t.Errorf("%s: main has %d orders after the rejected merge", tt.name, n)
+		}
+	}
+
+	if err := m.ForkBranch(ctx, "db", "good", "main", git.BranchMeta{}); err != nil {
+		t.Fatal(err)
+	}
+	mustExec(t, m, "good", "INSERT INTO customers VALUES (7)", "INSERT INTO orders VALUES (1, 7, 10)")
+	if _, err := m.MergeBranch(ctx, "db", "good", "main", ""); err != nil {
+		t.Fatalf("merge passing the checks: %v", err)
+	}
+	if n := count(t, m.gitMgr.GetBranchPath("db", "main"), "orders"); n != 1 {
+		t.Errorf("main has %d orders after the merge, want 1", n)
+	}
+}
+
+func TestBranchesInWALModeForkCommitAndMergeTheirLatestWrites(t *testing.T) {
+	m := newTestManager(t)
+	ctx := context.Background()
+
+	mustExec(t, m, "main", "CREATE TABLE t (a INTEGER)")
+	if _, err := m.CommitBranch(ctx, "db", "main", "schema"); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.ForkBranch(ctx, "db", "dev", "main", git.BranchMeta{}); err != nil {
+		t.Fatal(err)
+	}
+	mustExec(t, m, "dev", "PRAGMA journal_mode = WAL", "CREATE TABLE w (a INTEGER)", "INSERT INTO t VALUES (1)")
+
+	if err := m.ForkBranch(ctx, "db", "fork", "dev", git.BranchMeta{}); err != nil {
+		t.Fatal(err)
+	}
+	if n := count(t, m.gitMgr.GetBranchPath("db", "fork"), "w"); n != 0 {
+		t.Errorf("fork has %d rows in w, want 0", n)
+	}
+
+	mustExec(t, m, "dev", "INSERT INTO t VALUES (2)")
+	hash, err := m.CommitBranch(ctx, "db", "dev", "write")
+	if err != nil {
+		t.Fatal(err)
+	}
+	committed := filepath.Join(t.TempDir(), "committed.db")
+	if err := m.gitMgr.CheckoutFile("db", hash, committed); err != nil {
+		t.Fatal(err)
+	}
+	if n := count(t, committed, "t"); n != 2 {
+		t.Errorf("commit has %d rows, want 2", n)
+	}
+
+	mustExec(t, m, "main", "PRAGMA journal_mode = WAL")
+	mustExec(t, m, "dev", "INSERT INTO t VALUES (3)")
+	hash, err = m.MergeBranch(ctx, "db", "dev", "main", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	merged := filepath.Join(t.TempDir(), "merged.db")
+	if err := m.gitMgr.CheckoutFile("db", hash, merged); err != nil {
+		t.Fatal(err)
+	}
+	if n := count(t, merged, "t"); n != 3 {
+		t.Errorf("merge commit has %d rows, want 3", n)
+	}
+}
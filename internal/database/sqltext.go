@@ -0,0 +1,158 @@
+package database
+
+import (
+	"slices"
+	"strings"
+)
+
+// statementVerb returns the keyword that decides what query does, in upper
+// case: its first word after any leading comments, or for a WITH statement
+// the first word after its common table expressions.
+func statementVerb(query string) string {
+	return verb(topLevelWords(query))
+}
+
+// verb returns the keyword that decides what the statement of words does,
+// as for statementVerb.
+func verb(words []string) string {
+	if len(words) == 0 {
+		return ""
+	}
+	if words[0] != "WITH" {
+		return words[0]
+	}
+	for _, w := range words[1:] {
+		switch w {
+		case "SELECT", "VALUES", "INSERT", "REPLACE", "UPDATE", "DELETE":
+			return w
+		}
+	}
+	return "WITH"
+}
+
+// reads reports whether query is a statement that only reads: a SELECT,
+// VALUES, PRAGMA or EXPLAIN, or a WITH whose main statement is a SELECT or
+// VALUES.
+func reads(query string) bool {
+	switch statementVerb(query) {
+	case "SELECT", "VALUES", "PRAGMA", "EXPLAIN":
+		return true
+	}
+	return false
+}
+
+// returning reports whether query is an INSERT, UPDATE or DELETE with a
+// RETURNING clause, which returns rows as it writes.
+func returning(query string) bool {
+	switch statementVerb(query) {
+	case "INSERT", "REPLACE", "UPDATE", "DELETE":
+		return slices.Contains(topLevelWords(query), "RETURNING")
+	}
+	return false
+}
+
+// Keywords returns up to n leading words of the first statement in q in
+// upper case, skipping comments and anything in parentheses or quotes.
+func Keywords(q string, n int) []string {
+	words := topLevelWords(q)
+	if len(words) > n {
+		words = words[:n]
+	}
+	return words
+}
+
+// isVacuum reports whether query is a plain VACUUM of the main database and
+// nothing else.
+func isVacuum(query string) bool {
+	words, rest := statementWords(query)
+	if !slices.Equal(words, []string{"VACUUM"}) && !slices.Equal(words, []string{"VACUUM", "MAIN"}) {
+		return false
+	}
+	for rest != "" {
+		if words, rest = statementWords(rest); len(words) > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// topLevelWords returns the words of the first statement in q that are
+// outside parentheses, string literals, quoted identifiers and comments, in
+// upper case.
+func topLevelWords(q string) []string {
+	words, _ := statementWords(q)
+	return words
+}
+
+// statementWords returns topLevelWords of q and what follows the semicolon
+// ending its first statement.
+func statementWords(q string) (words []string, rest string) {
+	depth := 0
+	for i := 0; i < len(q); {
+		if j := SkipLiteral(q, i); j > i {
+			i = j
+			continue
+		}
+		c := q[i]
+		switch {
+		case c == '(':
+			depth++
+		case c == ')':
+			depth--
+		case c == ';' && depth == 0:
+			return words, q[i+1:]
+		case isWordByte(c):
+			j := i
+			for j < len(q) && isWordByte(q[j]) {
+				j++
+			}
+			if depth == 0 {
+				words = append(words, strings.ToUpper(q[i:j]))
+			}
+			i = j
+			continue
+		}
+		i++
+	}
+	return words, ""
+}
+
+func isWordByte(c byte) bool {
+	return c == '_' || c >= 0x80 || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9'
+}
+
+// SkipLiteral returns the index just past the string literal, quoted
+// identifier or comment starting at q[i], or i if none starts there.
+func SkipLiteral(q string, i int) int {
+	switch {
+	case q[i] == '\'' || q[i] == '"' || q[i] == '`':
+		quote := q[i]
+		for j := i + 1; j < len(q); j++ {
+			if q[j] != quote {
+				continue
+			}
+			if j+1 < len(q) && q[j+1] == quote {
+				j++
+				continue
+			}
+			return j + 1
+		}
+		return len(q)
+	case q[i] == '[':
+		if j := strings.IndexByte(q[i:], ']'); j >= 0 {
+			return i + j + 1
+		}
+		return len(q)
+	case strings.HasPrefix(q[i:], "--"):
+		if j := strings.IndexByte(q[i:], '\n'); j >= 0 {
+			return i + j + 1
+		}
+		return len(q)
+	case strings.HasPrefix(q[i:], "/*"):
+		if j := strings.Index(q[i+2:], "*/"); j >= 0 {
+			return i + j + 4
+		}
+		return len(q)
+	}
+	return i
+}
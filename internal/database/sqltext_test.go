@@ -0,0 +1,85 @@
+package database
+
+import "testing"
+
+func TestIsSelect(t *testing.T) {
+	tests := []struct {
+		query string
+		want  bool
+	}{
+		{"SELECT 1", true},
+		{"select\n\t*\nfrom t", true},
+		{"  -- leading comment\nSELECT 1", true},
+		{"/* block */ SELECT 1", true},
+		{"VALUES (1), (2)", true},
+		{"PRAGMA table_info(t)", true},
+		{"EXPLAIN QUERY PLAN SELECT 1", true},
+		{"WITH x AS (SELECT 1) SELECT * FROM x", true},
+		{"WITH RECURSIVE n(i) AS (VALUES(1) UNION ALL SELECT i+1 FROM n WHERE i < 3) SELECT i FROM n", true},
+		{"WITH x(a) AS (SELECT 1) VALUES (2)", true},
+		{"WITH x AS (SELECT 1) DELETE FROM t WHERE a IN x", false},
+		{"WITH x AS (SELECT 1) INSERT INTO t SELECT * FROM x", false},
+		{"INSERT INTO t SELECT 1", false},
+		{"INSERT INTO t (a) VALUES (1) RETURNING id, a", true},
+		{"update t SET a = 2 returning *", true},
+		{"WITH x AS (SELECT 1) DELETE FROM t WHERE a IN x RETURNING a", true},
+		{"INSERT INTO t VALUES ('RETURNING')", false},
+		{"CREATE TRIGGER tr AFTER INSERT ON t BEGIN DELETE FROM u RETURNING a; END", false},
+		{"UPDATE t SET a = 'SELECT'", false},
+		{"-- SELECT\nDELETE FROM t", false},
+		{"CREATE TABLE t (a)", false},
+		{"", false},
+		{"-- only a comment", false},
+	}
+	for _, tt := range tests {
+		if got := IsSelect(tt.query); got != tt.want {
+			t.Errorf("IsSelect(%q) = %v, want %v", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestGrows(t *testing.T) {
+	tests := []struct {
+		query string
+		want  bool
+	}{
+		{"DELETE FROM t", false},
+		{"-- tidy up\ndrop TABLE t", false},
+		{"WITH old AS (SELECT 1) DELETE FROM t WHERE a IN old", false},
+		{"VACUUM", false},
+		{"DELETE FROM t; VACUUM;", false},
+		{"SELECT count(*) FROM t", false},
+		{"INSERT INTO t VALUES (1)", true},
+		{"INSERT INTO t VALUES (1) RETURNING a", true},
+		{"WITH x AS (SELECT 1) INSERT INTO t SELECT * FROM x", true},
+		{"VACUUM INTO 'copy.db'", true},
+		{"DELETE FROM t; INSERT INTO t VALUES (1)", true},
+		{"SELECT 1; CREATE TABLE u (a)", true},
+	}
+	for _, tt := range tests {
+		if got := grows(tt.query); got != tt.want {
+			t.Errorf("grows(%q) = %v, want %v", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestIsVacuum(t *testing.T) {
+	tests := []struct {
+		query string
+		want  bool
+	}{
+		{"VACUUM", true},
+		{"  vacuum main;\n-- done", true},
+		{"/* tidy */ VACUUM;", true},
+		{"VACUUM INTO 'copy.db'", false},
+		{"VACUUM main INTO ?", false},
+		{"VACUUM; ATTACH 'other.db' AS o", false},
+		{"VACUUM temp", false},
+		{"DELETE FROM t; VACUUM", false},
+	}
+	for _, tt := range tests {
+		if got := isVacuum(tt.query); got != tt.want {
+			t.Errorf("isVacuum(%q) = %v, want %v", tt.query, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// Tx is a transaction on a single branch. It pins one pooled connection until
+// it is committed or rolled back.
+type Tx struct {
+	m      *Manager
+	ctx    context.Context
+	dbName string
+	branch string
+	conn   *sql.Conn
+	tx     *sql.Tx
+}
+
+// Database returns the name of the database the transaction runs on.
+func (t *Tx) Database() string {
+	return t.dbName
+}
+
+// BeginTx starts a transaction on dbName@branch. The transaction is rolled
+// back if ctx is cancelled before Commit.
+func (m *Manager) BeginTx(ctx context.Context, dbName, branch string) (*Tx, error) {
+	start := time.Now()
+	db, err := m.conn(ctx, dbName, branch)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := conn.BeginTx(ctx, nil)
+	m.observe(ctx, dbName, branch, "BEGIN", start, 0, err)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return &Tx{m: m, ctx: ctx, dbName: dbName, branch: branch, conn: conn, tx: tx}, nil
+}
+
+// ExecuteQuery runs query inside the transaction; see Manager.ExecuteQuery.
+func (t *Tx) ExecuteQuery(ctx context.Context, query string, args []interface{}, opts QueryOptions) ([]byte, error) {
+	start := time.Now()
+	if err := t.m.checkQuota(ctx, t.dbName, t.branch, query); err != nil {
+		t.m.observe(ctx, t.dbName, t.branch, query, start, 0, err)
+		return nil, err
+	}
+	result, rowsAffected, err := execute(ctx, t.tx, query, args, opts)
+	t.m.observe(ctx, t.dbName, t.branch, query, start, rowsAffected, err)
+	return result, err
+}
+
+// Exec runs a statement that returns no rows inside the transaction.
+func (t *Tx) Exec(ctx context.Context, query string, args []interface{}) (ModifyResult, error) {
+	start := time.Now()
+	if err := t.m.checkQuota(ctx, t.dbName, t.branch, query); err != nil {
+		t.m.observe(ctx, t.dbName, t.branch, query, start, 0, err)
+		return ModifyResult{}, err
+	}
+	result, err := modify(ctx, t.tx, query, args)
+	t.m.observe(ctx, t.dbName, t.branch, query, start, result.RowsAffected, err)
+	return result, err
+}
+
+// OpenCursor starts query inside the transaction; see Manager.OpenCursor.
+func (t *Tx) OpenCursor(ctx context.Context, query string, args []interface{}, format string) (*Cursor, error) {
+	start := time.Now()
+	if err := t.m.checkQuota(ctx, t.dbName, t.branch, query); err != nil {
+		t.m.observe(ctx, t.dbName, t.branch, query, start, 0, err)
+		return nil, err
+	}
+	cursor, err := queryCursor(ctx, t.tx, query, args, format)
+	t.m.observe(ctx, t.dbName, t.branch, query, start, 0, err)
+	return cursor, err
+}
+
+// Describe describes query as seen from inside the transaction.
+func (t *Tx) Describe(ctx context.Context, query string) (*Statement, error) {
+	return describe(ctx, t.conn, query)
+}
+
+func (t *Tx) Commit() error {
+	return t.finish("COMMIT", t.tx.Commit)
+}
+
+func (t *Tx) Rollback() error {
+	return t.finish("ROLLBACK", t.tx.Rollback)
+}
+
+// finish ends the transaction with end and releases its connection. Ending
+// a transaction that is already over is not reported to the observers.
+func (t *Tx) finish(query string, end func() error) error {
+	defer t.conn.Close()
+	start := time.Now()
+	err := end()
+	if !errors.Is(err, sql.ErrTxDone) {
+		t.m.observe(t.ctx, t.dbName, t.branch, query, start, 0, err)
+	}
+	return err
+}
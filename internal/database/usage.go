@@ -0,0 +1,190 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+)
+
+// ErrQuotaExceeded is returned for writes to a branch or database that has
+// reached its quota, and for branches that would take one over it.
+var ErrQuotaExceeded = errors.New("quota exceeded")
+
+// Quota limits the bytes a database and one of its branches take on disk.
+// Zero fields set no limit.
+type Quota struct {
+	// Database counts every branch's files and the Git repository.
+	Database int64
+	// Branch counts the branch's database file and write-ahead log.
+	Branch int64
+}
+
+// SetQuota makes the manager look up the quota of a branch with fn before
+// every write to it and every branch created from it. It must be called
+// before the manager is used.
+func (m *Manager) SetQuota(fn func(dbName, branch string) Quota) {
+	m.quota = fn
+}
+
+// Usage is how much disk space a database and its branches take.
+type Usage struct {
+	Database string `json:"database"`
+	// TotalBytes is every branch's files plus the Git repository.
+	TotalBytes int64 `json:"total_bytes"`
+	// RepositoryBytes is the Git repository, including history no branch
+	// reaches any more.
+	RepositoryBytes int64         `json:"repository_bytes"`
+	QuotaBytes      int64         `json:"quota_bytes,omitempty"`
+	Branches        []BranchUsage `json:"branches"`
+}
+
+// BranchUsage is how much disk space one branch takes.
+type BranchUsage struct {
+	Branch        string `json:"branch"`
+	DatabaseBytes int64  `json:"database_bytes"`
+	WALBytes      int64  `json:"wal_bytes"`
+	// HistoryBytes is the part of the Git repository reachable from the
+	// branch, of which SharedBytes is also reachable from other branches.
+	HistoryBytes int64 `json:"history_bytes"`
+	SharedBytes  int64 `json:"shared_bytes"`
+	// UniqueBytes is what deleting the branch and pruning its history
+	// frees: its files and the history no other branch shares.
+	UniqueBytes int64 `json:"unique_bytes"`
+	QuotaBytes  int64 `json:"quota_bytes,omitempty"`
+}
+
+// Usage measures dbName and each of its branches.
+func (m *Manager) Usage(dbName string) (*Usage, error) {
+	history, err := m.gitMgr.HistorySizes(dbName)
+	if err != nil {
+		return nil, err
+	}
+	repoSize, err := m.gitMgr.RepositorySize(dbName)
+	if err != nil {
+		return nil, err
+	}
+	branches, err := m.gitMgr.ListBranches(dbName)
+	if err != nil {
+		return nil, err
+	}
+
+	usage := &Usage{Database: dbName, TotalBytes: repoSize, RepositoryBytes: repoSize, Branches: []BranchUsage{}}
+	for _, branch := range branches {
+		dbSize, walSize := m.fileSizes(dbName, branch)
+		hs := history[branch]
+		b := BranchUsage{
+			Branch:        branch,
+			DatabaseBytes: dbSize,
+			WALBytes:      walSize,
+			HistoryBytes:  hs.Bytes,
+			SharedBytes:   hs.SharedBytes,
+			UniqueBytes:   dbSize + walSize + hs.Bytes - hs.SharedBytes,
+		}
+		if m.quota != nil {
+			q := m.quota(dbName, branch)
+			b.QuotaBytes, usage.QuotaBytes = q.Branch, q.Database
+		}
+		usage.TotalBytes += dbSize + walSize
+		usage.Branches = append(usage.Branches, b)
+	}
+	return usage, nil
+}
+
+// fileSizes returns the sizes of dbName@branch's database file and
+// write-ahead log. Missing files count as empty.
+func (m *Manager) fileSizes(dbName, branch string) (db, wal int64) {
+	path := m.gitMgr.GetBranchPath(dbName, branch)
+	if info, err := os.Stat(path); err == nil {
+		db = info.Size()
+	}
+	if info, err := os.Stat(path + "-wal"); err == nil {
+		wal = info.Size()
+	}
+	return db, wal
+}
+
+// databaseSize returns the bytes every branch of dbName and its Git
+// repository take.
+func (m *Manager) databaseSize(dbName string) (int64, error) {
+	size, err := m.gitMgr.RepositorySize(dbName)
+	if err != nil {
+		return 0, err
+	}
+	branches, err := m.gitMgr.ListBranches(dbName)
+	if err != nil {
+		return 0, err
+	}
+	for _, branch := range branches {
+		db, wal := m.fileSizes(dbName, branch)
+		size += db + wal
+	}
+	return size, nil
+}
+
+// checkQuota fails with ErrQuotaExceeded when query could grow
+// dbName@branch and it or dbName has reached its quota. Statements on
+// read-only connections cannot write, and DELETE, DROP and VACUUM are let
+// through so a full branch can be cleaned up.
+func (m *Manager) checkQuota(ctx context.Context, dbName, branch, query string) error {
+	if m.quota == nil || IsReadOnly(ctx) || !grows(query) {
+		return nil
+	}
+	return m.checkGrowth(dbName, branch, m.quota(dbName, branch), 0)
+}
+
+// grows reports whether any statement in query can take more disk space:
+// anything but reads, DELETE, DROP and VACUUM. VACUUM INTO writes a new
+// file.
+func grows(query string) bool {
+	for rest := query; rest != ""; {
+		var words []string
+		words, rest = statementWords(rest)
+		switch verb(words) {
+		case "", "SELECT", "VALUES", "PRAGMA", "EXPLAIN", "DELETE", "DROP":
+		case "VACUUM":
+			if slices.Contains(words, "INTO") {
+				return true
+			}
+		default:
+			return true
+		}
+	}
+	return false
+}
+
+// checkFork fails with ErrQuotaExceeded when a copy of from would take
+// dbName or the new branch over its quota.
+func (m *Manager) checkFork(dbName, branch, from string) error {
+	if m.quota == nil {
+		return nil
+	}
+	db, wal := m.fileSizes(dbName, from)
+	return m.checkGrowth(dbName, branch, m.quota(dbName, branch), db+wal)
+}
+
+// checkGrowth checks that dbName@branch can grow by n bytes without
+// reaching q, or, when n is zero, that it has not reached q already.
+func (m *Manager) checkGrowth(dbName, branch string, q Quota, n int64) error {
+	verb := "is"
+	if n > 0 {
+		verb = "would be"
+	}
+	if q.Branch > 0 {
+		db, wal := m.fileSizes(dbName, branch)
+		if size := db + wal + n; size > q.Branch || (n == 0 && size >= q.Branch) {
+			return fmt.Errorf("%w: %s@%s %s %d bytes, its quota is %d", ErrQuotaExceeded, dbName, branch, verb, size, q.Branch)
+		}
+	}
+	if q.Database > 0 {
+		size, err := m.databaseSize(dbName)
+		if err != nil {
+			return err
+		}
+		if size += n; size > q.Database || (n == 0 && size >= q.Database) {
+			return fmt.Errorf("%w: %s %s %d bytes, its quota is %d", ErrQuotaExceeded, dbName, verb, size, q.Database)
+		}
+	}
+	return nil
+}
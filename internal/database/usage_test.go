@@ -0,0 +1,130 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/bxrne/branchlore/internal/git"
+)
+
+func TestBranchQuota(t *testing.T) {
+	m := newTestManager(t)
+	quota := Quota{}
+	m.SetQuota(func(dbName, branch string) Quota { return quota })
+	ctx := context.Background()
+
+	mustExec(t, m, "main", "CREATE TABLE t (data BLOB)", "INSERT INTO t VALUES (zeroblob(100000))")
+	db, wal := m.fileSizes("db", "main")
+	quota.Branch = db + wal
+
+	if _, err := m.Exec(ctx, "db", "main", "INSERT INTO t VALUES (zeroblob(100000))", nil); !errors.Is(err, ErrQuotaExceeded) {
+		t.Fatalf("write to a full branch: %v, want ErrQuotaExceeded", err)
+	}
+	if _, err := m.ExecuteQuery(ctx, "db", "main", "INSERT INTO t VALUES (zeroblob(100000)) RETURNING rowid", nil, QueryOptions{}); !errors.Is(err, ErrQuotaExceeded) {
+		t.Errorf("write returning rows to a full branch: %v, want ErrQuotaExceeded", err)
+	}
+	if _, err := m.Exec(ctx, "db", "main", "SELECT count(*) FROM t", nil); err != nil {
+		t.Errorf("read a full branch: %v", err)
+	}
+	tx, err := m.BeginTx(ctx, "db", "main")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = tx.Exec(ctx, "INSERT INTO t VALUES (1)", nil)
+	tx.Rollback()
+	if !errors.Is(err, ErrQuotaExceeded) {
+		t.Errorf("write to a full branch in a transaction: %v, want ErrQuotaExceeded", err)
+	}
+	if _, err := m.Exec(ctx, "db", "main", "DELETE FROM t WHERE 0; INSERT INTO t VALUES (zeroblob(100000))", nil); !errors.Is(err, ErrQuotaExceeded) {
+		t.Errorf("write after a delete to a full branch: %v, want ErrQuotaExceeded", err)
+	}
+	// Freeing space is always allowed.
+	if _, err := m.Exec(ctx, "db", "main", "DELETE FROM t", nil); err != nil {
+		t.Errorf("delete from a full branch: %v", err)
+	}
+
+	// Other branches have the same quota, which a copy of main would
+	// reach.
+	quota.Branch--
+	if err := m.ForkBranch(ctx, "db", "dev", "main", git.BranchMeta{}); !errors.Is(err, ErrQuotaExceeded) {
+		t.Errorf("fork over the branch quota: %v, want ErrQuotaExceeded", err)
+	}
+	if m.gitMgr.BranchExists("db", "dev") {
+		t.Error("the rejected fork created dev")
+	}
+}
+
+func TestDatabaseQuota(t *testing.T) {
+	m := newTestManager(t)
+	quota := Quota{}
+	m.SetQuota(func(dbName, branch string) Quota { return quota })
+	ctx := context.Background()
+
+	mustExec(t, m, "main", "CREATE TABLE t (data BLOB)", "INSERT INTO t VALUES (zeroblob(100000))")
+	size, err := m.databaseSize("db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	quota.Database = size + 1000
+
+	if _, err := m.Exec(ctx, "db", "main", "INSERT INTO t VALUES (1)", nil); err != nil {
+		t.Fatalf("write under the database quota: %v", err)
+	}
+	if err := m.ForkBranch(ctx, "db", "dev", "main", git.BranchMeta{}); !errors.Is(err, ErrQuotaExceeded) {
+		t.Errorf("fork over the database quota: %v, want ErrQuotaExceeded", err)
+	}
+	quota.Database = size
+	if _, err := m.Exec(ctx, "db", "main", "INSERT INTO t VALUES (2)", nil); !errors.Is(err, ErrQuotaExceeded) {
+		t.Errorf("write to a full database: %v, want ErrQuotaExceeded", err)
+	}
+}
+
+func TestUsage(t *testing.T) {
+	m := newTestManager(t)
+	m.SetQuota(func(dbName, branch string) Quota { return Quota{Database: 1 << 30, Branch: 1 << 20} })
+	ctx := context.Background()
+
+	mustExec(t, m, "main", "CREATE TABLE t (data BLOB)", "INSERT INTO t VALUES (randomblob(50000))")
+	if _, err := m.CommitBranch(ctx, "db", "main", "data"); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.ForkBranch(ctx, "db", "dev", "main", git.BranchMeta{}); err != nil {
+		t.Fatal(err)
+	}
+	mustExec(t, m, "dev", "INSERT INTO t VALUES (randomblob(50000))")
+	if _, err := m.CommitBranch(ctx, "db", "dev", "more data"); err != nil {
+		t.Fatal(err)
+	}
+
+	usage, err := m.Usage("db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if usage.Database != "db" || usage.QuotaBytes != 1<<30 || len(usage.Branches) != 2 {
+		t.Fatalf("usage %+v", usage)
+	}
+	total := usage.RepositoryBytes
+	branches := make(map[string]BranchUsage)
+	for _, b := range usage.Branches {
+		branches[b.Branch] = b
+		total += b.DatabaseBytes + b.WALBytes
+		if b.QuotaBytes != 1<<20 || b.UniqueBytes != b.DatabaseBytes+b.WALBytes+b.HistoryBytes-b.SharedBytes {
+			t.Errorf("branch usage %+v", b)
+		}
+	}
+	if usage.TotalBytes != total {
+		t.Errorf("total %d, want the repository and every branch's files, %d", usage.TotalBytes, total)
+	}
+
+	main, dev := branches["main"], branches["dev"]
+	if main.SharedBytes == 0 || main.SharedBytes != main.HistoryBytes {
+		t.Errorf("main shares all its history with dev: %+v", main)
+	}
+	if dev.HistoryBytes <= main.HistoryBytes || dev.SharedBytes != main.SharedBytes {
+		t.Errorf("dev holds main's history and its own commit: %+v, main %+v", dev, main)
+	}
+	if dev.DatabaseBytes <= main.DatabaseBytes {
+		t.Errorf("dev's file (%d bytes) should be larger than main's (%d)", dev.DatabaseBytes, main.DatabaseBytes)
+	}
+}
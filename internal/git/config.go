@@ -0,0 +1,146 @@
+package git
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+)
+
+// configFile is the per-database configuration file, next to main.db.
+const configFile = "branchlore.json"
+
+// Config is a database's configuration.
+type Config struct {
+	// Protected lists the branches that cannot be written to or deleted
+	// directly. They only change by merging other branches into them.
+	Protected []Protection `json:"protected_branches,omitempty"`
+}
+
+// Protection protects the branches matching Branches, a branch name or a
+// path.Match pattern such as "release/*".
+type Protection struct {
+	Branches string `json:"branches"`
+	// Checks must pass on the merged result before a merge into a matching
+	// branch is committed.
+	Checks Checks `json:"checks,omitempty"`
+}
+
+// Checks are run against a database before a merge is committed.
+type Checks struct {
+	// IntegrityCheck requires PRAGMA integrity_check to report ok.
+	IntegrityCheck bool `json:"integrity_check,omitempty"`
+	// ForeignKeyCheck requires PRAGMA foreign_key_check to report no
+	// violations.
+	ForeignKeyCheck bool `json:"foreign_key_check,omitempty"`
+	// Assertions are queries that must return no rows.
+	Assertions []Assertion `json:"assertions,omitempty"`
+}
+
+// Assertion is a named query that must return no rows, e.g. one selecting
+// orders without a customer.
+type Assertion struct {
+	Name  string `json:"name"`
+	Query string `json:"query"`
+}
+
+// Protection returns the protection of branch, or nil when it is not
+// protected. When several rules match the first one applies.
+func (c *Config) Protection(branch string) *Protection {
+	for i, p := range c.Protected {
+		if ok, err := path.Match(p.Branches, branch); err == nil && ok {
+			return &c.Protected[i]
+		}
+	}
+	return nil
+}
+
+// Protect protects the branches matching p.Branches, replacing any rule for
+// the same pattern.
+func (c *Config) Protect(p Protection) error {
+	if p.Branches == "" {
+		return errors.New("branch pattern required")
+	}
+	if _, err := path.Match(p.Branches, ""); err != nil {
+		return fmt.Errorf("%w: pattern %q: %v", ErrInvalidName, p.Branches, err)
+	}
+	for _, a := range p.Checks.Assertions {
+		if a.Name == "" || a.Query == "" {
+			return errors.New("assertions need a name and a query")
+		}
+	}
+
+	if i := slices.IndexFunc(c.Protected, func(old Protection) bool { return old.Branches == p.Branches }); i >= 0 {
+		c.Protected[i] = p
+	} else {
+		c.Protected = append(c.Protected, p)
+	}
+	return nil
+}
+
+// Unprotect removes the rule for pattern.
+func (c *Config) Unprotect(pattern string) error {
+	i := slices.IndexFunc(c.Protected, func(p Protection) bool { return p.Branches == pattern })
+	if i < 0 {
+		return fmt.Errorf("no protection rule for %q", pattern)
+	}
+	c.Protected = slices.Delete(c.Protected, i, i+1)
+	return nil
+}
+
+// Config reads the configuration of dbName. A database without a
+// configuration file has the zero Config.
+func (m *Manager) Config(dbName string) (*Config, error) {
+	if _, err := m.open(dbName); err != nil {
+		return nil, err
+	}
+
+	config := &Config{}
+	data, err := os.ReadFile(filepath.Join(m.dataDir, dbName, configFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return config, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read database config: %w", err)
+	}
+	if err := json.Unmarshal(data, config); err != nil {
+		return nil, fmt.Errorf("failed to parse database config: %w", err)
+	}
+	return config, nil
+}
+
+// SaveConfig replaces the configuration of dbName.
+func (m *Manager) SaveConfig(dbName string, config *Config) error {
+	if _, err := m.open(dbName); err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(config, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode database config: %w", err)
+	}
+
+	configPath := filepath.Join(m.dataDir, dbName, configFile)
+	tmp := configPath + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write database config: %w", err)
+	}
+	if err := os.Rename(tmp, configPath); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to write database config: %w", err)
+	}
+	return nil
+}
+
+// Protection returns the protection of dbName@branch, or nil when the
+// branch is not protected.
+func (m *Manager) Protection(dbName, branch string) (*Protection, error) {
+	config, err := m.Config(dbName)
+	if err != nil {
+		return nil, err
+	}
+	return config.Protection(branch), nil
+}
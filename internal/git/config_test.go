@@ -0,0 +1,80 @@
+package git
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestConfigProtection(t *testing.T) {
+	config := &Config{}
+	for _, p := range []Protection{
+		{Branches: "release/*", Checks: Checks{IntegrityCheck: true}},
+		{Branches: "*"},
+		{Branches: "release/*", Checks: Checks{ForeignKeyCheck: true}},
+	} {
+		if err := config.Protect(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(config.Protected) != 2 {
+		t.Fatalf("rules %v, want the release/* rule replaced", config.Protected)
+	}
+	// The first matching rule applies.
+	if p := config.Protection("release/1"); p == nil || p.Branches != "release/*" || !p.Checks.ForeignKeyCheck || p.Checks.IntegrityCheck {
+		t.Errorf("Protection(release/1) = %+v", p)
+	}
+	if p := config.Protection("main"); p == nil || p.Branches != "*" {
+		t.Errorf("Protection(main) = %+v", p)
+	}
+
+	if err := config.Unprotect("*"); err != nil {
+		t.Fatal(err)
+	}
+	if p := config.Protection("main"); p != nil {
+		t.Errorf("Protection(main) after unprotecting = %+v", p)
+	}
+	if err := config.Unprotect("*"); err == nil {
+		t.Error("removed a rule twice")
+	}
+
+	for _, p := range []Protection{
+		{},
+		{Branches: "["},
+		{Branches: "main", Checks: Checks{Assertions: []Assertion{{Name: "no query"}}}},
+	} {
+		if err := config.Protect(p); err == nil {
+			t.Errorf("Protect(%+v) succeeded", p)
+		}
+	}
+}
+
+func TestProtectedBranchesCannotBeDeleted(t *testing.T) {
+	m := newTestManager(t)
+	if err := m.ForkBranch("db", "release/1", "main", NewBranchMeta(time.Now(), 0, false)); err != nil {
+		t.Fatal(err)
+	}
+	if config, err := m.Config("db"); err != nil || len(config.Protected) != 0 {
+		t.Fatalf("config of a new database: %+v, %v", config, err)
+	}
+
+	config := &Config{}
+	if err := config.Protect(Protection{Branches: "release/*"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.SaveConfig("db", config); err != nil {
+		t.Fatal(err)
+	}
+	if p, err := m.Protection("db", "release/1"); err != nil || p == nil {
+		t.Fatalf("Protection after saving: %+v, %v", p, err)
+	}
+
+	for _, branch := range []string{"main", "release/1"} {
+		if err := m.DeleteBranch("db", branch); !errors.Is(err, ErrBranchProtected) {
+			t.Errorf("DeleteBranch(%s): %v, want ErrBranchProtected", branch, err)
+		}
+	}
+	if _, err := m.Config("nope"); err == nil {
+		t.Error("read the config of a missing database")
+	}
+}
@@ -0,0 +1,144 @@
+package git
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/go-git/go-git/v6"
+	"github.com/go-git/go-git/v6/plumbing"
+)
+
+// DefaultEphemeralTTL is how long ephemeral branches live unless given a
+// TTL of their own.
+const DefaultEphemeralTTL = 24 * time.Hour
+
+// branchMetaFile holds a branch's metadata, next to its database file, so
+// it goes when the branch is deleted.
+const branchMetaFile = "branch.json"
+
+// BranchMeta describes how long a branch is meant to live. The zero value
+// is a branch that lives until it is deleted.
+type BranchMeta struct {
+	// Ephemeral marks branches created to be thrown away, such as one per
+	// CI run.
+	Ephemeral bool `json:"ephemeral,omitempty"`
+	// ExpiresAt is when the server deletes the branch. Zero is never.
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// NewBranchMeta returns the metadata of a branch created at now that
+// expires after ttl. Ephemeral branches without a ttl get
+// DefaultEphemeralTTL; other branches without one never expire.
+func NewBranchMeta(now time.Time, ttl time.Duration, ephemeral bool) BranchMeta {
+	if ttl <= 0 && ephemeral {
+		ttl = DefaultEphemeralTTL
+	}
+	meta := BranchMeta{Ephemeral: ephemeral}
+	if ttl > 0 {
+		meta.ExpiresAt = now.Add(ttl).UTC().Truncate(time.Second)
+	}
+	return meta
+}
+
+// Expired reports whether the branch should be deleted at now.
+func (b BranchMeta) Expired(now time.Time) bool {
+	return !b.ExpiresAt.IsZero() && !now.Before(b.ExpiresAt)
+}
+
+func (m *Manager) branchMetaPath(dbName, branchName string) string {
+	return filepath.Join(filepath.Dir(m.GetBranchPath(dbName, branchName)), branchMetaFile)
+}
+
+// writeBranchMeta records meta for branchName. Branches with the zero
+// BranchMeta get no file.
+func (m *Manager) writeBranchMeta(dbName, branchName string, meta BranchMeta) error {
+	if meta == (BranchMeta{}) {
+		return nil
+	}
+	data, err := json.Marshal(meta)
+	if err != nil {
+		return fmt.Errorf("failed to encode branch metadata: %w", err)
+	}
+	if err := os.WriteFile(m.branchMetaPath(dbName, branchName), append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write branch metadata: %w", err)
+	}
+	return nil
+}
+
+// BranchMeta returns the metadata of dbName@branchName. The main branch
+// and branches created without any have the zero BranchMeta.
+func (m *Manager) BranchMeta(dbName, branchName string) (BranchMeta, error) {
+	var meta BranchMeta
+	if branchName == "main" {
+		return meta, nil
+	}
+	data, err := os.ReadFile(m.branchMetaPath(dbName, branchName))
+	if errors.Is(err, os.ErrNotExist) {
+		return meta, nil
+	}
+	if err != nil {
+		return meta, fmt.Errorf("failed to read branch metadata: %w", err)
+	}
+	if err := json.Unmarshal(data, &meta); err != nil {
+		return meta, fmt.Errorf("failed to parse branch metadata of %s: %w", branchName, err)
+	}
+	return meta, nil
+}
+
+// ExpiredBranches lists the branches of dbName that expired by now.
+func (m *Manager) ExpiredBranches(dbName string, now time.Time) ([]string, error) {
+	branches, err := m.ListBranches(dbName)
+	if err != nil {
+		return nil, err
+	}
+	var expired []string
+	for _, branch := range branches {
+		meta, err := m.BranchMeta(dbName, branch)
+		if err != nil {
+			return nil, err
+		}
+		if meta.Expired(now) {
+			expired = append(expired, branch)
+		}
+	}
+	return expired, nil
+}
+
+// Prune deletes the loose objects of dbName that no branch reaches any
+// more and that were written before olderThan. Objects written since may
+// belong to a commit still being recorded. It returns how many objects
+// and bytes it removed.
+func (m *Manager) Prune(dbName string, olderThan time.Time) (objects int, bytes int64, err error) {
+	repo, err := m.open(dbName)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer m.forgetSize(dbName)
+	err = repo.Prune(git.PruneOptions{
+		OnlyObjectsOlderThan: olderThan,
+		Handler: func(h plumbing.Hash) error {
+			size, err := m.objectSize(repo, dbName, h)
+			if err != nil {
+				return err
+			}
+			if err := repo.DeleteObject(h); err != nil {
+				return err
+			}
+			objects++
+			bytes += size
+			return nil
+		},
+	})
+	if err != nil {
+		return objects, bytes, fmt.Errorf("failed to prune repository: %w", err)
+	}
+	if objects > 0 {
+		m.logger.Info("Pruned repository", slog.String("db", dbName), slog.Int("objects", objects), slog.Int64("bytes", bytes))
+	}
+	return objects, bytes, nil
+}
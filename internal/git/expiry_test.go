@@ -0,0 +1,115 @@
+package git
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+func TestNewBranchMeta(t *testing.T) {
+	now := time.Date(2025, 1, 2, 3, 4, 5, 600, time.UTC)
+	tests := []struct {
+		ttl       time.Duration
+		ephemeral bool
+		want      BranchMeta
+	}{
+		{0, false, BranchMeta{}},
+		{0, true, BranchMeta{Ephemeral: true, ExpiresAt: now.Add(DefaultEphemeralTTL).Truncate(time.Second)}},
+		{time.Hour, false, BranchMeta{ExpiresAt: now.Add(time.Hour).Truncate(time.Second)}},
+		{time.Minute, true, BranchMeta{Ephemeral: true, ExpiresAt: now.Add(time.Minute).Truncate(time.Second)}},
+	}
+	for _, tt := range tests {
+		if got := NewBranchMeta(now, tt.ttl, tt.ephemeral); got != tt.want {
+			t.Errorf("NewBranchMeta(%v, %v) = %+v, want %+v", tt.ttl, tt.ephemeral, got, tt.want)
+		}
+	}
+
+	meta := NewBranchMeta(now, time.Hour, false)
+	if meta.Expired(now) || meta.Expired(meta.ExpiresAt.Add(-time.Nanosecond)) || !meta.Expired(meta.ExpiresAt) {
+		t.Errorf("%+v expires at the wrong time", meta)
+	}
+	if (BranchMeta{}).Expired(now.Add(100 * 365 * 24 * time.Hour)) {
+		t.Error("a branch without an expiry expired")
+	}
+}
+
+func TestExpiredBranches(t *testing.T) {
+	m := newTestManager(t)
+	now := time.Now()
+	for name, ttl := range map[string]time.Duration{"short": time.Minute, "long": 2 * time.Hour, "forever": 0} {
+		if err := m.ForkBranch("db", name, "main", NewBranchMeta(now, ttl, false)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	meta, err := m.BranchMeta("db", "short")
+	if err != nil || meta.ExpiresAt.IsZero() {
+		t.Fatalf("BranchMeta(short) = %+v, %v", meta, err)
+	}
+	if meta, err := m.BranchMeta("db", "forever"); err != nil || meta != (BranchMeta{}) {
+		t.Errorf("BranchMeta(forever) = %+v, %v, want the zero value", meta, err)
+	}
+
+	expired, err := m.ExpiredBranches("db", now.Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(expired) != 1 || expired[0] != "short" {
+		t.Errorf("expired in an hour: %v, want [short]", expired)
+	}
+	if expired, _ := m.ExpiredBranches("db", now.Add(3*time.Hour)); len(expired) != 2 {
+		t.Errorf("expired in three hours: %v, want short and long", expired)
+	}
+
+	// Deleting a branch takes its metadata with it.
+	if err := m.DeleteBranch("db", "short"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(m.branchMetaPath("db", "short")); !os.IsNotExist(err) {
+		t.Errorf("metadata of a deleted branch: %v", err)
+	}
+}
+
+func TestPrune(t *testing.T) {
+	m := newTestManager(t)
+	if err := m.ForkBranch("db", "dev", "main", BranchMeta{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(m.GetBranchPath("db", "dev"), []byte("only dev has this"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.CommitBranch("db", "dev", "dev data"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Objects a branch still reaches are kept.
+	if objects, _, err := m.Prune("db", time.Now().Add(time.Hour)); err != nil || objects != 0 {
+		t.Fatalf("prune with dev alive: %d objects, %v", objects, err)
+	}
+	if err := m.DeleteBranch("db", "dev"); err != nil {
+		t.Fatal(err)
+	}
+	// So are unreachable objects written since the cutoff.
+	if objects, _, err := m.Prune("db", time.Now().Add(-time.Hour)); err != nil || objects != 0 {
+		t.Fatalf("prune of new objects: %d objects, %v", objects, err)
+	}
+
+	before, err := m.RepositorySize("db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	objects, bytes, err := m.Prune("db", time.Now().Add(time.Hour))
+	if err != nil || objects == 0 || bytes == 0 {
+		t.Fatalf("prune of dev's history: %d objects, %d bytes, %v", objects, bytes, err)
+	}
+	after, err := m.RepositorySize("db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if after >= before {
+		t.Errorf("repository grew from %d to %d bytes after a prune", before, after)
+	}
+	if err := m.CheckRepository("db"); err != nil {
+		t.Errorf("repository after a prune: %v", err)
+	}
+}
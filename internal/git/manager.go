@@ -1,18 +1,44 @@
 package git
 
 import (
+	"errors"
 	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/go-git/go-git/v6"
 	"github.com/go-git/go-git/v6/plumbing"
+	"github.com/go-git/go-git/v6/plumbing/filemode"
+	"github.com/go-git/go-git/v6/plumbing/object"
+)
+
+var (
+	ErrInvalidName      = errors.New("invalid name")
+	ErrDatabaseNotFound = errors.New("database not found")
+	ErrDatabaseExists   = errors.New("database already exists")
+	ErrBranchNotFound   = errors.New("branch not found")
+	ErrBranchExists     = errors.New("branch already exists")
+	ErrBranchProtected  = errors.New("branch is protected")
+	ErrMergeConflict    = errors.New("merge conflict")
 )
 
 type Manager struct {
 	dataDir string
+	logger  *slog.Logger
+
+	// sizes caches RepositorySize by database. Everything that writes to a
+	// repository forgets its entry and bumps sizesGen, so a measurement
+	// that raced with a write is not kept.
+	sizesMu  sync.Mutex
+	sizes    map[string]int64
+	sizesGen uint64
 }
 
 func NewManager(dataDir string) (*Manager, error) {
@@ -22,18 +48,74 @@ func NewManager(dataDir string) (*Manager, error) {
 
 	return &Manager{
 		dataDir: dataDir,
+		logger:  slog.New(slog.DiscardHandler),
+		sizes:   make(map[string]int64),
 	}, nil
 }
 
+// validName reports whether name is safe to use as a path component below
+// the data directory. Branch names may contain '/' separated segments.
+func validName(name string, allowSlash bool) bool {
+	if name == "" || strings.HasPrefix(name, "/") || strings.HasSuffix(name, "/") {
+		return false
+	}
+	if !allowSlash && strings.Contains(name, "/") {
+		return false
+	}
+	for _, seg := range strings.Split(name, "/") {
+		if seg == "" || seg == "." || seg == ".." || strings.HasPrefix(seg, ".") {
+			return false
+		}
+		if strings.ContainsAny(seg, "\\\x00:@ ~^?*[") {
+			return false
+		}
+	}
+	return true
+}
+
+// SetLogger makes the manager log changes to databases and branches with
+// logger. By default it logs nothing.
+func (m *Manager) SetLogger(logger *slog.Logger) {
+	m.logger = logger
+}
+
+func (m *Manager) open(dbName string) (*git.Repository, error) {
+	if !validName(dbName, false) {
+		return nil, fmt.Errorf("%w: database %q", ErrInvalidName, dbName)
+	}
+
+	repo, err := git.PlainOpen(filepath.Join(m.dataDir, dbName))
+	if errors.Is(err, git.ErrRepositoryNotExists) {
+		return nil, fmt.Errorf("%w: %s", ErrDatabaseNotFound, dbName)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open repository: %w", err)
+	}
+	return repo, nil
+}
+
+func (m *Manager) DatabaseExists(dbName string) bool {
+	_, err := m.open(dbName)
+	return err == nil
+}
+
 func (m *Manager) InitDatabase(dbName string) error {
+	if !validName(dbName, false) {
+		return fmt.Errorf("%w: database %q", ErrInvalidName, dbName)
+	}
+	if m.DatabaseExists(dbName) {
+		return fmt.Errorf("%w: %s", ErrDatabaseExists, dbName)
+	}
+
 	dbPath := filepath.Join(m.dataDir, dbName)
 
 	if err := os.MkdirAll(dbPath, 0755); err != nil {
 		return fmt.Errorf("failed to create database directory: %w", err)
 	}
 
-	// Initialize git repository using git command
-	initCmd := exec.Command("git", "init")
+	// Initialize git repository using git command. The first branch is
+	// main whatever init.defaultBranch says, since branches fork from it.
+	initCmd := exec.Command("git", "init", "--initial-branch=main")
 	initCmd.Dir = dbPath
 	if err := initCmd.Run(); err != nil {
 		return fmt.Errorf("failed to initialize git repository: %w", err)
@@ -71,80 +153,375 @@ func (m *Manager) InitDatabase(dbName string) error {
 		return fmt.Errorf("failed to create initial commit: %w", err)
 	}
 
+	m.logger.Info("Created database", slog.String("db", dbName))
+
 	return nil
 }
 
-func (m *Manager) CreateBranch(dbName, branchName string) error {
-	dbPath := filepath.Join(m.dataDir, dbName)
+func (m *Manager) ListDatabases() ([]string, error) {
+	entries, err := os.ReadDir(m.dataDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read data directory: %w", err)
+	}
+
+	var databases []string
+	for _, entry := range entries {
+		if entry.IsDir() && m.DatabaseExists(entry.Name()) {
+			databases = append(databases, entry.Name())
+		}
+	}
+	return databases, nil
+}
+
+func (m *Manager) DeleteDatabase(dbName string) error {
+	if _, err := m.open(dbName); err != nil {
+		return err
+	}
+
+	defer m.forgetSize(dbName)
+	if err := os.RemoveAll(filepath.Join(m.dataDir, dbName)); err != nil {
+		return fmt.Errorf("failed to remove database directory: %w", err)
+	}
+	m.logger.Info("Deleted database", slog.String("db", dbName))
+	return nil
+}
+
+// ForkBranch creates branchName at the current commit of from, with a copy
+// of from's database file and the given metadata. Writers to from must be
+// locked out for the duration of the copy.
+func (m *Manager) ForkBranch(dbName, branchName, from string, meta BranchMeta) error {
+	if !validName(branchName, true) {
+		return fmt.Errorf("%w: branch %q", ErrInvalidName, branchName)
+	}
+
+	repo, err := m.open(dbName)
+	if err != nil {
+		return err
+	}
 
-	repo, err := git.PlainOpen(dbPath)
+	branches, err := m.ListBranches(dbName)
 	if err != nil {
-		return fmt.Errorf("failed to open repository: %w", err)
+		return err
+	}
+	for _, other := range branches {
+		switch {
+		case other == branchName:
+			return fmt.Errorf("%w: %s", ErrBranchExists, branchName)
+		case strings.HasPrefix(other, branchName+"/"), strings.HasPrefix(branchName, other+"/"):
+			// Git cannot hold refs/heads/a next to refs/heads/a/b, and
+			// their worktrees would nest.
+			return fmt.Errorf("%w: %s conflicts with branch %s", ErrBranchExists, branchName, other)
+		}
 	}
 
-	head, err := repo.Head()
+	fromRef, err := repo.Reference(plumbing.NewBranchReferenceName(from), true)
+	if errors.Is(err, plumbing.ErrReferenceNotFound) {
+		return fmt.Errorf("%w: %s", ErrBranchNotFound, from)
+	}
 	if err != nil {
-		return fmt.Errorf("failed to get HEAD: %w", err)
+		return fmt.Errorf("failed to resolve branch %s: %w", from, err)
 	}
 
-	branchRefName := plumbing.NewBranchReferenceName(branchName)
-	branchRef := plumbing.NewHashReference(branchRefName, head.Hash())
+	branchPath := m.GetBranchPath(dbName, branchName)
+	if err := os.MkdirAll(filepath.Dir(branchPath), 0755); err != nil {
+		return fmt.Errorf("failed to create worktree directory: %w", err)
+	}
+
+	if err := copyFile(m.GetBranchPath(dbName, from), branchPath); err != nil {
+		m.removeWorktree(dbName, branchName)
+		return fmt.Errorf("failed to copy database file: %w", err)
+	}
+	if err := m.writeBranchMeta(dbName, branchName, meta); err != nil {
+		m.removeWorktree(dbName, branchName)
+		return err
+	}
 
+	branchRef := plumbing.NewHashReference(plumbing.NewBranchReferenceName(branchName), fromRef.Hash())
 	if err := repo.Storer.SetReference(branchRef); err != nil {
+		m.removeWorktree(dbName, branchName)
 		return fmt.Errorf("failed to create branch reference: %w", err)
 	}
+	m.forgetSize(dbName)
 
-	branchPath := filepath.Join(dbPath, fmt.Sprintf("worktrees/%s", branchName))
-	if err := os.MkdirAll(branchPath, 0755); err != nil {
-		return fmt.Errorf("failed to create worktree directory: %w", err)
+	logger := m.logger
+	if !meta.ExpiresAt.IsZero() {
+		logger = logger.With(slog.Time("expires_at", meta.ExpiresAt))
 	}
+	logger.Info("Created branch", slog.String("db", dbName), slog.String("branch", branchName), slog.String("from", from))
+	return nil
+}
 
-	worktree, err := repo.Worktree()
+// removeWorktree undoes a ForkBranch that failed part way: it removes the
+// files ForkBranch creates for branchName, then the directories above them
+// up to worktrees/ as long as they are empty. Anything else, such as the
+// files of another branch, is left alone.
+func (m *Manager) removeWorktree(dbName, branchName string) {
+	branchPath := m.GetBranchPath(dbName, branchName)
+	os.Remove(branchPath)
+	os.Remove(m.branchMetaPath(dbName, branchName))
+
+	worktrees := filepath.Join(m.dataDir, dbName, "worktrees")
+	for dir := filepath.Dir(branchPath); dir != worktrees && strings.HasPrefix(dir, worktrees); dir = filepath.Dir(dir) {
+		if os.Remove(dir) != nil {
+			break
+		}
+	}
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
 	if err != nil {
-		return fmt.Errorf("failed to get main worktree: %w", err)
+		return err
 	}
+	defer in.Close()
 
-	if err := worktree.Checkout(&git.CheckoutOptions{
-		Branch: branchRefName,
-		Create: false,
-	}); err != nil {
-		return fmt.Errorf("failed to checkout branch: %w", err)
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Sync(); err != nil {
+		out.Close()
+		return err
 	}
+	return out.Close()
+}
 
-	return nil
+// CommitBranch records the current contents of branchName's database file
+// as a new commit on the branch and returns its hash. When the file is
+// unchanged since the last commit no commit is made and the existing hash is
+// returned. Writers to the branch must be locked out while it runs.
+func (m *Manager) CommitBranch(dbName, branchName, message string) (string, error) {
+	return m.commit(dbName, branchName, message, plumbing.ZeroHash)
+}
+
+// CommitMerge records the current contents of branchName's database file as
+// a merge of the commit from into the branch, and returns its hash. Writers
+// to the branch must be locked out while it runs.
+func (m *Manager) CommitMerge(dbName, branchName, from, message string) (string, error) {
+	return m.commit(dbName, branchName, message, plumbing.NewHash(from))
+}
+
+func (m *Manager) commit(dbName, branchName, message string, merged plumbing.Hash) (string, error) {
+	repo, err := m.open(dbName)
+	if err != nil {
+		return "", err
+	}
+	defer m.forgetSize(dbName)
+
+	ref, err := m.branchRef(repo, branchName)
+	if err != nil {
+		return "", err
+	}
+
+	parent, err := object.GetCommit(repo.Storer, ref.Hash())
+	if err != nil {
+		return "", fmt.Errorf("failed to read branch head: %w", err)
+	}
+
+	blobHash, err := storeFile(repo, m.GetBranchPath(dbName, branchName))
+	if err != nil {
+		return "", fmt.Errorf("failed to store database file: %w", err)
+	}
+
+	tree := &object.Tree{Entries: []object.TreeEntry{{Name: "main.db", Mode: filemode.Regular, Hash: blobHash}}}
+	treeHash, err := storeObject(repo, tree)
+	if err != nil {
+		return "", fmt.Errorf("failed to store tree: %w", err)
+	}
+	parents := []plumbing.Hash{ref.Hash()}
+	if !merged.IsZero() {
+		parents = append(parents, merged)
+	} else if treeHash == parent.TreeHash {
+		return ref.Hash().String(), nil
+	}
+
+	sig := object.Signature{Name: "branchlore", Email: "branchlore@local.dev", When: time.Now()}
+	commit := &object.Commit{
+		Author:       sig,
+		Committer:    sig,
+		Message:      message,
+		TreeHash:     treeHash,
+		ParentHashes: parents,
+	}
+	commitHash, err := storeObject(repo, commit)
+	if err != nil {
+		return "", fmt.Errorf("failed to store commit: %w", err)
+	}
+
+	newRef := plumbing.NewHashReference(ref.Name(), commitHash)
+	if err := repo.Storer.CheckAndSetReference(newRef, ref); err != nil {
+		return "", fmt.Errorf("failed to update branch reference: %w", err)
+	}
+	m.logger.Info("Committed branch", slog.String("db", dbName), slog.String("branch", branchName), slog.String("commit", commitHash.String()))
+	return commitHash.String(), nil
+}
+
+func (m *Manager) branchRef(repo *git.Repository, branchName string) (*plumbing.Reference, error) {
+	ref, err := repo.Reference(plumbing.NewBranchReferenceName(branchName), true)
+	if errors.Is(err, plumbing.ErrReferenceNotFound) {
+		return nil, fmt.Errorf("%w: %s", ErrBranchNotFound, branchName)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve branch %s: %w", branchName, err)
+	}
+	return ref, nil
+}
+
+// Head returns the hash of the newest commit on branchName.
+func (m *Manager) Head(dbName, branchName string) (string, error) {
+	repo, err := m.open(dbName)
+	if err != nil {
+		return "", err
+	}
+	ref, err := m.branchRef(repo, branchName)
+	if err != nil {
+		return "", err
+	}
+	return ref.Hash().String(), nil
+}
+
+// MergeBase returns the hash of the newest commit that branches a and b
+// have in common.
+func (m *Manager) MergeBase(dbName, a, b string) (string, error) {
+	repo, err := m.open(dbName)
+	if err != nil {
+		return "", err
+	}
+
+	var heads [2]*object.Commit
+	for i, branchName := range []string{a, b} {
+		ref, err := m.branchRef(repo, branchName)
+		if err != nil {
+			return "", err
+		}
+		if heads[i], err = object.GetCommit(repo.Storer, ref.Hash()); err != nil {
+			return "", fmt.Errorf("failed to read branch head: %w", err)
+		}
+	}
+
+	bases, err := heads[0].MergeBase(heads[1])
+	if err != nil {
+		return "", fmt.Errorf("failed to find merge base: %w", err)
+	}
+	if len(bases) == 0 {
+		return "", fmt.Errorf("%w: %s and %s share no history", ErrMergeConflict, a, b)
+	}
+	return bases[0].Hash.String(), nil
+}
+
+// CheckoutFile writes the database file as of the given commit to dst.
+func (m *Manager) CheckoutFile(dbName, commitHash, dst string) error {
+	repo, err := m.open(dbName)
+	if err != nil {
+		return err
+	}
+
+	commit, err := object.GetCommit(repo.Storer, plumbing.NewHash(commitHash))
+	if err != nil {
+		return fmt.Errorf("failed to read commit %s: %w", commitHash, err)
+	}
+	file, err := commit.File("main.db")
+	if err != nil {
+		return fmt.Errorf("failed to read database file of %s: %w", commitHash, err)
+	}
+	r, err := file.Reader()
+	if err != nil {
+		return fmt.Errorf("failed to read database file of %s: %w", commitHash, err)
+	}
+	defer r.Close()
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, r); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+func storeFile(repo *git.Repository, path string) (plumbing.Hash, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+	defer f.Close()
+
+	obj := repo.Storer.NewEncodedObject()
+	obj.SetType(plumbing.BlobObject)
+	w, err := obj.Writer()
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+	if _, err := io.Copy(w, f); err != nil {
+		w.Close()
+		return plumbing.ZeroHash, err
+	}
+	if err := w.Close(); err != nil {
+		return plumbing.ZeroHash, err
+	}
+	return repo.Storer.SetEncodedObject(obj)
+}
+
+func storeObject(repo *git.Repository, o interface {
+	Encode(plumbing.EncodedObject) error
+}) (plumbing.Hash, error) {
+	obj := repo.Storer.NewEncodedObject()
+	if err := o.Encode(obj); err != nil {
+		return plumbing.ZeroHash, err
+	}
+	return repo.Storer.SetEncodedObject(obj)
 }
 
 func (m *Manager) DeleteBranch(dbName, branchName string) error {
+	// main holds the database's own file, so it can never be deleted.
 	if branchName == "main" {
-		return fmt.Errorf("cannot delete main branch")
+		return fmt.Errorf("%w: cannot delete main branch", ErrBranchProtected)
 	}
 
 	dbPath := filepath.Join(m.dataDir, dbName)
 
-	repo, err := git.PlainOpen(dbPath)
+	repo, err := m.open(dbName)
 	if err != nil {
-		return fmt.Errorf("failed to open repository: %w", err)
+		return err
+	}
+
+	protection, err := m.Protection(dbName, branchName)
+	if err != nil {
+		return err
+	}
+	if protection != nil {
+		return fmt.Errorf("%w: cannot delete %s", ErrBranchProtected, branchName)
+	}
+
+	if !m.BranchExists(dbName, branchName) {
+		return fmt.Errorf("%w: %s", ErrBranchNotFound, branchName)
 	}
 
 	branchRefName := plumbing.NewBranchReferenceName(branchName)
 	if err := repo.Storer.RemoveReference(branchRefName); err != nil {
 		return fmt.Errorf("failed to remove branch reference: %w", err)
 	}
+	m.forgetSize(dbName)
 
 	branchPath := filepath.Join(dbPath, fmt.Sprintf("worktrees/%s", branchName))
 	if err := os.RemoveAll(branchPath); err != nil {
 		return fmt.Errorf("failed to remove worktree directory: %w", err)
 	}
 
+	m.logger.Info("Deleted branch", slog.String("db", dbName), slog.String("branch", branchName))
 	return nil
 }
 
 func (m *Manager) ListBranches(dbName string) ([]string, error) {
-	dbPath := filepath.Join(m.dataDir, dbName)
-
-	repo, err := git.PlainOpen(dbPath)
+	repo, err := m.open(dbName)
 	if err != nil {
-		return nil, fmt.Errorf("failed to open repository: %w", err)
+		return nil, err
 	}
 
 	refs, err := repo.References()
@@ -171,6 +548,73 @@ func (m *Manager) GetBranchPath(dbName, branchName string) string {
 	return filepath.Join(m.dataDir, dbName, fmt.Sprintf("worktrees/%s/main.db", branchName))
 }
 
+// CheckRepository opens dbName's repository and reads the newest commit of
+// every branch, failing if any of them cannot be read.
+func (m *Manager) CheckRepository(dbName string) error {
+	repo, err := m.open(dbName)
+	if err != nil {
+		return err
+	}
+	branches, err := repo.Branches()
+	if err != nil {
+		return fmt.Errorf("failed to get branches: %w", err)
+	}
+	return branches.ForEach(func(ref *plumbing.Reference) error {
+		if _, err := repo.CommitObject(ref.Hash()); err != nil {
+			return fmt.Errorf("failed to read head of %s: %w", ref.Name().Short(), err)
+		}
+		return nil
+	})
+}
+
+// RepositorySize returns the bytes dbName's Git history takes on disk. The
+// size is measured once and kept until the manager next writes to the
+// repository, so changes made by other processes show after that.
+func (m *Manager) RepositorySize(dbName string) (int64, error) {
+	if !validName(dbName, false) {
+		return 0, fmt.Errorf("%w: database %q", ErrInvalidName, dbName)
+	}
+	m.sizesMu.Lock()
+	size, ok := m.sizes[dbName]
+	gen := m.sizesGen
+	m.sizesMu.Unlock()
+	if ok {
+		return size, nil
+	}
+
+	err := filepath.WalkDir(filepath.Join(m.dataDir, dbName, ".git"), func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.Type().IsRegular() {
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			size += info.Size()
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to measure repository: %w", err)
+	}
+	m.sizesMu.Lock()
+	if m.sizesGen == gen {
+		m.sizes[dbName] = size
+	}
+	m.sizesMu.Unlock()
+	return size, nil
+}
+
+// forgetSize drops the cached size of dbName's repository, which has
+// changed or is about to.
+func (m *Manager) forgetSize(dbName string) {
+	m.sizesMu.Lock()
+	delete(m.sizes, dbName)
+	m.sizesGen++
+	m.sizesMu.Unlock()
+}
+
 func (m *Manager) BranchExists(dbName, branchName string) bool {
 	branches, err := m.ListBranches(dbName)
 	if err != nil {
@@ -0,0 +1,116 @@
+package git
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// newTestManager returns a manager over a fresh data directory holding the
+// database "db".
+func newTestManager(t *testing.T) *Manager {
+	t.Helper()
+	m, err := NewManager(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := m.InitDatabase("db"); err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func TestInitDatabaseIgnoresDefaultBranchSetting(t *testing.T) {
+	t.Setenv("GIT_CONFIG_COUNT", "1")
+	t.Setenv("GIT_CONFIG_KEY_0", "init.defaultBranch")
+	t.Setenv("GIT_CONFIG_VALUE_0", "master")
+	m := newTestManager(t)
+
+	if branches, err := m.ListBranches("db"); err != nil || len(branches) != 1 || branches[0] != "main" {
+		t.Fatalf("branches of a new database: %q, %v, want [main]", branches, err)
+	}
+	if err := m.ForkBranch("db", "dev/x", "main", NewBranchMeta(time.Now(), 0, false)); err != nil {
+		t.Fatalf("fork main: %v", err)
+	}
+}
+
+func TestForkBranchRejectsNestedNames(t *testing.T) {
+	m := newTestManager(t)
+	meta := NewBranchMeta(time.Now(), 0, false)
+
+	if err := m.ForkBranch("db", "dev/x", "main", meta); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"dev", "dev/x/y", "dev/x"} {
+		if err := m.ForkBranch("db", name, "main", meta); !errors.Is(err, ErrBranchExists) {
+			t.Errorf("ForkBranch(%q): %v, want %v", name, err, ErrBranchExists)
+		}
+	}
+
+	// The rejected forks must leave dev/x untouched.
+	if _, err := os.Stat(m.GetBranchPath("db", "dev/x")); err != nil {
+		t.Fatalf("dev/x lost its database: %v", err)
+	}
+	if _, err := m.BranchMeta("db", "dev/x"); err != nil {
+		t.Fatalf("dev/x lost its metadata: %v", err)
+	}
+	if err := m.ForkBranch("db", "dev2", "main", meta); err != nil {
+		t.Errorf("ForkBranch(dev2): %v", err)
+	}
+}
+
+func TestForkBranchCleansUpOnlyItsOwnFiles(t *testing.T) {
+	m := newTestManager(t)
+	meta := NewBranchMeta(time.Now(), 0, false)
+
+	if err := m.ForkBranch("db", "a/b", "nosuch", meta); !errors.Is(err, ErrBranchNotFound) {
+		t.Fatalf("fork of a missing branch: %v, want %v", err, ErrBranchNotFound)
+	}
+
+	// A failure after the worktree was created removes it again, but only
+	// as far up as the directories are empty.
+	if err := m.ForkBranch("db", "a/b", "main", meta); err != nil {
+		t.Fatal(err)
+	}
+	stray := m.GetBranchPath("db", "a/c")
+	if err := os.MkdirAll(stray, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.ForkBranch("db", "a/c", "main", meta); err == nil {
+		t.Fatal("ForkBranch over a directory succeeded")
+	}
+	if m.BranchExists("db", "a/c") {
+		t.Error("failed fork left a branch behind")
+	}
+	if _, err := os.Stat(m.GetBranchPath("db", "a/b")); err != nil {
+		t.Errorf("failed fork of a/c removed a/b: %v", err)
+	}
+}
+
+func TestRepositorySizeIsCachedUntilTheRepositoryChanges(t *testing.T) {
+	m := newTestManager(t)
+	before, err := m.RepositorySize("db")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Files the manager did not write go unnoticed.
+	if err := os.WriteFile(filepath.Join(m.dataDir, "db", ".git", "extra"), make([]byte, 4096), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if size, err := m.RepositorySize("db"); err != nil || size != before {
+		t.Errorf("size after an outside write: %d, %v, want the cached %d", size, err, before)
+	}
+
+	if err := os.WriteFile(m.GetBranchPath("db", "main"), []byte("new contents"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.CommitBranch("db", "main", "change"); err != nil {
+		t.Fatal(err)
+	}
+	if size, err := m.RepositorySize("db"); err != nil || size < before+4096 {
+		t.Errorf("size after a commit: %d, %v, want at least %d", size, err, before+4096)
+	}
+}
@@ -0,0 +1,116 @@
+package git
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/go-git/go-git/v6"
+	"github.com/go-git/go-git/v6/plumbing"
+	"github.com/go-git/go-git/v6/plumbing/object"
+)
+
+// HistorySize is how much of a database's Git history a branch holds.
+type HistorySize struct {
+	// Bytes counts every commit, tree and database file version reachable
+	// from the branch.
+	Bytes int64
+	// SharedBytes counts those also reachable from another branch, which
+	// deleting the branch would not free.
+	SharedBytes int64
+}
+
+// HistorySizes returns the history size of every branch of dbName. Objects
+// are measured as stored: loose objects by their compressed file size and
+// packed ones by their uncompressed size.
+func (m *Manager) HistorySizes(dbName string) (map[string]HistorySize, error) {
+	repo, err := m.open(dbName)
+	if err != nil {
+		return nil, err
+	}
+
+	branches, err := m.ListBranches(dbName)
+	if err != nil {
+		return nil, err
+	}
+
+	reachable := make(map[string]map[plumbing.Hash]bool, len(branches))
+	holders := make(map[plumbing.Hash]int)
+	for _, branch := range branches {
+		objects, err := m.branchObjects(repo, branch)
+		if err != nil {
+			return nil, err
+		}
+		reachable[branch] = objects
+		for h := range objects {
+			holders[h]++
+		}
+	}
+
+	sizes := make(map[plumbing.Hash]int64, len(holders))
+	for h := range holders {
+		if sizes[h], err = m.objectSize(repo, dbName, h); err != nil {
+			return nil, fmt.Errorf("failed to measure object %s: %w", h, err)
+		}
+	}
+
+	history := make(map[string]HistorySize, len(branches))
+	for branch, objects := range reachable {
+		var hs HistorySize
+		for h := range objects {
+			hs.Bytes += sizes[h]
+			if holders[h] > 1 {
+				hs.SharedBytes += sizes[h]
+			}
+		}
+		history[branch] = hs
+	}
+	return history, nil
+}
+
+// branchObjects returns the commits, trees and blobs reachable from branch.
+func (m *Manager) branchObjects(repo *git.Repository, branch string) (map[plumbing.Hash]bool, error) {
+	ref, err := m.branchRef(repo, branch)
+	if err != nil {
+		return nil, err
+	}
+	head, err := object.GetCommit(repo.Storer, ref.Hash())
+	if err != nil {
+		return nil, fmt.Errorf("failed to read head of %s: %w", branch, err)
+	}
+
+	objects := make(map[plumbing.Hash]bool)
+	err = object.NewCommitPreorderIter(head, nil, nil).ForEach(func(c *object.Commit) error {
+		objects[c.Hash] = true
+		if objects[c.TreeHash] {
+			return nil
+		}
+		objects[c.TreeHash] = true
+		tree, err := c.Tree()
+		if err != nil {
+			return err
+		}
+		for _, entry := range tree.Entries {
+			objects[entry.Hash] = true
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to walk history of %s: %w", branch, err)
+	}
+	return objects, nil
+}
+
+// objectSize returns the bytes h takes in dbName's repository.
+func (m *Manager) objectSize(repo *git.Repository, dbName string, h plumbing.Hash) (int64, error) {
+	hex := h.String()
+	info, err := os.Stat(filepath.Join(m.dataDir, dbName, ".git", "objects", hex[:2], hex[2:]))
+	if err == nil {
+		return info.Size(), nil
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return 0, err
+	}
+	return repo.Storer.EncodedObjectSize(h)
+}
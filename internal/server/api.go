@@ -0,0 +1,299 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/bxrne/branchlore/internal/database"
+	"github.com/bxrne/branchlore/internal/git"
+)
+
+const maxRequestBody = 1 << 20
+
+type databaseInfo struct {
+	Name     string   `json:"name"`
+	Branches []string `json:"branches"`
+}
+
+type branchInfo struct {
+	Name      string     `json:"name"`
+	Database  string     `json:"database"`
+	Ephemeral bool       `json:"ephemeral,omitempty"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+func newBranchInfo(dbName, branch string, meta git.BranchMeta) branchInfo {
+	info := branchInfo{Name: branch, Database: dbName, Ephemeral: meta.Ephemeral}
+	if !meta.ExpiresAt.IsZero() {
+		info.ExpiresAt = &meta.ExpiresAt
+	}
+	return info
+}
+
+type databaseList struct {
+	Databases []string `json:"databases"`
+}
+
+type branchList struct {
+	Branches []string `json:"branches"`
+}
+
+type createRequest struct {
+	Name string `json:"name"`
+}
+
+type createBranchRequest struct {
+	Name string `json:"name"`
+	// From is the branch to copy, main when empty.
+	From string `json:"from,omitempty"`
+	// TTL, such as "24h", has the branch deleted once it passes.
+	// Ephemeral branches without one live for Config.EphemeralTTL.
+	TTL       string `json:"ttl,omitempty"`
+	Ephemeral bool   `json:"ephemeral,omitempty"`
+}
+
+// meta returns the metadata of the branch the request creates at now.
+// Ephemeral branches without a TTL get ephemeralTTL, or
+// git.DefaultEphemeralTTL when that is zero.
+func (req *createBranchRequest) meta(now time.Time, ephemeralTTL time.Duration) (git.BranchMeta, error) {
+	var ttl time.Duration
+	if req.TTL != "" {
+		d, err := time.ParseDuration(req.TTL)
+		if err != nil || d <= 0 {
+			return git.BranchMeta{}, fmt.Errorf("%w: invalid ttl %q", database.ErrInvalidArgument, req.TTL)
+		}
+		ttl = d
+	}
+	if ttl == 0 && req.Ephemeral {
+		ttl = ephemeralTTL
+	}
+	return git.NewBranchMeta(now, ttl, req.Ephemeral), nil
+}
+
+type commitRequest struct {
+	Message string `json:"message,omitempty"`
+}
+
+type mergeRequest struct {
+	// From is the branch to merge.
+	From    string `json:"from"`
+	Message string `json:"message,omitempty"`
+}
+
+type commitInfo struct {
+	Hash string `json:"hash"`
+}
+
+func (s *Server) handleListDatabases(w http.ResponseWriter, r *http.Request) {
+	databases, err := s.listDatabases(r.Context())
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, databaseList{Databases: nonNil(databases)})
+}
+
+func (s *Server) handleCreateDatabase(w http.ResponseWriter, r *http.Request) {
+	var req createRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	if err := s.createDatabase(r.Context(), req.Name); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Location", "/v1/databases/"+url.PathEscape(req.Name))
+	writeJSON(w, http.StatusCreated, databaseInfo{Name: req.Name, Branches: []string{"main"}})
+}
+
+func (s *Server) handleGetDatabase(w http.ResponseWriter, r *http.Request) {
+	dbName := r.PathValue("db")
+	branches, err := s.listBranches(r.Context(), dbName)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, databaseInfo{Name: dbName, Branches: nonNil(branches)})
+}
+
+func (s *Server) handleDeleteDatabase(w http.ResponseWriter, r *http.Request) {
+	if err := s.deleteDatabase(r.Context(), r.PathValue("db")); err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handleListBranches(w http.ResponseWriter, r *http.Request) {
+	branches, err := s.listBranches(r.Context(), r.PathValue("db"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, branchList{Branches: nonNil(branches)})
+}
+
+func (s *Server) handleCreateBranch(w http.ResponseWriter, r *http.Request) {
+	var req createBranchRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	meta, err := req.meta(time.Now(), s.cfg().EphemeralTTL)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	dbName := r.PathValue("db")
+	if err := s.createBranch(r.Context(), dbName, req.Name, req.From, meta); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Location", "/v1/databases/"+url.PathEscape(dbName)+"/branches/"+url.PathEscape(req.Name))
+	writeJSON(w, http.StatusCreated, newBranchInfo(dbName, req.Name, meta))
+}
+
+func (s *Server) handleGetBranch(w http.ResponseWriter, r *http.Request) {
+	dbName, branch := r.PathValue("db"), r.PathValue("branch")
+	meta, err := s.getBranch(r.Context(), dbName, branch)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, newBranchInfo(dbName, branch, meta))
+}
+
+func (s *Server) handleDeleteBranch(w http.ResponseWriter, r *http.Request) {
+	if err := s.deleteBranch(r.Context(), r.PathValue("db"), r.PathValue("branch")); err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handleCommitBranch(w http.ResponseWriter, r *http.Request) {
+	var req commitRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	hash, err := s.commitBranch(r.Context(), r.PathValue("db"), r.PathValue("branch"), req.Message)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, commitInfo{Hash: hash})
+}
+
+func (s *Server) handleMergeBranch(w http.ResponseWriter, r *http.Request) {
+	var req mergeRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	hash, err := s.mergeBranch(r.Context(), r.PathValue("db"), r.PathValue("branch"), req.From, req.Message)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, commitInfo{Hash: hash})
+}
+
+func (s *Server) handleDiffBranches(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	diff, err := s.diffBranches(r.Context(), r.PathValue("db"), q.Get("base"), q.Get("head"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, diff)
+}
+
+func (s *Server) handleV1Query(w http.ResponseWriter, r *http.Request) {
+	var req queryRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	s.runQuery(w, r, r.PathValue("db"), r.PathValue("branch"), req)
+}
+
+func (s *Server) handleNextCursor(w http.ResponseWriter, r *http.Request) {
+	s.nextPage(w, r, r.PathValue("cursor"))
+}
+
+func (s *Server) handleCloseCursor(w http.ResponseWriter, r *http.Request) {
+	s.closeCursor(w, r, r.PathValue("cursor"))
+}
+
+// decodeJSON reads a single JSON object from the request body into v,
+// rejecting unknown fields. It writes an error response and returns false on
+// failure.
+func decodeJSON(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestBody))
+	dec.DisallowUnknownFields()
+	dec.UseNumber()
+	if err := dec.Decode(v); err != nil {
+		msg := fmt.Sprintf("Invalid JSON body: %v", err)
+		if errors.Is(err, io.EOF) {
+			msg = "JSON body required"
+		}
+		writeErrorCode(w, http.StatusBadRequest, CodeInvalidArgument, msg)
+		return false
+	}
+	return true
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func nonNil(s []string) []string {
+	if s == nil {
+		return []string{}
+	}
+	return s
+}
+
+// statusProbe records what a handler would respond without writing anything.
+type statusProbe struct {
+	header http.Header
+	status int
+}
+
+func (p *statusProbe) Header() http.Header         { return p.header }
+func (p *statusProbe) Write(b []byte) (int, error) { return len(b), nil }
+func (p *statusProbe) WriteHeader(status int)      { p.status = status }
+
+// withErrorEnvelope answers requests the mux has no route for with the same
+// JSON error envelope as the handlers, instead of the mux's plain text.
+func withErrorEnvelope(mux *http.ServeMux) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, pattern := mux.Handler(r); pattern != "" {
+			mux.ServeHTTP(w, r)
+			return
+		}
+
+		probe := &statusProbe{header: http.Header{}}
+		mux.ServeHTTP(probe, r)
+		switch probe.status {
+		case http.StatusNotFound:
+			writeErrorCode(w, http.StatusNotFound, CodeNotFound, "No such endpoint")
+		case http.StatusMethodNotAllowed:
+			w.Header().Set("Allow", probe.header.Get("Allow"))
+			writeErrorCode(w, http.StatusMethodNotAllowed, CodeMethodNotAllowed, "Method not allowed")
+		default:
+			mux.ServeHTTP(w, r)
+		}
+	})
+}
@@ -0,0 +1,130 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/bxrne/branchlore/internal/database"
+)
+
+func TestDatabaseAndBranchLifecycle(t *testing.T) {
+	s := newTestServer(t, nil)
+	h := s.handler()
+
+	w := do(t, h, http.MethodPost, "/v1/databases", createRequest{Name: "shop"})
+	if w.Code != http.StatusCreated || w.Header().Get("Location") != "/v1/databases/shop" {
+		t.Fatalf("create database: %d %s, Location %q", w.Code, w.Body, w.Header().Get("Location"))
+	}
+	if w := do(t, h, http.MethodPost, "/v1/databases", createRequest{Name: "shop"}); w.Code != http.StatusConflict || errorCode(t, w) != CodeDBExists {
+		t.Errorf("create it again: %d %s, want 409 %s", w.Code, w.Body, CodeDBExists)
+	}
+
+	var databases databaseList
+	decode(t, do(t, h, http.MethodGet, "/v1/databases", nil), &databases)
+	if !reflect.DeepEqual(databases.Databases, []string{"shop"}) {
+		t.Errorf("databases %q, want [shop]", databases.Databases)
+	}
+
+	main := "/v1/databases/shop/branches/main"
+	for _, query := range []string{"CREATE TABLE t (a INTEGER)", "INSERT INTO t VALUES (1)"} {
+		if w := do(t, h, http.MethodPost, main+"/query", queryRequest{Query: query}); w.Code != http.StatusOK {
+			t.Fatalf("%s: %d %s", query, w.Code, w.Body)
+		}
+	}
+	if w := do(t, h, http.MethodPost, main+"/commits", commitRequest{Message: "add t"}); w.Code != http.StatusCreated {
+		t.Fatalf("commit: %d %s", w.Code, w.Body)
+	}
+
+	w = do(t, h, http.MethodPost, "/v1/databases/shop/branches", createBranchRequest{Name: "dev"})
+	if w.Code != http.StatusCreated || w.Header().Get("Location") != "/v1/databases/shop/branches/dev" {
+		t.Fatalf("create branch: %d %s, Location %q", w.Code, w.Body, w.Header().Get("Location"))
+	}
+	var info branchInfo
+	decode(t, do(t, h, http.MethodGet, "/v1/databases/shop/branches/dev", nil), &info)
+	if info.Name != "dev" || info.Database != "shop" || info.Ephemeral {
+		t.Errorf("branch %+v", info)
+	}
+
+	dev := "/v1/databases/shop/branches/dev"
+	do(t, h, http.MethodPost, dev+"/query", queryRequest{Query: "INSERT INTO t VALUES (2)"})
+	var result database.QueryResult
+	decode(t, do(t, h, http.MethodPost, main+"/query", queryRequest{Query: "SELECT count(*) FROM t"}), &result)
+	if len(result.Rows) != 1 || result.Rows[0][0] != 1.0 {
+		t.Errorf("main sees %v rows after a write on dev, want 1", result.Rows)
+	}
+
+	var branches branchList
+	decode(t, do(t, h, http.MethodGet, "/v1/databases/shop/branches", nil), &branches)
+	if !reflect.DeepEqual(branches.Branches, []string{"dev", "main"}) {
+		t.Errorf("branches %q, want [dev main]", branches.Branches)
+	}
+
+	if w := do(t, h, http.MethodDelete, dev, nil); w.Code != http.StatusNoContent {
+		t.Fatalf("delete branch: %d %s", w.Code, w.Body)
+	}
+	if w := do(t, h, http.MethodGet, dev, nil); w.Code != http.StatusNotFound || errorCode(t, w) != CodeBranchNotFound {
+		t.Errorf("deleted branch: %d %s, want 404 %s", w.Code, w.Body, CodeBranchNotFound)
+	}
+	if w := do(t, h, http.MethodDelete, "/v1/databases/shop", nil); w.Code != http.StatusNoContent {
+		t.Fatalf("delete database: %d %s", w.Code, w.Body)
+	}
+	if w := do(t, h, http.MethodGet, "/v1/databases/shop", nil); w.Code != http.StatusNotFound || errorCode(t, w) != CodeDBNotFound {
+		t.Errorf("deleted database: %d %s, want 404 %s", w.Code, w.Body, CodeDBNotFound)
+	}
+}
+
+func TestRejectsInvalidJSONBodies(t *testing.T) {
+	s := newTestServer(t, nil)
+	h := s.handler()
+
+	for _, body := range []string{"{", `{"name": 1}`, `{"name": "a", "unknown": true}`} {
+		r := httptest.NewRequest(http.MethodPost, "/v1/databases", strings.NewReader(body))
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if w.Code != http.StatusBadRequest || errorCode(t, w) != CodeInvalidArgument {
+			t.Errorf("body %s: %d %s, want 400 %s", body, w.Code, w.Body, CodeInvalidArgument)
+		}
+	}
+}
+
+// doForm posts form to target on h.
+func doForm(h http.Handler, target string, form url.Values) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodPost, target, strings.NewReader(form.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+func TestLegacyEndpointsAreDeprecated(t *testing.T) {
+	s := newTestServer(t, nil)
+	newTestDatabase(t, s, "db")
+	h := s.handler()
+
+	for _, tt := range []struct {
+		name      string
+		w         *httptest.ResponseRecorder
+		status    int
+		successor string
+	}{
+		{"query", doForm(h, "/query?db=db&branch=main", url.Values{"query": {"SELECT 1"}}), http.StatusOK, "/v1/databases/db/branches/main/query"},
+		{"create branch", doForm(h, "/branch?db=db&action=create&branch=dev", nil), http.StatusOK, "/v1/databases/db/branches"},
+		{"list branches", do(t, h, http.MethodGet, "/branch?db=db&action=list", nil), http.StatusOK, "/v1/databases/db/branches"},
+		{"delete branch", doForm(h, "/branch?db=db&action=delete&branch=dev", nil), http.StatusOK, "/v1/databases/db/branches/dev"},
+		{"health", do(t, h, http.MethodGet, "/health", nil), http.StatusOK, "/healthz"},
+	} {
+		if tt.w.Code != tt.status {
+			t.Errorf("%s: %d %s, want %d", tt.name, tt.w.Code, tt.w.Body, tt.status)
+		}
+		if tt.w.Header().Get("Deprecation") != "true" {
+			t.Errorf("%s: no Deprecation header", tt.name)
+		}
+		if link, want := tt.w.Header().Get("Link"), "<"+tt.successor+`>; rel="successor-version"`; link != want {
+			t.Errorf("%s: Link %q, want %q", tt.name, link, want)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package server
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/bxrne/branchlore/internal/audit"
+	"github.com/bxrne/branchlore/internal/database"
+)
+
+type clientKey struct{}
+
+// client describes where a request or connection came from, for the audit
+// log. user is the name the client claimed, such as a PostgreSQL user; the
+// authenticated identity takes precedence over it.
+type client struct {
+	protocol string
+	addr     string
+	user     string
+}
+
+func withClient(ctx context.Context, protocol, addr, user string) context.Context {
+	return context.WithValue(ctx, clientKey{}, client{protocol: protocol, addr: addr, user: user})
+}
+
+// withHTTPClient records the client of every HTTP request.
+func withHTTPClient(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r.WithContext(withClient(r.Context(), "http", r.RemoteAddr, "")))
+	})
+}
+
+// record appends e to the audit log, if enabled, on behalf of the caller
+// in ctx. A failure to record does not fail the operation, which has
+// already happened.
+func (s *Server) record(ctx context.Context, e audit.Entry) {
+	if s.audit == nil {
+		return
+	}
+	c, _ := ctx.Value(clientKey{}).(client)
+	e.User, e.Client, e.Protocol = c.user, c.addr, c.protocol
+	if id := identity(ctx); id != nil {
+		e.User = id.Name
+	}
+	if err := s.audit.Append(&e); err != nil {
+		s.logger.ErrorContext(ctx, "Failed to write audit log", slog.String("db", e.Database), slog.Any("error", err))
+	}
+}
+
+// recordStatement audits a statement run by the database manager.
+func (s *Server) recordStatement(ctx context.Context, x database.Execution) {
+	e := audit.Entry{
+		Time:         x.Start,
+		Database:     x.Database,
+		Branch:       x.Branch,
+		Action:       audit.ActionQuery,
+		Detail:       x.Query,
+		Outcome:      audit.OutcomeOK,
+		Duration:     x.Duration,
+		RowsAffected: x.RowsAffected,
+	}
+	if x.Err != nil {
+		e.Outcome, e.Error = audit.OutcomeError, x.Err.Error()
+	}
+	s.record(ctx, e)
+}
+
+// recordOperation audits a database or branch operation that started at
+// start and ended with *err. detail, if not nil, is read once the operation
+// is over, so it can point at a result. It is meant to be deferred.
+func (s *Server) recordOperation(ctx context.Context, action audit.Action, dbName, branch string, detail *string, start time.Time, err *error) {
+	e := audit.Entry{
+		Time:     start,
+		Database: dbName,
+		Branch:   branch,
+		Action:   action,
+		Outcome:  audit.OutcomeOK,
+		Duration: time.Since(start),
+	}
+	if detail != nil {
+		e.Detail = *detail
+	}
+	if *err != nil {
+		e.Outcome, e.Error = audit.OutcomeError, (*err).Error()
+	}
+	s.record(ctx, e)
+}
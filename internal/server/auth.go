@@ -0,0 +1,140 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/bxrne/branchlore/internal/auth"
+	"github.com/bxrne/branchlore/internal/database"
+)
+
+type identityKey struct{}
+
+// authorize wraps a route's handler so it requires a valid token, or a
+// client certificate of a known identity when no token is given. Public
+// routes skip it, and with auth disabled every route is public. Auth can be
+// turned on and off by Reload, so it is checked on each request. What the
+// caller may do is checked by each operation.
+func (s *Server) authorize(public bool, next http.HandlerFunc) http.HandlerFunc {
+	if public {
+		return next
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		if s.tokens() == nil {
+			next(w, r)
+			return
+		}
+		ctx, err := s.authenticateRequest(r)
+		if err != nil {
+			if errors.Is(err, auth.ErrUnauthenticated) {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="branchlore"`)
+			}
+			writeError(w, err)
+			return
+		}
+		next(w, r.WithContext(ctx))
+	}
+}
+
+// authenticateRequest authenticates r by its bearer token, or by its client
+// certificate when it has no token, and returns its context carrying the
+// caller's identity.
+func (s *Server) authenticateRequest(r *http.Request) (context.Context, error) {
+	token := bearerToken(r.Header.Get("Authorization"))
+	if token == "" && r.TLS != nil && len(r.TLS.VerifiedChains) > 0 {
+		return s.authenticateCert(r.Context(), r.TLS)
+	}
+	return s.authenticate(r.Context(), token)
+}
+
+// authenticate checks secret against the token store and returns ctx
+// carrying the token's identity. It returns ctx unchanged if auth has been
+// disabled.
+func (s *Server) authenticate(ctx context.Context, secret string) (context.Context, error) {
+	tokens := s.tokens()
+	if tokens == nil {
+		return ctx, nil
+	}
+	id, err := tokens.Authenticate(secret)
+	if err != nil {
+		return nil, err
+	}
+	return withIdentity(ctx, id), nil
+}
+
+// withIdentity returns ctx carrying id, whose name also tags the records
+// logged with it.
+func withIdentity(ctx context.Context, id *auth.Identity) context.Context {
+	ctx = withLogAttrs(ctx, slog.String("user", id.Name))
+	return context.WithValue(ctx, identityKey{}, id)
+}
+
+func identity(ctx context.Context) *auth.Identity {
+	id, _ := ctx.Value(identityKey{}).(*auth.Identity)
+	return id
+}
+
+// allow checks that the caller has perm on dbName@branch, or on the
+// database itself when branch is empty. With auth disabled everything is
+// allowed.
+func (s *Server) allow(ctx context.Context, perm auth.Permission, dbName, branch string) error {
+	if s.tokens() == nil {
+		return nil
+	}
+	id := identity(ctx)
+	if id == nil {
+		return auth.ErrUnauthenticated
+	}
+	if !id.Allows(perm, dbName, branch) {
+		target := dbName
+		if branch != "" {
+			target += "@" + branch
+		}
+		return fmt.Errorf("%w: %s may not %s %s", auth.ErrPermissionDenied, id.Name, perm, target)
+	}
+	return nil
+}
+
+// sees checks that the caller holds some grant on dbName.
+func (s *Server) sees(ctx context.Context, dbName string) error {
+	if s.tokens() == nil {
+		return nil
+	}
+	id := identity(ctx)
+	if id == nil {
+		return auth.ErrUnauthenticated
+	}
+	if !id.Sees(dbName) {
+		return fmt.Errorf("%w: %s has no access to %s", auth.ErrPermissionDenied, id.Name, dbName)
+	}
+	return nil
+}
+
+// branchContext authorizes running statements on dbName@branch. Statements
+// run with the returned context cannot write unless the caller may write
+// the branch and it is not protected.
+func (s *Server) branchContext(ctx context.Context, dbName, branch string) (context.Context, error) {
+	if err := s.allow(ctx, auth.PermRead, dbName, branch); err != nil {
+		return nil, err
+	}
+	protection, err := s.gitMgr.Protection(dbName, branch)
+	if err != nil {
+		return nil, err
+	}
+	if protection != nil || s.allow(ctx, auth.PermWrite, dbName, branch) != nil {
+		ctx = database.WithReadOnly(ctx)
+	}
+	return ctx, nil
+}
+
+func bearerToken(header string) string {
+	scheme, token, ok := strings.Cut(header, " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return ""
+	}
+	return strings.TrimSpace(token)
+}
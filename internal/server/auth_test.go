@@ -0,0 +1,159 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bxrne/branchlore/internal/auth"
+)
+
+func TestAuthRequiresToken(t *testing.T) {
+	s := newTestServer(t, &Config{Auth: true})
+	newTestDatabase(t, s, "db")
+	secret := newTestToken(t, s, "ci", auth.ScopeRead)
+	h := s.handler()
+
+	for _, token := range []string{"", "blt_wrong"} {
+		w := doAs(t, h, token, http.MethodGet, "/v1/databases", nil)
+		if w.Code != http.StatusUnauthorized || w.Header().Get("WWW-Authenticate") != `Bearer realm="branchlore"` {
+			t.Errorf("token %q: %d %q, want 401 with a Bearer challenge", token, w.Code, w.Header().Get("WWW-Authenticate"))
+		}
+		if code := errorCode(t, w); code != CodeUnauthenticated {
+			t.Errorf("token %q: code %s, want %s", token, code, CodeUnauthenticated)
+		}
+	}
+
+	if w := do(t, h, http.MethodGet, "/healthz", nil); w.Code != http.StatusOK {
+		t.Errorf("public route without a token: %d", w.Code)
+	}
+	if w := doAs(t, h, secret, http.MethodGet, "/v1/databases", nil); w.Code != http.StatusOK {
+		t.Errorf("with a token: %d %s", w.Code, w.Body)
+	}
+	w := doAs(t, h, secret, http.MethodPost, "/v1/databases", createRequest{Name: "other"})
+	if w.Code != http.StatusForbidden || errorCode(t, w) != CodePermissionDenied {
+		t.Errorf("create a database with a read token: %d %s", w.Code, w.Body)
+	}
+
+	if err := s.tokens().Revoke("ci"); err != nil {
+		t.Fatal(err)
+	}
+	if w := doAs(t, h, secret, http.MethodGet, "/v1/databases", nil); w.Code != http.StatusUnauthorized {
+		t.Errorf("revoked token: %d, want 401", w.Code)
+	}
+}
+
+func TestBranchPermissions(t *testing.T) {
+	s := newTestServer(t, &Config{Auth: true})
+	newTestDatabase(t, s, "app")
+	newTestDatabase(t, s, "other")
+	store := s.tokens()
+	for _, g := range []auth.Grant{
+		{Database: "app", Branches: "main", Permissions: []auth.Permission{auth.PermRead}},
+		{Database: "app", Branches: "dev/*", Permissions: []auth.Permission{auth.PermWrite, auth.PermBranchCreate}},
+	} {
+		if err := store.Grant("dev", g); err != nil {
+			t.Fatal(err)
+		}
+	}
+	secret, _, err := store.Create("anna", nil, []string{"dev"}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := s.handler()
+
+	w := doAs(t, h, secret, http.MethodGet, "/v1/databases", nil)
+	var dbs databaseList
+	decode(t, w, &dbs)
+	if len(dbs.Databases) != 1 || dbs.Databases[0] != "app" {
+		t.Errorf("listed databases %v, want only app", dbs.Databases)
+	}
+	if w := doAs(t, h, secret, http.MethodGet, "/v1/databases/other", nil); w.Code != http.StatusForbidden {
+		t.Errorf("database without grants: %d, want 403", w.Code)
+	}
+
+	if w := doAs(t, h, secret, http.MethodPost, "/v1/databases/app/branches", createBranchRequest{Name: "dev/anna"}); w.Code != http.StatusCreated {
+		t.Fatalf("create dev/anna: %d %s", w.Code, w.Body)
+	}
+	if w := doAs(t, h, secret, http.MethodPost, "/v1/databases/app/branches", createBranchRequest{Name: "feature"}); w.Code != http.StatusForbidden || errorCode(t, w) != CodePermissionDenied {
+		t.Errorf("create feature: %d %s, want 403", w.Code, w.Body)
+	}
+	if w := doAs(t, h, secret, http.MethodPost, "/v1/databases/app/branches/dev%2Fanna/query", queryRequest{Query: "CREATE TABLE t (id INTEGER)"}); w.Code != http.StatusOK {
+		t.Errorf("write dev/anna: %d %s", w.Code, w.Body)
+	}
+	if w := doAs(t, h, secret, http.MethodPost, "/v1/databases/app/branches/main/query", queryRequest{Query: "CREATE TABLE t (id INTEGER)"}); w.Code != http.StatusForbidden {
+		t.Errorf("write main: %d %s, want 403", w.Code, w.Body)
+	}
+	if w := doAs(t, h, secret, http.MethodPost, "/v1/databases/app/branches/main/query", queryRequest{Query: "SELECT 1"}); w.Code != http.StatusOK {
+		t.Errorf("read main: %d %s", w.Code, w.Body)
+	}
+	if w := doAs(t, h, secret, http.MethodDelete, "/v1/databases/app/branches/dev%2Fanna", nil); w.Code != http.StatusForbidden {
+		t.Errorf("delete dev/anna without branch-delete: %d, want 403", w.Code)
+	}
+}
+
+// Permissions are checked per branch, so a statement must not reach files
+// other than its branch's.
+func TestQueriesCannotReachOtherDatabases(t *testing.T) {
+	s := newTestServer(t, &Config{Auth: true})
+	newTestDatabase(t, s, "app")
+	newTestDatabase(t, s, "secret")
+	h := s.handler()
+	if _, err := s.dbMgr.Exec(context.Background(), "secret", "main", "CREATE TABLE keys (k TEXT)", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	store := s.tokens()
+	for role, perms := range map[string][]auth.Permission{
+		"writer": {auth.PermRead, auth.PermWrite},
+		"reader": {auth.PermRead},
+	} {
+		if err := store.Grant(role, auth.Grant{Database: "app", Branches: "*", Permissions: perms}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writer, _, err := store.Create("writer", nil, []string{"writer"}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reader, _, err := store.Create("reader", nil, []string{"reader"}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	secretPath := s.gitMgr.GetBranchPath("secret", "main")
+	exfil := filepath.Join(t.TempDir(), "exfil.db")
+	for _, tt := range []struct {
+		token string
+		req   queryRequest
+	}{
+		{writer, queryRequest{Query: "ATTACH DATABASE '" + secretPath + "' AS sec"}},
+		{writer, queryRequest{Query: "ATTACH DATABASE ? AS sec", Args: []interface{}{secretPath}}},
+		{reader, queryRequest{Query: "ATTACH DATABASE '" + secretPath + "' || '' AS sec"}},
+		{reader, queryRequest{Query: "VACUUM INTO '" + exfil + "'"}},
+		{writer, queryRequest{Query: "VACUUM main INTO ?", Args: []interface{}{exfil}}},
+	} {
+		w := doAs(t, h, tt.token, http.MethodPost, "/v1/databases/app/branches/main/query", tt.req)
+		if w.Code != http.StatusForbidden {
+			t.Errorf("%s: %d %s, want 403", tt.req.Query, w.Code, w.Body)
+		}
+	}
+	w := doAs(t, h, writer, http.MethodPost, "/v1/databases/app/branches/main/query", queryRequest{Query: "SELECT * FROM sec.keys"})
+	if w.Code == http.StatusOK {
+		t.Errorf("read another database through an attachment: %s", w.Body)
+	}
+	if _, err := os.Stat(exfil); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("VACUUM INTO wrote %s: %v", exfil, err)
+	}
+
+	// A plain VACUUM attaches a temporary database, and still runs.
+	if w := doAs(t, h, writer, http.MethodPost, "/v1/databases/app/branches/main/query", queryRequest{Query: "VACUUM"}); w.Code != http.StatusOK {
+		t.Errorf("VACUUM: %d %s", w.Code, w.Body)
+	}
+	if w := doAs(t, h, reader, http.MethodPost, "/v1/databases/app/branches/main/query", queryRequest{Query: "VACUUM"}); w.Code != http.StatusForbidden {
+		t.Errorf("VACUUM with a read token: %d %s, want 403", w.Code, w.Body)
+	}
+}
@@ -0,0 +1,160 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/bxrne/branchlore/internal/database"
+)
+
+// openCursor is a paged SELECT kept alive between next page requests.
+type openCursor struct {
+	dbName   string
+	cursor   *database.Cursor
+	cancel   context.CancelFunc
+	pageSize int
+	// timeout bounds opening the cursor and fetching each page. Zero is
+	// no limit.
+	timeout time.Duration
+}
+
+func (c *openCursor) database() string {
+	return c.dbName
+}
+
+func (c *openCursor) close() {
+	c.cursor.Close()
+	c.cancel()
+}
+
+// pageQuery opens a cursor for query and responds with its first page. When
+// more rows remain the response carries a cursor ID for /v1/cursors/{cursor}.
+// Opening the cursor and fetching each page must each finish within timeout.
+func (s *Server) pageQuery(w http.ResponseWriter, r *http.Request, dbName, branch, query string, args []interface{}, format string, pageSize int, timeout time.Duration) {
+	ctx, cancel := s.sessionContext(r)
+	c := &openCursor{dbName: dbName, cancel: cancel, pageSize: pageSize, timeout: timeout}
+
+	err := c.bounded(func() (err error) {
+		c.cursor, err = s.dbMgr.OpenCursor(ctx, dbName, branch, query, args, format)
+		return err
+	})
+	if err != nil {
+		if c.cursor != nil {
+			c.cursor.Close()
+		}
+		cancel()
+		writeError(w, err)
+		return
+	}
+	s.limits(r.Context(), dbName).limitCursor(c.cursor)
+
+	s.writePage(w, r, "", c)
+}
+
+// bounded runs fn, cancelling the cursor's context if it takes longer than
+// the cursor's timeout. A cursor cancelled this way is dead, so the result
+// is a timeout even if fn happened to finish.
+func (c *openCursor) bounded(fn func() error) error {
+	if c.timeout <= 0 {
+		return fn()
+	}
+	timer := time.AfterFunc(c.timeout, c.cancel)
+	err := fn()
+	if !timer.Stop() {
+		return fmt.Errorf("%w: query ran longer than %s", context.DeadlineExceeded, c.timeout)
+	}
+	return err
+}
+
+func (s *Server) handleQueryNext(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		writeErrorCode(w, http.StatusMethodNotAllowed, CodeMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	id := r.FormValue("cursor")
+	if id == "" {
+		writeErrorCode(w, http.StatusBadRequest, CodeInvalidArgument, "Cursor parameter required")
+		return
+	}
+	deprecated(w, "/v1/cursors/"+url.PathEscape(id))
+
+	s.nextPage(w, r, id)
+}
+
+func (s *Server) nextPage(w http.ResponseWriter, r *http.Request, id string) {
+	c, ok := s.cursors.acquire(id, sessionOwner(r.Context()))
+	if !ok {
+		writeErrorCode(w, http.StatusNotFound, CodeCursorNotFound, "Cursor not found or expired")
+		return
+	}
+
+	s.writePage(w, r, id, c)
+}
+
+func (s *Server) closeCursor(w http.ResponseWriter, r *http.Request, id string) {
+	if _, ok := s.cursors.acquire(id, sessionOwner(r.Context())); !ok {
+		writeErrorCode(w, http.StatusNotFound, CodeCursorNotFound, "Cursor not found or expired")
+		return
+	}
+
+	s.cursors.remove(id)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// writePage reads the next page from c. Exhausted or failed cursors are
+// closed, live ones are (re)registered under their ID.
+func (s *Server) writePage(w http.ResponseWriter, r *http.Request, id string, c *openCursor) {
+	var (
+		rows [][]interface{}
+		done bool
+	)
+	err := c.bounded(func() (err error) {
+		rows, done, err = c.cursor.Page(c.pageSize)
+		return err
+	})
+	if err != nil {
+		s.dropCursor(id, c)
+		writeError(w, err)
+		return
+	}
+
+	result := database.QueryResult{
+		Columns:     c.cursor.Columns(),
+		ColumnTypes: c.cursor.ColumnTypes(),
+		Rows:        rows,
+	}
+
+	switch {
+	case done:
+		result.Truncated = c.cursor.Truncated()
+		s.dropCursor(id, c)
+	case id == "":
+		if id, err = s.cursors.add(sessionOwner(r.Context()), c); err != nil {
+			s.dropCursor("", c)
+			writeError(w, fmt.Errorf("failed to register cursor: %w", err))
+			return
+		}
+		result.Cursor = id
+	default:
+		s.cursors.release(id)
+		result.Cursor = id
+	}
+
+	writeQueryResult(w, result)
+}
+
+func (s *Server) dropCursor(id string, c *openCursor) {
+	if id == "" {
+		c.close()
+		return
+	}
+	s.cursors.remove(id)
+}
+
+func writeQueryResult(w http.ResponseWriter, result database.QueryResult) {
+	writeJSON(w, http.StatusOK, result)
+}
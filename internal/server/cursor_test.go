@@ -0,0 +1,93 @@
+package server
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/bxrne/branchlore/internal/database"
+)
+
+func TestPagedQuery(t *testing.T) {
+	s := newTestServer(t, nil)
+	newTestDatabase(t, s, "db")
+	h := s.handler()
+
+	w := do(t, h, http.MethodPost, testQueryPath, queryRequest{Query: "VALUES (1), (2), (3), (4), (5)", PageSize: 2})
+	var rows [][]interface{}
+	for page := 1; ; page++ {
+		if w.Code != http.StatusOK {
+			t.Fatalf("page %d: %d %s", page, w.Code, w.Body)
+		}
+		var result database.QueryResult
+		decode(t, w, &result)
+		rows = append(rows, result.Rows...)
+		if result.Cursor == "" {
+			break
+		}
+		if len(result.Rows) != 2 {
+			t.Fatalf("page %d has %d rows, want 2", page, len(result.Rows))
+		}
+		w = do(t, h, http.MethodGet, "/v1/cursors/"+result.Cursor, nil)
+	}
+	if len(rows) != 5 {
+		t.Fatalf("got %d rows over all pages, want 5", len(rows))
+	}
+	if s.cursors.len() != 0 {
+		t.Errorf("%d cursors open after the last page", s.cursors.len())
+	}
+}
+
+func TestCloseCursor(t *testing.T) {
+	s := newTestServer(t, nil)
+	newTestDatabase(t, s, "db")
+	h := s.handler()
+
+	w := do(t, h, http.MethodPost, testQueryPath, queryRequest{Query: "VALUES (1), (2)", PageSize: 1})
+	var result database.QueryResult
+	decode(t, w, &result)
+	if result.Cursor == "" {
+		t.Fatalf("no cursor in %s", w.Body)
+	}
+
+	if w := do(t, h, http.MethodDelete, "/v1/cursors/"+result.Cursor, nil); w.Code != http.StatusNoContent {
+		t.Fatalf("close: %d %s", w.Code, w.Body)
+	}
+	w = do(t, h, http.MethodGet, "/v1/cursors/"+result.Cursor, nil)
+	if w.Code != http.StatusNotFound || errorCode(t, w) != CodeCursorNotFound {
+		t.Errorf("next page of a closed cursor: %d %s, want 404 %s", w.Code, w.Body, CodeCursorNotFound)
+	}
+}
+
+func TestIdleCursorsExpire(t *testing.T) {
+	s := newTestServer(t, nil)
+	newTestDatabase(t, s, "db")
+
+	w := do(t, s.handler(), http.MethodPost, testQueryPath, queryRequest{Query: "VALUES (1), (2)", PageSize: 1})
+	if w.Code != http.StatusOK {
+		t.Fatalf("%d %s", w.Code, w.Body)
+	}
+	if n := s.cursors.expire(time.Now().Add(-time.Minute)); n != 0 {
+		t.Fatalf("expired %d cursors used within the last minute", n)
+	}
+	if n := s.cursors.expire(time.Now().Add(time.Minute)); n != 1 {
+		t.Fatalf("expired %d idle cursors, want 1", n)
+	}
+}
+
+func TestPagedQueryTimeout(t *testing.T) {
+	s := newTestServer(t, nil)
+	newTestDatabase(t, s, "db")
+
+	start := time.Now()
+	w := do(t, s.handler(), http.MethodPost, testQueryPath, queryRequest{Query: slowQuery, Timeout: "50ms", PageSize: 10})
+	if w.Code != http.StatusGatewayTimeout || errorCode(t, w) != CodeQueryTimeout {
+		t.Fatalf("%d %s, want 504 %s", w.Code, w.Body, CodeQueryTimeout)
+	}
+	if d := time.Since(start); d > 5*time.Second {
+		t.Errorf("timed out after %v", d)
+	}
+	if s.cursors.len() != 0 {
+		t.Errorf("%d cursors left open", s.cursors.len())
+	}
+}
@@ -0,0 +1,9 @@
+//go:build !unix
+
+package server
+
+import "errors"
+
+func freeSpace(path string) (uint64, error) {
+	return 0, errors.ErrUnsupported
+}
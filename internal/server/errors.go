@@ -0,0 +1,188 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/bxrne/branchlore/internal/auth"
+	"github.com/bxrne/branchlore/internal/database"
+	"github.com/bxrne/branchlore/internal/git"
+	sqlite3 "github.com/mattn/go-sqlite3"
+)
+
+// Stable error codes returned in the "code" field of error responses. SQLite
+// failures use "SQLITE_" followed by the primary result code name, e.g.
+// SQLITE_CONSTRAINT or SQLITE_BUSY.
+const (
+	CodeInvalidArgument  = "INVALID_ARGUMENT"
+	CodeNotFound         = "NOT_FOUND"
+	CodeMethodNotAllowed = "METHOD_NOT_ALLOWED"
+	CodeDBNotFound       = "DB_NOT_FOUND"
+	CodeDBExists         = "DB_EXISTS"
+	CodeBranchNotFound   = "BRANCH_NOT_FOUND"
+	CodeBranchExists     = "BRANCH_EXISTS"
+	CodeBranchProtected  = "BRANCH_PROTECTED"
+	CodeMergeConflict    = "MERGE_CONFLICT"
+	CodeCheckFailed      = "CHECK_FAILED"
+	CodeCursorNotFound   = "CURSOR_NOT_FOUND"
+	CodeTxNotFound       = "TX_NOT_FOUND"
+	CodeQueryTimeout     = "QUERY_TIMEOUT"
+	CodeQueryCancelled   = "QUERY_CANCELLED"
+	CodeUnauthenticated  = "UNAUTHENTICATED"
+	CodePermissionDenied = "PERMISSION_DENIED"
+	CodeShuttingDown     = "SHUTTING_DOWN"
+	CodeRateLimited      = "RATE_LIMITED"
+	CodeTooManyQueries   = "TOO_MANY_QUERIES"
+	CodeInternal         = "INTERNAL"
+)
+
+// ErrorBody is the payload of every error response:
+//
+//	{"error": {"code": "SQLITE_CONSTRAINT", "message": "...", "sqlite_code": 19, "sqlite_extended_code": 2067}}
+type ErrorBody struct {
+	Code               string `json:"code"`
+	Message            string `json:"message"`
+	SQLiteCode         int    `json:"sqlite_code,omitempty"`
+	SQLiteExtendedCode int    `json:"sqlite_extended_code,omitempty"`
+	Retryable          bool   `json:"retryable,omitempty"`
+}
+
+type errorResponse struct {
+	Error ErrorBody `json:"error"`
+}
+
+var sqliteCodeNames = map[sqlite3.ErrNo]string{
+	sqlite3.ErrError:      "ERROR",
+	sqlite3.ErrInternal:   "INTERNAL",
+	sqlite3.ErrPerm:       "PERM",
+	sqlite3.ErrAbort:      "ABORT",
+	sqlite3.ErrBusy:       "BUSY",
+	sqlite3.ErrLocked:     "LOCKED",
+	sqlite3.ErrNomem:      "NOMEM",
+	sqlite3.ErrReadonly:   "READONLY",
+	sqlite3.ErrInterrupt:  "INTERRUPT",
+	sqlite3.ErrIoErr:      "IOERR",
+	sqlite3.ErrCorrupt:    "CORRUPT",
+	sqlite3.ErrNotFound:   "NOTFOUND",
+	sqlite3.ErrFull:       "FULL",
+	sqlite3.ErrCantOpen:   "CANTOPEN",
+	sqlite3.ErrProtocol:   "PROTOCOL",
+	sqlite3.ErrEmpty:      "EMPTY",
+	sqlite3.ErrSchema:     "SCHEMA",
+	sqlite3.ErrTooBig:     "TOOBIG",
+	sqlite3.ErrConstraint: "CONSTRAINT",
+	sqlite3.ErrMismatch:   "MISMATCH",
+	sqlite3.ErrMisuse:     "MISUSE",
+	sqlite3.ErrNoLFS:      "NOLFS",
+	sqlite3.ErrAuth:       "AUTH",
+	sqlite3.ErrFormat:     "FORMAT",
+	sqlite3.ErrRange:      "RANGE",
+	sqlite3.ErrNotADB:     "NOTADB",
+}
+
+// classify maps err to an HTTP status and error body.
+func classify(err error) (int, ErrorBody) {
+	body := ErrorBody{Code: CodeInternal, Message: err.Error()}
+
+	var sqliteErr sqlite3.Error
+	switch {
+	case errors.Is(err, errShuttingDown):
+		body.Code, body.Retryable = CodeShuttingDown, true
+		return http.StatusServiceUnavailable, body
+	case errors.Is(err, errRateLimited):
+		body.Code, body.Retryable = CodeRateLimited, true
+		return http.StatusTooManyRequests, body
+	case errors.Is(err, errTooManyQueries):
+		body.Code, body.Retryable = CodeTooManyQueries, true
+		return http.StatusTooManyRequests, body
+	case errors.Is(err, context.DeadlineExceeded):
+		body.Code, body.Retryable = CodeQueryTimeout, true
+		return http.StatusGatewayTimeout, body
+	case errors.Is(err, context.Canceled):
+		body.Code = CodeQueryCancelled
+		return http.StatusServiceUnavailable, body
+	case errors.Is(err, git.ErrInvalidName), errors.Is(err, database.ErrInvalidArgument):
+		body.Code = CodeInvalidArgument
+		return http.StatusBadRequest, body
+	case errors.Is(err, git.ErrDatabaseNotFound):
+		body.Code = CodeDBNotFound
+		return http.StatusNotFound, body
+	case errors.Is(err, git.ErrDatabaseExists):
+		body.Code = CodeDBExists
+		return http.StatusConflict, body
+	case errors.Is(err, git.ErrBranchNotFound):
+		body.Code = CodeBranchNotFound
+		return http.StatusNotFound, body
+	case errors.Is(err, git.ErrBranchExists):
+		body.Code = CodeBranchExists
+		return http.StatusConflict, body
+	case errors.Is(err, git.ErrBranchProtected):
+		body.Code = CodeBranchProtected
+		return http.StatusConflict, body
+	case errors.Is(err, git.ErrMergeConflict):
+		body.Code = CodeMergeConflict
+		return http.StatusConflict, body
+	case errors.Is(err, database.ErrCheckFailed):
+		body.Code = CodeCheckFailed
+		return http.StatusPreconditionFailed, body
+	case errors.Is(err, auth.ErrUnauthenticated):
+		body.Code = CodeUnauthenticated
+		return http.StatusUnauthorized, body
+	case errors.Is(err, database.ErrQuotaExceeded):
+		// Clients see a full quota as a full disk, which SQLite reports
+		// the same way.
+		body.Code, body.SQLiteCode = "SQLITE_"+sqliteCodeNames[sqlite3.ErrFull], int(sqlite3.ErrFull)
+		return http.StatusInsufficientStorage, body
+	case errors.Is(err, auth.ErrPermissionDenied):
+		body.Code = CodePermissionDenied
+		return http.StatusForbidden, body
+	case errors.As(err, &sqliteErr):
+		return classifySQLite(sqliteErr, body)
+	}
+	return http.StatusInternalServerError, body
+}
+
+func classifySQLite(err sqlite3.Error, body ErrorBody) (int, ErrorBody) {
+	body.SQLiteCode = int(err.Code)
+	body.SQLiteExtendedCode = int(err.ExtendedCode)
+	if name, ok := sqliteCodeNames[err.Code]; ok {
+		body.Code = "SQLITE_" + name
+	} else {
+		body.Code = "SQLITE_ERROR"
+	}
+
+	switch err.Code {
+	case sqlite3.ErrBusy, sqlite3.ErrLocked:
+		body.Retryable = true
+		return http.StatusServiceUnavailable, body
+	case sqlite3.ErrInterrupt:
+		return http.StatusServiceUnavailable, body
+	case sqlite3.ErrConstraint:
+		return http.StatusConflict, body
+	case sqlite3.ErrError, sqlite3.ErrMismatch, sqlite3.ErrRange, sqlite3.ErrTooBig:
+		return http.StatusBadRequest, body
+	case sqlite3.ErrReadonly, sqlite3.ErrPerm, sqlite3.ErrAuth:
+		return http.StatusForbidden, body
+	case sqlite3.ErrFull:
+		return http.StatusInsufficientStorage, body
+	}
+	return http.StatusInternalServerError, body
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	status, body := classify(err)
+	setRetryAfter(w, err)
+	writeErrorBody(w, status, body)
+}
+
+func writeErrorCode(w http.ResponseWriter, status int, code, message string) {
+	writeErrorBody(w, status, ErrorBody{Code: code, Message: message})
+}
+
+func writeErrorBody(w http.ResponseWriter, status int, body ErrorBody) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(errorResponse{Error: body})
+}
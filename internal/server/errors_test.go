@@ -0,0 +1,94 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/bxrne/branchlore/internal/auth"
+	"github.com/bxrne/branchlore/internal/database"
+	"github.com/bxrne/branchlore/internal/git"
+	sqlite3 "github.com/mattn/go-sqlite3"
+)
+
+func TestClassify(t *testing.T) {
+	for _, tt := range []struct {
+		err       error
+		status    int
+		code      string
+		retryable bool
+	}{
+		{errShuttingDown, http.StatusServiceUnavailable, CodeShuttingDown, true},
+		{&limitError{err: errRateLimited}, http.StatusTooManyRequests, CodeRateLimited, true},
+		{context.DeadlineExceeded, http.StatusGatewayTimeout, CodeQueryTimeout, true},
+		{context.Canceled, http.StatusServiceUnavailable, CodeQueryCancelled, false},
+		{fmt.Errorf("opening db: %w", git.ErrDatabaseNotFound), http.StatusNotFound, CodeDBNotFound, false},
+		{git.ErrDatabaseExists, http.StatusConflict, CodeDBExists, false},
+		{git.ErrBranchNotFound, http.StatusNotFound, CodeBranchNotFound, false},
+		{git.ErrBranchExists, http.StatusConflict, CodeBranchExists, false},
+		{git.ErrBranchProtected, http.StatusConflict, CodeBranchProtected, false},
+		{git.ErrMergeConflict, http.StatusConflict, CodeMergeConflict, false},
+		{git.ErrInvalidName, http.StatusBadRequest, CodeInvalidArgument, false},
+		{database.ErrCheckFailed, http.StatusPreconditionFailed, CodeCheckFailed, false},
+		{database.ErrQuotaExceeded, http.StatusInsufficientStorage, "SQLITE_FULL", false},
+		{auth.ErrUnauthenticated, http.StatusUnauthorized, CodeUnauthenticated, false},
+		{auth.ErrPermissionDenied, http.StatusForbidden, CodePermissionDenied, false},
+		{sqlite3.Error{Code: sqlite3.ErrBusy}, http.StatusServiceUnavailable, "SQLITE_BUSY", true},
+		{sqlite3.Error{Code: sqlite3.ErrReadonly}, http.StatusForbidden, "SQLITE_READONLY", false},
+		{errors.New("boom"), http.StatusInternalServerError, CodeInternal, false},
+	} {
+		status, body := classify(tt.err)
+		if status != tt.status || body.Code != tt.code || body.Retryable != tt.retryable {
+			t.Errorf("classify(%v) = %d %s retryable=%t, want %d %s retryable=%t",
+				tt.err, status, body.Code, body.Retryable, tt.status, tt.code, tt.retryable)
+		}
+	}
+}
+
+func TestSQLiteErrorResponse(t *testing.T) {
+	s := newTestServer(t, nil)
+	newTestDatabase(t, s, "db")
+	h := s.handler()
+
+	do(t, h, http.MethodPost, testQueryPath, queryRequest{Query: "CREATE TABLE t (id INTEGER PRIMARY KEY)"})
+	do(t, h, http.MethodPost, testQueryPath, queryRequest{Query: "INSERT INTO t VALUES (1)"})
+	w := do(t, h, http.MethodPost, testQueryPath, queryRequest{Query: "INSERT INTO t VALUES (1)"})
+	if w.Code != http.StatusConflict {
+		t.Fatalf("%d %s, want 409", w.Code, w.Body)
+	}
+	var resp errorResponse
+	decode(t, w, &resp)
+	if resp.Error.Code != "SQLITE_CONSTRAINT" || resp.Error.SQLiteCode != int(sqlite3.ErrConstraint) ||
+		resp.Error.SQLiteExtendedCode != int(sqlite3.ErrConstraintPrimaryKey) || resp.Error.Message == "" {
+		t.Errorf("got %+v, want SQLITE_CONSTRAINT with the primary key extended code", resp.Error)
+	}
+}
+
+func TestNotFoundErrorResponses(t *testing.T) {
+	s := newTestServer(t, nil)
+	newTestDatabase(t, s, "db")
+	h := s.handler()
+
+	for _, tt := range []struct {
+		target, code string
+	}{
+		{"/v1/databases/missing/branches/main/query", CodeDBNotFound},
+		{"/v1/databases/db/branches/missing/query", CodeBranchNotFound},
+		{"/v1/transactions/missing/query", CodeTxNotFound},
+	} {
+		w := do(t, h, http.MethodPost, tt.target, queryRequest{Query: "SELECT 1"})
+		if w.Code != http.StatusNotFound || errorCode(t, w) != tt.code {
+			t.Errorf("%s: %d %s, want 404 %s", tt.target, w.Code, w.Body, tt.code)
+		}
+		if ct := w.Header().Get("Content-Type"); ct != "application/json" {
+			t.Errorf("%s: Content-Type %q", tt.target, ct)
+		}
+	}
+
+	w := do(t, h, http.MethodGet, "/no/such/route", nil)
+	if w.Code != http.StatusNotFound || errorCode(t, w) != CodeNotFound {
+		t.Errorf("unknown route: %d %s, want 404 %s", w.Code, w.Body, CodeNotFound)
+	}
+}
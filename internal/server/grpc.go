@@ -0,0 +1,534 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/bxrne/branchlore/internal/database"
+	"github.com/bxrne/branchlore/internal/git"
+	pb "github.com/bxrne/branchlore/proto/branchlore/v1"
+	sqlite3 "github.com/mattn/go-sqlite3"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/protoadapt"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+// grpcBatchBytes caps the approximate size of a batch of streamed rows, well
+// below gRPC's default 4MB message limit.
+const grpcBatchBytes = 1 << 20
+
+// grpcAuth records the caller's address and authenticates a call by the
+// bearer token in its authorization metadata. Like the HTTP routes, each operation then checks what the token
+// may do.
+func (s *Server) grpcAuth(ctx context.Context) (context.Context, error) {
+	if p, ok := peer.FromContext(ctx); ok {
+		ctx = withClient(ctx, "grpc", p.Addr.String(), "")
+	}
+	if s.tokens() == nil {
+		return ctx, nil
+	}
+
+	var secret string
+	if v := metadata.ValueFromIncomingContext(ctx, "authorization"); len(v) > 0 {
+		secret = bearerToken(v[0])
+	}
+	if p, ok := peer.FromContext(ctx); ok && secret == "" {
+		if info, ok := p.AuthInfo.(credentials.TLSInfo); ok && len(info.State.VerifiedChains) > 0 {
+			authed, err := s.authenticateCert(ctx, &info.State)
+			if err != nil {
+				return nil, grpcError(err)
+			}
+			return authed, nil
+		}
+	}
+	ctx, err := s.authenticate(ctx, secret)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return ctx, nil
+}
+
+func (s *Server) grpcUnaryAuth(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	if err := s.throttleClient(ctx); err != nil {
+		return nil, grpcError(err)
+	}
+	ctx, err := s.grpcAuth(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.throttleIdentity(ctx); err != nil {
+		return nil, grpcError(err)
+	}
+	return handler(ctx, req)
+}
+
+func (s *Server) grpcStreamAuth(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if err := s.throttleClient(ss.Context()); err != nil {
+		return grpcError(err)
+	}
+	ctx, err := s.grpcAuth(ss.Context())
+	if err != nil {
+		return err
+	}
+	if err := s.throttleIdentity(ctx); err != nil {
+		return grpcError(err)
+	}
+	return handler(srv, &authedStream{ServerStream: ss, ctx: ctx})
+}
+
+// authedStream replaces a stream's context with the authenticated one.
+type authedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (a *authedStream) Context() context.Context {
+	return a.ctx
+}
+
+// grpcService implements the gRPC API on top of the same operations as the
+// HTTP handlers.
+type grpcService struct {
+	pb.UnimplementedBranchloreServer
+	s *Server
+}
+
+func (g *grpcService) ListDatabases(ctx context.Context, req *pb.ListDatabasesRequest) (*pb.ListDatabasesResponse, error) {
+	databases, err := g.s.listDatabases(ctx)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return &pb.ListDatabasesResponse{Databases: databases}, nil
+}
+
+func (g *grpcService) CreateDatabase(ctx context.Context, req *pb.CreateDatabaseRequest) (*pb.Database, error) {
+	if err := g.s.createDatabase(ctx, req.Name); err != nil {
+		return nil, grpcError(err)
+	}
+	return &pb.Database{Name: req.Name, Branches: []string{"main"}}, nil
+}
+
+func (g *grpcService) GetDatabase(ctx context.Context, req *pb.GetDatabaseRequest) (*pb.Database, error) {
+	branches, err := g.s.listBranches(ctx, req.Name)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return &pb.Database{Name: req.Name, Branches: branches}, nil
+}
+
+func (g *grpcService) DeleteDatabase(ctx context.Context, req *pb.DeleteDatabaseRequest) (*pb.DeleteDatabaseResponse, error) {
+	if err := g.s.deleteDatabase(ctx, req.Name); err != nil {
+		return nil, grpcError(err)
+	}
+	return &pb.DeleteDatabaseResponse{}, nil
+}
+
+func (g *grpcService) ListBranches(ctx context.Context, req *pb.ListBranchesRequest) (*pb.ListBranchesResponse, error) {
+	branches, err := g.s.listBranches(ctx, req.Database)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return &pb.ListBranchesResponse{Branches: branches}, nil
+}
+
+func (g *grpcService) CreateBranch(ctx context.Context, req *pb.CreateBranchRequest) (*pb.Branch, error) {
+	if err := g.s.createBranch(ctx, req.Database, req.Name, req.From, git.BranchMeta{}); err != nil {
+		return nil, grpcError(err)
+	}
+	return &pb.Branch{Database: req.Database, Name: req.Name}, nil
+}
+
+func (g *grpcService) GetBranch(ctx context.Context, req *pb.GetBranchRequest) (*pb.Branch, error) {
+	if _, err := g.s.getBranch(ctx, req.Database, req.Name); err != nil {
+		return nil, grpcError(err)
+	}
+	return &pb.Branch{Database: req.Database, Name: req.Name}, nil
+}
+
+func (g *grpcService) DeleteBranch(ctx context.Context, req *pb.DeleteBranchRequest) (*pb.DeleteBranchResponse, error) {
+	if err := g.s.deleteBranch(ctx, req.Database, req.Name); err != nil {
+		return nil, grpcError(err)
+	}
+	return &pb.DeleteBranchResponse{}, nil
+}
+
+func (g *grpcService) CommitBranch(ctx context.Context, req *pb.CommitBranchRequest) (*pb.CommitBranchResponse, error) {
+	hash, err := g.s.commitBranch(ctx, req.Database, req.Branch, req.Message)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return &pb.CommitBranchResponse{Hash: hash}, nil
+}
+
+func (g *grpcService) MergeBranch(ctx context.Context, req *pb.MergeBranchRequest) (*pb.MergeBranchResponse, error) {
+	hash, err := g.s.mergeBranch(ctx, req.Database, req.Branch, req.From, req.Message)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return &pb.MergeBranchResponse{Hash: hash}, nil
+}
+
+func (g *grpcService) DiffBranches(ctx context.Context, req *pb.DiffBranchesRequest) (*pb.Diff, error) {
+	diff, err := g.s.diffBranches(ctx, req.Database, req.Base, req.Head)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+
+	resp := &pb.Diff{Base: diff.Base, Head: diff.Head}
+	for _, t := range diff.Tables {
+		resp.Tables = append(resp.Tables, &pb.TableDiff{
+			Name:        t.Name,
+			Change:      t.Change,
+			RowsAdded:   t.RowsAdded,
+			RowsRemoved: t.RowsRemoved,
+		})
+	}
+	return resp, nil
+}
+
+func (g *grpcService) Query(req *pb.QueryRequest, stream pb.Branchlore_QueryServer) error {
+	branch := branchOrMain(req.Branch)
+	ctx, err := g.s.branchContext(stream.Context(), req.Database, branch)
+	if err != nil {
+		return grpcError(err)
+	}
+	ctx, cancel, err := g.queryContext(ctx, req.Database, req.Query, req.Timeout)
+	if err != nil {
+		return grpcError(err)
+	}
+	defer cancel()
+
+	cursor, err := g.s.dbMgr.OpenCursor(ctx, req.Database, branch, req.Query, grpcArgs(req.Args), database.FormatTyped)
+	if err != nil {
+		return grpcError(err)
+	}
+	defer cursor.Close()
+	g.s.limits(ctx, req.Database).limitCursor(cursor)
+
+	if err := sendRows(cursor, stream.Send); err != nil {
+		return grpcError(err)
+	}
+	return nil
+}
+
+func (g *grpcService) Exec(ctx context.Context, req *pb.ExecRequest) (*pb.ExecResponse, error) {
+	branch := branchOrMain(req.Branch)
+	ctx, err := g.s.branchContext(ctx, req.Database, branch)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	ctx, cancel, err := g.queryContext(ctx, req.Database, req.Query, req.Timeout)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	defer cancel()
+
+	res, err := g.s.dbMgr.Exec(ctx, req.Database, branch, req.Query, grpcArgs(req.Args))
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return &pb.ExecResponse{RowsAffected: res.RowsAffected, LastInsertId: res.LastInsertID}, nil
+}
+
+func (g *grpcService) Transaction(stream pb.Branchlore_TransactionServer) error {
+	req, err := stream.Recv()
+	if err != nil {
+		return err
+	}
+	begin := req.GetBegin()
+	if begin == nil {
+		return status.Error(codes.FailedPrecondition, "the first request must begin the transaction")
+	}
+
+	// The transaction lives as long as the stream; ending the stream or
+	// losing the client rolls it back.
+	branch := branchOrMain(begin.Branch)
+	ctx, err := g.s.branchContext(stream.Context(), begin.Database, branch)
+	if err != nil {
+		return grpcError(err)
+	}
+	tx, err := g.s.dbMgr.BeginTx(ctx, begin.Database, branch)
+	if err != nil {
+		return grpcError(err)
+	}
+	finished := false
+	defer func() {
+		if !finished {
+			tx.Rollback()
+		}
+	}()
+
+	if err := stream.Send(&pb.TransactionResponse{Response: &pb.TransactionResponse_Started_{Started: &pb.TransactionResponse_Started{}}}); err != nil {
+		return err
+	}
+
+	for {
+		req, err := stream.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		switch r := req.Request.(type) {
+		case *pb.TransactionRequest_Statement_:
+			if err := g.txStatement(stream, tx, r.Statement); err != nil {
+				// Report the failure in-band; the transaction stays usable.
+				if err := stream.Send(&pb.TransactionResponse{Response: &pb.TransactionResponse_Error{Error: errorMessage(err)}}); err != nil {
+					return err
+				}
+			}
+		case *pb.TransactionRequest_Commit_, *pb.TransactionRequest_Rollback_:
+			_, commit := r.(*pb.TransactionRequest_Commit_)
+			finished = true
+			if commit {
+				err = tx.Commit()
+			} else {
+				err = tx.Rollback()
+			}
+			if err != nil {
+				return grpcError(err)
+			}
+			return stream.Send(&pb.TransactionResponse{Response: &pb.TransactionResponse_Finished_{Finished: &pb.TransactionResponse_Finished{Committed: commit}}})
+		case *pb.TransactionRequest_Begin_:
+			return status.Error(codes.FailedPrecondition, "transaction already begun")
+		default:
+			return status.Error(codes.InvalidArgument, "empty transaction request")
+		}
+	}
+}
+
+// txStatement runs one statement of a Transaction stream, sending its rows
+// and then its result.
+func (g *grpcService) txStatement(stream pb.Branchlore_TransactionServer, tx *database.Tx, stmt *pb.TransactionRequest_Statement) error {
+	ctx, cancel, err := g.queryContext(stream.Context(), tx.Database(), stmt.Query, stmt.Timeout)
+	if err != nil {
+		return err
+	}
+	defer cancel()
+
+	var res database.ModifyResult
+	if database.IsSelect(stmt.Query) {
+		cursor, err := tx.OpenCursor(ctx, stmt.Query, grpcArgs(stmt.Args), database.FormatTyped)
+		if err != nil {
+			return err
+		}
+		defer cursor.Close()
+		g.s.limits(ctx, tx.Database()).limitCursor(cursor)
+
+		err = sendRows(cursor, func(rows *pb.QueryResponse) error {
+			return stream.Send(&pb.TransactionResponse{Response: &pb.TransactionResponse_Rows{Rows: rows}})
+		})
+		if err != nil {
+			return err
+		}
+	} else if res, err = tx.Exec(ctx, stmt.Query, grpcArgs(stmt.Args)); err != nil {
+		return err
+	}
+
+	done := &pb.ExecResponse{RowsAffected: res.RowsAffected, LastInsertId: res.LastInsertID}
+	return stream.Send(&pb.TransactionResponse{Response: &pb.TransactionResponse_Done{Done: done}})
+}
+
+// queryContext validates a statement, takes one of dbName's concurrency
+// slots and bounds the statement by its timeout, or dbName's default when
+// none is given. The caller's roles cap the timeout. The returned cancel
+// also gives back the slot.
+func (g *grpcService) queryContext(parent context.Context, dbName, query string, timeout *durationpb.Duration) (context.Context, context.CancelFunc, error) {
+	if query == "" {
+		return nil, nil, fmt.Errorf("%w: query required", database.ErrInvalidArgument)
+	}
+
+	limits := g.s.limits(parent, dbName)
+	var d time.Duration
+	if timeout != nil {
+		if err := timeout.CheckValid(); err != nil || timeout.AsDuration() <= 0 {
+			return nil, nil, fmt.Errorf("%w: invalid timeout", database.ErrInvalidArgument)
+		}
+		d = timeout.AsDuration()
+	}
+
+	release, err := g.s.acquireDatabase(dbName)
+	if err != nil {
+		return nil, nil, err
+	}
+	ctx, cancel := g.s.queryContext(parent, limits.timeoutFor(d))
+	return ctx, func() {
+		cancel()
+		release()
+	}, nil
+}
+
+// sendRows sends the cursor's columns, then its rows in batches of at most
+// wireFlushRows rows or about grpcBatchBytes bytes.
+func sendRows(cursor *database.Cursor, send func(*pb.QueryResponse) error) error {
+	header := &pb.QueryResponse{}
+	types := cursor.ColumnTypes()
+	for i, name := range cursor.Columns() {
+		header.Columns = append(header.Columns, &pb.Column{Name: name, DeclaredType: types[i]})
+	}
+	if err := send(header); err != nil {
+		return err
+	}
+
+	batch, size := &pb.QueryResponse{}, 0
+	for cursor.Next() {
+		values, err := cursor.Values()
+		if err != nil {
+			return err
+		}
+
+		row := &pb.Row{Values: make([]*pb.Value, len(values))}
+		for i, v := range values {
+			row.Values[i] = grpcValue(v)
+			size += 16
+			switch v := v.(type) {
+			case string:
+				size += len(v)
+			case []byte:
+				size += len(v)
+			}
+		}
+		batch.Rows = append(batch.Rows, row)
+
+		if len(batch.Rows) >= wireFlushRows || size >= grpcBatchBytes {
+			if err := send(batch); err != nil {
+				return err
+			}
+			batch, size = &pb.QueryResponse{}, 0
+		}
+	}
+	if err := cursor.Err(); err != nil {
+		return err
+	}
+
+	if len(batch.Rows) > 0 {
+		return send(batch)
+	}
+	return nil
+}
+
+func grpcValue(v interface{}) *pb.Value {
+	switch v := v.(type) {
+	case nil:
+		return &pb.Value{}
+	case int64:
+		return &pb.Value{Kind: &pb.Value_Integer{Integer: v}}
+	case float64:
+		return &pb.Value{Kind: &pb.Value_Real{Real: v}}
+	case bool:
+		var n int64
+		if v {
+			n = 1
+		}
+		return &pb.Value{Kind: &pb.Value_Integer{Integer: n}}
+	case string:
+		return &pb.Value{Kind: &pb.Value_Text{Text: v}}
+	case []byte:
+		return &pb.Value{Kind: &pb.Value_Blob{Blob: v}}
+	case time.Time:
+		return &pb.Value{Kind: &pb.Value_Text{Text: v.Format(sqlite3.SQLiteTimestampFormats[0])}}
+	}
+	return &pb.Value{Kind: &pb.Value_Text{Text: fmt.Sprint(v)}}
+}
+
+func grpcArgs(values []*pb.Value) []interface{} {
+	args := make([]interface{}, len(values))
+	for i, v := range values {
+		switch k := v.GetKind().(type) {
+		case *pb.Value_Integer:
+			args[i] = k.Integer
+		case *pb.Value_Real:
+			args[i] = k.Real
+		case *pb.Value_Text:
+			args[i] = k.Text
+		case *pb.Value_Blob:
+			args[i] = k.Blob
+		}
+	}
+	return args
+}
+
+func branchOrMain(branch string) string {
+	if branch == "" {
+		return "main"
+	}
+	return branch
+}
+
+func errorMessage(err error) *pb.Error {
+	_, body := classify(err)
+	return &pb.Error{
+		Code:               body.Code,
+		Message:            body.Message,
+		SqliteCode:         int32(body.SQLiteCode),
+		SqliteExtendedCode: int32(body.SQLiteExtendedCode),
+	}
+}
+
+// grpcError converts err into a gRPC status carrying the HTTP API's error
+// code as an ErrorInfo reason.
+func grpcError(err error) error {
+	httpStatus, body := classify(err)
+
+	code := codes.Internal
+	switch body.Code {
+	case CodeQueryTimeout:
+		code = codes.DeadlineExceeded
+	case CodeQueryCancelled:
+		code = codes.Canceled
+	case CodeDBExists, CodeBranchExists:
+		code = codes.AlreadyExists
+	case CodeBranchProtected, CodeCheckFailed:
+		code = codes.FailedPrecondition
+	case CodeMergeConflict:
+		code = codes.Aborted
+	default:
+		switch httpStatus {
+		case http.StatusBadRequest:
+			code = codes.InvalidArgument
+		case http.StatusNotFound:
+			code = codes.NotFound
+		case http.StatusConflict:
+			code = codes.FailedPrecondition
+		case http.StatusUnauthorized:
+			code = codes.Unauthenticated
+		case http.StatusForbidden:
+			code = codes.PermissionDenied
+		case http.StatusServiceUnavailable:
+			code = codes.Unavailable
+		case http.StatusInsufficientStorage, http.StatusTooManyRequests:
+			code = codes.ResourceExhausted
+		}
+	}
+
+	info := &errdetails.ErrorInfo{Reason: body.Code, Domain: "branchlore"}
+	if body.SQLiteCode != 0 {
+		info.Metadata = map[string]string{
+			"sqlite_code":          strconv.Itoa(body.SQLiteCode),
+			"sqlite_extended_code": strconv.Itoa(body.SQLiteExtendedCode),
+		}
+	}
+	details := []protoadapt.MessageV1{info}
+	var le *limitError
+	if errors.As(err, &le) {
+		details = append(details, &errdetails.RetryInfo{RetryDelay: durationpb.New(le.retryAfter)})
+	}
+	st := status.New(code, body.Message)
+	if detailed, err := st.WithDetails(details...); err == nil {
+		st = detailed
+	}
+	return st.Err()
+}
@@ -0,0 +1,279 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+
+	"github.com/bxrne/branchlore/internal/auth"
+	pb "github.com/bxrne/branchlore/proto/branchlore/v1"
+)
+
+// serveGRPCTest serves the gRPC API of s on a free local port and returns
+// a client connected to it.
+func serveGRPCTest(t *testing.T, s *Server) pb.BranchloreClient {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := s.newGRPCServer()
+	go srv.Serve(ln)
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.NewClient(ln.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return pb.NewBranchloreClient(conn)
+}
+
+// grpcReason returns the status code of err and the error code in its
+// ErrorInfo.
+func grpcReason(err error) (codes.Code, string) {
+	st := status.Convert(err)
+	for _, d := range st.Details() {
+		if info, ok := d.(*errdetails.ErrorInfo); ok {
+			return st.Code(), info.Reason
+		}
+	}
+	return st.Code(), ""
+}
+
+// grpcQuery runs req and collects the columns and rows of its stream.
+func grpcQuery(t *testing.T, c pb.BranchloreClient, req *pb.QueryRequest) ([]*pb.Column, []*pb.Row) {
+	t.Helper()
+	stream, err := c.Query(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var columns []*pb.Column
+	var rows []*pb.Row
+	for {
+		msg, err := stream.Recv()
+		if err == io.EOF {
+			return columns, rows
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		columns = append(columns, msg.Columns...)
+		rows = append(rows, msg.Rows...)
+	}
+}
+
+func TestGRPCQueries(t *testing.T) {
+	s := newTestServer(t, nil)
+	c := serveGRPCTest(t, s)
+	ctx := context.Background()
+
+	if _, err := c.CreateDatabase(ctx, &pb.CreateDatabaseRequest{Name: "db"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Exec(ctx, &pb.ExecRequest{Database: "db", Query: "CREATE TABLE t (id INTEGER PRIMARY KEY, name TEXT, score REAL, data BLOB)"}); err != nil {
+		t.Fatal(err)
+	}
+	res, err := c.Exec(ctx, &pb.ExecRequest{
+		Database: "db",
+		Query:    "INSERT INTO t (name, score, data) VALUES (?, ?, ?)",
+		Args: []*pb.Value{
+			{Kind: &pb.Value_Text{Text: "ada"}},
+			{Kind: &pb.Value_Real{Real: 1.5}},
+			{Kind: &pb.Value_Blob{Blob: []byte{0, 1, 2}}},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.RowsAffected != 1 || res.LastInsertId != 1 {
+		t.Errorf("insert: %v", res)
+	}
+
+	columns, rows := grpcQuery(t, c, &pb.QueryRequest{Database: "db", Query: "SELECT id, name, score, data, NULL FROM t"})
+	if len(columns) != 5 || columns[0].Name != "id" || columns[0].DeclaredType != "INTEGER" || columns[4].DeclaredType != "" {
+		t.Errorf("columns: %v", columns)
+	}
+	if len(rows) != 1 {
+		t.Fatalf("got %d rows, want 1", len(rows))
+	}
+	v := rows[0].Values
+	if v[0].GetInteger() != 1 || v[1].GetText() != "ada" || v[2].GetReal() != 1.5 || !bytes.Equal(v[3].GetBlob(), []byte{0, 1, 2}) || v[4].Kind != nil {
+		t.Errorf("row: %v", v)
+	}
+}
+
+func TestGRPCTransaction(t *testing.T) {
+	s := newTestServer(t, nil)
+	newTestDatabase(t, s, "db")
+	c := serveGRPCTest(t, s)
+	ctx := context.Background()
+
+	if _, err := c.Exec(ctx, &pb.ExecRequest{Database: "db", Query: "CREATE TABLE t (id INTEGER PRIMARY KEY)"}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, commit := range []bool{false, true} {
+		stream, err := c.Transaction(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		send := func(req *pb.TransactionRequest) *pb.TransactionResponse {
+			t.Helper()
+			if err := stream.Send(req); err != nil {
+				t.Fatal(err)
+			}
+			resp, err := stream.Recv()
+			if err != nil {
+				t.Fatal(err)
+			}
+			return resp
+		}
+		statement := func(query string) *pb.TransactionRequest {
+			return &pb.TransactionRequest{Request: &pb.TransactionRequest_Statement_{Statement: &pb.TransactionRequest_Statement{Query: query}}}
+		}
+
+		if resp := send(&pb.TransactionRequest{Request: &pb.TransactionRequest_Begin_{Begin: &pb.TransactionRequest_Begin{Database: "db"}}}); resp.GetStarted() == nil {
+			t.Fatalf("begin: %v", resp)
+		}
+		if resp := send(statement("INSERT INTO t (id) VALUES (1)")); resp.GetDone().GetRowsAffected() != 1 {
+			t.Fatalf("insert: %v", resp)
+		}
+		// Failures are reported in the stream, which stays usable.
+		if resp := send(statement("INSERT INTO t (id) VALUES (1)")); resp.GetError().GetCode() != "SQLITE_CONSTRAINT" {
+			t.Fatalf("duplicate insert: %v", resp)
+		}
+		if resp := send(statement("SELECT count(*) FROM t")); resp.GetRows() == nil {
+			t.Fatalf("select: %v", resp)
+		}
+		if resp, err := stream.Recv(); err != nil || resp.GetRows().GetRows()[0].Values[0].GetInteger() != 1 {
+			t.Fatalf("select rows: %v, %v", resp, err)
+		}
+		if resp, err := stream.Recv(); err != nil || resp.GetDone() == nil {
+			t.Fatalf("select done: %v, %v", resp, err)
+		}
+
+		finish := &pb.TransactionRequest{Request: &pb.TransactionRequest_Rollback_{Rollback: &pb.TransactionRequest_Rollback{}}}
+		if commit {
+			finish = &pb.TransactionRequest{Request: &pb.TransactionRequest_Commit_{Commit: &pb.TransactionRequest_Commit{}}}
+		}
+		if resp := send(finish); resp.GetFinished() == nil || resp.GetFinished().Committed != commit {
+			t.Fatalf("finish: %v", resp)
+		}
+		stream.CloseSend()
+
+		_, rows := grpcQuery(t, c, &pb.QueryRequest{Database: "db", Query: "SELECT count(*) FROM t"})
+		count := rows[0].Values[0].GetInteger()
+		want := int64(0)
+		if commit {
+			want = 1
+		}
+		if count != want {
+			t.Errorf("commit=%v: %d rows after the transaction, want %d", commit, count, want)
+		}
+	}
+}
+
+func TestGRPCErrors(t *testing.T) {
+	s := newTestServer(t, nil)
+	newTestDatabase(t, s, "db")
+	c := serveGRPCTest(t, s)
+	ctx := context.Background()
+
+	tests := []struct {
+		name   string
+		call   func() error
+		code   codes.Code
+		reason string
+	}{
+		{"missing database", func() error {
+			_, err := c.GetDatabase(ctx, &pb.GetDatabaseRequest{Name: "nope"})
+			return err
+		}, codes.NotFound, CodeDBNotFound},
+		{"existing database", func() error {
+			_, err := c.CreateDatabase(ctx, &pb.CreateDatabaseRequest{Name: "db"})
+			return err
+		}, codes.AlreadyExists, CodeDBExists},
+		{"missing branch", func() error {
+			_, err := c.Exec(ctx, &pb.ExecRequest{Database: "db", Branch: "nope", Query: "SELECT 1"})
+			return err
+		}, codes.NotFound, CodeBranchNotFound},
+		{"empty query", func() error {
+			_, err := c.Exec(ctx, &pb.ExecRequest{Database: "db"})
+			return err
+		}, codes.InvalidArgument, CodeInvalidArgument},
+		{"timeout", func() error {
+			stream, err := c.Query(ctx, &pb.QueryRequest{Database: "db", Query: slowQuery, Timeout: durationpb.New(50 * time.Millisecond)})
+			if err != nil {
+				return err
+			}
+			for {
+				if _, err := stream.Recv(); err != nil {
+					return err
+				}
+			}
+		}, codes.DeadlineExceeded, CodeQueryTimeout},
+	}
+	for _, tt := range tests {
+		code, reason := grpcReason(tt.call())
+		if code != tt.code || reason != tt.reason {
+			t.Errorf("%s: got %v %s, want %v %s", tt.name, code, reason, tt.code, tt.reason)
+		}
+	}
+
+	_, err := c.Exec(ctx, &pb.ExecRequest{Database: "db", Query: "SELEKT 1"})
+	st := status.Convert(err)
+	var info *errdetails.ErrorInfo
+	for _, d := range st.Details() {
+		if i, ok := d.(*errdetails.ErrorInfo); ok {
+			info = i
+		}
+	}
+	if st.Code() != codes.InvalidArgument || info == nil || info.Reason != "SQLITE_ERROR" || info.Metadata["sqlite_code"] != "1" {
+		t.Errorf("syntax error: %v, details %v", err, info)
+	}
+}
+
+func TestGRPCAuth(t *testing.T) {
+	s := newTestServer(t, &Config{Auth: true})
+	newTestDatabase(t, s, "db")
+	reader := newTestToken(t, s, "reader", auth.ScopeRead)
+	c := serveGRPCTest(t, s)
+
+	withToken := func(secret string) context.Context {
+		return metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer "+secret)
+	}
+
+	_, err := c.ListDatabases(context.Background(), &pb.ListDatabasesRequest{})
+	if code, reason := grpcReason(err); code != codes.Unauthenticated || reason != CodeUnauthenticated {
+		t.Errorf("without a token: %v %s", code, reason)
+	}
+	_, err = c.ListDatabases(withToken("blt_wrong"), &pb.ListDatabasesRequest{})
+	if code, _ := grpcReason(err); code != codes.Unauthenticated {
+		t.Errorf("with a wrong token: %v", err)
+	}
+
+	list, err := c.ListDatabases(withToken(reader), &pb.ListDatabasesRequest{})
+	if err != nil || len(list.Databases) != 1 || list.Databases[0] != "db" {
+		t.Errorf("with a token: %v, %v", list, err)
+	}
+	_, err = c.Exec(withToken(reader), &pb.ExecRequest{Database: "db", Query: "CREATE TABLE t (id INTEGER)"})
+	if code, reason := grpcReason(err); code != codes.PermissionDenied || reason != "SQLITE_READONLY" {
+		t.Errorf("write with a read token: %v %s", code, reason)
+	}
+	_, err = c.CreateDatabase(withToken(reader), &pb.CreateDatabaseRequest{Name: "other"})
+	if code, reason := grpcReason(err); code != codes.PermissionDenied || reason != CodePermissionDenied {
+		t.Errorf("create a database with a read token: %v %s", code, reason)
+	}
+}
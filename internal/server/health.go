@@ -0,0 +1,324 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bxrne/branchlore/internal/auth"
+	"github.com/bxrne/branchlore/internal/git"
+)
+
+// healthCheckTimeout bounds a whole health or readiness check. Checks still
+// running when it passes are reported as failed.
+const healthCheckTimeout = 5 * time.Second
+
+// quickCheckBudget is how long the quick_check probe checks branches for,
+// ending before healthCheckTimeout so that running out of time is not a
+// failure.
+const quickCheckBudget = healthCheckTimeout - time.Second
+
+// readinessCacheTTL is how long the results of the readiness checks that
+// read storage are reused. /readyz needs no token, so callers cannot make
+// it sync files or read branches more often than this.
+const readinessCacheTTL = 5 * time.Second
+
+// Health check results.
+const (
+	checkOK      = "ok"
+	checkFailed  = "failed"
+	checkSkipped = "skipped"
+)
+
+type healthStatus struct {
+	// Status is healthy if every check passed or was skipped.
+	Status string        `json:"status"`
+	Checks []healthCheck `json:"checks,omitempty"`
+}
+
+type healthCheck struct {
+	Name     string        `json:"name"`
+	Status   string        `json:"status"`
+	Detail   string        `json:"detail,omitempty"`
+	Error    string        `json:"error,omitempty"`
+	Duration time.Duration `json:"duration_ns"`
+}
+
+// probe is one named check. It returns a short description of what it
+// found, or an error if the server should not be considered healthy. An
+// errors.ErrUnsupported error skips the check.
+type probe struct {
+	name string
+	run  func(ctx context.Context) (string, error)
+}
+
+// handleHealthz reports whether the server is alive: it answers and its
+// database manager is not stuck. It fails only when restarting the
+// process would help.
+func (s *Server) handleHealthz(w http.ResponseWriter, r *http.Request) {
+	s.writeHealth(w, r, []probe{
+		{"database_manager", func(ctx context.Context) (string, error) {
+			return fmt.Sprintf("%d connection pools open", len(s.dbMgr.Stats())), nil
+		}},
+	})
+}
+
+// handleReadyz reports whether the server can serve requests: it is not
+// shutting down, its data directory is writable with enough free space,
+// every Git repository opens and a sample of branch databases pass PRAGMA
+// quick_check.
+func (s *Server) handleReadyz(w http.ResponseWriter, r *http.Request) {
+	s.writeHealth(w, r, []probe{
+		{"server", s.checkRunning},
+		s.readiness.cached(probe{"data_dir", s.checkDataDir}),
+		s.readiness.cached(probe{"disk_space", s.checkDiskSpace}),
+		s.readiness.cached(probe{"git", s.checkRepositories}),
+		s.readiness.cached(probe{"quick_check", s.checkBranches}),
+	})
+}
+
+// probeCache reuses the results of probes for readinessCacheTTL. Callers
+// arriving while a probe runs wait for its result rather than run it again.
+type probeCache struct {
+	mu      sync.Mutex
+	results map[string]*cachedResult
+}
+
+type cachedResult struct {
+	mu     sync.Mutex
+	at     time.Time
+	detail string
+	err    error
+}
+
+// cached returns p with its results reused. It runs under its own timeout,
+// so a caller that goes away does not leave a cancelled result behind.
+func (c *probeCache) cached(p probe) probe {
+	c.mu.Lock()
+	if c.results == nil {
+		c.results = make(map[string]*cachedResult)
+	}
+	result, ok := c.results[p.name]
+	if !ok {
+		result = &cachedResult{}
+		c.results[p.name] = result
+	}
+	c.mu.Unlock()
+
+	return probe{p.name, func(ctx context.Context) (string, error) {
+		result.mu.Lock()
+		defer result.mu.Unlock()
+		if time.Since(result.at) < readinessCacheTTL {
+			return result.detail, result.err
+		}
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), healthCheckTimeout)
+		defer cancel()
+		result.detail, result.err = p.run(ctx)
+		result.at = time.Now()
+		return result.detail, result.err
+	}}
+}
+
+// writeHealth runs probes concurrently and responds with their results,
+// with status 503 if any failed. Details and errors can name databases and
+// branches, so with auth enabled only an admin of all databases sees them;
+// other callers get the status of each check.
+func (s *Server) writeHealth(w http.ResponseWriter, r *http.Request, probes []probe) {
+	ctx, cancel := context.WithTimeout(r.Context(), healthCheckTimeout)
+	defer cancel()
+
+	start := time.Now()
+	results := make([]chan healthCheck, len(probes))
+	for i, p := range probes {
+		results[i] = make(chan healthCheck, 1)
+		go func() {
+			start := time.Now()
+			detail, err := p.run(ctx)
+			check := healthCheck{Name: p.name, Status: checkOK, Detail: detail, Duration: time.Since(start)}
+			switch {
+			case errors.Is(err, errors.ErrUnsupported):
+				check.Status = checkSkipped
+			case err != nil:
+				check.Status, check.Error = checkFailed, err.Error()
+			}
+			results[i] <- check
+		}()
+	}
+
+	health := healthStatus{Status: "healthy", Checks: make([]healthCheck, len(probes))}
+	for i, result := range results {
+		var check healthCheck
+		select {
+		case check = <-result:
+		case <-ctx.Done():
+			select {
+			case check = <-result:
+			default:
+				check = healthCheck{Name: probes[i].name, Status: checkFailed, Error: "timed out", Duration: time.Since(start)}
+			}
+		}
+		if check.Status == checkFailed {
+			health.Status = "unhealthy"
+			s.logger.WarnContext(ctx, "Health check failed", slog.String("check", check.Name), slog.String("error", check.Error))
+		}
+		health.Checks[i] = check
+	}
+
+	status := http.StatusOK
+	if health.Status != "healthy" {
+		status = http.StatusServiceUnavailable
+	}
+	if !s.seesHealthDetails(r) {
+		for i := range health.Checks {
+			health.Checks[i].Detail, health.Checks[i].Error = "", ""
+		}
+	}
+	writeJSON(w, status, health)
+}
+
+// seesHealthDetails reports whether the caller of the public route r may see
+// the details of health checks.
+func (s *Server) seesHealthDetails(r *http.Request) bool {
+	if s.tokens() == nil {
+		return true
+	}
+	ctx, err := s.authenticateRequest(r)
+	return err == nil && s.allow(ctx, auth.PermAdmin, "*", "") == nil
+}
+
+func (s *Server) checkRunning(ctx context.Context) (string, error) {
+	if s.draining.Load() {
+		return "", errShuttingDown
+	}
+	return "", nil
+}
+
+// checkDataDir writes, syncs and removes a file in the data directory.
+func (s *Server) checkDataDir(ctx context.Context) (string, error) {
+	f, err := os.CreateTemp(s.cfg().DataDir, ".readyz-*")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.WriteString("ok"); err != nil {
+		f.Close()
+		return "", err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return "", err
+	}
+	return "", f.Close()
+}
+
+func (s *Server) checkDiskSpace(ctx context.Context) (string, error) {
+	free, err := freeSpace(s.cfg().DataDir)
+	if err != nil {
+		return "", err
+	}
+	detail := formatBytes(free) + " free"
+	if minimum := s.cfg().MinFreeDisk; minimum > 0 && free < uint64(minimum) {
+		return detail, fmt.Errorf("%s free, below the minimum of %s", formatBytes(free), formatBytes(uint64(minimum)))
+	}
+	return detail, nil
+}
+
+// checkRepositories opens every database's Git repository.
+func (s *Server) checkRepositories(ctx context.Context) (string, error) {
+	databases, err := s.gitMgr.ListDatabases()
+	if err != nil {
+		return "", err
+	}
+	var problems []string
+	for _, dbName := range databases {
+		if ctx.Err() != nil {
+			return "", ctx.Err()
+		}
+		if err := s.gitMgr.CheckRepository(dbName); err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %v", dbName, err))
+		}
+	}
+	detail := fmt.Sprintf("%d repositories", len(databases))
+	if problems != nil {
+		return detail, errors.New(strings.Join(problems, "; "))
+	}
+	return detail, nil
+}
+
+// checkBranches runs PRAGMA quick_check on a random sample of branches, so
+// successive checks cover every branch over time without reading them all
+// each time. Branches left when quickCheckBudget runs out are not checked:
+// a large branch can take longer than that, which does not make the server
+// unready.
+func (s *Server) checkBranches(ctx context.Context) (string, error) {
+	if s.cfg().HealthCheckSample <= 0 {
+		return "disabled", errors.ErrUnsupported
+	}
+	ctx, cancel := context.WithTimeout(ctx, quickCheckBudget)
+	defer cancel()
+	databases, err := s.gitMgr.ListDatabases()
+	if err != nil {
+		return "", err
+	}
+	var targets [][2]string
+	for _, dbName := range databases {
+		branches, err := s.gitMgr.ListBranches(dbName)
+		if err != nil {
+			// Reported by the git check.
+			continue
+		}
+		for _, branch := range branches {
+			targets = append(targets, [2]string{dbName, branch})
+		}
+	}
+	rand.Shuffle(len(targets), func(i, j int) {
+		targets[i], targets[j] = targets[j], targets[i]
+	})
+	targets = targets[:min(len(targets), s.cfg().HealthCheckSample)]
+
+	checked := 0
+	var problems []string
+	for _, t := range targets {
+		err := s.dbMgr.QuickCheck(ctx, t[0], t[1])
+		if ctx.Err() != nil {
+			break
+		}
+		checked++
+		// A branch deleted since it was listed is not a problem.
+		if err != nil && !errors.Is(err, git.ErrBranchNotFound) {
+			problems = append(problems, fmt.Sprintf("%s@%s: %v", t[0], t[1], err))
+		}
+	}
+	detail := "no branches"
+	switch {
+	case checked < len(targets):
+		detail = fmt.Sprintf("checked %d of %d branches in time", checked, len(targets))
+	case checked > 0:
+		detail = fmt.Sprintf("checked %d branches", checked)
+	}
+	if problems != nil {
+		return detail, errors.New(strings.Join(problems, "; "))
+	}
+	return detail, nil
+}
+
+// formatBytes formats n bytes in binary units, such as 1.5 GiB.
+func formatBytes(n uint64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := uint64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
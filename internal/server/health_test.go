@@ -0,0 +1,161 @@
+package server
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/bxrne/branchlore/internal/auth"
+)
+
+// checks returns the checks of health by name.
+func checks(health healthStatus) map[string]healthCheck {
+	byName := make(map[string]healthCheck)
+	for _, c := range health.Checks {
+		byName[c.Name] = c
+	}
+	return byName
+}
+
+func TestHealthz(t *testing.T) {
+	s := newTestServer(t, nil)
+	h := s.handler()
+
+	for _, path := range []string{"/healthz", "/health"} {
+		w := do(t, h, http.MethodGet, path, nil)
+		var health healthStatus
+		decode(t, w, &health)
+		if w.Code != http.StatusOK || health.Status != "healthy" || checks(health)["database_manager"].Status != checkOK {
+			t.Errorf("%s: %d %s", path, w.Code, w.Body)
+		}
+	}
+
+	// Liveness does not depend on readiness: a draining server is alive.
+	s.draining.Store(true)
+	if w := do(t, h, http.MethodGet, "/healthz", nil); w.Code != http.StatusOK {
+		t.Errorf("/healthz while draining: %d, want 200", w.Code)
+	}
+}
+
+func TestReadyz(t *testing.T) {
+	s := newTestServer(t, &Config{HealthCheckSample: 10})
+	newTestDatabase(t, s, "db")
+	h := s.handler()
+
+	w := do(t, h, http.MethodGet, "/readyz", nil)
+	var health healthStatus
+	decode(t, w, &health)
+	byName := checks(health)
+	if w.Code != http.StatusOK || health.Status != "healthy" || len(byName) != 5 {
+		t.Fatalf("/readyz: %d %s", w.Code, w.Body)
+	}
+	for name, c := range byName {
+		if c.Status != checkOK {
+			t.Errorf("check %s: %+v", name, c)
+		}
+	}
+	if d := byName["quick_check"].Detail; d != "checked 1 branches" {
+		t.Errorf("quick_check detail %q", d)
+	}
+	if d := byName["git"].Detail; d != "1 repositories" {
+		t.Errorf("git detail %q", d)
+	}
+
+	// A corrupt branch fails the quick check, once the last results are
+	// out of the cache.
+	s.dbMgr.CloseDatabase("db")
+	if err := os.WriteFile(s.gitMgr.GetBranchPath("db", "main"), []byte(strings.Repeat("x", 4096)), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if w := do(t, h, http.MethodGet, "/readyz", nil); w.Code != http.StatusOK {
+		t.Errorf("/readyz with cached results: %d %s", w.Code, w.Body)
+	}
+	s.readiness = probeCache{}
+	w = do(t, h, http.MethodGet, "/readyz", nil)
+	health = healthStatus{}
+	decode(t, w, &health)
+	if c := checks(health)["quick_check"]; w.Code != http.StatusServiceUnavailable || c.Status != checkFailed || !strings.Contains(c.Error, "db@main") {
+		t.Errorf("/readyz with a corrupt branch: %d %s", w.Code, w.Body)
+	}
+}
+
+func TestReadyzFailures(t *testing.T) {
+	s := newTestServer(t, &Config{MinFreeDisk: math.MaxInt64})
+	h := s.handler()
+
+	w := do(t, h, http.MethodGet, "/readyz", nil)
+	var health healthStatus
+	decode(t, w, &health)
+	byName := checks(health)
+	if w.Code != http.StatusServiceUnavailable || health.Status != "unhealthy" || byName["disk_space"].Status != checkFailed {
+		t.Errorf("/readyz below the free space minimum: %d %s", w.Code, w.Body)
+	}
+	if c := byName["quick_check"]; c.Status != checkSkipped {
+		t.Errorf("quick_check with no sample: %+v, want skipped", c)
+	}
+
+	s.draining.Store(true)
+	w = do(t, h, http.MethodGet, "/readyz", nil)
+	health = healthStatus{}
+	decode(t, w, &health)
+	if c := checks(health)["server"]; w.Code != http.StatusServiceUnavailable || c.Status != checkFailed {
+		t.Errorf("/readyz while draining: %d %s", w.Code, w.Body)
+	}
+}
+
+func TestReadyzHidesDetailsFromAnonymousCallers(t *testing.T) {
+	s := newTestServer(t, &Config{Auth: true, HealthCheckSample: 10})
+	newTestDatabase(t, s, "db")
+	s.dbMgr.CloseDatabase("db")
+	if err := os.WriteFile(s.gitMgr.GetBranchPath("db", "main"), []byte(strings.Repeat("x", 4096)), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	h := s.handler()
+
+	for _, tt := range []struct {
+		token   string
+		details bool
+	}{
+		{"", false},
+		{newTestToken(t, s, "reader", auth.ScopeRead), false},
+		{newTestToken(t, s, "admin", auth.ScopeAdmin), true},
+	} {
+		w := doAs(t, h, tt.token, http.MethodGet, "/readyz", nil)
+		var health healthStatus
+		decode(t, w, &health)
+		c := checks(health)["quick_check"]
+		if w.Code != http.StatusServiceUnavailable || c.Status != checkFailed {
+			t.Errorf("/readyz with a corrupt branch: %d %s", w.Code, w.Body)
+		}
+		if details := strings.Contains(c.Error, "db@main") && c.Detail != ""; details != tt.details {
+			t.Errorf("details shown %v, want %v: %s", details, tt.details, w.Body)
+		}
+	}
+	if w := do(t, h, http.MethodGet, "/readyz", nil); strings.Contains(w.Body.String(), "db@main") || strings.Contains(w.Body.String(), "free") {
+		t.Errorf("/readyz without a token: %s", w.Body)
+	}
+}
+
+// Running out of time checks fewer branches, rather than failing.
+func TestQuickCheckOutOfTime(t *testing.T) {
+	s := newTestServer(t, &Config{HealthCheckSample: 10})
+	newTestDatabase(t, s, "db")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	detail, err := s.checkBranches(ctx)
+	if err != nil || detail != "checked 0 of 1 branches in time" {
+		t.Errorf("quick check out of time: %q, %v", detail, err)
+	}
+}
+
+func TestFormatBytes(t *testing.T) {
+	for n, want := range map[uint64]string{0: "0 B", 1023: "1023 B", 1024: "1.0 KiB", 1536: "1.5 KiB", 5 << 30: "5.0 GiB"} {
+		if got := formatBytes(n); got != want {
+			t.Errorf("formatBytes(%d) = %q, want %q", n, got, want)
+		}
+	}
+}
@@ -0,0 +1,146 @@
+package server
+
+import (
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/bxrne/branchlore/internal/audit"
+)
+
+// defaultJanitorInterval is how often a disabled janitor checks whether a
+// reload enabled it.
+const defaultJanitorInterval = time.Minute
+
+// defaultPruneGrace is how old an unreachable object must be before it is
+// pruned when the configuration does not say. A grace period leaves alone
+// the objects of a commit still being recorded.
+const defaultPruneGrace = time.Minute
+
+// pruneQueue tracks the databases that lost a branch, and when, so their
+// history can be pruned once the grace period has passed.
+type pruneQueue struct {
+	mu      sync.Mutex
+	pending map[string]time.Time
+}
+
+func newPruneQueue() *pruneQueue {
+	return &pruneQueue{pending: make(map[string]time.Time)}
+}
+
+// add queues dbName, which lost a branch at at.
+func (q *pruneQueue) add(dbName string, at time.Time) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.pending[dbName] = at
+}
+
+// remove forgets dbName, for when the whole database is deleted.
+func (q *pruneQueue) remove(dbName string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	delete(q.pending, dbName)
+}
+
+// due removes and returns the databases whose latest branch deletion came
+// before cutoff.
+func (q *pruneQueue) due(cutoff time.Time) []string {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	var due []string
+	for dbName, at := range q.pending {
+		if at.Before(cutoff) {
+			due = append(due, dbName)
+			delete(q.pending, dbName)
+		}
+	}
+	return due
+}
+
+// janitorInterval returns how often the janitor runs, and whether it is
+// enabled.
+func (s *Server) janitorInterval() (time.Duration, bool) {
+	if interval := s.cfg().JanitorInterval; interval > 0 {
+		return interval, true
+	}
+	return defaultJanitorInterval, false
+}
+
+// runJanitor deletes expired branches and prunes the history deleted
+// branches leave behind until the server stops.
+func (s *Server) runJanitor() {
+	defer s.wg.Done()
+
+	interval, _ := s.janitorInterval()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.ctx.Done():
+			return
+		case <-ticker.C:
+			// The interval can change on Reload.
+			next, enabled := s.janitorInterval()
+			if next != interval {
+				interval = next
+				ticker.Reset(interval)
+			}
+			if enabled {
+				s.deleteExpiredBranches(time.Now())
+				s.pruneDeletedHistory(time.Now())
+			}
+		}
+	}
+}
+
+// deleteExpiredBranches deletes every branch that expired by now. Failures
+// are logged and the branch is tried again on the next run.
+func (s *Server) deleteExpiredBranches(now time.Time) {
+	databases, err := s.gitMgr.ListDatabases()
+	if err != nil {
+		s.logger.Error("Failed to list databases", slog.Any("error", err))
+		return
+	}
+	ctx := withClient(s.ctx, "janitor", "", "")
+	for _, dbName := range databases {
+		expired, err := s.gitMgr.ExpiredBranches(dbName, now)
+		if err != nil {
+			s.logger.Error("Failed to list expired branches", slog.String("db", dbName), slog.Any("error", err))
+			continue
+		}
+		for _, branch := range expired {
+			detail := "expired"
+			err := s.removeBranch(dbName, branch)
+			s.recordOperation(ctx, audit.ActionBranchDelete, dbName, branch, &detail, now, &err)
+			if err != nil {
+				s.logger.Warn("Failed to delete expired branch", slog.String("db", dbName), slog.String("branch", branch), slog.Any("error", err))
+				continue
+			}
+			s.logger.Info("Deleted expired branch", slog.String("db", dbName), slog.String("branch", branch))
+		}
+	}
+}
+
+// pruneGrace returns how long unreachable history is kept.
+func (s *Server) pruneGrace() time.Duration {
+	if grace := s.cfg().PruneGrace; grace > 0 {
+		return grace
+	}
+	return defaultPruneGrace
+}
+
+// pruneDeletedHistory prunes the databases that lost a branch more than the
+// prune grace period before now. Databases that fail are queued again.
+func (s *Server) pruneDeletedHistory(now time.Time) {
+	cutoff := now.Add(-s.pruneGrace())
+	for _, dbName := range s.prunes.due(cutoff) {
+		if !s.gitMgr.DatabaseExists(dbName) {
+			continue
+		}
+		if _, _, err := s.gitMgr.Prune(dbName, cutoff); err != nil {
+			s.logger.Error("Failed to prune repository", slog.String("db", dbName), slog.Any("error", err))
+			s.prunes.add(dbName, now)
+		}
+	}
+}
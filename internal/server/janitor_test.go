@@ -0,0 +1,228 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestCreateExpiringBranch(t *testing.T) {
+	s := newTestServer(t, &Config{EphemeralTTL: time.Hour})
+	newTestDatabase(t, s, "db")
+	h := s.handler()
+
+	start := time.Now()
+	tests := []struct {
+		req createBranchRequest
+		ttl time.Duration
+	}{
+		{createBranchRequest{Name: "ci", Ephemeral: true}, time.Hour},
+		{createBranchRequest{Name: "ci-short", Ephemeral: true, TTL: "10m"}, 10 * time.Minute},
+		{createBranchRequest{Name: "trial", TTL: "48h"}, 48 * time.Hour},
+		{createBranchRequest{Name: "dev"}, 0},
+	}
+	for _, tt := range tests {
+		w := do(t, h, http.MethodPost, "/v1/databases/db/branches", tt.req)
+		if w.Code != http.StatusCreated {
+			t.Fatalf("create %s: %d %s", tt.req.Name, w.Code, w.Body)
+		}
+		var created, got branchInfo
+		decode(t, w, &created)
+		decode(t, do(t, h, http.MethodGet, "/v1/databases/db/branches/"+tt.req.Name, nil), &got)
+		for _, info := range []branchInfo{created, got} {
+			if info.Ephemeral != tt.req.Ephemeral {
+				t.Errorf("%s: ephemeral %v, want %v", tt.req.Name, info.Ephemeral, tt.req.Ephemeral)
+			}
+			if tt.ttl == 0 {
+				if info.ExpiresAt != nil {
+					t.Errorf("%s: expires at %v, want never", tt.req.Name, info.ExpiresAt)
+				}
+				continue
+			}
+			if info.ExpiresAt == nil || info.ExpiresAt.Before(start.Add(tt.ttl).Truncate(time.Second)) || info.ExpiresAt.After(time.Now().Add(tt.ttl)) {
+				t.Errorf("%s: expires at %v, want in %v", tt.req.Name, info.ExpiresAt, tt.ttl)
+			}
+		}
+	}
+
+	for _, ttl := range []string{"soon", "-1h", "0s"} {
+		w := do(t, h, http.MethodPost, "/v1/databases/db/branches", createBranchRequest{Name: "bad", TTL: ttl})
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("ttl %q: %d, want 400", ttl, w.Code)
+		}
+	}
+	if s.gitMgr.BranchExists("db", "bad") {
+		t.Error("a branch with an invalid ttl was created")
+	}
+}
+
+func TestJanitorDeletesExpiredBranches(t *testing.T) {
+	s := newTestServer(t, &Config{PruneGrace: time.Minute})
+	newTestDatabase(t, s, "db")
+	h := s.handler()
+
+	for _, req := range []createBranchRequest{{Name: "ci", Ephemeral: true, TTL: "1h"}, {Name: "dev"}} {
+		if w := do(t, h, http.MethodPost, "/v1/databases/db/branches", req); w.Code != http.StatusCreated {
+			t.Fatalf("create %s: %d %s", req.Name, w.Code, w.Body)
+		}
+	}
+	path := "/v1/databases/db/branches/ci/query"
+	for _, query := range []string{"CREATE TABLE t (a TEXT)", "INSERT INTO t VALUES ('ci data')"} {
+		if w := do(t, h, http.MethodPost, path, queryRequest{Query: query}); w.Code != http.StatusOK {
+			t.Fatalf("%s: %d %s", query, w.Code, w.Body)
+		}
+	}
+	if w := do(t, h, http.MethodPost, "/v1/databases/db/branches/ci/commits", commitRequest{}); w.Code != http.StatusCreated {
+		t.Fatalf("commit ci: %d %s", w.Code, w.Body)
+	}
+
+	// Nothing has expired yet.
+	s.deleteExpiredBranches(time.Now())
+	if !s.gitMgr.BranchExists("db", "ci") {
+		t.Fatal("the janitor deleted ci before it expired")
+	}
+	if due := s.prunes.due(time.Now().Add(time.Hour)); len(due) != 0 {
+		t.Fatalf("prunes queued with no branch deleted: %v", due)
+	}
+
+	later := time.Now().Add(2 * time.Hour)
+	s.deleteExpiredBranches(later)
+	if s.gitMgr.BranchExists("db", "ci") {
+		t.Error("the janitor kept the expired branch ci")
+	}
+	for _, branch := range []string{"main", "dev"} {
+		if !s.gitMgr.BranchExists("db", branch) {
+			t.Errorf("the janitor deleted %s, which never expires", branch)
+		}
+	}
+	if w := do(t, h, http.MethodGet, "/v1/databases/db/branches/ci", nil); w.Code != http.StatusNotFound {
+		t.Errorf("get the expired branch: %d, want 404", w.Code)
+	}
+
+	// ci's history is pruned only after the grace period.
+	before, err := s.gitMgr.RepositorySize("db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.pruneDeletedHistory(time.Now())
+	if size, _ := s.gitMgr.RepositorySize("db"); size != before {
+		t.Errorf("history pruned within the grace period: %d bytes, was %d", size, before)
+	}
+	s.pruneDeletedHistory(later)
+	if size, _ := s.gitMgr.RepositorySize("db"); size >= before {
+		t.Errorf("history not pruned after the grace period: %d bytes, was %d", size, before)
+	}
+	if due := s.prunes.due(later.Add(time.Hour)); len(due) != 0 {
+		t.Errorf("prunes still queued: %v", due)
+	}
+}
+
+func TestPruneQueue(t *testing.T) {
+	q := newPruneQueue()
+	now := time.Now()
+	q.add("a", now)
+	q.add("b", now.Add(time.Minute))
+	q.add("c", now)
+	q.remove("c")
+
+	if due := q.due(now); len(due) != 0 {
+		t.Errorf("due at the deletion time: %v, want none", due)
+	}
+	if due := q.due(now.Add(time.Second)); len(due) != 1 || due[0] != "a" {
+		t.Errorf("due a second later: %v, want [a]", due)
+	}
+	// A later deletion pushes the prune back.
+	q.add("b", now.Add(time.Hour))
+	if due := q.due(now.Add(time.Hour)); len(due) != 0 {
+		t.Errorf("due before b's latest deletion: %v, want none", due)
+	}
+	if due := q.due(now.Add(2 * time.Hour)); len(due) != 1 || due[0] != "b" {
+		t.Errorf("due after b's latest deletion: %v, want [b]", due)
+	}
+}
+
+func TestJanitorInterval(t *testing.T) {
+	s := newTestServer(t, nil)
+	if interval, enabled := s.janitorInterval(); enabled || interval != defaultJanitorInterval {
+		t.Errorf("janitor without an interval: %v, %v, want disabled", interval, enabled)
+	}
+	if grace := s.pruneGrace(); grace != defaultPruneGrace {
+		t.Errorf("default prune grace %v", grace)
+	}
+
+	s = newTestServer(t, &Config{JanitorInterval: time.Second, PruneGrace: time.Hour})
+	if interval, enabled := s.janitorInterval(); !enabled || interval != time.Second {
+		t.Errorf("janitor every second: %v, %v", interval, enabled)
+	}
+	if grace := s.pruneGrace(); grace != time.Hour {
+		t.Errorf("prune grace %v, want 1h", grace)
+	}
+}
+
+// A branch deleted while a query reads it leaves no pool behind for a new
+// branch of the same name.
+func TestJanitorDeletesBranchWithQueryInFlight(t *testing.T) {
+	s := newTestServer(t, nil)
+	newTestDatabase(t, s, "db")
+	h := s.handler()
+
+	create := createBranchRequest{Name: "ci", Ephemeral: true, TTL: "1h"}
+	if w := do(t, h, http.MethodPost, "/v1/databases/db/branches", create); w.Code != http.StatusCreated {
+		t.Fatalf("create ci: %d %s", w.Code, w.Body)
+	}
+	path := "/v1/databases/db/branches/ci/query"
+	for _, query := range []string{"CREATE TABLE t (a INTEGER)", "INSERT INTO t VALUES (1), (2), (3)"} {
+		if w := do(t, h, http.MethodPost, path, queryRequest{Query: query}); w.Code != http.StatusOK {
+			t.Fatalf("%s: %d %s", query, w.Code, w.Body)
+		}
+	}
+
+	// The cursor holds a connection, with its statement part way through.
+	cursor, err := s.dbMgr.OpenCursor(context.Background(), "db", "ci", "SELECT a FROM t", nil, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cursor.Close()
+	if !cursor.Next() {
+		t.Fatalf("first row: %v", cursor.Err())
+	}
+
+	// Other queries keep arriving while the janitor runs.
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+				do(t, h, http.MethodPost, path, queryRequest{Query: "SELECT count(*) FROM t"})
+			}
+		}
+	}()
+	s.deleteExpiredBranches(time.Now().Add(2 * time.Hour))
+	close(stop)
+	<-done
+	if s.gitMgr.BranchExists("db", "ci") {
+		t.Fatal("the janitor left the expired branch")
+	}
+
+	// The statement in flight finishes on the deleted file.
+	rows := 1
+	for cursor.Next() {
+		rows++
+	}
+	if err := cursor.Err(); err != nil || rows != 3 {
+		t.Errorf("query in flight read %d rows, %v, want 3", rows, err)
+	}
+
+	// A new ci is a copy of main, which has no table t.
+	if w := do(t, h, http.MethodPost, "/v1/databases/db/branches", createBranchRequest{Name: "ci"}); w.Code != http.StatusCreated {
+		t.Fatalf("create ci again: %d %s", w.Code, w.Body)
+	}
+	if w := do(t, h, http.MethodPost, path, queryRequest{Query: "SELECT count(*) FROM t"}); w.Code == http.StatusOK {
+		t.Errorf("the new ci reads the deleted branch's table: %s", w.Body)
+	}
+}
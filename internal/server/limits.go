@@ -0,0 +1,367 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/bxrne/branchlore/internal/database"
+)
+
+var (
+	errRateLimited    = errors.New("rate limit exceeded")
+	errTooManyQueries = errors.New("too many concurrent queries")
+)
+
+// concurrencyRetryAfter is the wait suggested to clients turned away by a
+// database's concurrency cap, which has no schedule to compute one from.
+const concurrencyRetryAfter = time.Second
+
+// limitError rejects work that exceeds a limit and tells the client when
+// to retry.
+type limitError struct {
+	err        error
+	detail     string
+	retryAfter time.Duration
+}
+
+func (e *limitError) Error() string {
+	return fmt.Sprintf("%v: %s", e.err, e.detail)
+}
+
+func (e *limitError) Unwrap() error {
+	return e.err
+}
+
+// setRetryAfter sets the Retry-After header, in whole seconds rounded up,
+// when err is a limitError.
+func setRetryAfter(w http.ResponseWriter, err error) {
+	var le *limitError
+	if errors.As(err, &le) {
+		secs := max(1, int(math.Ceil(le.retryAfter.Seconds())))
+		w.Header().Set("Retry-After", strconv.Itoa(secs))
+	}
+}
+
+// RoleLimits restricts the tokens holding a role. Zero fields set no
+// limit. A token holding several roles gets the strictest of each limit.
+type RoleLimits struct {
+	// MaxQueryDuration caps every statement's timeout, including ones the
+	// client asks for.
+	MaxQueryDuration time.Duration
+	// MaxRows and MaxResultBytes cut off every result, streamed and paged
+	// ones included.
+	MaxRows        int
+	MaxResultBytes int64
+	// Rate and Burst replace the server's TokenRate and TokenBurst.
+	Rate  float64
+	Burst int
+}
+
+// roleLimits merges the limits of the caller's roles.
+func (s *Server) roleLimits(ctx context.Context) RoleLimits {
+	var merged RoleLimits
+	id := identity(ctx)
+	if id == nil {
+		return merged
+	}
+	roles := s.cfg().Roles
+	for _, role := range id.Roles {
+		l, ok := roles[role]
+		if !ok {
+			continue
+		}
+		merged.MaxQueryDuration = strictest(merged.MaxQueryDuration, l.MaxQueryDuration)
+		merged.MaxRows = strictest(merged.MaxRows, l.MaxRows)
+		merged.MaxResultBytes = strictest(merged.MaxResultBytes, l.MaxResultBytes)
+		merged.Rate = strictest(merged.Rate, l.Rate)
+		merged.Burst = strictest(merged.Burst, l.Burst)
+	}
+	return merged
+}
+
+// strictest returns the smaller of two limits where zero means none.
+func strictest[T int | int64 | float64 | time.Duration](a, b T) T {
+	if a == 0 || (b != 0 && b < a) {
+		return b
+	}
+	return a
+}
+
+// queryLimits bounds the statements of one caller on one database.
+type queryLimits struct {
+	// timeout is the default statement timeout and maxTimeout the most a
+	// statement may ask for.
+	timeout    time.Duration
+	maxTimeout time.Duration
+	// bufferRows caps buffered responses; maxRows and maxBytes cap every
+	// result.
+	bufferRows int
+	maxRows    int
+	maxBytes   int64
+}
+
+// limits returns the limits of the caller in ctx on dbName.
+func (s *Server) limits(ctx context.Context, dbName string) queryLimits {
+	config := s.cfg()
+	l := queryLimits{timeout: config.QueryTimeout, bufferRows: config.MaxRows}
+	if db, ok := config.Databases[dbName]; ok {
+		if db.QueryTimeout != 0 {
+			l.timeout = db.QueryTimeout
+		}
+		if db.MaxRows != 0 {
+			l.bufferRows = db.MaxRows
+		}
+	}
+	role := s.roleLimits(ctx)
+	l.maxTimeout, l.maxRows, l.maxBytes = role.MaxQueryDuration, role.MaxRows, role.MaxResultBytes
+	l.bufferRows = strictest(l.bufferRows, l.maxRows)
+	return l
+}
+
+// timeoutFor resolves the timeout of a statement that asked for requested,
+// or for the default when requested is zero.
+func (l queryLimits) timeoutFor(requested time.Duration) time.Duration {
+	d := l.timeout
+	if requested > 0 {
+		d = requested
+	}
+	if l.maxTimeout > 0 && (d <= 0 || d > l.maxTimeout) {
+		d = l.maxTimeout
+	}
+	return d
+}
+
+// limitCursor applies the result caps of l to c.
+func (l queryLimits) limitCursor(c *database.Cursor) {
+	c.Limit(l.maxRows, l.maxBytes)
+}
+
+// bucket is a token bucket holding up to burst tokens, refilled at a rate
+// per second.
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// rateLimiter keeps a token bucket per client key.
+type rateLimiter struct {
+	mu      sync.Mutex
+	buckets map[string]*bucket
+}
+
+func newRateLimiter() *rateLimiter {
+	return &rateLimiter{buckets: make(map[string]*bucket)}
+}
+
+// take removes a token from key's bucket. When it is empty it returns how
+// long until it holds one again. A burst below one allows one request.
+func (rl *rateLimiter) take(key string, rate float64, burst int, now time.Time) (time.Duration, bool) {
+	capacity := float64(max(burst, 1))
+
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	b, ok := rl.buckets[key]
+	if !ok {
+		b = &bucket{tokens: capacity, last: now}
+		rl.buckets[key] = b
+	}
+	b.tokens = min(capacity, b.tokens+now.Sub(b.last).Seconds()*rate)
+	b.last = now
+	if b.tokens < 1 {
+		return time.Duration((1 - b.tokens) / rate * float64(time.Second)), false
+	}
+	b.tokens--
+	return 0, true
+}
+
+// prune forgets buckets untouched since cutoff. Any bucket idle that long
+// under the current rates has refilled, so forgetting it changes nothing.
+func (rl *rateLimiter) prune(cutoff time.Time) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	for key, b := range rl.buckets {
+		if b.last.Before(cutoff) {
+			delete(rl.buckets, key)
+		}
+	}
+}
+
+// throttle takes a request or statement from the rate limits of the
+// caller's client address and, when authenticated, its token.
+func (s *Server) throttle(ctx context.Context) error {
+	if err := s.throttleClient(ctx); err != nil {
+		return err
+	}
+	return s.throttleIdentity(ctx)
+}
+
+// throttleClient takes a request or statement from the rate limit of the
+// caller's client address.
+func (s *Server) throttleClient(ctx context.Context) error {
+	config := s.cfg()
+	if config.IPRate <= 0 {
+		return nil
+	}
+	c, _ := ctx.Value(clientKey{}).(client)
+	host, _, err := net.SplitHostPort(c.addr)
+	if err != nil {
+		host = c.addr
+	}
+	if wait, ok := s.rates.take("ip:"+host, config.IPRate, config.IPBurst, time.Now()); !ok {
+		return &limitError{err: errRateLimited, detail: "too many requests from " + host, retryAfter: wait}
+	}
+	return nil
+}
+
+// throttleIdentity takes a request or statement from the rate limit of the
+// caller's token. Unauthenticated callers have none.
+func (s *Server) throttleIdentity(ctx context.Context) error {
+	id := identity(ctx)
+	if id == nil {
+		return nil
+	}
+	config := s.cfg()
+	rate, burst := config.TokenRate, config.TokenBurst
+	if role := s.roleLimits(ctx); role.Rate > 0 {
+		rate, burst = role.Rate, role.Burst
+	}
+	if rate <= 0 {
+		return nil
+	}
+	if wait, ok := s.rates.take("token:"+id.Name, rate, burst, time.Now()); !ok {
+		return &limitError{err: errRateLimited, detail: "too many requests for " + id.Name, retryAfter: wait}
+	}
+	return nil
+}
+
+// pruneRates forgets the buckets of clients idle long enough to have
+// refilled at the slowest configured rate.
+func (s *Server) pruneRates() {
+	config := s.cfg()
+	slowest, burst := math.Inf(1), 1
+	for _, l := range []RoleLimits{{Rate: config.IPRate, Burst: config.IPBurst}, {Rate: config.TokenRate, Burst: config.TokenBurst}} {
+		if l.Rate > 0 {
+			slowest, burst = min(slowest, l.Rate), max(burst, l.Burst)
+		}
+	}
+	for _, l := range config.Roles {
+		if l.Rate > 0 {
+			slowest, burst = min(slowest, l.Rate), max(burst, l.Burst)
+		}
+	}
+	if math.IsInf(slowest, 1) {
+		s.rates.prune(time.Now())
+		return
+	}
+	refill := time.Duration(float64(burst) / slowest * float64(time.Second))
+	s.rates.prune(time.Now().Add(-refill))
+}
+
+// querySlots counts the queries running against each database.
+type querySlots struct {
+	mu      sync.Mutex
+	running map[string]int
+}
+
+func newQuerySlots() *querySlots {
+	return &querySlots{running: make(map[string]int)}
+}
+
+// acquireDatabase takes one of dbName's concurrency slots. The returned
+// function gives it back.
+func (s *Server) acquireDatabase(dbName string) (func(), error) {
+	config := s.cfg()
+	limit := config.MaxConcurrentQueries
+	if db, ok := config.Databases[dbName]; ok && db.MaxConcurrentQueries != 0 {
+		limit = db.MaxConcurrentQueries
+	}
+	if limit <= 0 || dbName == "" {
+		return func() {}, nil
+	}
+
+	slots := s.slots
+	slots.mu.Lock()
+	defer slots.mu.Unlock()
+	if slots.running[dbName] >= limit {
+		return nil, &limitError{err: errTooManyQueries, detail: fmt.Sprintf("%s is running its limit of %d", dbName, limit), retryAfter: concurrencyRetryAfter}
+	}
+	slots.running[dbName]++
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			slots.mu.Lock()
+			defer slots.mu.Unlock()
+			if slots.running[dbName]--; slots.running[dbName] == 0 {
+				delete(slots.running, dbName)
+			}
+		})
+	}, nil
+}
+
+// limitClients wraps a route's handler in the rate limit of the caller's
+// client address. It runs before authentication, so a client guessing
+// tokens is held to it too.
+func (s *Server) limitClients(public bool, next http.HandlerFunc) http.HandlerFunc {
+	if public {
+		return next
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := s.throttleClient(r.Context()); err != nil {
+			writeError(w, err)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// limitRequests wraps an authorized route's handler in the caller's token
+// rate limit and, for routes on a database, its concurrency cap.
+func (s *Server) limitRequests(public bool, next http.HandlerFunc) http.HandlerFunc {
+	if public {
+		return next
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := s.throttleIdentity(r.Context()); err != nil {
+			writeError(w, err)
+			return
+		}
+		release, err := s.acquireDatabase(s.requestDatabase(r))
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		defer release()
+		next(w, r)
+	}
+}
+
+// requestDatabase returns the database r runs against: the one it names,
+// or that of the transaction or cursor it continues. It is "" for requests
+// on no database and for sessions the caller does not hold, which the
+// handlers turn away.
+func (s *Server) requestDatabase(r *http.Request) string {
+	if dbName := r.PathValue("db"); dbName != "" {
+		return dbName
+	}
+	owner := sessionOwner(r.Context())
+	if id := r.PathValue("tx"); id != "" {
+		return s.txs.database(id, owner)
+	}
+	if id := r.PathValue("cursor"); id != "" {
+		return s.cursors.database(id, owner)
+	}
+	if r.URL.Path == "/query/next" {
+		return s.cursors.database(r.FormValue("cursor"), owner)
+	}
+	return r.URL.Query().Get("db")
+}
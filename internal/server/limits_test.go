@@ -0,0 +1,114 @@
+package server
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/bxrne/branchlore/internal/auth"
+)
+
+func TestConcurrencyCapCoversSessions(t *testing.T) {
+	s := newTestServer(t, &Config{MaxConcurrentQueries: 1})
+	newTestDatabase(t, s, "db")
+	h := s.handler()
+
+	w := do(t, h, http.MethodPost, "/v1/databases/db/branches/main/transactions", nil)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("begin: %d %s", w.Code, w.Body)
+	}
+	var tx txInfo
+	decode(t, w, &tx)
+
+	w = do(t, h, http.MethodPost, testQueryPath, queryRequest{Query: "VALUES (1), (2), (3)", PageSize: 1})
+	if w.Code != http.StatusOK {
+		t.Fatalf("paged query: %d %s", w.Code, w.Body)
+	}
+	var page struct {
+		Cursor string `json:"cursor"`
+	}
+	decode(t, w, &page)
+
+	requests := []struct {
+		method, target string
+		body           interface{}
+	}{
+		{http.MethodPost, "/v1/transactions/" + tx.ID + "/query", queryRequest{Query: "SELECT 1"}},
+		{http.MethodGet, "/v1/cursors/" + page.Cursor, nil},
+		{http.MethodGet, "/query/next?cursor=" + page.Cursor, nil},
+	}
+
+	release, err := s.acquireDatabase("db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, req := range requests {
+		w := do(t, h, req.method, req.target, req.body)
+		if w.Code != http.StatusTooManyRequests || errorCode(t, w) != CodeTooManyQueries {
+			t.Errorf("%s %s with the database busy: %d %s, want 429 %s", req.method, req.target, w.Code, w.Body, CodeTooManyQueries)
+		}
+	}
+	release()
+
+	for _, req := range requests {
+		w := do(t, h, req.method, req.target, req.body)
+		if w.Code != http.StatusOK {
+			t.Errorf("%s %s with the database free: %d %s", req.method, req.target, w.Code, w.Body)
+		}
+	}
+}
+
+func TestClientRateLimitAppliesBeforeAuthentication(t *testing.T) {
+	s := newTestServer(t, &Config{Auth: true, IPRate: 0.001, IPBurst: 1})
+	h := s.handler()
+
+	w := doAs(t, h, "blt_wrong", http.MethodGet, "/v1/databases", nil)
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("first guess: %d %s, want 401", w.Code, w.Body)
+	}
+	w = doAs(t, h, "blt_wrong", http.MethodGet, "/v1/databases", nil)
+	if w.Code != http.StatusTooManyRequests || errorCode(t, w) != CodeRateLimited {
+		t.Fatalf("second guess: %d %s, want 429 %s", w.Code, w.Body, CodeRateLimited)
+	}
+	if w.Header().Get("Retry-After") == "" {
+		t.Error("no Retry-After header")
+	}
+}
+
+func TestIdentityRateLimit(t *testing.T) {
+	s := newTestServer(t, &Config{Auth: true, TokenRate: 0.001, TokenBurst: 1})
+	alice := newTestToken(t, s, "alice", auth.ScopeRead)
+	bob := newTestToken(t, s, "bob", auth.ScopeRead)
+	h := s.handler()
+
+	if w := doAs(t, h, alice, http.MethodGet, "/v1/databases", nil); w.Code != http.StatusOK {
+		t.Fatalf("first request: %d %s", w.Code, w.Body)
+	}
+	if w := doAs(t, h, alice, http.MethodGet, "/v1/databases", nil); w.Code != http.StatusTooManyRequests {
+		t.Fatalf("second request: %d %s, want 429", w.Code, w.Body)
+	}
+	if w := doAs(t, h, bob, http.MethodGet, "/v1/databases", nil); w.Code != http.StatusOK {
+		t.Fatalf("another identity: %d %s", w.Code, w.Body)
+	}
+}
+
+func TestTimeoutFor(t *testing.T) {
+	for _, tt := range []struct {
+		name      string
+		limits    queryLimits
+		requested time.Duration
+		want      time.Duration
+	}{
+		{"default", queryLimits{timeout: time.Second}, 0, time.Second},
+		{"requested", queryLimits{timeout: time.Second}, time.Minute, time.Minute},
+		{"no limit", queryLimits{}, 0, 0},
+		{"capped request", queryLimits{maxTimeout: 5 * time.Second}, time.Minute, 5 * time.Second},
+		{"capped default", queryLimits{timeout: time.Minute, maxTimeout: 5 * time.Second}, 0, 5 * time.Second},
+		{"cap on no limit", queryLimits{maxTimeout: 5 * time.Second}, 0, 5 * time.Second},
+		{"under the cap", queryLimits{maxTimeout: 5 * time.Second}, time.Second, time.Second},
+	} {
+		if got := tt.limits.timeoutFor(tt.requested); got != tt.want {
+			t.Errorf("%s: timeoutFor(%v) = %v, want %v", tt.name, tt.requested, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,304 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/bxrne/branchlore/internal/database"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// maxLoggedStatement caps the length of a statement's fingerprint text in
+// log records.
+const maxLoggedStatement = 200
+
+// newLogger builds the server's logger from the configured level and
+// format, writing to w. The returned level can be changed while the logger
+// is in use.
+func newLogger(config *Config, w io.Writer) (*slog.Logger, *slog.LevelVar, error) {
+	level, err := parseLogLevel(config.LogLevel)
+	if err != nil {
+		return nil, nil, err
+	}
+	levelVar := new(slog.LevelVar)
+	levelVar.Set(level)
+	opts := &slog.HandlerOptions{Level: levelVar}
+
+	var handler slog.Handler
+	switch config.LogFormat {
+	case "", "text":
+		handler = slog.NewTextHandler(w, opts)
+	case "json":
+		handler = slog.NewJSONHandler(w, opts)
+	default:
+		return nil, nil, fmt.Errorf("invalid log format %q: want text or json", config.LogFormat)
+	}
+	return slog.New(contextHandler{handler}), levelVar, nil
+}
+
+// parseLogLevel parses debug, info, warn or error. The empty string is info.
+func parseLogLevel(s string) (slog.Level, error) {
+	var level slog.Level
+	if s != "" {
+		if err := level.UnmarshalText([]byte(s)); err != nil {
+			return 0, fmt.Errorf("invalid log level %q: want debug, info, warn or error", s)
+		}
+	}
+	return level, nil
+}
+
+type logAttrsKey struct{}
+
+// withLogAttrs returns ctx carrying attrs, which every record logged with
+// ctx is given, whichever package logs it.
+func withLogAttrs(ctx context.Context, attrs ...slog.Attr) context.Context {
+	prev, _ := ctx.Value(logAttrsKey{}).([]slog.Attr)
+	return context.WithValue(ctx, logAttrsKey{}, append(slices.Clip(prev), attrs...))
+}
+
+// contextHandler adds the attributes of withLogAttrs to each record.
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if attrs, ok := ctx.Value(logAttrsKey{}).([]slog.Attr); ok {
+		r.AddAttrs(attrs...)
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
+
+// requestID returns the client's request ID if it sent a usable one, or a
+// new random one.
+func requestID(fromClient string) string {
+	if fromClient != "" && len(fromClient) <= 128 && !strings.ContainsFunc(fromClient, func(r rune) bool {
+		return r > unicode.MaxASCII || !unicode.IsPrint(r)
+	}) {
+		return fromClient
+	}
+	buf := make([]byte, 8)
+	rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
+
+// statusRecorder remembers the status a handler responded with.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	return r.ResponseWriter.Write(b)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer, so
+// streamed responses can still be flushed.
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+// withRequestLog tags each HTTP request with a request ID, echoed in the
+// X-Request-ID response header, and logs it once it is served.
+func (s *Server) withRequestLog(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		id := requestID(r.Header.Get("X-Request-ID"))
+		w.Header().Set("X-Request-ID", id)
+		ctx := withLogAttrs(r.Context(), slog.String("request_id", id))
+
+		rec := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, r.WithContext(ctx))
+
+		level := slog.LevelInfo
+		switch {
+		case rec.status == http.StatusServiceUnavailable:
+			// Busy, cancelled or shutting down rather than broken.
+			level = slog.LevelWarn
+		case rec.status >= http.StatusInternalServerError:
+			level = slog.LevelError
+		}
+		s.logger.LogAttrs(ctx, level, "HTTP request",
+			slog.String("method", r.Method),
+			slog.String("path", r.URL.Path),
+			slog.Int("status", rec.status),
+			slog.Duration("duration", time.Since(start)),
+			slog.String("remote", r.RemoteAddr),
+		)
+	})
+}
+
+// grpcUnaryLog tags each unary call with a request ID, logs it and records
+// its metrics.
+func (s *Server) grpcUnaryLog(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	start := time.Now()
+	ctx = s.grpcRequestContext(ctx, grpc.SetHeader)
+	resp, err := handler(ctx, req)
+	s.finishCall(ctx, info.FullMethod, start, err)
+	return resp, err
+}
+
+// grpcStreamLog tags each streaming call with a request ID, and logs it
+// and records its metrics once the stream ends.
+func (s *Server) grpcStreamLog(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	start := time.Now()
+	ctx := s.grpcRequestContext(ss.Context(), func(_ context.Context, md metadata.MD) error {
+		return ss.SetHeader(md)
+	})
+	err := handler(srv, &authedStream{ServerStream: ss, ctx: ctx})
+	s.finishCall(ctx, info.FullMethod, start, err)
+	return err
+}
+
+// grpcRequestContext picks the call's request ID, sends it back in the
+// x-request-id header and returns ctx tagged with it.
+func (s *Server) grpcRequestContext(ctx context.Context, setHeader func(context.Context, metadata.MD) error) context.Context {
+	var fromClient string
+	if v := metadata.ValueFromIncomingContext(ctx, "x-request-id"); len(v) > 0 {
+		fromClient = v[0]
+	}
+	id := requestID(fromClient)
+	setHeader(ctx, metadata.Pairs("x-request-id", id))
+	return withLogAttrs(ctx, slog.String("request_id", id))
+}
+
+func (s *Server) finishCall(ctx context.Context, method string, start time.Time, err error) {
+	s.metrics.observeCall(method, start, err)
+	code := status.Code(err)
+	level := slog.LevelInfo
+	switch code {
+	case codes.Internal, codes.Unknown, codes.DataLoss, codes.Unavailable:
+		level = slog.LevelError
+	}
+	var remote string
+	if p, ok := peer.FromContext(ctx); ok {
+		remote = p.Addr.String()
+	}
+	s.logger.LogAttrs(ctx, level, "gRPC call",
+		slog.String("method", method),
+		slog.String("code", code.String()),
+		slog.Duration("duration", time.Since(start)),
+		slog.String("remote", remote),
+	)
+}
+
+// logStatement returns a database manager observer that logs statements:
+// successful ones at debug level and failed ones at info. Only the
+// statement's fingerprint is logged, never its literal values.
+func logStatement(logger *slog.Logger) func(context.Context, database.Execution) {
+	return func(ctx context.Context, x database.Execution) {
+		level := slog.LevelDebug
+		if x.Err != nil {
+			level = slog.LevelInfo
+		}
+		if !logger.Enabled(ctx, level) {
+			return
+		}
+
+		text, fingerprint := fingerprintStatement(x.Query)
+		attrs := []slog.Attr{
+			slog.String("db", x.Database),
+			slog.String("branch", x.Branch),
+			slog.String("fingerprint", fingerprint),
+			slog.String("statement", text),
+			slog.Duration("duration", x.Duration),
+		}
+		if x.Err != nil {
+			logger.LogAttrs(ctx, level, "Statement failed", append(attrs, slog.String("error", x.Err.Error()))...)
+			return
+		}
+		logger.LogAttrs(ctx, level, "Statement executed", append(attrs, slog.Int64("rows_affected", x.RowsAffected))...)
+	}
+}
+
+// fingerprintStatement normalizes query for grouping in logs: literals
+// become ?, comments are dropped and whitespace is collapsed. It returns
+// the normalized text, cut to maxLoggedStatement bytes, and a short hash of
+// all of it.
+func fingerprintStatement(query string) (string, string) {
+	var b strings.Builder
+	space := false
+	for i := 0; i < len(query); {
+		c := query[i]
+		j := database.SkipLiteral(query, i)
+		if unicode.IsSpace(rune(c)) || (j > i && (c == '-' || c == '/')) {
+			space = true
+			i = max(j, i+1)
+			continue
+		}
+		if space && b.Len() > 0 {
+			b.WriteByte(' ')
+		}
+		space = false
+
+		var prev byte
+		if i > 0 {
+			prev = query[i-1]
+		}
+		switch {
+		case c == '\'':
+			b.WriteByte('?')
+			i = j
+		case j > i:
+			// A quoted identifier.
+			b.WriteString(query[i:j])
+			i = j
+		case (c == 'x' || c == 'X') && i+1 < len(query) && query[i+1] == '\'' && !identByte(prev):
+			b.WriteByte('?')
+			i = database.SkipLiteral(query, i+1)
+		case c >= '0' && c <= '9' && !identByte(prev):
+			// A number, but not the digits of an identifier or a numbered
+			// parameter such as ?1.
+			for i < len(query) && (identByte(query[i]) || query[i] == '.') {
+				i++
+			}
+			b.WriteByte('?')
+		default:
+			b.WriteByte(c)
+			i++
+		}
+	}
+
+	text := b.String()
+	sum := sha256.Sum256([]byte(text))
+	if len(text) > maxLoggedStatement {
+		text = text[:maxLoggedStatement] + "..."
+	}
+	return text, hex.EncodeToString(sum[:8])
+}
+
+func identByte(c byte) bool {
+	return c == '_' || c == '$' || c == '?' || c == ':' || c == '@' ||
+		c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c >= 0x80
+}
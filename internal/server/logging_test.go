@@ -0,0 +1,165 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+
+	"github.com/bxrne/branchlore/internal/database"
+	pb "github.com/bxrne/branchlore/proto/branchlore/v1"
+)
+
+func TestParseLogLevel(t *testing.T) {
+	for in, want := range map[string]slog.Level{"": slog.LevelInfo, "debug": slog.LevelDebug, "WARN": slog.LevelWarn, "error": slog.LevelError} {
+		if got, err := parseLogLevel(in); err != nil || got != want {
+			t.Errorf("parseLogLevel(%q) = %v, %v, want %v", in, got, err, want)
+		}
+	}
+	if _, err := parseLogLevel("verbose"); err == nil {
+		t.Error("parsed an unknown level")
+	}
+	if _, _, err := newLogger(&Config{LogFormat: "xml"}, new(bytes.Buffer)); err == nil {
+		t.Error("built a logger with an unknown format")
+	}
+}
+
+// logRecords decodes the JSON log records in buf.
+func logRecords(t *testing.T, buf *bytes.Buffer) []map[string]interface{} {
+	t.Helper()
+	var records []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		var r map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &r); err != nil {
+			t.Fatalf("log line %q: %v", line, err)
+		}
+		records = append(records, r)
+	}
+	return records
+}
+
+func TestRequestLog(t *testing.T) {
+	s := newTestServer(t, nil)
+	var buf bytes.Buffer
+	logger, _, err := newLogger(&Config{LogLevel: "info", LogFormat: "json"}, &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.logger = logger
+	h := s.handler()
+
+	r := httptest.NewRequest(http.MethodGet, "/v1/databases/nope", nil)
+	r.Header.Set("X-Request-ID", "abc-123")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if got := w.Header().Get("X-Request-ID"); got != "abc-123" {
+		t.Errorf("X-Request-ID %q, want the client's abc-123", got)
+	}
+
+	records := logRecords(t, &buf)
+	if len(records) != 1 {
+		t.Fatalf("got %d log records, want 1: %s", len(records), buf.String())
+	}
+	rec := records[0]
+	if rec["msg"] != "HTTP request" || rec["request_id"] != "abc-123" || rec["path"] != "/v1/databases/nope" || rec["status"] != 404.0 || rec["level"] != "INFO" {
+		t.Errorf("log record %v", rec)
+	}
+
+	// A request ID the client did not send is generated.
+	w = do(t, h, http.MethodGet, "/healthz", nil)
+	if id := w.Header().Get("X-Request-ID"); len(id) != 16 {
+		t.Errorf("generated request ID %q", id)
+	}
+}
+
+func TestRequestID(t *testing.T) {
+	for _, ok := range []string{"abc", "trace-1/2:3", strings.Repeat("a", 128)} {
+		if got := requestID(ok); got != ok {
+			t.Errorf("requestID(%q) = %q, want it kept", ok, got)
+		}
+	}
+	for _, bad := range []string{"", strings.Repeat("a", 129), "new\nline", "naïve"} {
+		if got := requestID(bad); got == bad || len(got) != 16 {
+			t.Errorf("requestID(%q) = %q, want a new ID", bad, got)
+		}
+	}
+}
+
+func TestFingerprintStatement(t *testing.T) {
+	tests := []struct {
+		query, want string
+	}{
+		{"SELECT * FROM users WHERE email = 'ada@example.com'", "SELECT * FROM users WHERE email = ?"},
+		{"select  1,\n\t2.5 -- comment\nfrom t", "select ?, ? from t"},
+		{"INSERT INTO t2 VALUES (?1, :name, X'00ff', 'it''s') /* note */", "INSERT INTO t2 VALUES (?1, :name, ?, ?)"},
+		{`SELECT "col 1" FROM "t"`, `SELECT "col 1" FROM "t"`},
+	}
+	for _, tt := range tests {
+		if text, _ := fingerprintStatement(tt.query); text != tt.want {
+			t.Errorf("fingerprintStatement(%q) = %q, want %q", tt.query, text, tt.want)
+		}
+	}
+
+	_, a := fingerprintStatement("SELECT * FROM t WHERE id = 1")
+	_, b := fingerprintStatement("SELECT *  FROM t WHERE id = 42")
+	_, c := fingerprintStatement("SELECT * FROM u WHERE id = 1")
+	if a != b || a == c {
+		t.Errorf("fingerprints %s, %s, %s: want the first two equal and the third different", a, b, c)
+	}
+
+	long, _ := fingerprintStatement("SELECT " + strings.Repeat("x, ", 200) + "y")
+	if len(long) != maxLoggedStatement+len("...") {
+		t.Errorf("long statement logged as %d bytes", len(long))
+	}
+}
+
+func TestLogStatementHidesLiterals(t *testing.T) {
+	var buf bytes.Buffer
+	logger, _, err := newLogger(&Config{LogLevel: "debug", LogFormat: "json"}, &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	observe := logStatement(logger)
+	ctx := withLogAttrs(context.Background(), slog.String("request_id", "r1"))
+	observe(ctx, database.Execution{Database: "db", Branch: "main", Query: "UPDATE users SET password = 'hunter2'", RowsAffected: 1})
+	observe(ctx, database.Execution{Database: "db", Branch: "main", Query: "SELECT 'hunter2'", Err: errors.New("boom")})
+
+	if strings.Contains(buf.String(), "hunter2") {
+		t.Errorf("log holds a literal: %s", buf.String())
+	}
+	records := logRecords(t, &buf)
+	if len(records) != 2 {
+		t.Fatalf("got %d records, want 2", len(records))
+	}
+	if r := records[0]; r["level"] != "DEBUG" || r["msg"] != "Statement executed" || r["request_id"] != "r1" || r["rows_affected"] != 1.0 {
+		t.Errorf("success record %v", r)
+	}
+	if r := records[1]; r["level"] != "INFO" || r["msg"] != "Statement failed" || r["error"] != "boom" {
+		t.Errorf("failure record %v", r)
+	}
+}
+
+func TestGRPCRequestID(t *testing.T) {
+	s := newTestServer(t, nil)
+	c := serveGRPCTest(t, s)
+
+	var header metadata.MD
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "x-request-id", "abc-123")
+	if _, err := c.ListDatabases(ctx, &pb.ListDatabasesRequest{}, grpc.Header(&header)); err != nil {
+		t.Fatal(err)
+	}
+	if got := header.Get("x-request-id"); len(got) != 1 || got[0] != "abc-123" {
+		t.Errorf("x-request-id %q, want the client's abc-123", got)
+	}
+}
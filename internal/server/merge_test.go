@@ -0,0 +1,80 @@
+package server
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/bxrne/branchlore/internal/git"
+)
+
+func TestMergeIntoProtectedBranch(t *testing.T) {
+	s := newTestServer(t, nil)
+	newTestDatabase(t, s, "db")
+	h := s.handler()
+	main := "/v1/databases/db/branches/main"
+
+	if w := do(t, h, http.MethodPost, main+"/query", queryRequest{Query: "CREATE TABLE t (a INTEGER)"}); w.Code != http.StatusOK {
+		t.Fatalf("create table: %d %s", w.Code, w.Body)
+	}
+	if w := do(t, h, http.MethodPost, main+"/commits", commitRequest{Message: "schema"}); w.Code != http.StatusCreated {
+		t.Fatalf("commit: %d %s", w.Code, w.Body)
+	}
+	config := &git.Config{}
+	if err := config.Protect(git.Protection{Branches: "main", Checks: git.Checks{
+		Assertions: []git.Assertion{{Name: "positive", Query: "SELECT a FROM t WHERE a <= 0"}},
+	}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.gitMgr.SaveConfig("db", config); err != nil {
+		t.Fatal(err)
+	}
+
+	if w := do(t, h, http.MethodPost, main+"/query", queryRequest{Query: "INSERT INTO t VALUES (1)"}); w.Code == http.StatusOK {
+		t.Errorf("wrote to a protected branch directly")
+	}
+	if w := do(t, h, http.MethodPost, main+"/query", queryRequest{Query: "SELECT count(*) FROM t"}); w.Code != http.StatusOK {
+		t.Errorf("read a protected branch: %d %s", w.Code, w.Body)
+	}
+
+	for _, branch := range []string{"bad", "good"} {
+		if w := do(t, h, http.MethodPost, "/v1/databases/db/branches", createBranchRequest{Name: branch}); w.Code != http.StatusCreated {
+			t.Fatalf("create %s: %d %s", branch, w.Code, w.Body)
+		}
+	}
+	// Nor through another branch.
+	attach := "ATTACH DATABASE '" + s.gitMgr.GetBranchPath("db", "main") + "' AS m; CREATE TABLE m.pwned (x)"
+	if w := do(t, h, http.MethodPost, "/v1/databases/db/branches/bad/query", queryRequest{Query: attach}); w.Code == http.StatusOK {
+		t.Errorf("wrote to a protected branch through an attachment")
+	}
+	w := do(t, h, http.MethodPost, main+"/query", queryRequest{Query: "SELECT name FROM sqlite_master WHERE name = 'pwned'"})
+	if w.Code != http.StatusOK || strings.Contains(w.Body.String(), "pwned") {
+		t.Errorf("protected branch after a write through an attachment: %d %s", w.Code, w.Body)
+	}
+
+	do(t, h, http.MethodPost, "/v1/databases/db/branches/bad/query", queryRequest{Query: "INSERT INTO t VALUES (0)"})
+	do(t, h, http.MethodPost, "/v1/databases/db/branches/good/query", queryRequest{Query: "INSERT INTO t VALUES (1)"})
+
+	w = do(t, h, http.MethodPost, main+"/merges", mergeRequest{From: "bad"})
+	if w.Code != http.StatusPreconditionFailed || errorCode(t, w) != CodeCheckFailed {
+		t.Errorf("merge failing a check: %d %s, want 412 %s", w.Code, w.Body, CodeCheckFailed)
+	}
+	w = do(t, h, http.MethodPost, main+"/merges", mergeRequest{From: "good"})
+	if w.Code != http.StatusCreated {
+		t.Fatalf("merge passing the checks: %d %s", w.Code, w.Body)
+	}
+	var commit commitInfo
+	decode(t, w, &commit)
+	if commit.Hash == "" {
+		t.Errorf("merge commit %+v has no hash", commit)
+	}
+
+	// bad branched before the merge, so main has changed under it.
+	w = do(t, h, http.MethodPost, main+"/merges", mergeRequest{From: "bad"})
+	if w.Code != http.StatusConflict || errorCode(t, w) != CodeMergeConflict {
+		t.Errorf("merge of a stale branch: %d %s, want 409 %s", w.Code, w.Body, CodeMergeConflict)
+	}
+	if w := do(t, h, http.MethodDelete, main, nil); w.Code != http.StatusConflict || errorCode(t, w) != CodeBranchProtected {
+		t.Errorf("delete main: %d %s, want 409 %s", w.Code, w.Body, CodeBranchProtected)
+	}
+}
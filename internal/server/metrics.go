@@ -0,0 +1,226 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/bxrne/branchlore/internal/auth"
+	"github.com/bxrne/branchlore/internal/database"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"google.golang.org/grpc/status"
+)
+
+// metrics holds the server's Prometheus metrics. Events are counted as
+// they happen; the state of databases, branches and connections is read
+// when /metrics is scraped.
+type metrics struct {
+	registry *prometheus.Registry
+
+	httpRequests  *prometheus.CounterVec
+	httpDuration  *prometheus.HistogramVec
+	grpcCalls     *prometheus.CounterVec
+	grpcDuration  *prometheus.HistogramVec
+	queries       *prometheus.CounterVec
+	queryDuration *prometheus.HistogramVec
+}
+
+func newMetrics(s *Server) *metrics {
+	m := &metrics{
+		registry: prometheus.NewRegistry(),
+		httpRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "branchlore_http_requests_total",
+			Help: "HTTP requests served, by operation, method and status.",
+		}, []string{"operation", "method", "status"}),
+		httpDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "branchlore_http_request_duration_seconds",
+			Help:    "Time taken to serve HTTP requests, by operation and method.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"operation", "method"}),
+		grpcCalls: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "branchlore_grpc_calls_total",
+			Help: "gRPC calls served, by method and status code.",
+		}, []string{"method", "code"}),
+		grpcDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "branchlore_grpc_call_duration_seconds",
+			Help:    "Time taken to serve gRPC calls, by method.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"method"}),
+		queries: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "branchlore_queries_total",
+			Help: "SQL statements run, by database, branch, statement type and result.",
+		}, []string{"db", "branch", "type", "result"}),
+		queryDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "branchlore_query_duration_seconds",
+			Help:    "Time taken to run SQL statements, by database, branch and statement type.",
+			Buckets: []float64{.0001, .0005, .001, .005, .01, .05, .1, .5, 1, 5, 30},
+		}, []string{"db", "branch", "type"}),
+	}
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.httpRequests, m.httpDuration,
+		m.grpcCalls, m.grpcDuration,
+		m.queries, m.queryDuration,
+		stateCollector{s},
+	)
+	return m
+}
+
+// instrument counts and times the requests next serves for operation.
+func (m *metrics) instrument(operation string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w}
+		next(rec, r)
+		if rec.status == 0 {
+			rec.status = http.StatusOK
+		}
+		m.httpRequests.WithLabelValues(operation, r.Method, strconv.Itoa(rec.status)).Inc()
+		m.httpDuration.WithLabelValues(operation, r.Method).Observe(time.Since(start).Seconds())
+	}
+}
+
+func (m *metrics) observeCall(method string, start time.Time, err error) {
+	m.grpcCalls.WithLabelValues(method, status.Code(err).String()).Inc()
+	m.grpcDuration.WithLabelValues(method).Observe(time.Since(start).Seconds())
+}
+
+// observeStatement is a database manager observer counting and timing
+// statements.
+func (m *metrics) observeStatement(_ context.Context, x database.Execution) {
+	kind := statementType(x.Query)
+	result := "ok"
+	if x.Err != nil {
+		result = "error"
+	}
+	m.queries.WithLabelValues(x.Database, x.Branch, kind, result).Inc()
+	m.queryDuration.WithLabelValues(x.Database, x.Branch, kind).Observe(x.Duration.Seconds())
+}
+
+// forget drops the series of a deleted branch, or of every branch of a
+// deleted database when branch is empty, so they stop being exported.
+func (m *metrics) forget(dbName, branch string) {
+	labels := prometheus.Labels{"db": dbName}
+	if branch != "" {
+		labels["branch"] = branch
+	}
+	m.queries.DeletePartialMatch(labels)
+	m.queryDuration.DeletePartialMatch(labels)
+}
+
+// statementType classifies query by its first keyword, keeping the number
+// of label values small.
+func statementType(query string) string {
+	words := database.Keywords(query, 1)
+	if len(words) == 0 {
+		return "other"
+	}
+	switch words[0] {
+	case "SELECT", "WITH", "VALUES":
+		return "select"
+	case "INSERT", "REPLACE":
+		return "insert"
+	case "UPDATE":
+		return "update"
+	case "DELETE":
+		return "delete"
+	case "CREATE", "DROP", "ALTER":
+		return "ddl"
+	case "BEGIN", "COMMIT", "END", "ROLLBACK", "SAVEPOINT", "RELEASE":
+		return "transaction"
+	}
+	return "other"
+}
+
+var (
+	databasesDesc = prometheus.NewDesc("branchlore_databases",
+		"Databases in the data directory.", nil, nil)
+	branchesDesc = prometheus.NewDesc("branchlore_branches",
+		"Branches of each database.", []string{"db"}, nil)
+	fileSizeDesc = prometheus.NewDesc("branchlore_database_file_size_bytes",
+		"Size of each branch's SQLite database and write-ahead log files.", []string{"db", "branch", "file"}, nil)
+	repoSizeDesc = prometheus.NewDesc("branchlore_git_repository_size_bytes",
+		"Size of each database's Git repository.", []string{"db"}, nil)
+	connectionsDesc = prometheus.NewDesc("branchlore_open_connections",
+		"Open SQLite connections held by the database manager.", []string{"db", "branch", "mode", "state"}, nil)
+	sessionsDesc = prometheus.NewDesc("branchlore_sessions",
+		"Open client sessions, by kind.", []string{"kind"}, nil)
+)
+
+// stateCollector reports the server's current state when scraped.
+type stateCollector struct {
+	s *Server
+}
+
+func (c stateCollector) Describe(ch chan<- *prometheus.Desc) {
+	for _, d := range []*prometheus.Desc{databasesDesc, branchesDesc, fileSizeDesc, repoSizeDesc, connectionsDesc, sessionsDesc} {
+		ch <- d
+	}
+}
+
+func (c stateCollector) Collect(ch chan<- prometheus.Metric) {
+	s := c.s
+	gauge := func(desc *prometheus.Desc, value float64, labels ...string) {
+		ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, value, labels...)
+	}
+
+	databases, err := s.gitMgr.ListDatabases()
+	if err != nil {
+		ch <- prometheus.NewInvalidMetric(databasesDesc, err)
+		return
+	}
+	gauge(databasesDesc, float64(len(databases)))
+	for _, dbName := range databases {
+		branches, err := s.gitMgr.ListBranches(dbName)
+		if err != nil {
+			ch <- prometheus.NewInvalidMetric(branchesDesc, err)
+			continue
+		}
+		gauge(branchesDesc, float64(len(branches)), dbName)
+		for _, branch := range branches {
+			path := s.gitMgr.GetBranchPath(dbName, branch)
+			for file, suffix := range map[string]string{"db": "", "wal": "-wal"} {
+				if info, err := os.Stat(path + suffix); err == nil {
+					gauge(fileSizeDesc, float64(info.Size()), dbName, branch, file)
+				}
+			}
+		}
+		if size, err := s.gitMgr.RepositorySize(dbName); err == nil {
+			gauge(repoSizeDesc, float64(size), dbName)
+		}
+	}
+
+	for _, pool := range s.dbMgr.Stats() {
+		mode := "read_write"
+		if pool.ReadOnly {
+			mode = "read_only"
+		}
+		gauge(connectionsDesc, float64(pool.InUse), pool.Database, pool.Branch, mode, "in_use")
+		gauge(connectionsDesc, float64(pool.Idle), pool.Database, pool.Branch, mode, "idle")
+	}
+
+	s.pgMu.Lock()
+	gauge(sessionsDesc, float64(len(s.pgConns)), "postgres")
+	s.pgMu.Unlock()
+	s.myMu.Lock()
+	gauge(sessionsDesc, float64(len(s.myConns)), "mysql")
+	s.myMu.Unlock()
+	gauge(sessionsDesc, float64(s.cursors.len()), "cursor")
+	gauge(sessionsDesc, float64(s.txs.len()), "transaction")
+}
+
+// handleMetrics serves the metrics in the Prometheus text format. They name
+// every database and branch, so with auth enabled only an admin of all
+// databases may scrape them.
+func (s *Server) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	if err := s.allow(r.Context(), auth.PermAdmin, "*", ""); err != nil {
+		writeError(w, err)
+		return
+	}
+	promhttp.HandlerFor(s.metrics.registry, promhttp.HandlerOpts{}).ServeHTTP(w, r)
+}
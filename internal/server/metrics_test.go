@@ -0,0 +1,90 @@
+package server
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/bxrne/branchlore/internal/auth"
+)
+
+// scrape returns the metrics exposition of h.
+func scrape(t *testing.T, h http.Handler, token string) string {
+	t.Helper()
+	w := doAs(t, h, token, http.MethodGet, "/metrics", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("GET /metrics: %d %s", w.Code, w.Body)
+	}
+	return w.Body.String()
+}
+
+func TestMetrics(t *testing.T) {
+	s := newTestServer(t, nil)
+	newTestDatabase(t, s, "db")
+	h := s.handler()
+
+	do(t, h, http.MethodPost, testQueryPath, queryRequest{Query: "CREATE TABLE t (a INTEGER)"})
+	do(t, h, http.MethodPost, testQueryPath, queryRequest{Query: "SELECT * FROM missing"})
+	if w := do(t, h, http.MethodPost, "/v1/databases/db/branches", createBranchRequest{Name: "dev"}); w.Code != http.StatusCreated {
+		t.Fatalf("create branch: %d %s", w.Code, w.Body)
+	}
+	do(t, h, http.MethodPost, "/v1/databases/db/branches/dev/query", queryRequest{Query: "INSERT INTO t VALUES (1)"})
+
+	body := scrape(t, h, "")
+	for _, want := range []string{
+		"branchlore_databases 1\n",
+		`branchlore_branches{db="db"} 2` + "\n",
+		`branchlore_queries_total{branch="main",db="db",result="ok",type="ddl"} 1` + "\n",
+		`branchlore_queries_total{branch="main",db="db",result="error",type="select"} 1` + "\n",
+		`branchlore_queries_total{branch="dev",db="db",result="ok",type="insert"} 1` + "\n",
+		`branchlore_http_requests_total{method="POST",operation="query",status="200"} 2` + "\n",
+		`branchlore_http_requests_total{method="POST",operation="query",status="400"} 1` + "\n",
+		`branchlore_database_file_size_bytes{branch="main",db="db",file="db"}`,
+		`branchlore_git_repository_size_bytes{db="db"}`,
+		`branchlore_sessions{kind="transaction"} 0` + "\n",
+		"go_goroutines ",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("metrics lack %q", want)
+		}
+	}
+
+	// The series of a deleted branch stop being exported.
+	if w := do(t, h, http.MethodDelete, "/v1/databases/db/branches/dev", nil); w.Code != http.StatusNoContent {
+		t.Fatalf("delete branch: %d %s", w.Code, w.Body)
+	}
+	if body := scrape(t, h, ""); strings.Contains(body, `branch="dev"`) {
+		t.Error("metrics still name the deleted branch dev")
+	}
+}
+
+func TestMetricsNeedAdmin(t *testing.T) {
+	s := newTestServer(t, &Config{Auth: true})
+	reader := newTestToken(t, s, "reader", auth.ScopeRead)
+	admin := newTestToken(t, s, "admin", auth.ScopeAdmin)
+	h := s.handler()
+
+	if w := doAs(t, h, reader, http.MethodGet, "/metrics", nil); w.Code != http.StatusForbidden {
+		t.Errorf("scrape with a read token: %d, want 403", w.Code)
+	}
+	scrape(t, h, admin)
+}
+
+func TestStatementType(t *testing.T) {
+	for query, want := range map[string]string{
+		"SELECT 1":                        "select",
+		"  with x AS (SELECT 1) SELECT *": "select",
+		"VALUES (1)":                      "select",
+		"REPLACE INTO t VALUES (1)":       "insert",
+		"UPDATE t SET a = 1":              "update",
+		"DELETE FROM t":                   "delete",
+		"/* c */ DROP TABLE t":            "ddl",
+		"BEGIN":                           "transaction",
+		"PRAGMA user_version":             "other",
+		"":                                "other",
+	} {
+		if got := statementType(query); got != want {
+			t.Errorf("statementType(%q) = %q, want %q", query, got, want)
+		}
+	}
+}
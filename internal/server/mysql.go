@@ -0,0 +1,972 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bxrne/branchlore/internal/auth"
+	"github.com/bxrne/branchlore/internal/database"
+)
+
+const (
+	comQuit             = 0x01
+	comInitDB           = 0x02
+	comQuery            = 0x03
+	comPing             = 0x0e
+	comStmtPrepare      = 0x16
+	comStmtExecute      = 0x17
+	comStmtSendLongData = 0x18
+	comStmtClose        = 0x19
+	comStmtReset        = 0x1a
+	comResetConnection  = 0x1f
+)
+
+const (
+	clientLongPassword         = 0x00000001
+	clientLongFlag             = 0x00000004
+	clientConnectWithDB        = 0x00000008
+	clientSSL                  = 0x00000800
+	clientProtocol41           = 0x00000200
+	clientTransactions         = 0x00002000
+	clientSecureConnection     = 0x00008000
+	clientMultiStatements      = 0x00010000
+	clientMultiResults         = 0x00020000
+	clientPSMultiResults       = 0x00040000
+	clientPluginAuth           = 0x00080000
+	clientPluginAuthLenencData = 0x00200000
+
+	myServerCaps = clientLongPassword | clientLongFlag | clientConnectWithDB | clientProtocol41 |
+		clientTransactions | clientSecureConnection | clientMultiStatements | clientMultiResults |
+		clientPSMultiResults | clientPluginAuth | clientPluginAuthLenencData
+)
+
+const (
+	statusInTrans            = 0x0001
+	statusAutocommit         = 0x0002
+	statusMoreResults        = 0x0008
+	statusNoBackslashEscapes = 0x0200
+)
+
+const (
+	myServerVersion = "8.0.0-branchlore"
+	myAuthPlugin    = "mysql_native_password"
+	myMaxPacket     = 1<<24 - 1
+
+	// myClearPasswordPlugin carries API tokens when auth is enabled.
+	myClearPasswordPlugin = "mysql_clear_password"
+)
+
+// myError is an error with its own MySQL error number and SQLSTATE, for
+// failures that do not come from the database layer.
+type myError struct {
+	code    uint16
+	state   string
+	message string
+}
+
+func (e *myError) Error() string {
+	return e.message
+}
+
+var (
+	errMyNoDatabase     = &myError{code: 1046, state: "3D000", message: "No database selected"}
+	errMyMalformed      = &myError{code: 1835, state: "HY000", message: "Malformed communication packet"}
+	errMyPacketTooLarge = &myError{code: 1153, state: "08S01", message: "Got a packet bigger than 'max_allowed_packet' bytes"}
+)
+
+// myConn is one MySQL protocol session. The default database, "db@branch",
+// selects the branch statements run against and can be changed with
+// COM_INIT_DB or USE.
+type myConn struct {
+	s    *Server
+	conn net.Conn
+	r    *bufio.Reader
+	w    *bufio.Writer
+	seq  byte
+	ctx  context.Context
+	kill context.CancelFunc
+	// session is the context of the connection as authenticated; ctx
+	// derives from it with the permissions of the current branch.
+	session context.Context
+	id      uint32
+	caps    uint32
+
+	dbName string
+	branch string
+	tx     *database.Tx
+
+	stmts    map[uint32]*myStatement
+	lastStmt uint32
+
+	mu          sync.Mutex
+	cancelQuery context.CancelFunc
+	idle        idleGate
+}
+
+type myStatement struct {
+	query string
+	desc  *database.Statement
+	// paramTypes holds each parameter's type in the low byte and its flags
+	// in the high byte, as last sent by the client.
+	paramTypes []uint16
+	longData   map[int][]byte
+}
+
+func (s *Server) serveMySQL(ln net.Listener) {
+	defer s.wg.Done()
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.handleMySQLConn(conn)
+		}()
+	}
+}
+
+func (s *Server) handleMySQLConn(conn net.Conn) {
+	defer conn.Close()
+
+	ctx, cancel := context.WithCancel(s.ctx)
+	defer cancel()
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	c := &myConn{
+		s:       s,
+		conn:    conn,
+		r:       bufio.NewReader(conn),
+		w:       bufio.NewWriter(conn),
+		ctx:     ctx,
+		session: ctx,
+		kill:    cancel,
+		stmts:   make(map[uint32]*myStatement),
+	}
+	c.idle.conn = conn
+	defer c.close()
+
+	s.myMu.Lock()
+	s.myLastID++
+	c.id = s.myLastID
+	s.myConns[c.id] = c
+	s.myMu.Unlock()
+	defer func() {
+		s.myMu.Lock()
+		delete(s.myConns, c.id)
+		s.myMu.Unlock()
+	}()
+
+	c.ctx = withLogAttrs(c.ctx, slog.String("protocol", "mysql"), slog.String("remote", conn.RemoteAddr().String()),
+		slog.Uint64("conn_id", uint64(c.id)))
+	c.session = c.ctx
+	if !c.handshake() {
+		return
+	}
+	start := time.Now()
+	s.logger.DebugContext(c.ctx, "MySQL session started", slog.String("db", c.dbName), slog.String("branch", c.branch))
+	c.serve()
+	s.logger.DebugContext(c.ctx, "MySQL session ended", slog.Duration("duration", time.Since(start)))
+}
+
+// killMySQL handles KILL by cancelling what the session with the given
+// connection ID is running and, unless queryOnly, closing the session.
+func (s *Server) killMySQL(id uint32, queryOnly bool) bool {
+	s.myMu.Lock()
+	c, ok := s.myConns[id]
+	s.myMu.Unlock()
+	if !ok {
+		return false
+	}
+
+	c.mu.Lock()
+	if c.cancelQuery != nil {
+		c.cancelQuery()
+	}
+	c.mu.Unlock()
+	if !queryOnly {
+		c.kill()
+	}
+	return true
+}
+
+func (c *myConn) close() {
+	if c.tx != nil {
+		c.tx.Rollback()
+	}
+}
+
+// readPacket reads one payload, joining the packets it was split into. A
+// payload larger than the server's MySQL packet limit is answered with
+// ER_NET_PACKET_TOO_LARGE and fails, which ends the session, without being
+// read.
+func (c *myConn) readPacket() ([]byte, error) {
+	limit := c.s.cfg().MySQLMaxPacket
+	if limit <= 0 {
+		limit = defaultMySQLMaxPacket
+	}
+
+	var payload []byte
+	for {
+		var header [4]byte
+		if _, err := io.ReadFull(c.r, header[:]); err != nil {
+			return nil, err
+		}
+		n := int(header[0]) | int(header[1])<<8 | int(header[2])<<16
+		c.seq = header[3] + 1
+
+		if int64(len(payload)+n) > limit {
+			c.writeError(errMyPacketTooLarge)
+			c.w.Flush()
+			return nil, errMyPacketTooLarge
+		}
+		start := len(payload)
+		payload = append(payload, make([]byte, n)...)
+		if _, err := io.ReadFull(c.r, payload[start:]); err != nil {
+			return nil, err
+		}
+		if n < myMaxPacket {
+			return payload, nil
+		}
+	}
+}
+
+// writePacket buffers payload, splitting it into as many packets as its
+// size requires. Write errors surface on the next flush.
+func (c *myConn) writePacket(payload []byte) {
+	for {
+		n := min(len(payload), myMaxPacket)
+		c.w.Write([]byte{byte(n), byte(n >> 8), byte(n >> 16), c.seq})
+		c.w.Write(payload[:n])
+		c.seq++
+		payload = payload[n:]
+		if n < myMaxPacket {
+			return
+		}
+	}
+}
+
+func (c *myConn) status(more bool) uint16 {
+	status := uint16(statusNoBackslashEscapes)
+	if c.tx != nil {
+		status |= statusInTrans
+	} else {
+		status |= statusAutocommit
+	}
+	if more {
+		status |= statusMoreResults
+	}
+	return status
+}
+
+func (c *myConn) writeOK(affected, lastInsertID uint64, more bool) {
+	p := []byte{0x00}
+	p = appendLenEncInt(p, affected)
+	p = appendLenEncInt(p, lastInsertID)
+	p = binary.LittleEndian.AppendUint16(p, c.status(more))
+	p = binary.LittleEndian.AppendUint16(p, 0)
+	c.writePacket(p)
+}
+
+func (c *myConn) writeEOF(more bool) {
+	p := []byte{0xfe, 0, 0}
+	p = binary.LittleEndian.AppendUint16(p, c.status(more))
+	c.writePacket(p)
+}
+
+func (c *myConn) writeError(err error) {
+	resp := &myError{code: 1105, state: "HY000", message: err.Error()}
+	var me *myError
+	if errors.As(err, &me) {
+		resp = me
+	} else {
+		_, body := classify(err)
+		resp.code, resp.state = myErrorCode(body)
+		resp.message = body.Message
+	}
+
+	p := []byte{0xff}
+	p = binary.LittleEndian.AppendUint16(p, resp.code)
+	p = append(p, '#')
+	p = append(p, resp.state...)
+	p = append(p, resp.message...)
+	c.writePacket(p)
+}
+
+// myErrorCode maps a classified error onto the closest MySQL error number
+// and SQLSTATE.
+func myErrorCode(body ErrorBody) (uint16, string) {
+	switch body.SQLiteExtendedCode {
+	case 1555, 2067: // SQLITE_CONSTRAINT_PRIMARYKEY, SQLITE_CONSTRAINT_UNIQUE
+		return 1062, "23000"
+	case 1299: // SQLITE_CONSTRAINT_NOTNULL
+		return 1048, "23000"
+	case 787: // SQLITE_CONSTRAINT_FOREIGNKEY
+		return 1452, "23000"
+	case 275: // SQLITE_CONSTRAINT_CHECK
+		return 3819, "HY000"
+	}
+
+	switch body.Code {
+	case CodeInvalidArgument:
+		return 1210, "HY000"
+	case CodeDBNotFound, CodeBranchNotFound:
+		return 1049, "42000"
+	case CodeQueryTimeout:
+		return 3024, "HY000"
+	case CodeQueryCancelled:
+		return 1317, "70100"
+	case CodeRateLimited, CodeTooManyQueries:
+		return 1226, "42000"
+	case "SQLITE_CONSTRAINT":
+		return 1105, "23000"
+	case "SQLITE_ERROR":
+		switch {
+		case strings.Contains(body.Message, "syntax error"):
+			return 1064, "42000"
+		case strings.Contains(body.Message, "no such table"):
+			return 1146, "42S02"
+		case strings.Contains(body.Message, "no such column"):
+			return 1054, "42S22"
+		}
+	case "SQLITE_BUSY", "SQLITE_LOCKED":
+		return 1205, "HY000"
+	case "SQLITE_READONLY":
+		return 1792, "25006"
+	case "SQLITE_FULL":
+		return 1114, "HY000"
+	case "SQLITE_MISMATCH", "SQLITE_RANGE":
+		return 1210, "HY000"
+	}
+	return 1105, "HY000"
+}
+
+// handshake authenticates the client and reports whether the session is
+// ready for commands.
+func (c *myConn) handshake() bool {
+	scramble := make([]byte, 20)
+	if _, err := rand.Read(scramble); err != nil {
+		return false
+	}
+	for i, b := range scramble {
+		// The scramble is sent NUL terminated.
+		if b == 0 {
+			scramble[i] = 1
+		}
+	}
+
+	caps := uint32(myServerCaps)
+	if c.s.tls != nil {
+		caps |= clientSSL
+	}
+
+	p := []byte{10}
+	p = append(p, myServerVersion...)
+	p = append(p, 0)
+	p = binary.LittleEndian.AppendUint32(p, c.id)
+	p = append(p, scramble[:8]...)
+	p = append(p, 0)
+	p = binary.LittleEndian.AppendUint16(p, uint16(caps&0xffff))
+	p = append(p, myCharsetUTF8)
+	p = binary.LittleEndian.AppendUint16(p, c.status(false))
+	p = binary.LittleEndian.AppendUint16(p, uint16(caps>>16))
+	p = append(p, byte(len(scramble)+1))
+	p = append(p, make([]byte, 10)...)
+	p = append(p, scramble[8:]...)
+	p = append(p, 0)
+	p = append(p, myAuthPlugin...)
+	p = append(p, 0)
+	c.writePacket(p)
+	if c.w.Flush() != nil {
+		return false
+	}
+
+	pkt, err := c.readPacket()
+	if err != nil {
+		return false
+	}
+
+	// A client wanting TLS sends a truncated handshake response, then the
+	// full one over TLS. With TLS configured nothing else is accepted.
+	if c.s.tls != nil {
+		if len(pkt) != 32 || binary.LittleEndian.Uint32(pkt)&clientSSL == 0 {
+			return c.reject(&myError{code: 3159, state: "HY000", message: "Connections using insecure transport are prohibited"})
+		}
+		tlsConn := tls.Server(c.conn, c.s.tls)
+		if err := tlsConn.HandshakeContext(c.ctx); err != nil {
+			return false
+		}
+		c.conn = tlsConn
+		c.r, c.w = bufio.NewReader(tlsConn), bufio.NewWriter(tlsConn)
+		if pkt, err = c.readPacket(); err != nil {
+			return false
+		}
+	}
+
+	r := &myReader{b: pkt}
+	c.caps = r.uint32()
+	if c.caps&clientProtocol41 == 0 {
+		return c.reject(&myError{code: 1251, state: "08004", message: "Client does not support the 4.1 protocol"})
+	}
+	r.bytes(4 + 1 + 23) // max packet size, character set, reserved
+	user := r.nulString()
+
+	var response []byte
+	switch {
+	case c.caps&clientPluginAuthLenencData != 0:
+		response = r.lenEncBytes()
+	case c.caps&clientSecureConnection != 0:
+		response = r.bytes(int(r.uint8()))
+	default:
+		response = []byte(r.nulString())
+	}
+	var target string
+	if c.caps&clientConnectWithDB != 0 {
+		target = r.nulString()
+	}
+	plugin := myAuthPlugin
+	if c.caps&clientPluginAuth != 0 {
+		plugin = r.nulString()
+	}
+	if r.err != nil {
+		return false
+	}
+	c.ctx = withClient(c.ctx, "mysql", c.conn.RemoteAddr().String(), user)
+	c.session = c.ctx
+
+	// With auth enabled the password is an API token, which the server
+	// only stores hashed, so the client must send it in the clear. A client
+	// certificate of a known identity stands in for the token.
+	if ctx, err := c.certContext(); err == nil {
+		c.ctx, c.session = ctx, ctx
+	} else if c.s.tokens() != nil {
+		if plugin != myClearPasswordPlugin {
+			p := append([]byte{0xfe}, myClearPasswordPlugin...)
+			p = append(p, 0)
+			c.writePacket(p)
+			if c.w.Flush() != nil {
+				return false
+			}
+			if response, err = c.readPacket(); err != nil {
+				return false
+			}
+		}
+		ctx, err := c.s.authenticate(c.ctx, string(bytes.TrimSuffix(response, []byte{0})))
+		if err != nil {
+			return c.reject(&myError{code: 1045, state: "28000", message: "Access denied"})
+		}
+		c.ctx, c.session = ctx, ctx
+	} else if password := c.s.cfg().MySQLPassword; password != "" {
+		if plugin != myAuthPlugin {
+			p := append([]byte{0xfe}, myAuthPlugin...)
+			p = append(p, 0)
+			p = append(p, scramble...)
+			p = append(p, 0)
+			c.writePacket(p)
+			if c.w.Flush() != nil {
+				return false
+			}
+			if response, err = c.readPacket(); err != nil {
+				return false
+			}
+		}
+		if !checkNativePassword(response, scramble, password) {
+			return c.reject(&myError{code: 1045, state: "28000", message: "Access denied"})
+		}
+	}
+
+	if target != "" {
+		if err := c.use(target); err != nil {
+			return c.reject(err)
+		}
+	}
+
+	c.writeOK(0, 0, false)
+	return c.w.Flush() == nil
+}
+
+// reject fails the handshake with err.
+func (c *myConn) reject(err error) bool {
+	c.s.logger.WarnContext(c.ctx, "MySQL connection rejected", slog.Any("error", err))
+	c.writeError(err)
+	c.w.Flush()
+	return false
+}
+
+// certContext authenticates the session by its client certificate, when
+// auth is enabled and the client presented one.
+func (c *myConn) certContext() (context.Context, error) {
+	tlsConn, ok := c.conn.(*tls.Conn)
+	if c.s.tokens() == nil || !ok {
+		return nil, auth.ErrUnauthenticated
+	}
+	state := tlsConn.ConnectionState()
+	return c.s.authenticateCert(c.ctx, &state)
+}
+
+// checkNativePassword verifies a mysql_native_password response, which is
+// SHA1(password) XOR SHA1(scramble + SHA1(SHA1(password))).
+func checkNativePassword(resp, scramble []byte, password string) bool {
+	stage1 := sha1.Sum([]byte(password))
+	stage2 := sha1.Sum(stage1[:])
+	h := sha1.New()
+	h.Write(scramble)
+	h.Write(stage2[:])
+	want := h.Sum(nil)
+	for i := range want {
+		want[i] ^= stage1[i]
+	}
+	return subtle.ConstantTimeCompare(resp, want) == 1
+}
+
+// use switches the session to target, "db@branch".
+func (c *myConn) use(target string) error {
+	if c.tx != nil {
+		return &myError{code: 1192, state: "25000", message: "cannot switch branches inside a transaction"}
+	}
+
+	dbName, branch := splitTarget(target)
+	if err := c.s.checkBranch(dbName, branch); err != nil {
+		return &myError{code: 1049, state: "42000", message: fmt.Sprintf("Unknown database '%s': %v", target, err)}
+	}
+	ctx, err := c.s.branchContext(c.session, dbName, branch)
+	if err != nil {
+		return &myError{code: 1044, state: "42000", message: err.Error()}
+	}
+	c.ctx, c.dbName, c.branch = ctx, dbName, branch
+	return nil
+}
+
+func (c *myConn) serve() {
+	// closable is unset between a COM_STMT_SEND_LONG_DATA and the execute it
+	// belongs to, when shutdown must not end the session.
+	closable := true
+
+	for {
+		if !c.idle.wait(c.s, closable && c.tx == nil) {
+			c.shutDown()
+			return
+		}
+		pkt, err := c.readPacket()
+		if !c.idle.resume() {
+			c.shutDown()
+			return
+		}
+		if err != nil || len(pkt) == 0 {
+			return
+		}
+		closable = true
+
+		cmd, data := pkt[0], pkt[1:]
+		switch cmd {
+		case comQuit:
+			return
+		case comInitDB:
+			if err = c.use(string(data)); err == nil {
+				c.writeOK(0, 0, false)
+			}
+		case comQuery:
+			err = c.query(string(data))
+		case comPing:
+			c.writeOK(0, 0, false)
+		case comStmtPrepare:
+			err = c.prepare(string(data))
+		case comStmtExecute:
+			err = c.execute(data)
+		case comStmtSendLongData:
+			// No response is sent, even on error.
+			c.sendLongData(data)
+			closable = false
+			continue
+		case comStmtClose:
+			r := &myReader{b: data}
+			delete(c.stmts, r.uint32())
+			continue
+		case comStmtReset:
+			r := &myReader{b: data}
+			if stmt, ok := c.stmts[r.uint32()]; ok {
+				clear(stmt.longData)
+			}
+			c.writeOK(0, 0, false)
+		case comResetConnection:
+			if c.tx != nil {
+				c.tx.Rollback()
+				c.tx = nil
+			}
+			clear(c.stmts)
+			c.writeOK(0, 0, false)
+		default:
+			err = &myError{code: 1047, state: "08S01", message: fmt.Sprintf("Unknown command 0x%02x", cmd)}
+		}
+
+		if err != nil {
+			c.writeError(err)
+		}
+		if c.w.Flush() != nil || c.ctx.Err() != nil {
+			return
+		}
+	}
+}
+
+// shutDown tells the client the server is going away, as MySQL does on
+// shutdown.
+func (c *myConn) shutDown() {
+	c.seq = 0
+	c.writeError(&myError{code: 1053, state: "08S01", message: "Server shutdown in progress"})
+	c.w.Flush()
+}
+
+func (c *myConn) query(q string) error {
+	stmts := splitStatements(q)
+	if len(stmts) == 0 {
+		return &myError{code: 1065, state: "42000", message: "Query was empty"}
+	}
+	if len(stmts) > 1 && c.caps&clientMultiStatements == 0 {
+		return &myError{code: 1064, state: "42000", message: "multiple statements need the CLIENT_MULTI_STATEMENTS capability"}
+	}
+
+	for i, stmt := range stmts {
+		if err := c.run(stmt, nil, false, i < len(stmts)-1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// run executes one statement and writes its result, as text rows or, for
+// prepared statements, binary rows. more marks further results to come.
+func (c *myConn) run(query string, args []interface{}, binaryRows, more bool) error {
+	words := database.Keywords(query, 2)
+	if myControlStatement(words) {
+		if err := c.control(query, words); err != nil {
+			return err
+		}
+		c.writeOK(0, 0, more)
+		return nil
+	}
+	if c.dbName == "" {
+		return errMyNoDatabase
+	}
+
+	ctx, cancel, err := c.queryContext()
+	if err != nil {
+		return err
+	}
+	defer cancel()
+
+	if database.IsSelect(query) {
+		return c.sendResultSet(ctx, query, args, binaryRows, more)
+	}
+
+	var res database.ModifyResult
+	if c.tx != nil {
+		res, err = c.tx.Exec(ctx, query, args)
+	} else {
+		res, err = c.s.dbMgr.Exec(ctx, c.dbName, c.branch, query, args)
+	}
+	if err != nil {
+		return err
+	}
+	c.writeOK(uint64(res.RowsAffected), uint64(res.LastInsertID), more)
+	return nil
+}
+
+// queryContext admits a statement under the session's rate limits and
+// the database's concurrency cap, and bounds it by the database's timeout.
+// The returned cancel also gives back its concurrency slot.
+func (c *myConn) queryContext() (context.Context, context.CancelFunc, error) {
+	if err := c.s.throttle(c.ctx); err != nil {
+		return nil, nil, err
+	}
+	release, err := c.s.acquireDatabase(c.dbName)
+	if err != nil {
+		return nil, nil, err
+	}
+	ctx, cancel := c.s.queryContext(c.ctx, c.s.limits(c.ctx, c.dbName).timeoutFor(0))
+	c.mu.Lock()
+	c.cancelQuery = cancel
+	c.mu.Unlock()
+	return ctx, func() {
+		cancel()
+		release()
+	}, nil
+}
+
+// myControlStatement reports whether a statement starting with words is
+// handled by the session rather than passed to SQLite.
+func myControlStatement(words []string) bool {
+	if len(words) == 0 {
+		return false
+	}
+	switch words[0] {
+	case "BEGIN", "START", "COMMIT", "SET", "USE", "KILL":
+		return true
+	case "ROLLBACK":
+		return len(words) < 2 || words[1] != "TO"
+	}
+	return false
+}
+
+func (c *myConn) control(query string, words []string) error {
+	switch words[0] {
+	case "BEGIN", "START":
+		if c.dbName == "" {
+			return errMyNoDatabase
+		}
+		// Starting a transaction implicitly commits the current one.
+		if c.tx != nil {
+			err := c.tx.Commit()
+			c.tx = nil
+			if err != nil {
+				return err
+			}
+		}
+		tx, err := c.s.dbMgr.BeginTx(c.ctx, c.dbName, c.branch)
+		if err != nil {
+			return err
+		}
+		c.tx = tx
+	case "COMMIT":
+		if c.tx != nil {
+			err := c.tx.Commit()
+			c.tx = nil
+			return err
+		}
+	case "ROLLBACK":
+		if c.tx != nil {
+			err := c.tx.Rollback()
+			c.tx = nil
+			return err
+		}
+	case "USE":
+		target := strings.TrimSpace(query)[len("USE"):]
+		return c.use(strings.Trim(target, " \t\r\n;`'\""))
+	case "KILL":
+		fields := strings.Fields(strings.TrimSuffix(query, ";"))
+		queryOnly := len(fields) == 3 && strings.EqualFold(fields[1], "QUERY")
+		id, err := strconv.ParseUint(fields[len(fields)-1], 10, 32)
+		if err != nil {
+			return &myError{code: 1064, state: "42000", message: "KILL needs a connection ID"}
+		}
+		if !c.s.killMySQL(uint32(id), queryOnly) {
+			return &myError{code: 1094, state: "HY000", message: fmt.Sprintf("Unknown thread id: %d", id)}
+		}
+	}
+	// SET is accepted and ignored; sessions always use UTF-8 and autocommit
+	// outside explicit transactions.
+	return nil
+}
+
+func (c *myConn) sendResultSet(ctx context.Context, query string, args []interface{}, binaryRows, more bool) error {
+	var cursor *database.Cursor
+	var err error
+	if c.tx != nil {
+		cursor, err = c.tx.OpenCursor(ctx, query, args, database.FormatTyped)
+	} else {
+		cursor, err = c.s.dbMgr.OpenCursor(ctx, c.dbName, c.branch, query, args, database.FormatTyped)
+	}
+	if err != nil {
+		return err
+	}
+	defer cursor.Close()
+	c.s.limits(c.ctx, c.dbName).limitCursor(cursor)
+
+	// Columns without a declared type are typed from the first row, which
+	// is held back until the rows are sent.
+	var first []interface{}
+	types := make([]byte, len(cursor.Columns()))
+	for i, decl := range cursor.ColumnTypes() {
+		types[i] = myColumnType(decl)
+		if decl != "" {
+			continue
+		}
+		if first == nil {
+			if !cursor.Next() {
+				if err := cursor.Err(); err != nil {
+					return err
+				}
+				break
+			}
+			if first, err = cursor.Values(); err != nil {
+				return err
+			}
+		}
+		types[i] = myValueType(first[i])
+	}
+
+	c.writePacket(appendLenEncInt(nil, uint64(len(types))))
+	for i, name := range cursor.Columns() {
+		c.writePacket(columnDefinition(c.dbName, name, types[i]))
+	}
+	c.writeEOF(false)
+
+	for rows := 1; ; rows++ {
+		values := first
+		first = nil
+		if values == nil {
+			if !cursor.Next() {
+				break
+			}
+			if values, err = cursor.Values(); err != nil {
+				return err
+			}
+		}
+
+		var row []byte
+		if binaryRows {
+			row, err = binaryRow(values, types)
+			if err != nil {
+				return &myError{code: 1105, state: "HY000", message: err.Error()}
+			}
+		} else {
+			row = textRow(values)
+		}
+		c.writePacket(row)
+
+		if rows%wireFlushRows == 0 {
+			if err := c.w.Flush(); err != nil {
+				return err
+			}
+		}
+	}
+	if err := cursor.Err(); err != nil {
+		return err
+	}
+
+	c.writeEOF(more)
+	return nil
+}
+
+func (c *myConn) prepare(query string) error {
+	query = strings.TrimSpace(query)
+	if len(splitStatements(query)) > 1 {
+		return &myError{code: 1064, state: "42000", message: "cannot prepare multiple statements"}
+	}
+
+	stmt := &myStatement{query: query, desc: &database.Statement{}, longData: make(map[int][]byte)}
+	if !myControlStatement(database.Keywords(query, 2)) {
+		if c.dbName == "" {
+			return errMyNoDatabase
+		}
+		var err error
+		if c.tx != nil {
+			stmt.desc, err = c.tx.Describe(c.ctx, query)
+		} else {
+			stmt.desc, err = c.s.dbMgr.Describe(c.ctx, c.dbName, c.branch, query)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	c.lastStmt++
+	id := c.lastStmt
+	c.stmts[id] = stmt
+
+	p := []byte{0x00}
+	p = binary.LittleEndian.AppendUint32(p, id)
+	p = binary.LittleEndian.AppendUint16(p, uint16(len(stmt.desc.Columns)))
+	p = binary.LittleEndian.AppendUint16(p, uint16(stmt.desc.NumParams))
+	p = append(p, 0, 0, 0)
+	c.writePacket(p)
+
+	if stmt.desc.NumParams > 0 {
+		for range stmt.desc.NumParams {
+			c.writePacket(columnDefinition("", "?", myTypeVarString))
+		}
+		c.writeEOF(false)
+	}
+	if len(stmt.desc.Columns) > 0 {
+		for i, name := range stmt.desc.Columns {
+			c.writePacket(columnDefinition(c.dbName, name, myColumnType(stmt.desc.ColumnTypes[i])))
+		}
+		c.writeEOF(false)
+	}
+	return nil
+}
+
+func (c *myConn) execute(data []byte) error {
+	r := &myReader{b: data}
+	id := r.uint32()
+	r.bytes(1 + 4) // cursor flags, iteration count
+
+	stmt, ok := c.stmts[id]
+	if !ok {
+		return &myError{code: 1243, state: "HY000", message: fmt.Sprintf("Unknown prepared statement handler (%d)", id)}
+	}
+	defer clear(stmt.longData)
+
+	args, err := stmt.bind(r)
+	if err != nil {
+		return err
+	}
+	return c.run(stmt.query, args, true, false)
+}
+
+func (s *myStatement) bind(r *myReader) ([]interface{}, error) {
+	n := s.desc.NumParams
+	if n == 0 {
+		return nil, nil
+	}
+
+	nulls := r.bytes((n + 7) / 8)
+	if r.uint8() == 1 {
+		s.paramTypes = make([]uint16, n)
+		for i := range s.paramTypes {
+			s.paramTypes[i] = r.uint16()
+		}
+	}
+	if r.err != nil || s.paramTypes == nil {
+		return nil, errMyMalformed
+	}
+
+	args := make([]interface{}, n)
+	for i := range args {
+		if nulls[i/8]&(1<<(i%8)) != 0 {
+			continue
+		}
+		if data, ok := s.longData[i]; ok {
+			args[i] = longDataParam(data, s.paramTypes[i])
+			continue
+		}
+		arg, err := readMyParam(r, s.paramTypes[i])
+		if err != nil {
+			return nil, &myError{code: 1210, state: "HY000", message: fmt.Sprintf("parameter %d: %v", i+1, err)}
+		}
+		args[i] = arg
+	}
+	if r.err != nil {
+		return nil, errMyMalformed
+	}
+	return args, nil
+}
+
+func (c *myConn) sendLongData(data []byte) {
+	r := &myReader{b: data}
+	id := r.uint32()
+	param := int(r.uint16())
+	if r.err != nil {
+		return
+	}
+	if stmt, ok := c.stmts[id]; ok && param < stmt.desc.NumParams {
+		stmt.longData[param] = append(stmt.longData[param], r.b...)
+	}
+}
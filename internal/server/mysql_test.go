@@ -0,0 +1,232 @@
+package server
+
+import (
+	"bytes"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+
+	"github.com/bxrne/branchlore/internal/auth"
+)
+
+// serveMySQLTest serves the MySQL protocol of s on a free local port and
+// returns its address.
+func serveMySQLTest(t *testing.T, s *Server) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.wg.Add(1)
+	go s.serveMySQL(ln)
+	t.Cleanup(func() {
+		ln.Close()
+		s.cancel()
+		s.wg.Wait()
+	})
+	return ln.Addr().String()
+}
+
+func openMySQL(t *testing.T, dsn string) *sql.DB {
+	t.Helper()
+	db, err := sql.Open("mysql", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestMySQLNativePassword(t *testing.T) {
+	s := newTestServer(t, &Config{MySQLPassword: "secret"})
+	newTestDatabase(t, s, "db")
+	addr := serveMySQLTest(t, s)
+
+	db := openMySQL(t, fmt.Sprintf("app:secret@tcp(%s)/db@main", addr))
+	if err := db.Ping(); err != nil {
+		t.Fatalf("ping with the right password: %v", err)
+	}
+
+	wrong := openMySQL(t, fmt.Sprintf("app:wrong@tcp(%s)/db@main", addr))
+	var me *mysql.MySQLError
+	if err := wrong.Ping(); !errors.As(err, &me) || me.Number != 1045 {
+		t.Fatalf("ping with the wrong password: %v, want error 1045", err)
+	}
+}
+
+func TestMySQLClearPasswordToken(t *testing.T) {
+	s := newTestServer(t, &Config{Auth: true})
+	newTestDatabase(t, s, "db")
+	secret := newTestToken(t, s, "app", auth.ScopeWrite)
+	addr := serveMySQLTest(t, s)
+
+	db := openMySQL(t, fmt.Sprintf("app:%s@tcp(%s)/db@main?allowCleartextPasswords=true", secret, addr))
+	if err := db.Ping(); err != nil {
+		t.Fatalf("ping with a token: %v", err)
+	}
+
+	wrong := openMySQL(t, fmt.Sprintf("app:blt_wrong@tcp(%s)/db@main?allowCleartextPasswords=true", addr))
+	var me *mysql.MySQLError
+	if err := wrong.Ping(); !errors.As(err, &me) || me.Number != 1045 {
+		t.Fatalf("ping with a wrong token: %v, want error 1045", err)
+	}
+}
+
+func TestMySQLResultSets(t *testing.T) {
+	s := newTestServer(t, nil)
+	newTestDatabase(t, s, "db")
+	db := openMySQL(t, fmt.Sprintf("app@tcp(%s)/db@main", serveMySQLTest(t, s)))
+
+	if _, err := db.Exec("CREATE TABLE t (id INTEGER PRIMARY KEY, name TEXT, score REAL, data BLOB)"); err != nil {
+		t.Fatal(err)
+	}
+	// With arguments the driver prepares the statement and reads binary
+	// rows; without, it reads text rows.
+	res, err := db.Exec("INSERT INTO t (name, score, data) VALUES (?, ?, CAST(? AS BLOB))", "ada", 1.5, []byte{0, 1, 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id, err := res.LastInsertId(); err != nil || id != 1 {
+		t.Errorf("LastInsertId = %d, %v, want 1", id, err)
+	}
+
+	for _, tt := range []struct {
+		name  string
+		query string
+		args  []interface{}
+	}{
+		{"text", "SELECT id, name, score, data FROM t WHERE id = 1", nil},
+		{"binary", "SELECT id, name, score, data FROM t WHERE id = ?", []interface{}{1}},
+		{"leading comment", "-- the row\nWITH r AS (SELECT id, name, score, data FROM t) SELECT * FROM r", nil},
+	} {
+		var (
+			id    int64
+			name  string
+			score float64
+			data  []byte
+		)
+		err := db.QueryRow(tt.query, tt.args...).Scan(&id, &name, &score, &data)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if id != 1 || name != "ada" || score != 1.5 || !bytes.Equal(data, []byte{0, 1, 2}) {
+			t.Errorf("%s: got %d %q %g %v", tt.name, id, name, score, data)
+		}
+	}
+
+	var me *mysql.MySQLError
+	if _, err := db.Exec("INSERT INTO t (id) VALUES (1)"); !errors.As(err, &me) || me.Number != 1062 {
+		t.Errorf("duplicate key: %v, want error 1062", err)
+	}
+}
+
+func TestMySQLTransactions(t *testing.T) {
+	s := newTestServer(t, nil)
+	newTestDatabase(t, s, "db")
+	db := openMySQL(t, fmt.Sprintf("app@tcp(%s)/db@main", serveMySQLTest(t, s)))
+
+	if _, err := db.Exec("CREATE TABLE t (a INTEGER)"); err != nil {
+		t.Fatal(err)
+	}
+	count := func() int {
+		t.Helper()
+		var n int
+		if err := db.QueryRow("SELECT COUNT(*) FROM t").Scan(&n); err != nil {
+			t.Fatal(err)
+		}
+		return n
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tx.Exec("INSERT INTO t VALUES (?)", 1); err != nil {
+		t.Fatal(err)
+	}
+	var inside int
+	if err := tx.QueryRow("SELECT COUNT(*) FROM t").Scan(&inside); err != nil || inside != 1 {
+		t.Fatalf("count inside the transaction = %d, %v, want 1", inside, err)
+	}
+	if err := tx.Rollback(); err != nil {
+		t.Fatal(err)
+	}
+	if n := count(); n != 0 {
+		t.Fatalf("%d rows after rollback, want 0", n)
+	}
+
+	tx, err = db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tx.Exec("INSERT INTO t VALUES (1), (2)"); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if n := count(); n != 2 {
+		t.Fatalf("%d rows after commit, want 2", n)
+	}
+}
+
+func TestMySQLPacketLimit(t *testing.T) {
+	s := newTestServer(t, &Config{MySQLMaxPacket: 1 << 20})
+	newTestDatabase(t, s, "db")
+	addr := serveMySQLTest(t, s)
+	// The driver logs the connection the server closes.
+	mysql.SetLogger(log.New(io.Discard, "", 0))
+
+	// Before authenticating: a header announcing more than the limit is
+	// refused without the payload being read.
+	conn, err := net.DialTimeout("tcp", addr, 5*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	if _, err := readTestPacket(conn); err != nil {
+		t.Fatalf("reading the greeting: %v", err)
+	}
+	conn.Write([]byte{0xff, 0xff, 0xff, 1})
+	resp, err := readTestPacket(conn)
+	if err != nil {
+		t.Fatalf("reading the response: %v", err)
+	}
+	if len(resp) < 3 || resp[0] != 0xff || int(resp[1])|int(resp[2])<<8 != 1153 {
+		t.Fatalf("response %x, want error 1153", resp)
+	}
+	if _, err := readTestPacket(conn); err != io.EOF {
+		t.Fatalf("connection still open after an oversized packet: %v", err)
+	}
+
+	// After authenticating, a statement over the limit fails and the
+	// server keeps serving.
+	db := openMySQL(t, fmt.Sprintf("app@tcp(%s)/db@main", addr))
+	if _, err := db.Exec("SELECT '" + strings.Repeat("x", 2<<20) + "'"); err == nil {
+		t.Fatal("oversized statement succeeded")
+	}
+	if _, err := db.Exec("SELECT 1"); err != nil {
+		t.Fatalf("statement after an oversized one: %v", err)
+	}
+}
+
+// readTestPacket reads one MySQL packet from conn.
+func readTestPacket(conn net.Conn) ([]byte, error) {
+	var header [4]byte
+	if _, err := io.ReadFull(conn, header[:]); err != nil {
+		return nil, err
+	}
+	payload := make([]byte, int(header[0])|int(header[1])<<8|int(header[2])<<16)
+	_, err := io.ReadFull(conn, payload)
+	return payload, err
+}
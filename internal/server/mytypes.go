@@ -0,0 +1,402 @@
+package server
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	sqlite3 "github.com/mattn/go-sqlite3"
+)
+
+const (
+	myTypeDecimal    = 0x00
+	myTypeTiny       = 0x01
+	myTypeShort      = 0x02
+	myTypeLong       = 0x03
+	myTypeFloat      = 0x04
+	myTypeDouble     = 0x05
+	myTypeNull       = 0x06
+	myTypeTimestamp  = 0x07
+	myTypeLongLong   = 0x08
+	myTypeInt24      = 0x09
+	myTypeDate       = 0x0a
+	myTypeTime       = 0x0b
+	myTypeDatetime   = 0x0c
+	myTypeYear       = 0x0d
+	myTypeVarchar    = 0x0f
+	myTypeJSON       = 0xf5
+	myTypeNewDecimal = 0xf6
+	myTypeEnum       = 0xf7
+	myTypeSet        = 0xf8
+	myTypeTinyBlob   = 0xf9
+	myTypeMediumBlob = 0xfa
+	myTypeLongBlob   = 0xfb
+	myTypeBlob       = 0xfc
+	myTypeVarString  = 0xfd
+	myTypeString     = 0xfe
+)
+
+const (
+	myCharsetUTF8   = 45 // utf8mb4_general_ci
+	myCharsetBinary = 63
+
+	myFlagBlob   = 0x0010
+	myFlagBinary = 0x0080
+	myFlagNum    = 0x8000
+
+	myUnsigned = 0x8000
+)
+
+// myColumnType picks a MySQL type for a declared SQLite column type,
+// following SQLite's affinity rules like pgTypeOIDs.
+func myColumnType(decl string) byte {
+	d := strings.ToUpper(decl)
+	switch {
+	case strings.Contains(d, "INT"):
+		return myTypeLongLong
+	case strings.Contains(d, "BOOL"):
+		return myTypeTiny
+	case strings.Contains(d, "CHAR"), strings.Contains(d, "CLOB"), strings.Contains(d, "TEXT"):
+		return myTypeVarString
+	case strings.Contains(d, "BLOB"):
+		return myTypeBlob
+	case strings.Contains(d, "REAL"), strings.Contains(d, "FLOA"), strings.Contains(d, "DOUB"):
+		return myTypeDouble
+	}
+	return myTypeVarString
+}
+
+// myValueType picks a MySQL type for a column without a declared type from
+// one of its values.
+func myValueType(v interface{}) byte {
+	switch v.(type) {
+	case int64:
+		return myTypeLongLong
+	case float64:
+		return myTypeDouble
+	case bool:
+		return myTypeTiny
+	case []byte:
+		return myTypeBlob
+	}
+	return myTypeVarString
+}
+
+func columnDefinition(schema, name string, typ byte) []byte {
+	charset, length, flags, decimals := uint16(myCharsetBinary), uint32(0), uint16(myFlagBinary|myFlagNum), byte(0)
+	switch typ {
+	case myTypeLongLong:
+		length = 20
+	case myTypeTiny:
+		length = 1
+	case myTypeDouble:
+		length, decimals = 22, 31
+	case myTypeBlob:
+		length, flags = math.MaxUint32, myFlagBlob|myFlagBinary
+	default:
+		charset, length, flags = myCharsetUTF8, math.MaxUint32, 0
+	}
+
+	p := appendLenEncBytes(nil, []byte("def"))
+	p = appendLenEncBytes(p, []byte(schema))
+	p = appendLenEncBytes(p, nil) // table
+	p = appendLenEncBytes(p, nil) // original table
+	p = appendLenEncBytes(p, []byte(name))
+	p = appendLenEncBytes(p, []byte(name))
+	p = append(p, 0x0c)
+	p = binary.LittleEndian.AppendUint16(p, charset)
+	p = binary.LittleEndian.AppendUint32(p, length)
+	p = append(p, typ)
+	p = binary.LittleEndian.AppendUint16(p, flags)
+	p = append(p, decimals, 0, 0)
+	return p
+}
+
+func textRow(values []interface{}) []byte {
+	var p []byte
+	for _, v := range values {
+		if v == nil {
+			p = append(p, 0xfb)
+			continue
+		}
+		p = appendLenEncBytes(p, myText(v))
+	}
+	return p
+}
+
+// binaryRow encodes a row for a prepared statement's result. SQLite does not
+// enforce column types, so it fails for values that do not fit the column's
+// type.
+func binaryRow(values []interface{}, types []byte) ([]byte, error) {
+	// The NULL bitmap is offset by two bits.
+	nulls := make([]byte, (len(values)+7+2)/8)
+	p := append([]byte{0x00}, nulls...)
+	for i, v := range values {
+		if v == nil {
+			p[1+(i+2)/8] |= 1 << ((i + 2) % 8)
+			continue
+		}
+
+		switch types[i] {
+		case myTypeLongLong, myTypeTiny:
+			n, err := myInt(v)
+			if err != nil {
+				return nil, err
+			}
+			if types[i] == myTypeTiny {
+				if n < math.MinInt8 || n > math.MaxInt8 {
+					return nil, fmt.Errorf("%d does not fit a TINYINT", n)
+				}
+				p = append(p, byte(int8(n)))
+				continue
+			}
+			p = binary.LittleEndian.AppendUint64(p, uint64(n))
+		case myTypeDouble:
+			var f float64
+			switch v := v.(type) {
+			case float64:
+				f = v
+			case int64:
+				f = float64(v)
+			default:
+				return nil, fmt.Errorf("%T value is not a number", v)
+			}
+			p = binary.LittleEndian.AppendUint64(p, math.Float64bits(f))
+		default:
+			p = appendLenEncBytes(p, myText(v))
+		}
+	}
+	return p, nil
+}
+
+func myInt(v interface{}) (int64, error) {
+	switch v := v.(type) {
+	case int64:
+		return v, nil
+	case bool:
+		if v {
+			return 1, nil
+		}
+		return 0, nil
+	case float64:
+		if v != math.Trunc(v) || v < math.MinInt64 || v >= math.MaxInt64 {
+			return 0, fmt.Errorf("%v is not an integer", v)
+		}
+		return int64(v), nil
+	}
+	return 0, fmt.Errorf("%T value is not an integer", v)
+}
+
+func myText(v interface{}) []byte {
+	switch v := v.(type) {
+	case int64:
+		return strconv.AppendInt(nil, v, 10)
+	case float64:
+		return strconv.AppendFloat(nil, v, 'g', -1, 64)
+	case bool:
+		if v {
+			return []byte("1")
+		}
+		return []byte("0")
+	case string:
+		return []byte(v)
+	case []byte:
+		return v
+	case time.Time:
+		return []byte(v.Format(sqlite3.SQLiteTimestampFormats[0]))
+	}
+	return []byte(fmt.Sprint(v))
+}
+
+// readMyParam decodes one parameter of COM_STMT_EXECUTE into a value SQLite
+// can bind. String types bind as text and blob types as BLOBs.
+func readMyParam(r *myReader, typ uint16) (interface{}, error) {
+	unsigned := typ&myUnsigned != 0
+	switch byte(typ) {
+	case myTypeNull:
+		return nil, nil
+	case myTypeTiny:
+		if unsigned {
+			return int64(r.uint8()), nil
+		}
+		return int64(int8(r.uint8())), nil
+	case myTypeShort, myTypeYear:
+		if unsigned {
+			return int64(r.uint16()), nil
+		}
+		return int64(int16(r.uint16())), nil
+	case myTypeLong, myTypeInt24:
+		if unsigned {
+			return int64(r.uint32()), nil
+		}
+		return int64(int32(r.uint32())), nil
+	case myTypeLongLong:
+		n := r.uint64()
+		if unsigned && n > math.MaxInt64 {
+			return nil, fmt.Errorf("%d overflows a SQLite integer", n)
+		}
+		return int64(n), nil
+	case myTypeFloat:
+		return float64(math.Float32frombits(r.uint32())), nil
+	case myTypeDouble:
+		return math.Float64frombits(r.uint64()), nil
+	case myTypeDate, myTypeDatetime, myTypeTimestamp:
+		return readMyDatetime(r), nil
+	case myTypeTime:
+		return readMyTime(r), nil
+	case myTypeTinyBlob, myTypeMediumBlob, myTypeLongBlob, myTypeBlob:
+		return bytes.Clone(r.lenEncBytes()), nil
+	case myTypeDecimal, myTypeNewDecimal, myTypeVarchar, myTypeVarString, myTypeString,
+		myTypeEnum, myTypeSet, myTypeJSON:
+		return string(r.lenEncBytes()), nil
+	}
+	return nil, fmt.Errorf("unsupported parameter type 0x%02x", byte(typ))
+}
+
+func longDataParam(data []byte, typ uint16) interface{} {
+	switch byte(typ) {
+	case myTypeTinyBlob, myTypeMediumBlob, myTypeLongBlob, myTypeBlob:
+		return bytes.Clone(data)
+	}
+	return string(data)
+}
+
+func readMyDatetime(r *myReader) time.Time {
+	var year, month, day, hour, minute, second, micro int
+	n := r.uint8()
+	if n >= 4 {
+		year, month, day = int(r.uint16()), int(r.uint8()), int(r.uint8())
+	}
+	if n >= 7 {
+		hour, minute, second = int(r.uint8()), int(r.uint8()), int(r.uint8())
+	}
+	if n >= 11 {
+		micro = int(r.uint32())
+	}
+	return time.Date(year, time.Month(month), day, hour, minute, second, micro*1000, time.UTC)
+}
+
+// readMyTime decodes a TIME parameter as text, since it is a duration
+// rather than a time of day.
+func readMyTime(r *myReader) string {
+	n := r.uint8()
+	if n < 8 {
+		return "00:00:00"
+	}
+	negative := r.uint8() == 1
+	hours := int(r.uint32())*24 + int(r.uint8())
+	minute, second := r.uint8(), r.uint8()
+
+	s := fmt.Sprintf("%02d:%02d:%02d", hours, minute, second)
+	if n >= 12 {
+		s += fmt.Sprintf(".%06d", r.uint32())
+	}
+	if negative {
+		s = "-" + s
+	}
+	return s
+}
+
+func appendLenEncInt(p []byte, n uint64) []byte {
+	switch {
+	case n < 251:
+		return append(p, byte(n))
+	case n < 1<<16:
+		return append(p, 0xfc, byte(n), byte(n>>8))
+	case n < 1<<24:
+		return append(p, 0xfd, byte(n), byte(n>>8), byte(n>>16))
+	}
+	return binary.LittleEndian.AppendUint64(append(p, 0xfe), n)
+}
+
+func appendLenEncBytes(p, b []byte) []byte {
+	return append(appendLenEncInt(p, uint64(len(b))), b...)
+}
+
+// myReader decodes a MySQL packet payload. A short read sets err and yields
+// zero values, so callers check err once when done.
+type myReader struct {
+	b   []byte
+	err error
+}
+
+func (r *myReader) bytes(n int) []byte {
+	if r.err != nil || n < 0 || n > len(r.b) {
+		r.err, r.b = errMyMalformed, nil
+		return nil
+	}
+	b := r.b[:n]
+	r.b = r.b[n:]
+	return b
+}
+
+func (r *myReader) uint8() uint8 {
+	if b := r.bytes(1); b != nil {
+		return b[0]
+	}
+	return 0
+}
+
+func (r *myReader) uint16() uint16 {
+	if b := r.bytes(2); b != nil {
+		return binary.LittleEndian.Uint16(b)
+	}
+	return 0
+}
+
+func (r *myReader) uint32() uint32 {
+	if b := r.bytes(4); b != nil {
+		return binary.LittleEndian.Uint32(b)
+	}
+	return 0
+}
+
+func (r *myReader) uint64() uint64 {
+	if b := r.bytes(8); b != nil {
+		return binary.LittleEndian.Uint64(b)
+	}
+	return 0
+}
+
+func (r *myReader) lenEncInt() uint64 {
+	switch b := r.uint8(); b {
+	case 0xfc:
+		return uint64(r.uint16())
+	case 0xfd:
+		if b := r.bytes(3); b != nil {
+			return uint64(b[0]) | uint64(b[1])<<8 | uint64(b[2])<<16
+		}
+		return 0
+	case 0xfe:
+		return r.uint64()
+	default:
+		return uint64(b)
+	}
+}
+
+func (r *myReader) lenEncBytes() []byte {
+	n := r.lenEncInt()
+	if n > uint64(len(r.b)) {
+		r.err, r.b = errMyMalformed, nil
+		return nil
+	}
+	return r.bytes(int(n))
+}
+
+// nulString reads a NUL terminated string, or the rest of the payload if it
+// has no terminator.
+func (r *myReader) nulString() string {
+	i := bytes.IndexByte(r.b, 0)
+	if i < 0 {
+		s := string(r.b)
+		r.b = nil
+		return s
+	}
+	s := string(r.b[:i])
+	r.b = r.b[i+1:]
+	return s
+}
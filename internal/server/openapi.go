@@ -0,0 +1,213 @@
+package server
+
+import (
+	"net/http"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+var pathParamPattern = regexp.MustCompile(`\{([^}]+)\}`)
+
+func (s *Server) handleOpenAPI(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, openAPIDocument(s.routes()))
+}
+
+// openAPIDocument renders routes as an OpenAPI 3 document. Schemas are
+// reflected from the Go types the handlers encode and decode.
+func openAPIDocument(routes []route) map[string]interface{} {
+	schemas := make(map[string]interface{})
+	paths := make(map[string]map[string]interface{})
+
+	errorSchema := schemaFor(reflect.TypeOf(errorResponse{}), schemas)
+
+	for _, rt := range routes {
+		op := map[string]interface{}{
+			"operationId": rt.OperationID,
+			"summary":     rt.Summary,
+		}
+		if rt.Deprecated {
+			op["deprecated"] = true
+		}
+		if !rt.Public {
+			// Only enforced when the server runs with --auth.
+			op["security"] = []interface{}{map[string]interface{}{"bearerAuth": []string{}}}
+		}
+
+		var params []interface{}
+		for _, m := range pathParamPattern.FindAllStringSubmatch(rt.Path, -1) {
+			params = append(params, map[string]interface{}{
+				"name":     m[1],
+				"in":       "path",
+				"required": true,
+				"schema":   map[string]interface{}{"type": "string"},
+			})
+		}
+		for _, p := range rt.Params {
+			params = append(params, map[string]interface{}{
+				"name":        p.Name,
+				"in":          "query",
+				"description": p.Description,
+				"required":    p.Required,
+				"schema":      map[string]interface{}{"type": "string"},
+			})
+		}
+		if params != nil {
+			op["parameters"] = params
+		}
+
+		switch {
+		case rt.Body != nil:
+			op["requestBody"] = requestBody("application/json", schemaFor(reflect.TypeOf(rt.Body), schemas))
+		case rt.FormBody != nil:
+			op["requestBody"] = requestBody("application/x-www-form-urlencoded", schemaFor(reflect.TypeOf(rt.FormBody), schemas))
+		}
+
+		responses := map[string]interface{}{
+			"default": map[string]interface{}{
+				"description": "Error",
+				"content": map[string]interface{}{
+					"application/json": map[string]interface{}{"schema": errorSchema},
+				},
+			},
+		}
+		for status, bodies := range rt.Responses {
+			resp := map[string]interface{}{"description": http.StatusText(status)}
+			if len(bodies) > 0 {
+				contentType := rt.ContentType
+				if contentType == "" {
+					contentType = "application/json"
+				}
+				content := map[string]interface{}{
+					contentType: map[string]interface{}{"schema": oneOf(bodies, schemas)},
+				}
+				if rt.NDJSON {
+					content["application/x-ndjson"] = map[string]interface{}{
+						"schema": map[string]interface{}{
+							"type":        "string",
+							"description": "A {\"columns\"} header, one JSON array per row and a {\"row_count\"} or {\"error\"} trailer, one per line",
+						},
+					}
+				}
+				resp["content"] = content
+			}
+			responses[strconv.Itoa(status)] = resp
+		}
+		op["responses"] = responses
+
+		if paths[rt.Path] == nil {
+			paths[rt.Path] = make(map[string]interface{})
+		}
+		paths[rt.Path][strings.ToLower(rt.Method)] = op
+	}
+
+	return map[string]interface{}{
+		"openapi": "3.0.3",
+		"info": map[string]interface{}{
+			"title":   "Branchlore API",
+			"version": "1.0.0",
+		},
+		"paths": paths,
+		"components": map[string]interface{}{
+			"schemas": schemas,
+			"securitySchemes": map[string]interface{}{
+				"bearerAuth": map[string]interface{}{"type": "http", "scheme": "bearer"},
+			},
+		},
+	}
+}
+
+func requestBody(contentType string, schema interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"required": true,
+		"content": map[string]interface{}{
+			contentType: map[string]interface{}{"schema": schema},
+		},
+	}
+}
+
+func oneOf(bodies []interface{}, schemas map[string]interface{}) interface{} {
+	if len(bodies) == 1 {
+		return schemaFor(reflect.TypeOf(bodies[0]), schemas)
+	}
+
+	var alternatives []interface{}
+	for _, b := range bodies {
+		alternatives = append(alternatives, schemaFor(reflect.TypeOf(b), schemas))
+	}
+	return map[string]interface{}{"oneOf": alternatives}
+}
+
+// schemaFor returns the JSON schema of t. Named structs are added to schemas
+// and referenced by name.
+func schemaFor(t reflect.Type, schemas map[string]interface{}) map[string]interface{} {
+	if t == reflect.TypeFor[time.Time]() {
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	}
+	switch t.Kind() {
+	case reflect.Pointer:
+		return schemaFor(t.Elem(), schemas)
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int32, reflect.Uint, reflect.Uint32:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Int64, reflect.Uint64:
+		return map[string]interface{}{"type": "integer", "format": "int64"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{"type": "array", "items": schemaFor(t.Elem(), schemas)}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": schemaFor(t.Elem(), schemas)}
+	case reflect.Struct:
+		name := schemaName(t)
+		if _, ok := schemas[name]; !ok {
+			schemas[name] = nil // reserve the name before recursing
+			schemas[name] = structSchema(t, schemas)
+		}
+		return map[string]interface{}{"$ref": "#/components/schemas/" + name}
+	}
+	return map[string]interface{}{}
+}
+
+func structSchema(t reflect.Type, schemas map[string]interface{}) map[string]interface{} {
+	properties := make(map[string]interface{})
+	var required []string
+
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" || !f.IsExported() {
+			continue
+		}
+
+		name, opts, _ := strings.Cut(tag, ",")
+		if name == "" {
+			name = f.Name
+		}
+		properties[name] = schemaFor(f.Type, schemas)
+		if !strings.Contains(opts, "omitempty") {
+			required = append(required, name)
+		}
+	}
+
+	schema := map[string]interface{}{
+		"type":       "object",
+		"properties": properties,
+	}
+	if required != nil {
+		schema["required"] = required
+	}
+	return schema
+}
+
+func schemaName(t reflect.Type) string {
+	name := []rune(t.Name())
+	name[0] = unicode.ToUpper(name[0])
+	return string(name)
+}
@@ -0,0 +1,247 @@
+package server
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgproto3"
+	"github.com/jackc/pgx/v5/pgtype"
+	sqlite3 "github.com/mattn/go-sqlite3"
+)
+
+// pgTypeOIDs picks a PostgreSQL type for each declared SQLite column type,
+// following SQLite's affinity rules. Expressions without a declared type
+// and types with NUMERIC affinity are sent as text.
+func pgTypeOIDs(declTypes []string) []uint32 {
+	oids := make([]uint32, len(declTypes))
+	for i, decl := range declTypes {
+		d := strings.ToUpper(decl)
+		switch {
+		case strings.Contains(d, "INT"):
+			oids[i] = pgtype.Int8OID
+		case strings.Contains(d, "BOOL"):
+			oids[i] = pgtype.BoolOID
+		case strings.Contains(d, "CHAR"), strings.Contains(d, "CLOB"), strings.Contains(d, "TEXT"):
+			oids[i] = pgtype.TextOID
+		case strings.Contains(d, "BLOB"):
+			oids[i] = pgtype.ByteaOID
+		case strings.Contains(d, "REAL"), strings.Contains(d, "FLOA"), strings.Contains(d, "DOUB"):
+			oids[i] = pgtype.Float8OID
+		default:
+			oids[i] = pgtype.TextOID
+		}
+	}
+	return oids
+}
+
+// valueOID picks a PostgreSQL type for a column without a declared type
+// from one of its values.
+func valueOID(v interface{}) uint32 {
+	switch v.(type) {
+	case int64:
+		return pgtype.Int8OID
+	case float64:
+		return pgtype.Float8OID
+	case bool:
+		return pgtype.BoolOID
+	case []byte:
+		return pgtype.ByteaOID
+	}
+	return pgtype.TextOID
+}
+
+func rowDescription(columns []string, oids []uint32, formats []int16) *pgproto3.RowDescription {
+	fields := make([]pgproto3.FieldDescription, len(columns))
+	for i, name := range columns {
+		size := int16(-1)
+		switch oids[i] {
+		case pgtype.Int8OID, pgtype.Float8OID:
+			size = 8
+		case pgtype.BoolOID:
+			size = 1
+		}
+		fields[i] = pgproto3.FieldDescription{
+			Name:         []byte(name),
+			DataTypeOID:  oids[i],
+			DataTypeSize: size,
+			TypeModifier: -1,
+			Format:       formatCode(formats, i),
+		}
+	}
+	return &pgproto3.RowDescription{Fields: fields}
+}
+
+// encodePgValue encodes a value scanned from SQLite as the column's
+// PostgreSQL type. SQLite does not enforce column types, so binary encoding
+// fails for values that do not fit the declared type.
+func encodePgValue(v interface{}, oid uint32, format int16) ([]byte, error) {
+	if v == nil {
+		return nil, nil
+	}
+	if format == pgproto3.TextFormat {
+		return pgText(v, oid), nil
+	}
+
+	switch oid {
+	case pgtype.Int8OID:
+		var n int64
+		switch v := v.(type) {
+		case int64:
+			n = v
+		case bool:
+			if v {
+				n = 1
+			}
+		case float64:
+			if v != math.Trunc(v) || v < math.MinInt64 || v >= math.MaxInt64 {
+				return nil, fmt.Errorf("%v is not an integer", v)
+			}
+			n = int64(v)
+		default:
+			return nil, fmt.Errorf("%T value is not an integer", v)
+		}
+		return binary.BigEndian.AppendUint64(nil, uint64(n)), nil
+	case pgtype.Float8OID:
+		var f float64
+		switch v := v.(type) {
+		case float64:
+			f = v
+		case int64:
+			f = float64(v)
+		default:
+			return nil, fmt.Errorf("%T value is not a number", v)
+		}
+		return binary.BigEndian.AppendUint64(nil, math.Float64bits(f)), nil
+	case pgtype.BoolOID:
+		switch v := v.(type) {
+		case bool:
+			if v {
+				return []byte{1}, nil
+			}
+			return []byte{0}, nil
+		case int64:
+			if v != 0 {
+				return []byte{1}, nil
+			}
+			return []byte{0}, nil
+		}
+		return nil, fmt.Errorf("%T value is not a boolean", v)
+	case pgtype.ByteaOID:
+		switch v := v.(type) {
+		case []byte:
+			return v, nil
+		case string:
+			return []byte(v), nil
+		}
+		return nil, fmt.Errorf("%T value is not binary", v)
+	}
+
+	// The binary form of text is its text form.
+	return pgText(v, pgtype.TextOID), nil
+}
+
+func pgText(v interface{}, oid uint32) []byte {
+	switch v := v.(type) {
+	case int64:
+		return strconv.AppendInt(nil, v, 10)
+	case float64:
+		switch {
+		case math.IsInf(v, 1):
+			return []byte("Infinity")
+		case math.IsInf(v, -1):
+			return []byte("-Infinity")
+		case math.IsNaN(v):
+			return []byte("NaN")
+		}
+		return strconv.AppendFloat(nil, v, 'g', -1, 64)
+	case bool:
+		if v {
+			return []byte("t")
+		}
+		return []byte("f")
+	case string:
+		return []byte(v)
+	case []byte:
+		if oid == pgtype.ByteaOID {
+			return []byte(`\x` + hex.EncodeToString(v))
+		}
+		return v
+	case time.Time:
+		return []byte(v.Format(sqlite3.SQLiteTimestampFormats[0]))
+	}
+	return []byte(fmt.Sprint(v))
+}
+
+// decodePgParam converts a bound parameter into a value SQLite can bind.
+// Parameters of unspecified type are bound as strings, which SQLite's column
+// affinity converts on comparison and insert.
+func decodePgParam(raw []byte, oid uint32, format int16) (interface{}, error) {
+	if raw == nil {
+		return nil, nil
+	}
+
+	if format == pgproto3.TextFormat {
+		s := string(raw)
+		switch oid {
+		case pgtype.Int2OID, pgtype.Int4OID, pgtype.Int8OID:
+			return strconv.ParseInt(s, 10, 64)
+		case pgtype.Float4OID, pgtype.Float8OID:
+			return strconv.ParseFloat(s, 64)
+		case pgtype.BoolOID:
+			b, err := strconv.ParseBool(s)
+			if err != nil && (s == "t" || s == "f") {
+				b, err = s == "t", nil
+			}
+			return b, err
+		case pgtype.ByteaOID:
+			if !strings.HasPrefix(s, `\x`) {
+				return nil, fmt.Errorf("bytea must use hex format")
+			}
+			return hex.DecodeString(s[2:])
+		}
+		return s, nil
+	}
+
+	switch oid {
+	case pgtype.Int8OID:
+		if len(raw) != 8 {
+			return nil, fmt.Errorf("invalid int8 length %d", len(raw))
+		}
+		return int64(binary.BigEndian.Uint64(raw)), nil
+	case pgtype.Int4OID:
+		if len(raw) != 4 {
+			return nil, fmt.Errorf("invalid int4 length %d", len(raw))
+		}
+		return int64(int32(binary.BigEndian.Uint32(raw))), nil
+	case pgtype.Int2OID:
+		if len(raw) != 2 {
+			return nil, fmt.Errorf("invalid int2 length %d", len(raw))
+		}
+		return int64(int16(binary.BigEndian.Uint16(raw))), nil
+	case pgtype.Float8OID:
+		if len(raw) != 8 {
+			return nil, fmt.Errorf("invalid float8 length %d", len(raw))
+		}
+		return math.Float64frombits(binary.BigEndian.Uint64(raw)), nil
+	case pgtype.Float4OID:
+		if len(raw) != 4 {
+			return nil, fmt.Errorf("invalid float4 length %d", len(raw))
+		}
+		return float64(math.Float32frombits(binary.BigEndian.Uint32(raw))), nil
+	case pgtype.BoolOID:
+		if len(raw) != 1 {
+			return nil, fmt.Errorf("invalid bool length %d", len(raw))
+		}
+		return raw[0] != 0, nil
+	case pgtype.ByteaOID:
+		return append([]byte(nil), raw...), nil
+	case 0, pgtype.TextOID, pgtype.VarcharOID, pgtype.BPCharOID, pgtype.NameOID, pgtype.UnknownOID:
+		return string(raw), nil
+	}
+	return nil, fmt.Errorf("unsupported binary parameter type %d", oid)
+}
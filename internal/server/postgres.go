@@ -0,0 +1,875 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bxrne/branchlore/internal/auth"
+	"github.com/bxrne/branchlore/internal/database"
+	"github.com/jackc/pgx/v5/pgproto3"
+)
+
+// pgError is an error with its own SQLSTATE, for failures that do not come
+// from the database layer.
+type pgError struct {
+	code    string
+	message string
+}
+
+func (e *pgError) Error() string {
+	return e.message
+}
+
+var errPgTxAborted = &pgError{code: "25P02", message: "current transaction is aborted, commands ignored until end of transaction block"}
+
+// pgKey identifies a session to PostgreSQL CancelRequest messages.
+type pgKey struct {
+	processID uint32
+	secretKey uint32
+}
+
+// pgConn is one PostgreSQL protocol session. The database name given at
+// startup, "db@branch", selects the branch every statement runs against.
+type pgConn struct {
+	s       *Server
+	conn    net.Conn
+	backend *pgproto3.Backend
+	ctx     context.Context
+	key     pgKey
+
+	dbName string
+	branch string
+
+	tx       *database.Tx
+	txFailed bool
+
+	stmts   map[string]*pgStatement
+	portals map[string]*pgPortal
+
+	mu          sync.Mutex
+	cancelQuery context.CancelFunc
+	idle        idleGate
+}
+
+type pgStatement struct {
+	query     string
+	paramOIDs []uint32
+	desc      *database.Statement
+}
+
+// pgPortal is a bound statement. A SELECT executed with a row limit keeps
+// its cursor open between Execute messages.
+type pgPortal struct {
+	stmt    *pgStatement
+	args    []interface{}
+	formats []int16
+
+	cursor *database.Cursor
+	cancel context.CancelFunc
+	oids   []uint32
+	first  []interface{}
+	rows   int
+}
+
+func (p *pgPortal) close() {
+	if p.cursor != nil {
+		p.cursor.Close()
+		p.cancel()
+		p.cursor, p.first = nil, nil
+	}
+}
+
+func (s *Server) servePostgres(ln net.Listener) {
+	defer s.wg.Done()
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.handlePostgresConn(conn)
+		}()
+	}
+}
+
+func (s *Server) handlePostgresConn(conn net.Conn) {
+	defer conn.Close()
+
+	ctx, cancel := context.WithCancel(s.ctx)
+	defer cancel()
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+	ctx = withLogAttrs(ctx, slog.String("protocol", "postgres"), slog.String("remote", conn.RemoteAddr().String()))
+
+	c := &pgConn{
+		s:       s,
+		conn:    conn,
+		backend: pgproto3.NewBackend(conn, conn),
+		ctx:     ctx,
+		stmts:   make(map[string]*pgStatement),
+		portals: make(map[string]*pgPortal),
+	}
+	c.idle.conn = conn
+	defer c.close()
+
+	if !c.startup() {
+		return
+	}
+
+	s.pgMu.Lock()
+	s.pgConns[c.key] = c
+	s.pgMu.Unlock()
+	defer func() {
+		s.pgMu.Lock()
+		delete(s.pgConns, c.key)
+		s.pgMu.Unlock()
+	}()
+
+	start := time.Now()
+	s.logger.DebugContext(c.ctx, "PostgreSQL session started", slog.String("db", c.dbName), slog.String("branch", c.branch))
+	c.serve()
+	s.logger.DebugContext(c.ctx, "PostgreSQL session ended", slog.Duration("duration", time.Since(start)))
+}
+
+// cancelPostgresQuery handles a CancelRequest by cancelling whatever the
+// matching session is running.
+func (s *Server) cancelPostgresQuery(key pgKey) {
+	s.pgMu.Lock()
+	c, ok := s.pgConns[key]
+	s.pgMu.Unlock()
+	if !ok {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.cancelQuery != nil {
+		c.cancelQuery()
+	}
+}
+
+func (c *pgConn) close() {
+	for _, p := range c.portals {
+		p.close()
+	}
+	if c.tx != nil {
+		c.tx.Rollback()
+	}
+}
+
+// startup negotiates the session and reports whether it is ready for
+// queries.
+func (c *pgConn) startup() bool {
+	for {
+		msg, err := c.backend.ReceiveStartupMessage()
+		if err != nil {
+			return false
+		}
+
+		switch msg := msg.(type) {
+		case *pgproto3.SSLRequest:
+			if c.s.tls == nil {
+				if _, err := c.conn.Write([]byte("N")); err != nil {
+					return false
+				}
+				continue
+			}
+			if _, err := c.conn.Write([]byte("S")); err != nil {
+				return false
+			}
+			tlsConn := tls.Server(c.conn, c.s.tls)
+			if err := tlsConn.HandshakeContext(c.ctx); err != nil {
+				return false
+			}
+			c.conn = tlsConn
+			c.backend = pgproto3.NewBackend(tlsConn, tlsConn)
+		case *pgproto3.GSSEncRequest:
+			if _, err := c.conn.Write([]byte("N")); err != nil {
+				return false
+			}
+		case *pgproto3.CancelRequest:
+			c.s.cancelPostgresQuery(pgKey{msg.ProcessID, msg.SecretKey})
+			return false
+		case *pgproto3.StartupMessage:
+			// Cancel requests may arrive in the clear, but sessions must
+			// use TLS when it is configured.
+			if _, ok := c.conn.(*tls.Conn); c.s.tls != nil && !ok {
+				c.fatal("28000", "TLS is required")
+				return false
+			}
+			return c.handleStartup(msg)
+		default:
+			return false
+		}
+	}
+}
+
+func (c *pgConn) handleStartup(msg *pgproto3.StartupMessage) bool {
+	target := msg.Parameters["database"]
+	if target == "" {
+		target = msg.Parameters["user"]
+	}
+	c.dbName, c.branch = splitTarget(target)
+	c.ctx = withClient(c.ctx, "postgres", c.conn.RemoteAddr().String(), msg.Parameters["user"])
+
+	// With auth enabled the password is an API token; otherwise it is
+	// --postgres-password, if set.
+	switch password := c.s.cfg().PostgresPassword; {
+	case c.s.tokens() != nil:
+		// A client certificate of a known identity stands in for a token.
+		if ctx, err := c.certContext(); err == nil {
+			c.ctx = ctx
+		} else if !c.authenticate(c.checkToken) {
+			return false
+		}
+	case password != "":
+		if !c.authenticate(func(pw string) bool {
+			return subtle.ConstantTimeCompare([]byte(pw), []byte(password)) == 1
+		}) {
+			return false
+		}
+	}
+
+	if err := c.s.checkBranch(c.dbName, c.branch); err != nil {
+		c.fatal("3D000", fmt.Sprintf("database %q: %v", target, err))
+		return false
+	}
+	// The session is bound to one branch, so its permissions are settled
+	// here: without write access every statement runs read-only.
+	ctx, err := c.s.branchContext(c.ctx, c.dbName, c.branch)
+	if err != nil {
+		c.fatal("42501", err.Error())
+		return false
+	}
+	c.ctx = ctx
+
+	var key [8]byte
+	if _, err := rand.Read(key[:]); err != nil {
+		c.fatal("XX000", "failed to generate cancel key")
+		return false
+	}
+	c.key = pgKey{binary.BigEndian.Uint32(key[:4]), binary.BigEndian.Uint32(key[4:])}
+
+	c.backend.Send(&pgproto3.AuthenticationOk{})
+	for _, p := range [][2]string{
+		{"server_version", "15.0"},
+		{"server_encoding", "UTF8"},
+		{"client_encoding", "UTF8"},
+		{"DateStyle", "ISO, MDY"},
+		{"TimeZone", "UTC"},
+		{"integer_datetimes", "on"},
+		{"standard_conforming_strings", "on"},
+	} {
+		c.backend.Send(&pgproto3.ParameterStatus{Name: p[0], Value: p[1]})
+	}
+	c.backend.Send(&pgproto3.BackendKeyData{ProcessID: c.key.processID, SecretKey: c.key.secretKey})
+	c.backend.Send(&pgproto3.ReadyForQuery{TxStatus: 'I'})
+	return c.backend.Flush() == nil
+}
+
+// authenticate asks the client for its password and reports whether check
+// accepts it.
+func (c *pgConn) authenticate(check func(password string) bool) bool {
+	c.backend.Send(&pgproto3.AuthenticationCleartextPassword{})
+	if err := c.backend.Flush(); err != nil {
+		return false
+	}
+	if err := c.backend.SetAuthType(pgproto3.AuthTypeCleartextPassword); err != nil {
+		return false
+	}
+
+	msg, err := c.backend.Receive()
+	if err != nil {
+		return false
+	}
+	pw, ok := msg.(*pgproto3.PasswordMessage)
+	if !ok || !check(pw.Password) {
+		c.fatal("28P01", "password authentication failed")
+		return false
+	}
+	return true
+}
+
+// certContext authenticates the session by its client certificate, if it
+// presented one.
+func (c *pgConn) certContext() (context.Context, error) {
+	tlsConn, ok := c.conn.(*tls.Conn)
+	if !ok {
+		return nil, auth.ErrUnauthenticated
+	}
+	state := tlsConn.ConnectionState()
+	return c.s.authenticateCert(c.ctx, &state)
+}
+
+// checkToken authenticates the session by an API token.
+func (c *pgConn) checkToken(secret string) bool {
+	ctx, err := c.s.authenticate(c.ctx, secret)
+	if err != nil {
+		return false
+	}
+	c.ctx = ctx
+	return true
+}
+
+func (c *pgConn) fatal(code, message string) {
+	c.s.logger.WarnContext(c.ctx, "PostgreSQL connection rejected", slog.String("code", code), slog.String("error", message))
+	c.backend.Send(&pgproto3.ErrorResponse{Severity: "FATAL", Code: code, Message: message})
+	c.backend.Flush()
+}
+
+func (c *pgConn) serve() {
+	// After an error in the extended protocol everything up to the next
+	// Sync is discarded.
+	skipToSync := false
+
+	// ready is set while the client has been told ReadyForQuery and has
+	// not started another command, when shutdown may end the session.
+	ready := true
+
+	for {
+		if !c.idle.wait(c.s, ready && c.tx == nil) {
+			c.shutDown()
+			return
+		}
+		msg, err := c.backend.Receive()
+		if !c.idle.resume() {
+			c.shutDown()
+			return
+		}
+		if err != nil {
+			return
+		}
+
+		if _, ok := msg.(*pgproto3.Sync); !ok && skipToSync {
+			continue
+		}
+		ready = false
+
+		switch msg := msg.(type) {
+		case *pgproto3.Query:
+			c.simpleQuery(msg.String)
+			ready = true
+		case *pgproto3.Parse:
+			err = c.parse(msg)
+		case *pgproto3.Bind:
+			err = c.bind(msg)
+		case *pgproto3.Describe:
+			err = c.describe(msg)
+		case *pgproto3.Execute:
+			err = c.execute(msg)
+		case *pgproto3.Close:
+			c.closeObject(msg)
+		case *pgproto3.Sync:
+			skipToSync = false
+			ready = true
+			if c.tx == nil {
+				c.closePortals()
+			}
+			c.readyForQuery()
+		case *pgproto3.Flush:
+			err = c.backend.Flush()
+		case *pgproto3.Terminate:
+			return
+		default:
+			err = &pgError{code: "08P01", message: fmt.Sprintf("unsupported message %T", msg)}
+		}
+
+		if err != nil {
+			c.sendError(err)
+			skipToSync = true
+		}
+		if c.ctx.Err() != nil {
+			return
+		}
+	}
+}
+
+// shutDown tells the client the server is going away, as PostgreSQL does on
+// a fast shutdown.
+func (c *pgConn) shutDown() {
+	c.backend.Send(&pgproto3.ErrorResponse{Severity: "FATAL", Code: "57P01", Message: "terminating connection due to administrator command"})
+	c.backend.Flush()
+}
+
+func (c *pgConn) readyForQuery() {
+	status := byte('I')
+	switch {
+	case c.tx != nil && c.txFailed:
+		status = 'E'
+	case c.tx != nil:
+		status = 'T'
+	}
+	c.backend.Send(&pgproto3.ReadyForQuery{TxStatus: status})
+	c.backend.Flush()
+}
+
+func (c *pgConn) sendError(err error) {
+	if c.tx != nil {
+		c.txFailed = true
+	}
+
+	resp := &pgproto3.ErrorResponse{Severity: "ERROR", Code: "XX000", Message: err.Error()}
+	var pe *pgError
+	if errors.As(err, &pe) {
+		resp.Code = pe.code
+	} else {
+		_, body := classify(err)
+		resp.Code = pgErrorCode(body)
+		resp.Message = body.Message
+	}
+	c.backend.Send(resp)
+}
+
+// pgErrorCode maps a classified error onto the closest SQLSTATE.
+func pgErrorCode(body ErrorBody) string {
+	switch body.SQLiteExtendedCode {
+	case 1555, 2067: // SQLITE_CONSTRAINT_PRIMARYKEY, SQLITE_CONSTRAINT_UNIQUE
+		return "23505"
+	case 1299: // SQLITE_CONSTRAINT_NOTNULL
+		return "23502"
+	case 787: // SQLITE_CONSTRAINT_FOREIGNKEY
+		return "23503"
+	case 275: // SQLITE_CONSTRAINT_CHECK
+		return "23514"
+	}
+
+	switch body.Code {
+	case CodeInvalidArgument:
+		return "22023"
+	case CodeDBNotFound, CodeBranchNotFound:
+		return "3D000"
+	case CodeQueryTimeout, CodeQueryCancelled:
+		return "57014"
+	case CodeRateLimited, CodeTooManyQueries:
+		return "53400"
+	case "SQLITE_CONSTRAINT":
+		return "23000"
+	case "SQLITE_ERROR":
+		if strings.Contains(body.Message, "syntax error") {
+			return "42601"
+		}
+		return "42000"
+	case "SQLITE_BUSY", "SQLITE_LOCKED":
+		return "55P03"
+	case "SQLITE_READONLY":
+		return "25006"
+	case "SQLITE_FULL":
+		return "53100"
+	case "SQLITE_MISMATCH", "SQLITE_RANGE":
+		return "22000"
+	}
+	return "XX000"
+}
+
+func (c *pgConn) simpleQuery(query string) {
+	defer c.readyForQuery()
+
+	stmts := splitStatements(query)
+	if len(stmts) == 0 {
+		c.backend.Send(&pgproto3.EmptyQueryResponse{})
+		return
+	}
+
+	for _, stmt := range stmts {
+		p := &pgPortal{stmt: &pgStatement{query: stmt}}
+		err := c.run(p, true, 0)
+		p.close()
+		if err != nil {
+			c.sendError(err)
+			return
+		}
+	}
+}
+
+func (c *pgConn) parse(msg *pgproto3.Parse) error {
+	query := rewriteDollarParams(msg.Query)
+	if len(splitStatements(query)) > 1 {
+		return &pgError{code: "42601", message: "cannot insert multiple commands into a prepared statement"}
+	}
+
+	stmt := &pgStatement{query: strings.TrimSpace(query), desc: &database.Statement{}}
+	if stmt.query != "" && !isControlStatement(stmt.query) {
+		desc, err := c.describeQuery(stmt.query)
+		if err != nil {
+			return err
+		}
+		stmt.desc = desc
+	}
+
+	// SQLite cannot infer parameter types, so parameters the client did not
+	// type are described as unspecified (0) and bound as text.
+	stmt.paramOIDs = make([]uint32, max(stmt.desc.NumParams, len(msg.ParameterOIDs)))
+	copy(stmt.paramOIDs, msg.ParameterOIDs)
+
+	c.stmts[msg.Name] = stmt
+	c.backend.Send(&pgproto3.ParseComplete{})
+	return nil
+}
+
+func (c *pgConn) describeQuery(query string) (*database.Statement, error) {
+	if c.tx != nil {
+		return c.tx.Describe(c.ctx, query)
+	}
+	return c.s.dbMgr.Describe(c.ctx, c.dbName, c.branch, query)
+}
+
+func (c *pgConn) bind(msg *pgproto3.Bind) error {
+	stmt, ok := c.stmts[msg.PreparedStatement]
+	if !ok {
+		return &pgError{code: "26000", message: fmt.Sprintf("prepared statement %q does not exist", msg.PreparedStatement)}
+	}
+	if len(msg.Parameters) != len(stmt.paramOIDs) {
+		return &pgError{code: "08P01", message: fmt.Sprintf("bind message supplies %d parameters, but prepared statement requires %d", len(msg.Parameters), len(stmt.paramOIDs))}
+	}
+
+	args := make([]interface{}, len(msg.Parameters))
+	for i, raw := range msg.Parameters {
+		arg, err := decodePgParam(raw, stmt.paramOIDs[i], formatCode(msg.ParameterFormatCodes, i))
+		if err != nil {
+			return &pgError{code: "22P02", message: fmt.Sprintf("parameter $%d: %v", i+1, err)}
+		}
+		args[i] = arg
+	}
+
+	if old, ok := c.portals[msg.DestinationPortal]; ok {
+		old.close()
+	}
+	c.portals[msg.DestinationPortal] = &pgPortal{
+		stmt:    stmt,
+		args:    args,
+		formats: slices.Clone(msg.ResultFormatCodes),
+	}
+	c.backend.Send(&pgproto3.BindComplete{})
+	return nil
+}
+
+func (c *pgConn) describe(msg *pgproto3.Describe) error {
+	var stmt *pgStatement
+	var formats []int16
+
+	switch msg.ObjectType {
+	case 'S':
+		var ok bool
+		if stmt, ok = c.stmts[msg.Name]; !ok {
+			return &pgError{code: "26000", message: fmt.Sprintf("prepared statement %q does not exist", msg.Name)}
+		}
+		c.backend.Send(&pgproto3.ParameterDescription{ParameterOIDs: stmt.paramOIDs})
+	case 'P':
+		p, ok := c.portals[msg.Name]
+		if !ok {
+			return &pgError{code: "34000", message: fmt.Sprintf("portal %q does not exist", msg.Name)}
+		}
+		// A portal's column types can depend on its rows, so it is
+		// opened here rather than on Execute.
+		if p.cursor == nil && !c.txFailed && database.IsSelect(p.stmt.query) && !isControlStatement(p.stmt.query) {
+			if err := c.open(p); err != nil {
+				return err
+			}
+		}
+		if p.cursor != nil {
+			c.backend.Send(rowDescription(p.cursor.Columns(), p.oids, p.formats))
+			return nil
+		}
+		stmt, formats = p.stmt, p.formats
+	default:
+		return &pgError{code: "08P01", message: "invalid describe target"}
+	}
+
+	if len(stmt.desc.Columns) == 0 {
+		c.backend.Send(&pgproto3.NoData{})
+		return nil
+	}
+	c.backend.Send(rowDescription(stmt.desc.Columns, pgTypeOIDs(stmt.desc.ColumnTypes), formats))
+	return nil
+}
+
+func (c *pgConn) execute(msg *pgproto3.Execute) error {
+	p, ok := c.portals[msg.Portal]
+	if !ok {
+		return &pgError{code: "34000", message: fmt.Sprintf("portal %q does not exist", msg.Portal)}
+	}
+
+	if err := c.run(p, false, int(msg.MaxRows)); err != nil {
+		p.close()
+		return err
+	}
+	return nil
+}
+
+func (c *pgConn) closeObject(msg *pgproto3.Close) {
+	switch msg.ObjectType {
+	case 'S':
+		delete(c.stmts, msg.Name)
+	case 'P':
+		if p, ok := c.portals[msg.Name]; ok {
+			p.close()
+			delete(c.portals, msg.Name)
+		}
+	}
+	c.backend.Send(&pgproto3.CloseComplete{})
+}
+
+func (c *pgConn) closePortals() {
+	for name, p := range c.portals {
+		p.close()
+		delete(c.portals, name)
+	}
+}
+
+func isControlStatement(query string) bool {
+	words := database.Keywords(query, 2)
+	if len(words) == 0 {
+		return false
+	}
+	switch words[0] {
+	case "BEGIN", "START", "COMMIT", "END", "SET", "RESET":
+		return true
+	case "ROLLBACK", "ABORT":
+		return len(words) < 2 || words[1] != "TO"
+	}
+	return false
+}
+
+// run executes the portal's statement, or resumes a suspended one, sending
+// at most maxRows rows when maxRows > 0. Transaction control statements are
+// handled here rather than passed to SQLite, since a session's transaction
+// must pin one connection.
+func (c *pgConn) run(p *pgPortal, describe bool, maxRows int) error {
+	if p.cursor != nil {
+		return c.sendRows(p, maxRows)
+	}
+
+	query := p.stmt.query
+	if query == "" {
+		c.backend.Send(&pgproto3.EmptyQueryResponse{})
+		return nil
+	}
+
+	words := database.Keywords(query, 2)
+	if isControlStatement(query) {
+		return c.control(words[0])
+	}
+	if c.txFailed {
+		return errPgTxAborted
+	}
+
+	if database.IsSelect(query) {
+		if err := c.open(p); err != nil {
+			return err
+		}
+		if describe {
+			c.backend.Send(rowDescription(p.cursor.Columns(), p.oids, p.formats))
+		}
+		return c.sendRows(p, maxRows)
+	}
+
+	ctx, cancel, err := c.queryContext()
+	if err != nil {
+		return err
+	}
+	defer cancel()
+
+	var res database.ModifyResult
+	if c.tx != nil {
+		res, err = c.tx.Exec(ctx, query, p.args)
+	} else {
+		res, err = c.s.dbMgr.Exec(ctx, c.dbName, c.branch, query, p.args)
+	}
+	if err != nil {
+		return err
+	}
+	c.complete(commandTag(words, res.RowsAffected))
+	return nil
+}
+
+// open starts the portal's query and settles its column types. Columns
+// without a declared type are typed from the first row, which is held back
+// until the rows are sent.
+func (c *pgConn) open(p *pgPortal) error {
+	ctx, cancel, err := c.queryContext()
+	if err != nil {
+		return err
+	}
+
+	var cursor *database.Cursor
+	if c.tx != nil {
+		cursor, err = c.tx.OpenCursor(ctx, p.stmt.query, p.args, database.FormatTyped)
+	} else {
+		cursor, err = c.s.dbMgr.OpenCursor(ctx, c.dbName, c.branch, p.stmt.query, p.args, database.FormatTyped)
+	}
+	if err != nil {
+		cancel()
+		return err
+	}
+
+	c.s.limits(c.ctx, c.dbName).limitCursor(cursor)
+	p.cursor, p.cancel = cursor, cancel
+	p.oids = pgTypeOIDs(cursor.ColumnTypes())
+	p.rows = 0
+	for i, decl := range cursor.ColumnTypes() {
+		if decl != "" {
+			continue
+		}
+		if p.first == nil {
+			if !cursor.Next() {
+				break
+			}
+			if p.first, err = cursor.Values(); err != nil {
+				p.close()
+				return err
+			}
+		}
+		p.oids[i] = valueOID(p.first[i])
+	}
+	return nil
+}
+
+// queryContext admits a statement under the session's rate limits and
+// the database's concurrency cap, and bounds it by the database's timeout.
+// The returned cancel also gives back its concurrency slot.
+func (c *pgConn) queryContext() (context.Context, context.CancelFunc, error) {
+	if err := c.s.throttle(c.ctx); err != nil {
+		return nil, nil, err
+	}
+	release, err := c.s.acquireDatabase(c.dbName)
+	if err != nil {
+		return nil, nil, err
+	}
+	ctx, cancel := c.s.queryContext(c.ctx, c.s.limits(c.ctx, c.dbName).timeoutFor(0))
+	c.mu.Lock()
+	c.cancelQuery = cancel
+	c.mu.Unlock()
+	return ctx, func() {
+		cancel()
+		release()
+	}, nil
+}
+
+func (c *pgConn) control(keyword string) error {
+	var err error
+	tag := keyword
+	switch keyword {
+	case "BEGIN", "START":
+		tag = "BEGIN"
+		if c.tx == nil {
+			c.tx, err = c.s.dbMgr.BeginTx(c.ctx, c.dbName, c.branch)
+		}
+	case "COMMIT", "END":
+		tag = "COMMIT"
+		if c.tx != nil {
+			if c.txFailed {
+				tag = "ROLLBACK"
+				err = c.tx.Rollback()
+			} else {
+				err = c.tx.Commit()
+			}
+			c.endTx()
+		}
+	case "ROLLBACK", "ABORT":
+		tag = "ROLLBACK"
+		if c.tx != nil {
+			err = c.tx.Rollback()
+			c.endTx()
+		}
+	}
+	if err != nil {
+		return err
+	}
+	c.complete(tag)
+	return nil
+}
+
+func (c *pgConn) endTx() {
+	c.tx, c.txFailed = nil, false
+	c.closePortals()
+}
+
+func (c *pgConn) sendRows(p *pgPortal, maxRows int) error {
+	sent := 0
+	for maxRows <= 0 || sent < maxRows {
+		values := p.first
+		p.first = nil
+		if values == nil && !p.cursor.Next() {
+			err := p.cursor.Err()
+			p.close()
+			if err != nil {
+				return err
+			}
+			c.complete(fmt.Sprintf("SELECT %d", p.rows))
+			return nil
+		}
+
+		var err error
+		if values == nil {
+			if values, err = p.cursor.Values(); err != nil {
+				return err
+			}
+		}
+		row := make([][]byte, len(values))
+		for i, v := range values {
+			if row[i], err = encodePgValue(v, p.oids[i], formatCode(p.formats, i)); err != nil {
+				return &pgError{code: "22000", message: fmt.Sprintf("column %q: %v", p.cursor.Columns()[i], err)}
+			}
+		}
+		c.backend.Send(&pgproto3.DataRow{Values: row})
+
+		sent++
+		p.rows++
+		if p.rows%wireFlushRows == 0 {
+			if err := c.backend.Flush(); err != nil {
+				return err
+			}
+		}
+	}
+
+	c.backend.Send(&pgproto3.PortalSuspended{})
+	return nil
+}
+
+func (c *pgConn) complete(tag string) {
+	c.backend.Send(&pgproto3.CommandComplete{CommandTag: []byte(tag)})
+}
+
+// commandTag builds the CommandComplete tag PostgreSQL clients expect for a
+// statement starting with words.
+func commandTag(words []string, rowsAffected int64) string {
+	if len(words) == 0 {
+		return ""
+	}
+	switch words[0] {
+	case "INSERT":
+		return fmt.Sprintf("INSERT 0 %d", rowsAffected)
+	case "UPDATE", "DELETE":
+		return fmt.Sprintf("%s %d", words[0], rowsAffected)
+	case "CREATE", "DROP", "ALTER":
+		return strings.Join(words, " ")
+	}
+	return words[0]
+}
+
+func formatCode(codes []int16, i int) int16 {
+	switch len(codes) {
+	case 0:
+		return pgproto3.TextFormat
+	case 1:
+		return codes[0]
+	}
+	if i < len(codes) {
+		return codes[i]
+	}
+	return pgproto3.TextFormat
+}
@@ -0,0 +1,237 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
+
+	"github.com/bxrne/branchlore/internal/auth"
+)
+
+// servePostgresTest serves the PostgreSQL protocol of s on a free local
+// port and returns its address.
+func servePostgresTest(t *testing.T, s *Server) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.wg.Add(1)
+	go s.servePostgres(ln)
+	t.Cleanup(func() {
+		ln.Close()
+		s.cancel()
+		s.wg.Wait()
+	})
+	return ln.Addr().String()
+}
+
+// connectPostgres opens a session as user with password on target at addr.
+func connectPostgres(t *testing.T, addr, user, password, target string) (*pgx.Conn, error) {
+	t.Helper()
+	host, port, _ := net.SplitHostPort(addr)
+	dsn := fmt.Sprintf("host=%s port=%s user=%s password='%s' dbname=%s sslmode=disable", host, port, user, password, target)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	conn, err := pgx.Connect(ctx, dsn)
+	if err == nil {
+		t.Cleanup(func() { conn.Close(context.Background()) })
+	}
+	return conn, err
+}
+
+// pgCode returns the SQLSTATE of err, or "" if it is not a PostgreSQL
+// error.
+func pgCode(err error) string {
+	var pe *pgconn.PgError
+	if errors.As(err, &pe) {
+		return pe.Code
+	}
+	return ""
+}
+
+func TestPostgresQueries(t *testing.T) {
+	s := newTestServer(t, nil)
+	newTestDatabase(t, s, "db")
+	conn, err := connectPostgres(t, servePostgresTest(t, s), "app", "", "db@main")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	if _, err := conn.Exec(ctx, "CREATE TABLE t (id INTEGER PRIMARY KEY, name TEXT, score REAL, data BLOB)"); err != nil {
+		t.Fatal(err)
+	}
+	tag, err := conn.Exec(ctx, "INSERT INTO t (name, score) VALUES ($1, $2)", "ada", 1.5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tag.String() != "INSERT 0 1" {
+		t.Errorf("command tag %q, want INSERT 0 1", tag)
+	}
+	// Parameters without a type bind as text, so BLOBs need theirs.
+	res := conn.PgConn().ExecParams(ctx, "UPDATE t SET data = $1", [][]byte{{0, 1, 2}}, []uint32{pgtype.ByteaOID}, []int16{pgtype.BinaryFormatCode}, nil).Read()
+	if res.Err != nil || res.CommandTag.String() != "UPDATE 1" {
+		t.Fatalf("UPDATE with a bytea parameter: %q, %v", res.CommandTag, res.Err)
+	}
+
+	for _, mode := range []pgx.QueryExecMode{pgx.QueryExecModeCacheStatement, pgx.QueryExecModeSimpleProtocol} {
+		var (
+			id    int64
+			name  string
+			score float64
+			data  []byte
+		)
+		err := conn.QueryRow(ctx, "SELECT id, name, score, data FROM t WHERE id = $1", mode, 1).Scan(&id, &name, &score, &data)
+		if err != nil {
+			t.Errorf("mode %v: %v", mode, err)
+			continue
+		}
+		if id != 1 || name != "ada" || score != 1.5 || string(data) != "\x00\x01\x02" {
+			t.Errorf("mode %v: got %d %q %g %v", mode, id, name, score, data)
+		}
+	}
+
+	for _, mode := range []pgx.QueryExecMode{pgx.QueryExecModeCacheStatement, pgx.QueryExecModeSimpleProtocol} {
+		var (
+			id   int64
+			name string
+		)
+		err := conn.QueryRow(ctx, "INSERT INTO t (name) VALUES ($1) RETURNING id, name", mode, "grace").Scan(&id, &name)
+		if err != nil {
+			t.Errorf("mode %v: INSERT ... RETURNING: %v", mode, err)
+			continue
+		}
+		if id < 2 || name != "grace" {
+			t.Errorf("mode %v: INSERT ... RETURNING got %d %q", mode, id, name)
+		}
+	}
+
+	for _, tt := range []struct {
+		query, code string
+	}{
+		{"INSERT INTO t (id) VALUES (1)", "23505"},
+		{"SELEKT 1", "42601"},
+	} {
+		if _, err := conn.Exec(ctx, tt.query); pgCode(err) != tt.code {
+			t.Errorf("%s: %v, want SQLSTATE %s", tt.query, err, tt.code)
+		}
+	}
+}
+
+func TestPostgresTransactions(t *testing.T) {
+	s := newTestServer(t, nil)
+	newTestDatabase(t, s, "db")
+	conn, err := connectPostgres(t, servePostgresTest(t, s), "app", "", "db@main")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	conn.Exec(ctx, "CREATE TABLE t (a INTEGER)")
+
+	count := func(q interface {
+		QueryRow(context.Context, string, ...any) pgx.Row
+	}) int {
+		t.Helper()
+		var n int
+		if err := q.QueryRow(ctx, "SELECT count(*) FROM t").Scan(&n); err != nil {
+			t.Fatal(err)
+		}
+		return n
+	}
+
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx.Exec(ctx, "INSERT INTO t VALUES (1)")
+	if n := count(tx); n != 1 {
+		t.Fatalf("count inside the transaction = %d, want 1", n)
+	}
+	if err := tx.Rollback(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if n := count(conn); n != 0 {
+		t.Fatalf("%d rows after rollback", n)
+	}
+
+	// A failed statement aborts the transaction until it ends.
+	tx, err = conn.Begin(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx.Exec(ctx, "INSERT INTO t VALUES (1)")
+	if _, err := tx.Exec(ctx, "SELEKT 1"); err == nil {
+		t.Fatal("invalid statement succeeded")
+	}
+	if _, err := tx.Exec(ctx, "INSERT INTO t VALUES (2)"); pgCode(err) != "25P02" {
+		t.Errorf("statement after a failed one: %v, want SQLSTATE 25P02", err)
+	}
+	tx.Commit(ctx)
+	if n := count(conn); n != 0 {
+		t.Fatalf("%d rows after committing an aborted transaction, want 0", n)
+	}
+
+	tx, _ = conn.Begin(ctx)
+	tx.Exec(ctx, "INSERT INTO t VALUES (1), (2)")
+	if err := tx.Commit(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if n := count(conn); n != 2 {
+		t.Fatalf("%d rows after commit, want 2", n)
+	}
+}
+
+func TestPostgresStartup(t *testing.T) {
+	s := newTestServer(t, &Config{Auth: true})
+	newTestDatabase(t, s, "db")
+	secret := newTestToken(t, s, "app", auth.ScopeRead)
+	addr := servePostgresTest(t, s)
+
+	for _, tt := range []struct {
+		name, password, target, code string
+	}{
+		{"token", secret, "db@main", ""},
+		{"wrong token", "blt_wrong", "db@main", "28P01"},
+		{"missing database", secret, "missing", "3D000"},
+		{"missing branch", secret, "db@missing", "3D000"},
+	} {
+		_, err := connectPostgres(t, addr, "app", tt.password, tt.target)
+		if tt.code == "" && err != nil || pgCode(err) != tt.code {
+			t.Errorf("%s: %v, want SQLSTATE %q", tt.name, err, tt.code)
+		}
+	}
+
+	conn, err := connectPostgres(t, addr, "app", secret, "db@main")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := conn.Exec(context.Background(), "CREATE TABLE t (a INTEGER)"); pgCode(err) != "25006" {
+		t.Errorf("write with a read-only token: %v, want SQLSTATE 25006", err)
+	}
+}
+
+func TestPostgresQueryTimeout(t *testing.T) {
+	s := newTestServer(t, &Config{QueryTimeout: 50 * time.Millisecond})
+	newTestDatabase(t, s, "db")
+	conn, err := connectPostgres(t, servePostgresTest(t, s), "app", "", "db@main")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var n int64
+	err = conn.QueryRow(context.Background(), slowQuery).Scan(&n)
+	if pgCode(err) != "57014" {
+		t.Fatalf("slow query: %v, want SQLSTATE 57014", err)
+	}
+	if err := conn.Ping(context.Background()); err != nil {
+		t.Fatalf("session unusable after a timeout: %v", err)
+	}
+}
@@ -0,0 +1,118 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/bxrne/branchlore/internal/database"
+)
+
+const testQueryPath = "/v1/databases/db/branches/main/query"
+
+func TestQueryReturnsRowsOfEveryRowStatement(t *testing.T) {
+	s := newTestServer(t, nil)
+	newTestDatabase(t, s, "db")
+	h := s.handler()
+
+	w := do(t, h, http.MethodPost, testQueryPath, queryRequest{Query: "CREATE TABLE t (a INTEGER)"})
+	if w.Code != http.StatusOK {
+		t.Fatalf("CREATE TABLE: %d %s", w.Code, w.Body)
+	}
+
+	for _, query := range []string{
+		"SELECT 1 AS a",
+		"select\n1 AS a",
+		"-- comment\nSELECT 1 AS a",
+		"/* comment */ SELECT 1 AS a",
+		"VALUES (1)",
+		"WITH x(a) AS (SELECT 1) SELECT a FROM x",
+		"PRAGMA table_info(t)",
+	} {
+		w := do(t, h, http.MethodPost, testQueryPath, queryRequest{Query: query})
+		if w.Code != http.StatusOK {
+			t.Errorf("%q: %d %s", query, w.Code, w.Body)
+			continue
+		}
+		var result database.QueryResult
+		decode(t, w, &result)
+		if len(result.Columns) == 0 || len(result.Rows) != 1 {
+			t.Errorf("%q: got %s, want one row", query, w.Body)
+		}
+	}
+}
+
+// slowQuery runs for minutes unless interrupted.
+const slowQuery = "WITH RECURSIVE c(x) AS (SELECT 1 UNION ALL SELECT x + 1 FROM c) SELECT count(*) FROM (SELECT x FROM c LIMIT 1000000000)"
+
+func TestQueryTimeout(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		config  *Config
+		timeout string
+	}{
+		{"requested", nil, "50ms"},
+		{"server default", &Config{QueryTimeout: 50 * time.Millisecond}, ""},
+		{"database default", &Config{Databases: map[string]DatabaseConfig{"db": {QueryTimeout: 50 * time.Millisecond}}}, ""},
+	} {
+		s := newTestServer(t, tt.config)
+		newTestDatabase(t, s, "db")
+		h := s.handler()
+
+		for _, stream := range []string{"", "ndjson"} {
+			w := do(t, h, http.MethodPost, testQueryPath, queryRequest{Query: slowQuery, Timeout: tt.timeout, Stream: stream})
+			if w.Code != http.StatusGatewayTimeout || errorCode(t, w) != CodeQueryTimeout {
+				t.Errorf("%s (stream %q): %d %s, want 504 %s", tt.name, stream, w.Code, w.Body, CodeQueryTimeout)
+			}
+		}
+	}
+}
+
+func TestQueryRejectsInvalidTimeout(t *testing.T) {
+	s := newTestServer(t, nil)
+	newTestDatabase(t, s, "db")
+
+	for _, timeout := range []string{"soon", "-1s", "0s"} {
+		w := do(t, s.handler(), http.MethodPost, testQueryPath, queryRequest{Query: "SELECT 1", Timeout: timeout})
+		if w.Code != http.StatusBadRequest || errorCode(t, w) != CodeInvalidArgument {
+			t.Errorf("timeout %q: %d %s, want 400 %s", timeout, w.Code, w.Body, CodeInvalidArgument)
+		}
+	}
+}
+
+func TestQueryCancelledOnShutdown(t *testing.T) {
+	s := newTestServer(t, nil)
+	newTestDatabase(t, s, "db")
+
+	done := make(chan *httptest.ResponseRecorder)
+	go func() {
+		done <- do(t, s.handler(), http.MethodPost, testQueryPath, queryRequest{Query: slowQuery})
+	}()
+	time.Sleep(100 * time.Millisecond)
+	s.cancel()
+
+	select {
+	case w := <-done:
+		if w.Code != http.StatusServiceUnavailable || errorCode(t, w) != CodeQueryCancelled {
+			t.Errorf("%d %s, want 503 %s", w.Code, w.Body, CodeQueryCancelled)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("query still running after the server context was cancelled")
+	}
+}
+
+func TestQueryMaxRows(t *testing.T) {
+	s := newTestServer(t, &Config{MaxRows: 2})
+	newTestDatabase(t, s, "db")
+
+	w := do(t, s.handler(), http.MethodPost, testQueryPath, queryRequest{Query: "VALUES (1), (2), (3)"})
+	if w.Code != http.StatusOK {
+		t.Fatalf("%d %s", w.Code, w.Body)
+	}
+	var result database.QueryResult
+	decode(t, w, &result)
+	if len(result.Rows) != 2 || !result.Truncated {
+		t.Fatalf("got %s, want two rows marked truncated", w.Body)
+	}
+}
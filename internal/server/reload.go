@@ -0,0 +1,91 @@
+package server
+
+import (
+	"log/slog"
+	"maps"
+
+	"github.com/bxrne/branchlore/internal/auth"
+)
+
+// Reload applies config to the running server. The log level, token
+// authentication, the PostgreSQL and MySQL passwords, the MySQL packet limit,
+// query and session limits, rate, concurrency and per-role limits, disk
+// quotas, the janitor interval, ephemeral branch and prune retention,
+// connection pools, readiness thresholds, commit on shutdown and
+// per-database settings change at once. The rest, such as the listen
+// addresses, data directory, log format, TLS and auditing, keep their
+// current values until a restart, and changes to them are logged as
+// warnings. Requests already running keep the limits they started with.
+//
+// Turning authentication off opens every route to anyone who can reach the
+// server, so it is logged as an error.
+//
+// On error nothing is changed.
+func (s *Server) Reload(config *Config) error {
+	level, err := parseLogLevel(config.LogLevel)
+	if err != nil {
+		return err
+	}
+
+	current := s.cfg()
+	var ignored []string
+	for _, field := range []struct {
+		name    string
+		changed bool
+	}{
+		{"port", config.Port != current.Port},
+		{"data directory", config.DataDir != current.DataDir},
+		{"log format", config.LogFormat != current.LogFormat},
+		{"PostgreSQL address", config.PostgresAddr != current.PostgresAddr},
+		{"MySQL address", config.MySQLAddr != current.MySQLAddr},
+		{"gRPC address", config.GRPCAddr != current.GRPCAddr},
+		{"TLS", config.TLSCert != current.TLSCert || config.TLSKey != current.TLSKey || config.TLSClientCA != current.TLSClientCA},
+		{"audit", config.Audit != current.Audit},
+	} {
+		if field.changed {
+			ignored = append(ignored, field.name)
+		}
+	}
+
+	next := *current
+	next.LogLevel = config.LogLevel
+	next.Auth = config.Auth
+	next.PostgresPassword = config.PostgresPassword
+	next.MySQLPassword = config.MySQLPassword
+	next.MySQLMaxPacket = config.MySQLMaxPacket
+	next.QueryTimeout = config.QueryTimeout
+	next.MaxRows = config.MaxRows
+	next.CursorIdleTimeout = config.CursorIdleTimeout
+	next.TxIdleTimeout = config.TxIdleTimeout
+	next.MinFreeDisk = config.MinFreeDisk
+	next.HealthCheckSample = config.HealthCheckSample
+	next.CommitOnShutdown = config.CommitOnShutdown
+	next.TokenRate, next.TokenBurst = config.TokenRate, config.TokenBurst
+	next.IPRate, next.IPBurst = config.IPRate, config.IPBurst
+	next.MaxConcurrentQueries = config.MaxConcurrentQueries
+	next.Roles = maps.Clone(config.Roles)
+	next.DatabaseQuota, next.BranchQuota = config.DatabaseQuota, config.BranchQuota
+	next.JanitorInterval = config.JanitorInterval
+	next.EphemeralTTL, next.PruneGrace = config.EphemeralTTL, config.PruneGrace
+	next.Pool = config.Pool
+	next.Databases = maps.Clone(config.Databases)
+
+	s.logLevel.Set(level)
+	switch {
+	case next.Auth && s.tokens() == nil:
+		s.tokenStore.Store(auth.NewStore(next.DataDir))
+	case !next.Auth:
+		s.tokenStore.Store(nil)
+	}
+	s.dbMgr.SetPool(next.Pool)
+	s.config.Store(&next)
+
+	s.logger.Info("Reloaded configuration", slog.String("log_level", level.String()), slog.Bool("auth", next.Auth))
+	if current.Auth && !next.Auth {
+		s.logger.Error("Reload turned authentication off; every route now accepts requests without a token")
+	}
+	if len(ignored) > 0 {
+		s.logger.Warn("Some configuration changes take effect on restart", slog.Any("settings", ignored))
+	}
+	return nil
+}
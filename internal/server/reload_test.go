@@ -0,0 +1,36 @@
+package server
+
+import (
+	"bytes"
+	"net/http"
+	"testing"
+)
+
+func TestReloadTurningAuthOffLogsAnError(t *testing.T) {
+	s := newTestServer(t, &Config{Auth: true})
+	var buf bytes.Buffer
+	logger, _, err := newLogger(&Config{LogLevel: "info", LogFormat: "json"}, &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.logger = logger
+	h := s.handler()
+
+	next := *s.cfg()
+	next.Auth = false
+	if err := s.Reload(&next); err != nil {
+		t.Fatal(err)
+	}
+	if w := do(t, h, http.MethodGet, "/v1/databases", nil); w.Code != http.StatusOK {
+		t.Errorf("request without a token after turning auth off: %d", w.Code)
+	}
+	var logged int
+	for _, r := range logRecords(t, &buf) {
+		if r["level"] == "ERROR" {
+			logged++
+		}
+	}
+	if logged != 1 {
+		t.Errorf("logged %d errors, want 1: %s", logged, buf.String())
+	}
+}
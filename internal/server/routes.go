@@ -0,0 +1,291 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/bxrne/branchlore/internal/database"
+)
+
+// route describes one endpoint. The same table registers the handlers and
+// generates the OpenAPI document, and TestRoutesMatchOpenAPI checks that the
+// two agree.
+type route struct {
+	Method      string
+	Path        string
+	OperationID string
+	Summary     string
+	Deprecated  bool
+	// Params lists query parameters; path parameters are taken from Path.
+	Params []param
+	// Body is the JSON request body, FormBody a form encoded one.
+	Body     interface{}
+	FormBody interface{}
+	// Responses maps success statuses to their possible bodies. An empty
+	// list means the response has no body.
+	Responses map[int][]interface{}
+	// NDJSON marks endpoints that can stream application/x-ndjson.
+	NDJSON bool
+	// ContentType of the success bodies, application/json if empty.
+	ContentType string
+	// Public routes need no token when auth is enabled.
+	Public bool
+	// Session routes continue an open transaction or cursor, so they are
+	// still served while the server shuts down.
+	Session bool
+
+	handler http.HandlerFunc
+}
+
+type param struct {
+	Name        string
+	Description string
+	Required    bool
+}
+
+func (s *Server) routes() []route {
+	queryResults := []interface{}{database.QueryResult{}, database.ModifyResult{}}
+	dbParam := param{Name: "db", Description: "Database name", Required: true}
+	branchParam := param{Name: "branch", Description: "Branch name, defaults to main"}
+
+	return []route{
+		{
+			Method: http.MethodGet, Path: "/v1/databases",
+			OperationID: "listDatabases", Summary: "List databases",
+			Responses: map[int][]interface{}{http.StatusOK: {databaseList{}}},
+			handler:   s.handleListDatabases,
+		},
+		{
+			Method: http.MethodPost, Path: "/v1/databases",
+			OperationID: "createDatabase", Summary: "Create a database",
+			Body:      createRequest{},
+			Responses: map[int][]interface{}{http.StatusCreated: {databaseInfo{}}},
+			handler:   s.handleCreateDatabase,
+		},
+		{
+			Method: http.MethodGet, Path: "/v1/databases/{db}",
+			OperationID: "getDatabase", Summary: "Show a database and its branches",
+			Responses: map[int][]interface{}{http.StatusOK: {databaseInfo{}}},
+			handler:   s.handleGetDatabase,
+		},
+		{
+			Method: http.MethodDelete, Path: "/v1/databases/{db}",
+			OperationID: "deleteDatabase", Summary: "Delete a database and all its branches",
+			Responses: map[int][]interface{}{http.StatusNoContent: nil},
+			handler:   s.handleDeleteDatabase,
+		},
+		{
+			Method: http.MethodGet, Path: "/v1/databases/{db}/branches",
+			OperationID: "listBranches", Summary: "List branches",
+			Responses: map[int][]interface{}{http.StatusOK: {branchList{}}},
+			handler:   s.handleListBranches,
+		},
+		{
+			Method: http.MethodPost, Path: "/v1/databases/{db}/branches",
+			OperationID: "createBranch", Summary: "Create a branch as a copy of another, main by default",
+			Body:      createBranchRequest{},
+			Responses: map[int][]interface{}{http.StatusCreated: {branchInfo{}}},
+			handler:   s.handleCreateBranch,
+		},
+		{
+			Method: http.MethodGet, Path: "/v1/databases/{db}/branches/{branch}",
+			OperationID: "getBranch", Summary: "Show a branch",
+			Responses: map[int][]interface{}{http.StatusOK: {branchInfo{}}},
+			handler:   s.handleGetBranch,
+		},
+		{
+			Method: http.MethodDelete, Path: "/v1/databases/{db}/branches/{branch}",
+			OperationID: "deleteBranch", Summary: "Delete a branch",
+			Responses: map[int][]interface{}{http.StatusNoContent: nil},
+			handler:   s.handleDeleteBranch,
+		},
+		{
+			Method: http.MethodPost, Path: "/v1/databases/{db}/branches/{branch}/commits",
+			OperationID: "commitBranch", Summary: "Record the branch's current state in its history",
+			Body:      commitRequest{},
+			Responses: map[int][]interface{}{http.StatusCreated: {commitInfo{}}},
+			handler:   s.handleCommitBranch,
+		},
+		{
+			Method: http.MethodPost, Path: "/v1/databases/{db}/branches/{branch}/merges",
+			OperationID: "mergeBranch", Summary: "Merge another branch into the branch, running its protection checks",
+			Body:      mergeRequest{},
+			Responses: map[int][]interface{}{http.StatusCreated: {commitInfo{}}},
+			handler:   s.handleMergeBranch,
+		},
+		{
+			Method: http.MethodGet, Path: "/v1/databases/{db}/diff",
+			OperationID: "diffBranches", Summary: "Compare the tables of two branches",
+			Params: []param{
+				{Name: "base", Description: "Branch to compare against", Required: true},
+				{Name: "head", Description: "Branch to compare", Required: true},
+			},
+			Responses: map[int][]interface{}{http.StatusOK: {database.Diff{}}},
+			handler:   s.handleDiffBranches,
+		},
+		{
+			Method: http.MethodGet, Path: "/v1/databases/{db}/usage",
+			OperationID: "getUsage", Summary: "Report the disk space a database and each of its branches take",
+			Responses: map[int][]interface{}{http.StatusOK: {database.Usage{}}},
+			handler:   s.handleUsage,
+		},
+		{
+			Method: http.MethodPost, Path: "/v1/databases/{db}/branches/{branch}/query",
+			OperationID: "query", Summary: "Run a SQL statement against a branch",
+			Body:      queryRequest{},
+			Responses: map[int][]interface{}{http.StatusOK: queryResults},
+			NDJSON:    true,
+			handler:   s.handleV1Query,
+		},
+		{
+			Method: http.MethodPost, Path: "/v1/databases/{db}/branches/{branch}/transactions",
+			OperationID: "beginTransaction", Summary: "Begin a transaction on a branch",
+			Responses: map[int][]interface{}{http.StatusCreated: {txInfo{}}},
+			handler:   s.handleBeginTx,
+		},
+		{
+			Method: http.MethodPost, Path: "/v1/transactions/{tx}/query",
+			OperationID: "transactionQuery", Summary: "Run a SQL statement inside a transaction",
+			Body:      queryRequest{},
+			Responses: map[int][]interface{}{http.StatusOK: queryResults},
+			Session:   true,
+			handler:   s.handleTxQuery,
+		},
+		{
+			Method: http.MethodPost, Path: "/v1/transactions/{tx}/commit",
+			OperationID: "commitTransaction", Summary: "Commit a transaction",
+			Responses: map[int][]interface{}{http.StatusNoContent: nil},
+			Session:   true,
+			handler:   s.handleCommitTx,
+		},
+		{
+			Method: http.MethodPost, Path: "/v1/transactions/{tx}/rollback",
+			OperationID: "rollbackTransaction", Summary: "Roll back a transaction",
+			Responses: map[int][]interface{}{http.StatusNoContent: nil},
+			Session:   true,
+			handler:   s.handleRollbackTx,
+		},
+		{
+			Method: http.MethodGet, Path: "/v1/cursors/{cursor}",
+			OperationID: "nextPage", Summary: "Fetch the next page of a paged query",
+			Responses: map[int][]interface{}{http.StatusOK: {database.QueryResult{}}},
+			Session:   true,
+			handler:   s.handleNextCursor,
+		},
+		{
+			Method: http.MethodDelete, Path: "/v1/cursors/{cursor}",
+			OperationID: "closeCursor", Summary: "Close a paged query",
+			Responses: map[int][]interface{}{http.StatusNoContent: nil},
+			Session:   true,
+			handler:   s.handleCloseCursor,
+		},
+		{
+			Method: http.MethodGet, Path: "/healthz",
+			OperationID: "liveness", Summary: "Report whether the server is alive; 503 if it should be restarted",
+			Responses: map[int][]interface{}{http.StatusOK: {healthStatus{}}},
+			Public:    true,
+			handler:   s.handleHealthz,
+		},
+		{
+			Method: http.MethodGet, Path: "/readyz",
+			OperationID: "readiness", Summary: "Check storage, repositories and a sample of branches; 503 if not ready to serve",
+			Responses: map[int][]interface{}{http.StatusOK: {healthStatus{}}},
+			Public:    true,
+			handler:   s.handleReadyz,
+		},
+		{
+			Method: http.MethodGet, Path: "/health",
+			OperationID: "health", Summary: "Report whether the server is alive (use liveness)",
+			Deprecated: true,
+			Responses:  map[int][]interface{}{http.StatusOK: {healthStatus{}}},
+			Public:     true,
+			handler:    s.handleHealth,
+		},
+		{
+			Method: http.MethodGet, Path: "/openapi.json",
+			OperationID: "openAPI", Summary: "This OpenAPI document",
+			Responses: map[int][]interface{}{http.StatusOK: {map[string]interface{}{}}},
+			Public:    true,
+			handler:   s.handleOpenAPI,
+		},
+		{
+			Method: http.MethodGet, Path: "/metrics",
+			OperationID: "metrics", Summary: "Prometheus metrics",
+			Responses:   map[int][]interface{}{http.StatusOK: {""}},
+			ContentType: "text/plain",
+			handler:     s.handleMetrics,
+		},
+		{
+			Method: http.MethodPost, Path: "/query",
+			OperationID: "legacyQuery", Summary: "Run a SQL statement (use query)",
+			Deprecated: true,
+			Params:     []param{dbParam, branchParam},
+			FormBody:   queryRequest{},
+			Responses:  map[int][]interface{}{http.StatusOK: queryResults},
+			NDJSON:     true,
+			handler:    s.handleQuery,
+		},
+		{
+			Method: http.MethodGet, Path: "/query/next",
+			OperationID: "legacyNextPage", Summary: "Fetch the next page of a paged query (use nextPage)",
+			Deprecated: true,
+			Params:     []param{{Name: "cursor", Description: "Cursor ID", Required: true}},
+			Responses:  map[int][]interface{}{http.StatusOK: {database.QueryResult{}}},
+			Session:    true,
+			handler:    s.handleQueryNext,
+		},
+		{
+			Method: http.MethodPost, Path: "/query/next",
+			OperationID: "legacyNextPagePost", Summary: "Fetch the next page of a paged query (use nextPage)",
+			Deprecated: true,
+			Params:     []param{{Name: "cursor", Description: "Cursor ID", Required: true}},
+			Responses:  map[int][]interface{}{http.StatusOK: {database.QueryResult{}}},
+			Session:    true,
+			handler:    s.handleQueryNext,
+		},
+		{
+			Method: http.MethodGet, Path: "/branch",
+			OperationID: "legacyListBranches", Summary: "List branches with action=list (use listBranches)",
+			Deprecated: true,
+			Params:     []param{dbParam, {Name: "action", Description: "Must be list", Required: true}},
+			Responses:  map[int][]interface{}{http.StatusOK: {branchList{}}},
+			handler:    s.handleBranch,
+		},
+		{
+			Method: http.MethodPost, Path: "/branch",
+			OperationID: "legacyModifyBranch", Summary: "Create or delete a branch with action=create|delete (use createBranch/deleteBranch)",
+			Deprecated: true,
+			Params: []param{
+				dbParam,
+				{Name: "action", Description: "create or delete", Required: true},
+				{Name: "branch", Description: "Branch name", Required: true},
+			},
+			Responses: map[int][]interface{}{http.StatusOK: nil},
+			handler:   s.handleBranch,
+		},
+	}
+}
+
+// handler builds the HTTP handler serving every route.
+func (s *Server) handler() http.Handler {
+	mux, _ := s.mux()
+	return s.withRequestLog(withHTTPClient(withErrorEnvelope(mux)))
+}
+
+// mux registers every route on a new ServeMux. It also returns the
+// registered patterns, which the tests hold against the OpenAPI document.
+func (s *Server) mux() (*http.ServeMux, []string) {
+	mux := http.NewServeMux()
+	var patterns []string
+	seen := make(map[string]bool)
+	for _, rt := range s.routes() {
+		if rt.OperationID == "" || seen[rt.OperationID] {
+			panic(fmt.Sprintf("route %s %s: missing or duplicate operation ID %q", rt.Method, rt.Path, rt.OperationID))
+		}
+		seen[rt.OperationID] = true
+		pattern := rt.Method + " " + rt.Path
+		mux.HandleFunc(pattern, s.metrics.instrument(rt.OperationID, s.withDrain(rt.Session || rt.Public, s.limitClients(rt.Public, s.authorize(rt.Public, s.limitRequests(rt.Public, rt.handler))))))
+		patterns = append(patterns, pattern)
+	}
+	return mux, patterns
+}
@@ -0,0 +1,111 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// specOperation is the part of an OpenAPI operation the routes are held
+// against.
+type specOperation struct {
+	Deprecated bool `json:"deprecated"`
+	Parameters []struct {
+		Name     string `json:"name"`
+		In       string `json:"in"`
+		Required bool   `json:"required"`
+	} `json:"parameters"`
+}
+
+// sampleParams are values that get the handlers of the deprecated routes
+// far enough to announce their successor.
+var sampleParams = map[string]string{
+	"GET action":  "list",
+	"POST action": "delete",
+}
+
+func TestRoutesMatchOpenAPI(t *testing.T) {
+	s := newTestServer(t, nil)
+	handler := s.handler()
+	mux, patterns := s.mux()
+
+	w := do(t, handler, http.MethodGet, "/openapi.json", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("GET /openapi.json: %d %s", w.Code, w.Body)
+	}
+	var doc struct {
+		Paths map[string]map[string]specOperation `json:"paths"`
+	}
+	decode(t, w, &doc)
+
+	specified := make(map[string]specOperation)
+	for path, ops := range doc.Paths {
+		for method, op := range ops {
+			specified[strings.ToUpper(method)+" "+path] = op
+		}
+	}
+
+	registered := make(map[string]bool)
+	for _, pattern := range patterns {
+		if registered[pattern] {
+			t.Errorf("%s is registered twice", pattern)
+		}
+		registered[pattern] = true
+		if _, ok := specified[pattern]; !ok {
+			t.Errorf("%s is served but missing from the OpenAPI document", pattern)
+		}
+	}
+
+	for pattern, op := range specified {
+		if !registered[pattern] {
+			t.Errorf("%s is in the OpenAPI document but not served", pattern)
+			continue
+		}
+		method, path, _ := strings.Cut(pattern, " ")
+
+		// The path parameters must be exactly the wildcards of the pattern.
+		var wildcards, pathParams []string
+		for _, m := range pathParamPattern.FindAllStringSubmatch(path, -1) {
+			wildcards = append(wildcards, m[1])
+		}
+		query := url.Values{}
+		for _, p := range op.Parameters {
+			switch p.In {
+			case "path":
+				if !p.Required {
+					t.Errorf("%s: path parameter %s is not required", pattern, p.Name)
+				}
+				pathParams = append(pathParams, p.Name)
+			case "query":
+				value, ok := sampleParams[method+" "+p.Name]
+				if !ok {
+					value = "x"
+				}
+				query.Set(p.Name, value)
+			}
+		}
+		if !slices.Equal(wildcards, pathParams) {
+			t.Errorf("%s: path parameters %v, want %v", pattern, pathParams, wildcards)
+		}
+
+		// A request for the documented path must reach this pattern.
+		target := pathParamPattern.ReplaceAllString(path, "nosuch")
+		if len(query) > 0 {
+			target += "?" + query.Encode()
+		}
+		r := httptest.NewRequest(method, target, nil)
+		if _, got := mux.Handler(r); got != pattern {
+			t.Errorf("%s %s is routed to %q, want %q", method, target, got, pattern)
+			continue
+		}
+
+		// Deprecated routes point at their successor, others do not.
+		w := do(t, handler, method, target, nil)
+		if got := w.Header().Get("Deprecation") == "true"; got != op.Deprecated {
+			t.Errorf("%s: Deprecation header %v, documented as deprecated %v", pattern, got, op.Deprecated)
+		}
+	}
+}
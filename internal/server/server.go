@@ -2,33 +2,177 @@ package server
 
 import (
 	"context"
+	"crypto/tls"
+	"errors"
 	"fmt"
+	"log/slog"
 	"net"
 	"net/http"
+	"net/url"
+	"os"
+	"strconv"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/bxrne/branchlore/internal/audit"
+	"github.com/bxrne/branchlore/internal/auth"
 	"github.com/bxrne/branchlore/internal/database"
 	"github.com/bxrne/branchlore/internal/git"
+	pb "github.com/bxrne/branchlore/proto/branchlore/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/reflection"
+)
+
+const (
+	defaultCursorIdleTimeout = 5 * time.Minute
+	defaultTxIdleTimeout     = time.Minute
+	defaultMySQLMaxPacket    = 64 << 20
 )
 
 type Config struct {
-	Port     string
-	DataDir  string
-	LogLevel string
+	Port    string
+	DataDir string
+	// LogLevel is debug, info, warn or error, and LogFormat text or json.
+	// Logs are written to standard error.
+	LogLevel          string
+	LogFormat         string
+	QueryTimeout      time.Duration
+	MaxRows           int
+	CursorIdleTimeout time.Duration
+	TxIdleTimeout     time.Duration
+	// PostgresAddr enables the PostgreSQL protocol listener when set, e.g.
+	// ":5432". PostgresPassword, when set, is required from its clients.
+	PostgresAddr     string
+	PostgresPassword string
+	// MySQLAddr enables the MySQL protocol listener when set, e.g. ":3306".
+	// MySQLPassword, when set, is required from its clients.
+	// MySQLMaxPacket is the largest packet, in bytes, a client may send;
+	// zero is 64 MiB.
+	MySQLAddr      string
+	MySQLPassword  string
+	MySQLMaxPacket int64
+	// GRPCAddr enables the gRPC API when set, e.g. ":9090".
+	GRPCAddr string
+	// Auth requires every request to carry an API token from the data
+	// directory's token store.
+	Auth bool
+	// TLSCert and TLSKey serve every listener over TLS when set. With
+	// TLSClientCA clients must also present a certificate signed by it.
+	TLSCert     string
+	TLSKey      string
+	TLSClientCA string
+	// Audit records every statement and branch operation in the data
+	// directory's audit log.
+	Audit bool
+	// MinFreeDisk is the free space, in bytes, the data directory's file
+	// system needs for the server to report ready. HealthCheckSample is how
+	// many branch databases each readiness check runs PRAGMA quick_check on.
+	MinFreeDisk       int64
+	HealthCheckSample int
+	// CommitOnShutdown commits every branch written to when the server
+	// shuts down, if its database file changed since its last commit.
+	CommitOnShutdown bool
+	// Pool bounds the connection pool of every branch.
+	Pool database.PoolConfig
+	// TokenRate and IPRate cap the requests per second, and on the
+	// PostgreSQL and MySQL protocols the statements per second, of each
+	// token and each client address, allowing bursts of TokenBurst and
+	// IPBurst. Zero disables a limit.
+	TokenRate  float64
+	TokenBurst int
+	IPRate     float64
+	IPBurst    int
+	// MaxConcurrentQueries caps the requests and statements running at
+	// once against each database. Zero is unlimited.
+	MaxConcurrentQueries int
+	// Roles restricts the tokens holding the named roles.
+	Roles map[string]RoleLimits
+	// DatabaseQuota and BranchQuota cap the bytes each database and each
+	// branch take on disk. Writes and new branches that would exceed them
+	// fail. Zero disables a quota.
+	DatabaseQuota int64
+	BranchQuota   int64
+	// JanitorInterval is how often expired branches are deleted and the
+	// history no branch reaches any more is pruned. Zero disables it.
+	JanitorInterval time.Duration
+	// EphemeralTTL is how long ephemeral branches created without a TTL
+	// live, and PruneGrace how long the history of a deleted branch is
+	// kept before it is pruned. Zero keeps the defaults of a day and a
+	// minute.
+	EphemeralTTL time.Duration
+	PruneGrace   time.Duration
+	// Databases overrides limits for the named databases.
+	Databases map[string]DatabaseConfig
+}
+
+// DatabaseConfig holds the settings of one database that override the
+// server-wide ones. Zero fields keep the server-wide value.
+type DatabaseConfig struct {
+	QueryTimeout         time.Duration
+	MaxRows              int
+	MaxConcurrentQueries int
+	Quota                int64
+	BranchQuota          int64
+	// BranchQuotas overrides BranchQuota for the named branches.
+	BranchQuotas map[string]int64
 }
 
 type Server struct {
-	config   *Config
-	listener net.Listener
+	// config holds the current configuration, which Reload replaces.
+	config   atomic.Pointer[Config]
+	logger   *slog.Logger
+	logLevel *slog.LevelVar
 	dbMgr    *database.Manager
 	gitMgr   *git.Manager
 	ctx      context.Context
 	cancel   context.CancelFunc
 	wg       sync.WaitGroup
+	// tokenStore is nil while auth is disabled.
+	tokenStore atomic.Pointer[auth.Store]
+	audit      *audit.Log
+	metrics    *metrics
+	rates      *rateLimiter
+	slots      *querySlots
+	prunes     *pruneQueue
+	tls        *tls.Config
+	cursors    *sessionStore[*openCursor]
+	txs        *sessionStore[*openTx]
+	// readiness caches the readiness checks that read storage.
+	readiness probeCache
+
+	pgMu     sync.Mutex
+	pgConns  map[pgKey]*pgConn
+	myMu     sync.Mutex
+	myConns  map[uint32]*myConn
+	myLastID uint32
+
+	// listenMu guards the listeners and servers Start sets up, which
+	// Shutdown stops from another goroutine.
+	listenMu   sync.Mutex
+	listener   net.Listener
+	pgListener net.Listener
+	myListener net.Listener
+	grpcServer *grpc.Server
+	httpServer *http.Server
+
+	// draining is set once Shutdown starts; requests counts the HTTP
+	// requests in flight.
+	draining atomic.Bool
+	requests sync.WaitGroup
 }
 
 func New(config *Config) (*Server, error) {
+	logger, logLevel, err := newLogger(config, os.Stderr)
+	if err != nil {
+		return nil, err
+	}
+	tlsConfig, err := loadTLSConfig(config)
+	if err != nil {
+		return nil, err
+	}
+
 	ctx, cancel := context.WithCancel(context.Background())
 
 	gitMgr, err := git.NewManager(config.DataDir)
@@ -36,56 +180,190 @@ func New(config *Config) (*Server, error) {
 		cancel()
 		return nil, fmt.Errorf("failed to create git manager: %w", err)
 	}
+	gitMgr.SetLogger(logger)
 
 	dbMgr, err := database.NewManager(config.DataDir, gitMgr)
 	if err != nil {
 		cancel()
 		return nil, fmt.Errorf("failed to create database manager: %w", err)
 	}
+	dbMgr.SetLogger(logger)
+	dbMgr.SetPool(config.Pool)
+	dbMgr.Observe(logStatement(logger))
 
-	return &Server{
-		config: config,
-		dbMgr:  dbMgr,
-		gitMgr: gitMgr,
-		ctx:    ctx,
-		cancel: cancel,
-	}, nil
+	s := &Server{
+		logger:   logger,
+		logLevel: logLevel,
+		tls:      tlsConfig,
+		dbMgr:    dbMgr,
+		gitMgr:   gitMgr,
+		ctx:      ctx,
+		cancel:   cancel,
+		rates:    newRateLimiter(),
+		slots:    newQuerySlots(),
+		prunes:   newPruneQueue(),
+		cursors:  newSessionStore[*openCursor](),
+		txs:      newSessionStore[*openTx](),
+		pgConns:  make(map[pgKey]*pgConn),
+		myConns:  make(map[uint32]*myConn),
+	}
+	s.metrics = newMetrics(s)
+	dbMgr.Observe(s.metrics.observeStatement)
+	dbMgr.SetQuota(s.quota)
+	s.config.Store(config)
+	if config.Auth {
+		s.tokenStore.Store(auth.NewStore(config.DataDir))
+	}
+	if config.Audit {
+		s.audit = audit.Open(config.DataDir)
+		dbMgr.Observe(s.recordStatement)
+	}
+	return s, nil
+}
+
+// cfg returns the current configuration. Callers must not modify it.
+func (s *Server) cfg() *Config {
+	return s.config.Load()
+}
+
+// tokens returns the token store, or nil when auth is disabled.
+func (s *Server) tokens() *auth.Store {
+	return s.tokenStore.Load()
 }
 
+// Start listens on the configured addresses and serves HTTP until Shutdown.
+// Called once Shutdown has begun it returns at once.
 func (s *Server) Start() error {
-	listener, err := net.Listen("tcp", ":"+s.config.Port)
+	s.listenMu.Lock()
+	if s.draining.Load() {
+		s.listenMu.Unlock()
+		return nil
+	}
+	err := s.listen()
+	httpServer, listener := s.httpServer, s.listener
+	s.listenMu.Unlock()
 	if err != nil {
-		return fmt.Errorf("failed to listen on port %s: %w", s.config.Port, err)
+		return err
+	}
+
+	if s.tls != nil {
+		err = httpServer.ServeTLS(listener, "", "")
+	} else {
+		err = httpServer.Serve(listener)
+	}
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
+
+// listen opens every listener and starts serving all but HTTP. The caller
+// must hold s.listenMu.
+func (s *Server) listen() error {
+	config := s.cfg()
+	listener, err := net.Listen("tcp", ":"+config.Port)
+	if err != nil {
+		return fmt.Errorf("failed to listen on port %s: %w", config.Port, err)
 	}
 	s.listener = listener
 
-	mux := http.NewServeMux()
-	mux.HandleFunc("/query", s.handleQuery)
-	mux.HandleFunc("/branch", s.handleBranch)
-	mux.HandleFunc("/health", s.handleHealth)
+	if config.PostgresAddr != "" {
+		pgListener, err := net.Listen("tcp", config.PostgresAddr)
+		if err != nil {
+			s.closeListeners()
+			return fmt.Errorf("failed to listen for PostgreSQL on %s: %w", config.PostgresAddr, err)
+		}
+		s.pgListener = pgListener
+
+		s.wg.Add(1)
+		go s.servePostgres(pgListener)
+	}
+
+	if config.MySQLAddr != "" {
+		myListener, err := net.Listen("tcp", config.MySQLAddr)
+		if err != nil {
+			s.closeListeners()
+			return fmt.Errorf("failed to listen for MySQL on %s: %w", config.MySQLAddr, err)
+		}
+		s.myListener = myListener
+
+		s.wg.Add(1)
+		go s.serveMySQL(myListener)
+	}
+
+	if config.GRPCAddr != "" {
+		grpcListener, err := net.Listen("tcp", config.GRPCAddr)
+		if err != nil {
+			s.closeListeners()
+			return fmt.Errorf("failed to listen for gRPC on %s: %w", config.GRPCAddr, err)
+		}
+		s.grpcServer = s.newGRPCServer()
+
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.grpcServer.Serve(grpcListener)
+		}()
+	}
 
-	server := &http.Server{
-		Handler:      mux,
+	s.httpServer = &http.Server{
+		Handler:      s.handler(),
 		ReadTimeout:  30 * time.Second,
 		WriteTimeout: 30 * time.Second,
+		TLSConfig:    s.tls,
 	}
 
-	return server.Serve(listener)
+	s.wg.Add(1)
+	go s.reapSessions()
+	s.wg.Add(1)
+	go s.runJanitor()
+	return nil
 }
 
-func (s *Server) Shutdown() {
-	if s.cancel != nil {
-		s.cancel()
+// newGRPCServer returns a gRPC server for the API with the server's
+// interceptors and TLS configuration.
+func (s *Server) newGRPCServer() *grpc.Server {
+	opts := []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(s.grpcUnaryLog, s.grpcUnaryAuth),
+		grpc.ChainStreamInterceptor(s.grpcStreamLog, s.grpcStreamAuth),
+		grpc.WaitForHandlers(true),
+	}
+	if s.tls != nil {
+		opts = append(opts, grpc.Creds(credentials.NewTLS(s.tls)))
 	}
+	srv := grpc.NewServer(opts...)
+	pb.RegisterBranchloreServer(srv, &grpcService{s: s})
+	reflection.Register(srv)
+	return srv
+}
+
+func (s *Server) closeListeners() {
 	if s.listener != nil {
 		s.listener.Close()
 	}
-	s.wg.Wait()
+	if s.pgListener != nil {
+		s.pgListener.Close()
+	}
+	if s.myListener != nil {
+		s.myListener.Close()
+	}
 }
 
+// queryRequest carries the options of a single query, whether it arrived as
+// form values on the legacy /query endpoint or as a JSON body on /v1.
+type queryRequest struct {
+	Query    string        `json:"query"`
+	Timeout  string        `json:"timeout,omitempty"`
+	Format   string        `json:"format,omitempty"`
+	Stream   string        `json:"stream,omitempty"`
+	PageSize int           `json:"page_size,omitempty"`
+	Args     []interface{} `json:"args,omitempty"`
+}
+
+// handleQuery serves the deprecated /query?db=&branch= endpoint.
 func (s *Server) handleQuery(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		writeErrorCode(w, http.StatusMethodNotAllowed, CodeMethodNotAllowed, "Method not allowed")
 		return
 	}
 
@@ -94,16 +372,61 @@ func (s *Server) handleQuery(w http.ResponseWriter, r *http.Request) {
 	if branch == "" {
 		branch = "main"
 	}
+	deprecated(w, "/v1/databases/"+url.PathEscape(dbName)+"/branches/"+url.PathEscape(branch)+"/query")
 
-	query := r.FormValue("query")
-	if query == "" {
-		http.Error(w, "Query parameter required", http.StatusBadRequest)
+	req := queryRequest{
+		Query:   r.FormValue("query"),
+		Timeout: r.FormValue("timeout"),
+		Format:  r.FormValue("format"),
+		Stream:  r.FormValue("stream"),
+	}
+	if p := r.FormValue("page_size"); p != "" {
+		n, err := strconv.Atoi(p)
+		if err != nil || n <= 0 {
+			writeErrorCode(w, http.StatusBadRequest, CodeInvalidArgument, "Invalid page_size parameter")
+			return
+		}
+		req.PageSize = n
+	}
+
+	s.runQuery(w, r, dbName, branch, req)
+}
+
+func (s *Server) runQuery(w http.ResponseWriter, r *http.Request, dbName, branch string, req queryRequest) {
+	timeout, args, ok := s.prepareQuery(w, r, req, dbName)
+	if !ok {
 		return
 	}
 
-	result, err := s.dbMgr.ExecuteQuery(s.ctx, dbName, branch, query)
+	ctx, err := s.branchContext(r.Context(), dbName, branch)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	r = r.WithContext(ctx)
+
+	if database.IsSelect(req.Query) {
+		switch {
+		case req.Stream != "":
+			s.streamQuery(w, r, dbName, branch, req.Query, args, req.Format, timeout)
+			return
+		case req.PageSize > 0:
+			s.pageQuery(w, r, dbName, branch, req.Query, args, req.Format, req.PageSize, timeout)
+			return
+		}
+	}
+
+	ctx, cancel := s.queryContext(r.Context(), timeout)
+	defer cancel()
+
+	limits := s.limits(r.Context(), dbName)
+	result, err := s.dbMgr.ExecuteQuery(ctx, dbName, branch, req.Query, args, database.QueryOptions{
+		MaxRows:  limits.bufferRows,
+		MaxBytes: limits.maxBytes,
+		Format:   req.Format,
+	})
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Query execution failed: %v", err), http.StatusInternalServerError)
+		writeError(w, err)
 		return
 	}
 
@@ -111,40 +434,136 @@ func (s *Server) handleQuery(w http.ResponseWriter, r *http.Request) {
 	w.Write(result)
 }
 
+// prepareQuery validates req and resolves its arguments and its timeout,
+// which defaults to dbName's and is capped by the caller's roles. It writes
+// an error response and returns false when req is invalid.
+func (s *Server) prepareQuery(w http.ResponseWriter, r *http.Request, req queryRequest, dbName string) (time.Duration, []interface{}, bool) {
+	if req.Query == "" {
+		writeErrorCode(w, http.StatusBadRequest, CodeInvalidArgument, "Query parameter required")
+		return 0, nil, false
+	}
+
+	var requested time.Duration
+	if req.Timeout != "" {
+		d, err := time.ParseDuration(req.Timeout)
+		if err != nil || d <= 0 {
+			writeErrorCode(w, http.StatusBadRequest, CodeInvalidArgument, "Invalid timeout parameter")
+			return 0, nil, false
+		}
+		requested = d
+	}
+	timeout := s.limits(r.Context(), dbName).timeoutFor(requested)
+
+	if req.PageSize < 0 {
+		writeErrorCode(w, http.StatusBadRequest, CodeInvalidArgument, "Invalid page_size parameter")
+		return 0, nil, false
+	}
+
+	if req.Stream != "" && req.Stream != "ndjson" {
+		writeErrorCode(w, http.StatusBadRequest, CodeInvalidArgument, "Invalid stream parameter")
+		return 0, nil, false
+	}
+
+	if !database.ValidFormat(req.Format) {
+		writeErrorCode(w, http.StatusBadRequest, CodeInvalidArgument, "Invalid format parameter")
+		return 0, nil, false
+	}
+
+	args, err := database.DecodeArgs(req.Args)
+	if err != nil {
+		writeError(w, err)
+		return 0, nil, false
+	}
+
+	return timeout, args, true
+}
+
+// queryContext derives the context a single query runs under. It is cancelled
+// when the client disconnects, the timeout elapses or the server shuts down.
+func (s *Server) queryContext(parent context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	var (
+		ctx    context.Context
+		cancel context.CancelFunc
+	)
+	if timeout > 0 {
+		ctx, cancel = context.WithTimeout(parent, timeout)
+	} else {
+		ctx, cancel = context.WithCancel(parent)
+	}
+	stop := context.AfterFunc(s.ctx, cancel)
+	return ctx, func() {
+		stop()
+		cancel()
+	}
+}
+
+// sessionContext derives the context of a cursor or transaction. It
+// outlives r, so it is cancelled with the server context rather than the
+// request's, but keeps the request's values such as its identity and
+// read-only restriction.
+func (s *Server) sessionContext(r *http.Request) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.WithoutCancel(r.Context()))
+	stop := context.AfterFunc(s.ctx, cancel)
+	return ctx, func() {
+		stop()
+		cancel()
+	}
+}
+
+// handleBranch serves the deprecated /branch?db=&action= endpoint.
 func (s *Server) handleBranch(w http.ResponseWriter, r *http.Request) {
 	dbName := r.URL.Query().Get("db")
 	action := r.URL.Query().Get("action")
 	branch := r.URL.Query().Get("branch")
 
+	wantMethod := http.MethodPost
+	if action == "list" {
+		wantMethod = http.MethodGet
+	}
+	if r.Method != wantMethod {
+		w.Header().Set("Allow", wantMethod)
+		writeErrorCode(w, http.StatusMethodNotAllowed, CodeMethodNotAllowed, "Method not allowed")
+		return
+	}
+
 	switch action {
 	case "create":
-		if err := s.gitMgr.CreateBranch(dbName, branch); err != nil {
-			http.Error(w, fmt.Sprintf("Failed to create branch: %v", err), http.StatusInternalServerError)
+		deprecated(w, "/v1/databases/"+url.PathEscape(dbName)+"/branches")
+		if err := s.createBranch(r.Context(), dbName, branch, "", git.BranchMeta{}); err != nil {
+			writeError(w, err)
 			return
 		}
 	case "delete":
-		if err := s.gitMgr.DeleteBranch(dbName, branch); err != nil {
-			http.Error(w, fmt.Sprintf("Failed to delete branch: %v", err), http.StatusInternalServerError)
+		deprecated(w, "/v1/databases/"+url.PathEscape(dbName)+"/branches/"+url.PathEscape(branch))
+		if err := s.deleteBranch(r.Context(), dbName, branch); err != nil {
+			writeError(w, err)
 			return
 		}
 	case "list":
-		branches, err := s.gitMgr.ListBranches(dbName)
+		deprecated(w, "/v1/databases/"+url.PathEscape(dbName)+"/branches")
+		branches, err := s.listBranches(r.Context(), dbName)
 		if err != nil {
-			http.Error(w, fmt.Sprintf("Failed to list branches: %v", err), http.StatusInternalServerError)
+			writeError(w, err)
 			return
 		}
-		w.Header().Set("Content-Type", "application/json")
-		fmt.Fprintf(w, `{"branches": %q}`, branches)
+		writeJSON(w, http.StatusOK, branchList{Branches: nonNil(branches)})
 		return
 	default:
-		http.Error(w, "Invalid action", http.StatusBadRequest)
+		writeErrorCode(w, http.StatusBadRequest, CodeInvalidArgument, "Invalid action")
 		return
 	}
 
 	w.WriteHeader(http.StatusOK)
 }
 
+// deprecated marks a response from a legacy endpoint and points clients at
+// its /v1 replacement.
+func deprecated(w http.ResponseWriter, successor string) {
+	w.Header().Set("Deprecation", "true")
+	w.Header().Set("Link", "<"+successor+`>; rel="successor-version"`)
+}
+
 func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
-	fmt.Fprint(w, `{"status": "healthy"}`)
+	deprecated(w, "/healthz")
+	s.handleHealthz(w, r)
 }
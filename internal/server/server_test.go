@@ -0,0 +1,96 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/bxrne/branchlore/internal/auth"
+)
+
+// newTestServer returns a server over a fresh data directory. config may
+// be nil; its DataDir is always replaced.
+func newTestServer(t *testing.T, config *Config) *Server {
+	t.Helper()
+	if config == nil {
+		config = &Config{}
+	}
+	config.DataDir = t.TempDir()
+	if config.LogLevel == "" {
+		config.LogLevel = "error"
+	}
+	s, err := New(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		s.cancel()
+		s.cursors.closeAll()
+		s.txs.closeAll()
+		s.dbMgr.Close()
+	})
+	return s
+}
+
+// newTestToken creates a token named name with scope on s and returns its
+// secret.
+func newTestToken(t *testing.T, s *Server, name string, scope auth.Scope) string {
+	t.Helper()
+	secret, _, err := auth.NewStore(s.cfg().DataDir).Create(name, []auth.Scope{scope}, nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return secret
+}
+
+// newTestDatabase creates dbName on s.
+func newTestDatabase(t *testing.T, s *Server, dbName string) {
+	t.Helper()
+	if err := s.gitMgr.InitDatabase(dbName); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// do sends a request with an optional JSON body to h and returns the
+// recorded response.
+func do(t *testing.T, h http.Handler, method, target string, body interface{}) *httptest.ResponseRecorder {
+	t.Helper()
+	return doAs(t, h, "", method, target, body)
+}
+
+// doAs is do with token, when not empty, as the bearer token.
+func doAs(t *testing.T, h http.Handler, token, method, target string, body interface{}) *httptest.ResponseRecorder {
+	t.Helper()
+	var buf bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&buf).Encode(body); err != nil {
+			t.Fatal(err)
+		}
+	}
+	r := httptest.NewRequest(method, target, &buf)
+	if token != "" {
+		r.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+// decode unmarshals the JSON body of w into v.
+func decode(t *testing.T, w *httptest.ResponseRecorder, v interface{}) {
+	t.Helper()
+	if err := json.Unmarshal(w.Body.Bytes(), v); err != nil {
+		t.Fatalf("decoding %q: %v", w.Body.String(), err)
+	}
+}
+
+// errorCode returns the code of the error response in w, or "" if w is
+// not one.
+func errorCode(t *testing.T, w *httptest.ResponseRecorder) string {
+	t.Helper()
+	var resp errorResponse
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	return resp.Error.Code
+}
@@ -0,0 +1,156 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/bxrne/branchlore/internal/audit"
+	"github.com/bxrne/branchlore/internal/auth"
+	"github.com/bxrne/branchlore/internal/database"
+	"github.com/bxrne/branchlore/internal/git"
+)
+
+// The operations below back both the HTTP handlers and the gRPC service, so
+// the two APIs behave the same and enforce the same permissions.
+
+// listDatabases lists the databases the caller holds any grant on.
+func (s *Server) listDatabases(ctx context.Context) ([]string, error) {
+	databases, err := s.gitMgr.ListDatabases()
+	if err != nil {
+		return nil, err
+	}
+	return slices.DeleteFunc(databases, func(dbName string) bool {
+		return s.sees(ctx, dbName) != nil
+	}), nil
+}
+
+func (s *Server) createDatabase(ctx context.Context, name string) (err error) {
+	if err := s.allow(ctx, auth.PermAdmin, name, ""); err != nil {
+		return err
+	}
+	defer s.recordOperation(ctx, audit.ActionDatabaseCreate, name, "", nil, time.Now(), &err)
+	return s.gitMgr.InitDatabase(name)
+}
+
+func (s *Server) deleteDatabase(ctx context.Context, name string) (err error) {
+	if err := s.allow(ctx, auth.PermAdmin, name, ""); err != nil {
+		return err
+	}
+	defer s.recordOperation(ctx, audit.ActionDatabaseDelete, name, "", nil, time.Now(), &err)
+	if err := s.dbMgr.DeleteDatabase(name); err != nil {
+		return err
+	}
+	s.metrics.forget(name, "")
+	s.prunes.remove(name)
+	return nil
+}
+
+// listBranches lists the branches of dbName the caller can read.
+func (s *Server) listBranches(ctx context.Context, dbName string) ([]string, error) {
+	if err := s.sees(ctx, dbName); err != nil {
+		return nil, err
+	}
+	branches, err := s.gitMgr.ListBranches(dbName)
+	if err != nil {
+		return nil, err
+	}
+	return slices.DeleteFunc(branches, func(branch string) bool {
+		return s.allow(ctx, auth.PermRead, dbName, branch) != nil
+	}), nil
+}
+
+// createBranch creates branch as a copy of from, or of main when from is
+// empty. The janitor deletes it once meta says it expired.
+func (s *Server) createBranch(ctx context.Context, dbName, branch, from string, meta git.BranchMeta) (err error) {
+	if from == "" {
+		from = "main"
+	}
+	if err := s.allow(ctx, auth.PermBranchCreate, dbName, branch); err != nil {
+		return err
+	}
+	if err := s.allow(ctx, auth.PermRead, dbName, from); err != nil {
+		return err
+	}
+	defer s.recordOperation(ctx, audit.ActionBranchCreate, dbName, branch, &from, time.Now(), &err)
+	return s.dbMgr.ForkBranch(ctx, dbName, branch, from, meta)
+}
+
+// getBranch returns the metadata of dbName@branch, or an error unless it
+// exists and the caller can read it.
+func (s *Server) getBranch(ctx context.Context, dbName, branch string) (git.BranchMeta, error) {
+	if err := s.allow(ctx, auth.PermRead, dbName, branch); err != nil {
+		return git.BranchMeta{}, err
+	}
+	if err := s.checkBranch(dbName, branch); err != nil {
+		return git.BranchMeta{}, err
+	}
+	return s.gitMgr.BranchMeta(dbName, branch)
+}
+
+// checkBranch returns an error unless dbName@branch exists.
+func (s *Server) checkBranch(dbName, branch string) error {
+	branches, err := s.gitMgr.ListBranches(dbName)
+	if err != nil {
+		return err
+	}
+	if !slices.Contains(branches, branch) {
+		return fmt.Errorf("%w: %s", git.ErrBranchNotFound, branch)
+	}
+	return nil
+}
+
+func (s *Server) deleteBranch(ctx context.Context, dbName, branch string) (err error) {
+	if err := s.allow(ctx, auth.PermBranchDelete, dbName, branch); err != nil {
+		return err
+	}
+	defer s.recordOperation(ctx, audit.ActionBranchDelete, dbName, branch, nil, time.Now(), &err)
+	return s.removeBranch(dbName, branch)
+}
+
+// removeBranch deletes dbName@branch with its pooled connections and
+// queues its history for pruning.
+func (s *Server) removeBranch(dbName, branch string) error {
+	if err := s.dbMgr.DeleteBranch(dbName, branch); err != nil {
+		return err
+	}
+	s.metrics.forget(dbName, branch)
+	s.prunes.add(dbName, time.Now())
+	return nil
+}
+
+func (s *Server) commitBranch(ctx context.Context, dbName, branch, message string) (hash string, err error) {
+	if err := s.allow(ctx, auth.PermWrite, dbName, branch); err != nil {
+		return "", err
+	}
+	defer s.recordOperation(ctx, audit.ActionBranchCommit, dbName, branch, &hash, time.Now(), &err)
+	if message == "" {
+		message = "Commit " + branch
+	}
+	return s.dbMgr.CommitBranch(ctx, dbName, branch, message)
+}
+
+// mergeBranch merges from into branch.
+func (s *Server) mergeBranch(ctx context.Context, dbName, branch, from, message string) (_ string, err error) {
+	if err := s.allow(ctx, auth.PermMerge, dbName, branch); err != nil {
+		return "", err
+	}
+	if err := s.allow(ctx, auth.PermRead, dbName, from); err != nil {
+		return "", err
+	}
+	defer s.recordOperation(ctx, audit.ActionBranchMerge, dbName, branch, &from, time.Now(), &err)
+	return s.dbMgr.MergeBranch(ctx, dbName, from, branch, message)
+}
+
+func (s *Server) diffBranches(ctx context.Context, dbName, base, head string) (*database.Diff, error) {
+	if base == "" || head == "" {
+		return nil, fmt.Errorf("%w: base and head branches are required", database.ErrInvalidArgument)
+	}
+	for _, branch := range []string{base, head} {
+		if err := s.allow(ctx, auth.PermRead, dbName, branch); err != nil {
+			return nil, err
+		}
+	}
+	return s.dbMgr.DiffBranches(ctx, dbName, base, head)
+}
@@ -0,0 +1,185 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// session is server-side state that outlives a single request, such as an
+// open cursor or transaction.
+type session interface {
+	// database names the database the session runs against.
+	database() string
+	close()
+}
+
+type sessionEntry[T session] struct {
+	value T
+	// owner is the identity that opened the session, "" with auth
+	// disabled.
+	owner    string
+	lastUsed time.Time
+	busy     bool
+}
+
+// sessionStore keeps sessions under random IDs and hands each one to at most
+// one request at a time, and only to requests of the identity that opened
+// it. To anyone else a session does not exist.
+type sessionStore[T session] struct {
+	mu      sync.Mutex
+	entries map[string]*sessionEntry[T]
+}
+
+func newSessionStore[T session]() *sessionStore[T] {
+	return &sessionStore[T]{entries: make(map[string]*sessionEntry[T])}
+}
+
+// sessionOwner returns the name the sessions opened with ctx are kept
+// under.
+func sessionOwner(ctx context.Context) string {
+	if id := identity(ctx); id != nil {
+		return id.Name
+	}
+	return ""
+}
+
+func (ss *sessionStore[T]) add(owner string, v T) (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	id := hex.EncodeToString(buf)
+
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+
+	ss.entries[id] = &sessionEntry[T]{value: v, owner: owner, lastUsed: time.Now()}
+	return id, nil
+}
+
+// acquire hands out the session for exclusive use until it is released.
+func (ss *sessionStore[T]) acquire(id, owner string) (T, bool) {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+
+	e, ok := ss.entries[id]
+	if !ok || e.owner != owner || e.busy {
+		var zero T
+		return zero, false
+	}
+	e.busy = true
+	return e.value, true
+}
+
+// database returns the database of the session, or "" when owner holds no
+// session id. Unlike acquire it does not mind the session being busy.
+func (ss *sessionStore[T]) database(id, owner string) string {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+
+	e, ok := ss.entries[id]
+	if !ok || e.owner != owner {
+		return ""
+	}
+	return e.value.database()
+}
+
+func (ss *sessionStore[T]) release(id string) {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+
+	if e, ok := ss.entries[id]; ok {
+		e.busy = false
+		e.lastUsed = time.Now()
+	}
+}
+
+// remove forgets the session and closes it.
+func (ss *sessionStore[T]) remove(id string) {
+	ss.mu.Lock()
+	e, ok := ss.entries[id]
+	delete(ss.entries, id)
+	ss.mu.Unlock()
+
+	if ok {
+		e.value.close()
+	}
+}
+
+func (ss *sessionStore[T]) len() int {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	return len(ss.entries)
+}
+
+// expire closes idle sessions that have not been used since before cutoff
+// and returns how many it closed.
+func (ss *sessionStore[T]) expire(cutoff time.Time) int {
+	ss.mu.Lock()
+	var stale []T
+	for id, e := range ss.entries {
+		if !e.busy && e.lastUsed.Before(cutoff) {
+			stale = append(stale, e.value)
+			delete(ss.entries, id)
+		}
+	}
+	ss.mu.Unlock()
+
+	for _, v := range stale {
+		v.close()
+	}
+	return len(stale)
+}
+
+func (ss *sessionStore[T]) closeAll() {
+	ss.expire(time.Now().Add(time.Hour))
+}
+
+func (s *Server) reapSessions() {
+	defer s.wg.Done()
+
+	cursorTTL, txTTL := s.sessionTTLs()
+	interval := min(cursorTTL, txTTL) / 2
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.ctx.Done():
+			s.cursors.closeAll()
+			s.txs.closeAll()
+			return
+		case <-ticker.C:
+			// The timeouts can change on Reload.
+			cursorTTL, txTTL = s.sessionTTLs()
+			if next := min(cursorTTL, txTTL) / 2; next != interval {
+				interval = next
+				ticker.Reset(interval)
+			}
+			cursors := s.cursors.expire(time.Now().Add(-cursorTTL))
+			txs := s.txs.expire(time.Now().Add(-txTTL))
+			s.pruneRates()
+			if cursors > 0 || txs > 0 {
+				s.logger.Debug("Closed idle sessions", slog.Int("cursors", cursors), slog.Int("transactions", txs))
+			}
+		}
+	}
+}
+
+// sessionTTLs returns how long cursors and transactions may sit idle.
+func (s *Server) sessionTTLs() (cursorTTL, txTTL time.Duration) {
+	config := s.cfg()
+	cursorTTL = config.CursorIdleTimeout
+	if cursorTTL <= 0 {
+		cursorTTL = defaultCursorIdleTimeout
+	}
+	txTTL = config.TxIdleTimeout
+	if txTTL <= 0 {
+		txTTL = defaultTxIdleTimeout
+	}
+	return cursorTTL, txTTL
+}
@@ -0,0 +1,59 @@
+package server
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/bxrne/branchlore/internal/auth"
+)
+
+func TestSessionsBelongToTheirIdentity(t *testing.T) {
+	s := newTestServer(t, &Config{Auth: true})
+	newTestDatabase(t, s, "db")
+	alice := newTestToken(t, s, "alice", auth.ScopeWrite)
+	bob := newTestToken(t, s, "bob", auth.ScopeWrite)
+	h := s.handler()
+
+	w := doAs(t, h, alice, http.MethodPost, "/v1/databases/db/branches/main/transactions", nil)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("begin: %d %s", w.Code, w.Body)
+	}
+	var tx txInfo
+	decode(t, w, &tx)
+
+	w = doAs(t, h, alice, http.MethodPost, testQueryPath, queryRequest{Query: "VALUES (1), (2), (3)", PageSize: 1})
+	if w.Code != http.StatusOK {
+		t.Fatalf("paged query: %d %s", w.Code, w.Body)
+	}
+	var page struct {
+		Cursor string `json:"cursor"`
+	}
+	decode(t, w, &page)
+
+	for _, req := range []struct {
+		method, target, code string
+		body                 interface{}
+	}{
+		{http.MethodPost, "/v1/transactions/" + tx.ID + "/query", CodeTxNotFound, queryRequest{Query: "SELECT 1"}},
+		{http.MethodPost, "/v1/transactions/" + tx.ID + "/commit", CodeTxNotFound, nil},
+		{http.MethodPost, "/v1/transactions/" + tx.ID + "/rollback", CodeTxNotFound, nil},
+		{http.MethodGet, "/v1/cursors/" + page.Cursor, CodeCursorNotFound, nil},
+		{http.MethodDelete, "/v1/cursors/" + page.Cursor, CodeCursorNotFound, nil},
+		{http.MethodGet, "/query/next?cursor=" + page.Cursor, CodeCursorNotFound, nil},
+	} {
+		w := doAs(t, h, bob, req.method, req.target, req.body)
+		if w.Code != http.StatusNotFound || errorCode(t, w) != req.code {
+			t.Errorf("%s %s as another identity: %d %s, want 404 %s", req.method, req.target, w.Code, w.Body, req.code)
+		}
+	}
+
+	// The owner can still use both.
+	w = doAs(t, h, alice, http.MethodGet, "/v1/cursors/"+page.Cursor, nil)
+	if w.Code != http.StatusOK {
+		t.Errorf("next page as the owner: %d %s", w.Code, w.Body)
+	}
+	w = doAs(t, h, alice, http.MethodPost, "/v1/transactions/"+tx.ID+"/commit", nil)
+	if w.Code != http.StatusNoContent {
+		t.Errorf("commit as the owner: %d %s", w.Code, w.Body)
+	}
+}
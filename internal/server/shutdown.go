@@ -0,0 +1,238 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/bxrne/branchlore/internal/audit"
+)
+
+// errShuttingDown rejects new work while the server drains.
+var errShuttingDown = errors.New("server is shutting down")
+
+// drainPollInterval is how often Shutdown checks whether open sessions have
+// finished.
+const drainPollInterval = 100 * time.Millisecond
+
+// Shutdown stops the server gracefully. It stops accepting connections and
+// new work at once, then waits until in-flight requests, open transactions
+// and PostgreSQL and MySQL sessions in a transaction have finished, or ctx
+// is done. Whatever is still running then is cancelled and rolled back.
+// Finally, with Config.CommitOnShutdown, every branch written to is
+// committed, and every branch database is checkpointed and closed.
+//
+// An error means the drain was cut short or the databases were not all
+// closed cleanly; the server is stopped either way.
+func (s *Server) Shutdown(ctx context.Context) error {
+	if !s.draining.CompareAndSwap(false, true) {
+		return nil
+	}
+	start := time.Now()
+	s.logger.Info("Shutting down")
+
+	// Start sets up no listener after draining is set.
+	s.listenMu.Lock()
+	listener, pgListener, myListener := s.listener, s.pgListener, s.myListener
+	grpcServer, httpServer := s.grpcServer, s.httpServer
+	s.listenMu.Unlock()
+
+	// HTTP keeps serving requests for open transactions and cursors until
+	// they are drained; the other listeners take no new connections.
+	if pgListener != nil {
+		pgListener.Close()
+	}
+	if myListener != nil {
+		myListener.Close()
+	}
+	s.interruptIdleSessions()
+
+	grpcStopped := make(chan struct{})
+	if grpcServer != nil {
+		go func() {
+			grpcServer.GracefulStop()
+			close(grpcStopped)
+		}()
+	} else {
+		close(grpcStopped)
+	}
+
+	var errs []error
+	if err := s.waitDrained(ctx); err != nil {
+		errs = append(errs, err)
+	}
+	if httpServer != nil {
+		if err := httpServer.Shutdown(ctx); err != nil {
+			httpServer.Close()
+			if len(errs) == 0 {
+				errs = append(errs, fmt.Errorf("HTTP requests still running: %w", err))
+			}
+		}
+	} else if listener != nil {
+		listener.Close()
+	}
+
+	// Cancel what is left: the session reaper rolls back open HTTP
+	// transactions and closes cursors, and wire protocol sessions close
+	// their connections and roll back.
+	s.cancel()
+	if grpcServer != nil {
+		select {
+		case <-grpcStopped:
+		case <-ctx.Done():
+			grpcServer.Stop()
+		}
+	}
+	s.requests.Wait()
+	s.wg.Wait()
+
+	if s.cfg().CommitOnShutdown {
+		s.commitSnapshots(context.WithoutCancel(ctx))
+	}
+	if err := s.dbMgr.Close(); err != nil {
+		errs = append(errs, err)
+	}
+	if s.audit != nil {
+		s.audit.Close()
+	}
+
+	err := errors.Join(errs...)
+	if err != nil {
+		s.logger.Warn("Shut down uncleanly", slog.Duration("duration", time.Since(start)), slog.Any("error", err))
+	} else {
+		s.logger.Info("Shut down", slog.Duration("duration", time.Since(start)))
+	}
+	return err
+}
+
+// waitDrained waits until no HTTP transaction and no PostgreSQL or MySQL
+// session is left, or ctx is done. Sessions outside a transaction end as
+// soon as their current command has been answered.
+func (s *Server) waitDrained(ctx context.Context) error {
+	ticker := time.NewTicker(drainPollInterval)
+	defer ticker.Stop()
+	for {
+		txs, sessions := s.txs.len(), s.wireSessions()
+		if txs == 0 && sessions == 0 {
+			return nil
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return fmt.Errorf("%d transactions and %d PostgreSQL or MySQL sessions still open: %w", txs, sessions, ctx.Err())
+		}
+	}
+}
+
+func (s *Server) wireSessions() int {
+	s.pgMu.Lock()
+	n := len(s.pgConns)
+	s.pgMu.Unlock()
+	s.myMu.Lock()
+	n += len(s.myConns)
+	s.myMu.Unlock()
+	return n
+}
+
+// interruptIdleSessions ends the PostgreSQL and MySQL sessions waiting for a
+// command outside a transaction.
+func (s *Server) interruptIdleSessions() {
+	s.pgMu.Lock()
+	for _, c := range s.pgConns {
+		c.idle.interrupt()
+	}
+	s.pgMu.Unlock()
+	s.myMu.Lock()
+	for _, c := range s.myConns {
+		c.idle.interrupt()
+	}
+	s.myMu.Unlock()
+}
+
+// commitSnapshots commits every branch opened for writing whose database
+// file changed since its last commit.
+func (s *Server) commitSnapshots(ctx context.Context) {
+	for _, pool := range s.dbMgr.Stats() {
+		if pool.ReadOnly {
+			continue
+		}
+		var err error
+		var hash string
+		func() {
+			defer s.recordOperation(ctx, audit.ActionBranchCommit, pool.Database, pool.Branch, &hash, time.Now(), &err)
+			hash, err = s.dbMgr.CommitBranch(ctx, pool.Database, pool.Branch, "Snapshot on shutdown")
+		}()
+		if err != nil {
+			s.logger.Error("Failed to commit snapshot", slog.String("db", pool.Database), slog.String("branch", pool.Branch), slog.Any("error", err))
+		}
+	}
+}
+
+// withDrain rejects requests that would start new work while the server
+// shuts down, telling clients to reconnect elsewhere. Requests continuing
+// a transaction or cursor, and public ones such as health checks, are
+// still served. It also counts in-flight requests so Shutdown can wait for
+// them.
+func (s *Server) withDrain(continues bool, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s.requests.Add(1)
+		defer s.requests.Done()
+		if s.draining.Load() {
+			w.Header().Set("Connection", "close")
+			if !continues {
+				writeError(w, errShuttingDown)
+				return
+			}
+		}
+		next(w, r)
+	}
+}
+
+// idleGate lets Shutdown end a PostgreSQL or MySQL session while it waits
+// for the client's next command, without cutting off a command or a
+// transaction in progress.
+type idleGate struct {
+	mu          sync.Mutex
+	conn        net.Conn
+	idle        bool
+	interrupted bool
+}
+
+// wait is called before reading the next command. closable reports whether
+// the session is between commands and outside a transaction, so it may be
+// ended. wait returns false if it should end now because the server is
+// shutting down.
+func (g *idleGate) wait(s *Server, closable bool) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if closable && s.draining.Load() {
+		return false
+	}
+	g.idle = closable
+	return true
+}
+
+// resume is called once reading the command returned. It returns false if
+// Shutdown interrupted the read, in which case the session should end
+// without running the command.
+func (g *idleGate) resume() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.idle = false
+	return !g.interrupted
+}
+
+// interrupt makes the pending read of an idle session fail.
+func (g *idleGate) interrupt() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.idle && g.conn != nil {
+		g.interrupted = true
+		g.conn.SetReadDeadline(time.Now())
+	}
+}
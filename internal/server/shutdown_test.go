@@ -0,0 +1,121 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestShutdownWhileStarting(t *testing.T) {
+	for _, delay := range []time.Duration{0, 10 * time.Millisecond} {
+		s := newTestServer(t, &Config{Port: "0", MySQLAddr: "127.0.0.1:0", PostgresAddr: "127.0.0.1:0"})
+
+		started := make(chan error, 1)
+		go func() { started <- s.Start() }()
+		time.Sleep(delay)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		if err := s.Shutdown(ctx); err != nil {
+			t.Errorf("Shutdown after %s: %v", delay, err)
+		}
+		cancel()
+		select {
+		case err := <-started:
+			if err != nil {
+				t.Errorf("Start with Shutdown after %s: %v", delay, err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Start did not return after Shutdown after %s", delay)
+		}
+	}
+}
+
+func TestDrainRejectsNewWork(t *testing.T) {
+	s := newTestServer(t, nil)
+	newTestDatabase(t, s, "db")
+	h := s.handler()
+
+	w := do(t, h, http.MethodPost, "/v1/databases/db/branches/main/transactions", nil)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("begin: %d %s", w.Code, w.Body)
+	}
+	var tx txInfo
+	decode(t, w, &tx)
+
+	s.draining.Store(true)
+	w = do(t, h, http.MethodPost, testQueryPath, queryRequest{Query: "SELECT 1"})
+	if w.Code != http.StatusServiceUnavailable || errorCode(t, w) != CodeShuttingDown || w.Header().Get("Connection") != "close" {
+		t.Errorf("new query while draining: %d %s, Connection %q", w.Code, w.Body, w.Header().Get("Connection"))
+	}
+	w = do(t, h, http.MethodPost, "/v1/transactions/"+tx.ID+"/query", queryRequest{Query: "SELECT 1"})
+	if w.Code != http.StatusOK || w.Header().Get("Connection") != "close" {
+		t.Errorf("transaction query while draining: %d %s", w.Code, w.Body)
+	}
+	if w := do(t, h, http.MethodPost, "/v1/transactions/"+tx.ID+"/commit", nil); w.Code != http.StatusNoContent {
+		t.Errorf("commit while draining: %d %s", w.Code, w.Body)
+	}
+}
+
+func TestShutdownWaitsForTransactions(t *testing.T) {
+	s := newTestServer(t, &Config{CommitOnShutdown: true})
+	newTestDatabase(t, s, "db")
+	h := s.handler()
+	head, err := s.gitMgr.Head("db", "main")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := do(t, h, http.MethodPost, "/v1/databases/db/branches/main/transactions", nil)
+	var tx txInfo
+	decode(t, w, &tx)
+	do(t, h, http.MethodPost, "/v1/transactions/"+tx.ID+"/query", queryRequest{Query: "CREATE TABLE t (a INTEGER)"})
+
+	done := make(chan error, 1)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		done <- s.Shutdown(ctx)
+	}()
+	select {
+	case err := <-done:
+		t.Fatalf("Shutdown returned with a transaction open: %v", err)
+	case <-time.After(3 * drainPollInterval):
+	}
+
+	if w := do(t, h, http.MethodPost, "/v1/transactions/"+tx.ID+"/commit", nil); w.Code != http.StatusNoContent {
+		t.Fatalf("commit while draining: %d %s", w.Code, w.Body)
+	}
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Shutdown after the transaction finished: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Shutdown did not return after the transaction finished")
+	}
+
+	// The committed table was snapshotted into the branch's history.
+	if after, err := s.gitMgr.Head("db", "main"); err != nil || after == head {
+		t.Errorf("head of main after shutdown = %s, %v, want a new snapshot commit", after, err)
+	}
+}
+
+func TestShutdownTimesOutOnOpenTransactions(t *testing.T) {
+	s := newTestServer(t, nil)
+	newTestDatabase(t, s, "db")
+	h := s.handler()
+	if w := do(t, h, http.MethodPost, "/v1/databases/db/branches/main/transactions", nil); w.Code != http.StatusCreated {
+		t.Fatalf("begin: %d %s", w.Code, w.Body)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := s.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Shutdown with an open transaction: %v, want a deadline error", err)
+	}
+	if err := s.Shutdown(context.Background()); err != nil {
+		t.Errorf("second Shutdown: %v, want nil", err)
+	}
+}
@@ -0,0 +1,85 @@
+package server
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/bxrne/branchlore/internal/database"
+)
+
+// splitStatements splits a semicolon separated script into statements,
+// ignoring semicolons inside literals, comments and trigger bodies.
+func splitStatements(q string) []string {
+	var stmts []string
+	add := func(stmt string) {
+		if stmt = strings.TrimSpace(stmt); !onlyComments(stmt) {
+			stmts = append(stmts, stmt)
+		}
+	}
+
+	start := 0
+	for i := 0; i < len(q); {
+		if j := database.SkipLiteral(q, i); j > i {
+			i = j
+			continue
+		}
+		if q[i] == ';' && !inTriggerBody(q[start:i]) {
+			add(q[start:i])
+			start = i + 1
+		}
+		i++
+	}
+	add(q[start:])
+	return stmts
+}
+
+// inTriggerBody reports whether stmt is a CREATE TRIGGER whose BEGIN ... END
+// body is still open, so a following semicolon does not end it.
+func inTriggerBody(stmt string) bool {
+	words := database.Keywords(stmt, 4)
+	if len(words) < 2 || words[0] != "CREATE" {
+		return false
+	}
+	if words[1] == "TEMP" || words[1] == "TEMPORARY" {
+		words = words[1:]
+	}
+	if len(words) < 2 || words[1] != "TRIGGER" {
+		return false
+	}
+	fields := strings.Fields(strings.ToUpper(stmt))
+	return len(fields) == 0 || fields[len(fields)-1] != "END"
+}
+
+func onlyComments(q string) bool {
+	for i := 0; i < len(q); {
+		if j := database.SkipLiteral(q, i); j > i && (q[i] == '-' || q[i] == '/') {
+			i = j
+			continue
+		}
+		if !unicode.IsSpace(rune(q[i])) {
+			return false
+		}
+		i++
+	}
+	return true
+}
+
+// rewriteDollarParams turns PostgreSQL style $1 placeholders into SQLite's
+// equivalent ?1, which binds by position rather than by name.
+func rewriteDollarParams(q string) string {
+	var b strings.Builder
+	for i := 0; i < len(q); {
+		if j := database.SkipLiteral(q, i); j > i {
+			b.WriteString(q[i:j])
+			i = j
+			continue
+		}
+		if q[i] == '$' && i+1 < len(q) && q[i+1] >= '0' && q[i+1] <= '9' {
+			b.WriteByte('?')
+		} else {
+			b.WriteByte(q[i])
+		}
+		i++
+	}
+	return b.String()
+}
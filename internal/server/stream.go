@@ -0,0 +1,134 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// streamQuery writes the result of a SELECT as newline delimited JSON: a
+// header object with the columns, one array per row and a trailer object
+// with the row count or the error that ended the stream. In FormatTyped the
+// header also lists the declared column types, and the trailer of a result
+// cut off by the caller's role limits has "truncated": true. SQLite reports
+// most errors only when the first row is read, so the header is held back
+// until then and errors up to that point get a regular error response.
+//
+// Exports can run far longer than any statement timeout, so timeout bounds
+// the wait for the first row and then for each batch of rows, the way the
+// write deadline is pushed out, rather than the whole stream.
+func (s *Server) streamQuery(w http.ResponseWriter, r *http.Request, dbName, branch, query string, args []interface{}, format string, timeout time.Duration) {
+	ctx, cancel := s.queryContext(r.Context(), 0)
+	defer cancel()
+	stall := newStallTimer(timeout, cancel)
+	defer stall.stop()
+
+	cursor, err := s.dbMgr.OpenCursor(ctx, dbName, branch, query, args, format)
+	if err != nil {
+		writeError(w, stall.err(err))
+		return
+	}
+	defer cursor.Close()
+	s.limits(r.Context(), dbName).limitCursor(cursor)
+
+	more := cursor.Next()
+	if !more {
+		if err := cursor.Err(); err != nil {
+			writeError(w, stall.err(err))
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	enc := json.NewEncoder(w)
+	rc := http.NewResponseController(w)
+
+	header := map[string]interface{}{"columns": cursor.Columns()}
+	if types := cursor.ColumnTypes(); types != nil {
+		header["column_types"] = types
+	}
+	if err := enc.Encode(header); err != nil {
+		return
+	}
+
+	const flushEvery = 500
+	count := 0
+	for ; more; more = cursor.Next() {
+		row, err := cursor.Row()
+		if err != nil {
+			writeTrailerError(enc, stall.err(err), count)
+			return
+		}
+		if count%flushEvery == 0 {
+			// Long exports outlive the server's write timeout and the
+			// statement timeout; keep pushing both out while rows are still
+			// being produced.
+			rc.SetWriteDeadline(time.Now().Add(30 * time.Second))
+			rc.Flush()
+			stall.reset()
+		}
+		if err := enc.Encode(row); err != nil {
+			return
+		}
+		count++
+	}
+
+	if err := cursor.Err(); err != nil {
+		writeTrailerError(enc, stall.err(err), count)
+		return
+	}
+	trailer := map[string]interface{}{"row_count": count}
+	if cursor.Truncated() {
+		trailer["truncated"] = true
+	}
+	enc.Encode(trailer)
+}
+
+func writeTrailerError(enc *json.Encoder, err error, count int) {
+	_, body := classify(err)
+	enc.Encode(map[string]interface{}{"error": body, "row_count": count})
+}
+
+// stallTimer cancels a streamed query that goes longer than its timeout
+// without producing rows. A zero timeout never fires.
+type stallTimer struct {
+	timeout time.Duration
+	timer   *time.Timer
+	fired   atomic.Bool
+}
+
+func newStallTimer(timeout time.Duration, cancel context.CancelFunc) *stallTimer {
+	t := &stallTimer{timeout: timeout}
+	if timeout > 0 {
+		t.timer = time.AfterFunc(timeout, func() {
+			t.fired.Store(true)
+			cancel()
+		})
+	}
+	return t
+}
+
+// reset gives the query another timeout to produce its next rows.
+func (t *stallTimer) reset() {
+	if t.timer != nil {
+		t.timer.Reset(t.timeout)
+	}
+}
+
+func (t *stallTimer) stop() {
+	if t.timer != nil {
+		t.timer.Stop()
+	}
+}
+
+// err returns err, or a timeout if the timer cancelled the query: the
+// cursor only sees the cancellation.
+func (t *stallTimer) err(err error) error {
+	if t.fired.Load() {
+		return fmt.Errorf("%w: query produced no rows for %s", context.DeadlineExceeded, t.timeout)
+	}
+	return err
+}
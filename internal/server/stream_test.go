@@ -0,0 +1,118 @@
+package server
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bxrne/branchlore/internal/auth"
+)
+
+// ndjson decodes each line of body.
+func ndjson(t *testing.T, body string) []json.RawMessage {
+	t.Helper()
+	var lines []json.RawMessage
+	sc := bufio.NewScanner(strings.NewReader(body))
+	for sc.Scan() {
+		var line json.RawMessage
+		if err := json.Unmarshal(sc.Bytes(), &line); err != nil {
+			t.Fatalf("line %q: %v", sc.Text(), err)
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+func TestStreamQuery(t *testing.T) {
+	s := newTestServer(t, nil)
+	newTestDatabase(t, s, "db")
+	h := s.handler()
+
+	w := do(t, h, http.MethodPost, testQueryPath, queryRequest{Query: "VALUES (1, 'a'), (2, 'b'), (3, 'c')", Stream: "ndjson"})
+	if w.Code != http.StatusOK {
+		t.Fatalf("%d %s", w.Code, w.Body)
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "application/x-ndjson" {
+		t.Errorf("Content-Type = %q", ct)
+	}
+	lines := ndjson(t, w.Body.String())
+	if len(lines) != 5 {
+		t.Fatalf("got %d lines, want a header, 3 rows and a trailer:\n%s", len(lines), w.Body)
+	}
+	var header struct {
+		Columns []string `json:"columns"`
+	}
+	json.Unmarshal(lines[0], &header)
+	if len(header.Columns) != 2 {
+		t.Errorf("header %s, want two columns", lines[0])
+	}
+	if string(lines[2]) != `[2,"b"]` {
+		t.Errorf("second row %s", lines[2])
+	}
+	var trailer struct {
+		RowCount int `json:"row_count"`
+	}
+	json.Unmarshal(lines[4], &trailer)
+	if trailer.RowCount != 3 {
+		t.Errorf("trailer %s, want row_count 3", lines[4])
+	}
+}
+
+func TestStreamQueryErrorBeforeFirstRow(t *testing.T) {
+	s := newTestServer(t, nil)
+	newTestDatabase(t, s, "db")
+
+	w := do(t, s.handler(), http.MethodPost, testQueryPath, queryRequest{Query: "SELECT * FROM missing", Stream: "ndjson"})
+	if w.Code != http.StatusBadRequest || errorCode(t, w) != "SQLITE_ERROR" {
+		t.Fatalf("%d %s, want 400 SQLITE_ERROR", w.Code, w.Body)
+	}
+	if ct := w.Header().Get("Content-Type"); ct == "application/x-ndjson" {
+		t.Error("error response sent as a stream")
+	}
+}
+
+func TestStreamQueryOutlivesTimeout(t *testing.T) {
+	s := newTestServer(t, nil)
+	newTestDatabase(t, s, "db")
+
+	// The timeout bounds the wait for each batch of rows, not the export.
+	const rows = 200000
+	query := fmt.Sprintf("WITH RECURSIVE c(x) AS (SELECT 1 UNION ALL SELECT x + 1 FROM c LIMIT %d) SELECT x FROM c", rows)
+	start := time.Now()
+	w := do(t, s.handler(), http.MethodPost, testQueryPath, queryRequest{Query: query, Timeout: "20ms", Stream: "ndjson"})
+	if w.Code != http.StatusOK {
+		t.Fatalf("%d %s", w.Code, w.Body)
+	}
+	lines := ndjson(t, w.Body.String())
+	if trailer := string(lines[len(lines)-1]); trailer != fmt.Sprintf(`{"row_count":%d}`, rows) {
+		t.Fatalf("trailer %s, want all %d rows", trailer, rows)
+	}
+	if d := time.Since(start); d < 20*time.Millisecond {
+		t.Skipf("the stream took %v, less than its timeout", d)
+	}
+}
+
+func TestStreamQueryTruncatedByRole(t *testing.T) {
+	s := newTestServer(t, &Config{Auth: true, Roles: map[string]RoleLimits{"small": {MaxRows: 2}}})
+	newTestDatabase(t, s, "db")
+	if err := s.tokens().Grant("small", auth.Grant{Database: "*", Branches: "*", Permissions: []auth.Permission{auth.PermRead}}); err != nil {
+		t.Fatal(err)
+	}
+	secret, _, err := s.tokens().Create("app", nil, []string{"small"}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := doAs(t, s.handler(), secret, http.MethodPost, testQueryPath, queryRequest{Query: "VALUES (1), (2), (3)", Stream: "ndjson"})
+	if w.Code != http.StatusOK {
+		t.Fatalf("%d %s", w.Code, w.Body)
+	}
+	lines := ndjson(t, w.Body.String())
+	if len(lines) != 4 || string(lines[3]) != `{"row_count":2,"truncated":true}` {
+		t.Fatalf("got\n%s\nwant two rows and a truncated trailer", w.Body)
+	}
+}
@@ -0,0 +1,68 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/bxrne/branchlore/internal/auth"
+)
+
+// loadTLSConfig builds the TLS configuration shared by every listener, or
+// returns nil when TLS is not configured. With a client CA, clients must
+// present a certificate it signed.
+func loadTLSConfig(config *Config) (*tls.Config, error) {
+	if config.TLSCert == "" && config.TLSKey == "" {
+		if config.TLSClientCA != "" {
+			return nil, errors.New("--tls-client-ca requires --tls-cert and --tls-key")
+		}
+		return nil, nil
+	}
+	if config.TLSCert == "" || config.TLSKey == "" {
+		return nil, errors.New("--tls-cert and --tls-key must be given together")
+	}
+
+	cert, err := tls.LoadX509KeyPair(config.TLSCert, config.TLSKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load TLS certificate: %w", err)
+	}
+	tlsConfig := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+
+	if config.TLSClientCA != "" {
+		pem, err := os.ReadFile(config.TLSClientCA)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read client CA: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in client CA %s", config.TLSClientCA)
+		}
+		tlsConfig.ClientCAs = pool
+		tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return tlsConfig, nil
+}
+
+// authenticateCert authenticates a connection by its verified client
+// certificate, whose subject common name names a certificate identity. It
+// returns ctx unchanged if auth has been disabled.
+func (s *Server) authenticateCert(ctx context.Context, state *tls.ConnectionState) (context.Context, error) {
+	tokens := s.tokens()
+	if tokens == nil {
+		return ctx, nil
+	}
+	if state == nil || len(state.VerifiedChains) == 0 {
+		return nil, auth.ErrUnauthenticated
+	}
+	id, err := tokens.AuthenticateCertificate(state.VerifiedChains[0][0].Subject.CommonName)
+	if err != nil {
+		return nil, err
+	}
+	return withIdentity(ctx, id), nil
+}
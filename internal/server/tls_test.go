@@ -0,0 +1,227 @@
+package server
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/bxrne/branchlore/internal/auth"
+)
+
+// testPKI is a CA with a certificate for 127.0.0.1, written to PEM files
+// for the server's config, and client certificates it signed.
+type testPKI struct {
+	ca      *x509.Certificate
+	caKey   *ecdsa.PrivateKey
+	caFile  string
+	cert    string
+	key     string
+	rootCAs *x509.CertPool
+}
+
+func newTestPKI(t *testing.T) *testPKI {
+	t.Helper()
+	dir := t.TempDir()
+	key := newTestKey(t)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &testPKI{ca: ca, caKey: key, caFile: filepath.Join(dir, "ca.pem"), rootCAs: x509.NewCertPool()}
+	p.rootCAs.AddCert(ca)
+	writePEM(t, p.caFile, "CERTIFICATE", der)
+
+	server := p.issue(t, "localhost", x509.ExtKeyUsageServerAuth)
+	p.cert, p.key = filepath.Join(dir, "server.pem"), filepath.Join(dir, "server-key.pem")
+	writePEM(t, p.cert, "CERTIFICATE", server.Certificate[0])
+	keyDER, err := x509.MarshalPKCS8PrivateKey(server.PrivateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writePEM(t, p.key, "PRIVATE KEY", keyDER)
+	return p
+}
+
+// issue returns a certificate for name, valid for 127.0.0.1 too, signed by
+// the CA.
+func (p *testPKI) issue(t *testing.T, name string, usage x509.ExtKeyUsage) tls.Certificate {
+	t.Helper()
+	key := newTestKey(t)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		DNSNames:     []string{name},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, p.ca, &key.PublicKey, p.caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+// clientConfig returns a TLS client config trusting the CA and presenting
+// the client certificates.
+func (p *testPKI) clientConfig(certs ...tls.Certificate) *tls.Config {
+	return &tls.Config{RootCAs: p.rootCAs, Certificates: certs, ServerName: "127.0.0.1"}
+}
+
+func newTestKey(t *testing.T) *ecdsa.PrivateKey {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func writePEM(t *testing.T, path, kind string, der []byte) {
+	t.Helper()
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: kind, Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoadTLSConfig(t *testing.T) {
+	p := newTestPKI(t)
+
+	if config, err := loadTLSConfig(&Config{}); config != nil || err != nil {
+		t.Errorf("without TLS: %v, %v", config, err)
+	}
+	config, err := loadTLSConfig(&Config{TLSCert: p.cert, TLSKey: p.key})
+	if err != nil || config.ClientAuth != tls.NoClientCert || config.MinVersion != tls.VersionTLS12 {
+		t.Errorf("with a certificate: %+v, %v", config, err)
+	}
+	config, err = loadTLSConfig(&Config{TLSCert: p.cert, TLSKey: p.key, TLSClientCA: p.caFile})
+	if err != nil || config.ClientAuth != tls.RequireAndVerifyClientCert || config.ClientCAs == nil {
+		t.Errorf("with a client CA: %+v, %v", config, err)
+	}
+
+	for _, bad := range []*Config{
+		{TLSCert: p.cert},
+		{TLSKey: p.key},
+		{TLSClientCA: p.caFile},
+		{TLSCert: p.cert, TLSKey: p.caFile},
+		{TLSCert: p.cert, TLSKey: p.key, TLSClientCA: p.key},
+		{TLSCert: p.cert, TLSKey: p.key, TLSClientCA: filepath.Join(t.TempDir(), "missing.pem")},
+	} {
+		if _, err := loadTLSConfig(bad); err == nil {
+			t.Errorf("loadTLSConfig(%+v) succeeded", bad)
+		}
+	}
+}
+
+func TestMutualTLS(t *testing.T) {
+	p := newTestPKI(t)
+	s := newTestServer(t, &Config{Auth: true, TLSCert: p.cert, TLSKey: p.key, TLSClientCA: p.caFile})
+	newTestDatabase(t, s, "db")
+	if _, err := s.tokens().AllowCertificate("ci", []auth.Scope{auth.ScopeRead}, nil); err != nil {
+		t.Fatal(err)
+	}
+	secret := newTestToken(t, s, "app", auth.ScopeRead)
+
+	ts := httptest.NewUnstartedServer(s.handler())
+	ts.TLS = s.tls
+	ts.StartTLS()
+	defer ts.Close()
+
+	get := func(config *tls.Config, token string) (int, error) {
+		client := &http.Client{Transport: &http.Transport{TLSClientConfig: config}}
+		defer client.CloseIdleConnections()
+		req, _ := http.NewRequest(http.MethodGet, ts.URL+"/v1/databases", nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return 0, err
+		}
+		resp.Body.Close()
+		return resp.StatusCode, nil
+	}
+
+	ci := p.issue(t, "ci", x509.ExtKeyUsageClientAuth)
+	if code, err := get(p.clientConfig(ci), ""); err != nil || code != http.StatusOK {
+		t.Errorf("with an allowed certificate: %d, %v", code, err)
+	}
+	// A token takes precedence over the certificate.
+	if code, err := get(p.clientConfig(ci), "blt_wrong"); err != nil || code != http.StatusUnauthorized {
+		t.Errorf("with a certificate and a wrong token: %d, %v, want 401", code, err)
+	}
+	other := p.issue(t, "other", x509.ExtKeyUsageClientAuth)
+	if code, err := get(p.clientConfig(other), ""); err != nil || code != http.StatusUnauthorized {
+		t.Errorf("with an unknown certificate: %d, %v, want 401", code, err)
+	}
+	if code, err := get(p.clientConfig(other), secret); err != nil || code != http.StatusOK {
+		t.Errorf("with an unknown certificate and a token: %d, %v", code, err)
+	}
+	if _, err := get(p.clientConfig(), ""); err == nil {
+		t.Error("connected without a client certificate")
+	}
+}
+
+func TestPostgresTLS(t *testing.T) {
+	p := newTestPKI(t)
+	s := newTestServer(t, &Config{Auth: true, TLSCert: p.cert, TLSKey: p.key, TLSClientCA: p.caFile})
+	newTestDatabase(t, s, "db")
+	if _, err := s.tokens().AllowCertificate("ci", []auth.Scope{auth.ScopeRead}, nil); err != nil {
+		t.Fatal(err)
+	}
+	addr := servePostgresTest(t, s)
+
+	// Without TLS the server turns the session away.
+	if _, err := connectPostgres(t, addr, "ci", "", "db@main"); err == nil {
+		t.Fatal("connected without TLS")
+	}
+
+	host, port, _ := net.SplitHostPort(addr)
+	config, err := pgx.ParseConfig("host=" + host + " port=" + port + " user=ci dbname=db@main sslmode=require")
+	if err != nil {
+		t.Fatal(err)
+	}
+	config.TLSConfig = p.clientConfig(p.issue(t, "ci", x509.ExtKeyUsageClientAuth))
+	config.Fallbacks = nil
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	conn, err := pgx.ConnectConfig(ctx, config)
+	if err != nil {
+		t.Fatalf("connect with a client certificate: %v", err)
+	}
+	defer conn.Close(context.Background())
+	var n int
+	if err := conn.QueryRow(ctx, "SELECT 1").Scan(&n); err != nil || n != 1 {
+		t.Errorf("query over TLS: %d, %v", n, err)
+	}
+}
@@ -0,0 +1,128 @@
+package server
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/bxrne/branchlore/internal/database"
+)
+
+// openTx is a transaction kept alive between requests. Idle transactions are
+// rolled back by the session reaper.
+type openTx struct {
+	tx     *database.Tx
+	cancel context.CancelFunc
+}
+
+func (t *openTx) database() string {
+	return t.tx.Database()
+}
+
+func (t *openTx) close() {
+	t.tx.Rollback()
+	t.cancel()
+}
+
+type txInfo struct {
+	ID       string `json:"id"`
+	Database string `json:"database"`
+	Branch   string `json:"branch"`
+}
+
+func (s *Server) handleBeginTx(w http.ResponseWriter, r *http.Request) {
+	dbName, branch := r.PathValue("db"), r.PathValue("branch")
+
+	authed, err := s.branchContext(r.Context(), dbName, branch)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	r = r.WithContext(authed)
+
+	ctx, cancel := s.sessionContext(r)
+	tx, err := s.dbMgr.BeginTx(ctx, dbName, branch)
+	if err != nil {
+		cancel()
+		writeError(w, err)
+		return
+	}
+
+	t := &openTx{tx: tx, cancel: cancel}
+	id, err := s.txs.add(sessionOwner(r.Context()), t)
+	if err != nil {
+		t.close()
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, txInfo{ID: id, Database: dbName, Branch: branch})
+}
+
+func (s *Server) handleTxQuery(w http.ResponseWriter, r *http.Request) {
+	var req queryRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	if req.Stream != "" || req.PageSize != 0 {
+		writeErrorCode(w, http.StatusBadRequest, CodeInvalidArgument, "Streaming and paging are not supported inside transactions")
+		return
+	}
+
+	id := r.PathValue("tx")
+	t, ok := s.txs.acquire(id, sessionOwner(r.Context()))
+	if !ok {
+		writeErrorCode(w, http.StatusNotFound, CodeTxNotFound, "Transaction not found or expired")
+		return
+	}
+	defer s.txs.release(id)
+
+	dbName := t.tx.Database()
+	timeout, args, ok := s.prepareQuery(w, r, req, dbName)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := s.queryContext(r.Context(), timeout)
+	defer cancel()
+
+	limits := s.limits(r.Context(), dbName)
+	result, err := t.tx.ExecuteQuery(ctx, req.Query, args, database.QueryOptions{
+		MaxRows:  limits.bufferRows,
+		MaxBytes: limits.maxBytes,
+		Format:   req.Format,
+	})
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(result)
+}
+
+func (s *Server) handleCommitTx(w http.ResponseWriter, r *http.Request) {
+	s.finishTx(w, r, (*database.Tx).Commit)
+}
+
+func (s *Server) handleRollbackTx(w http.ResponseWriter, r *http.Request) {
+	s.finishTx(w, r, (*database.Tx).Rollback)
+}
+
+func (s *Server) finishTx(w http.ResponseWriter, r *http.Request, finish func(*database.Tx) error) {
+	id := r.PathValue("tx")
+	t, ok := s.txs.acquire(id, sessionOwner(r.Context()))
+	if !ok {
+		writeErrorCode(w, http.StatusNotFound, CodeTxNotFound, "Transaction not found or expired")
+		return
+	}
+
+	err := finish(t.tx)
+	// The transaction is over whether or not finish succeeded; remove rolls
+	// back anything left, which is a no-op after a successful commit.
+	s.txs.remove(id)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,54 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"slices"
+
+	"github.com/bxrne/branchlore/internal/auth"
+	"github.com/bxrne/branchlore/internal/database"
+)
+
+// quota returns the disk quota of dbName@branch under the current
+// configuration.
+func (s *Server) quota(dbName, branch string) database.Quota {
+	config := s.cfg()
+	q := database.Quota{Database: config.DatabaseQuota, Branch: config.BranchQuota}
+	if db, ok := config.Databases[dbName]; ok {
+		if db.Quota != 0 {
+			q.Database = db.Quota
+		}
+		if db.BranchQuota != 0 {
+			q.Branch = db.BranchQuota
+		}
+		if quota, ok := db.BranchQuotas[branch]; ok {
+			q.Branch = quota
+		}
+	}
+	return q
+}
+
+// usage measures dbName, listing only the branches the caller can read.
+// The totals still count every branch.
+func (s *Server) usage(ctx context.Context, dbName string) (*database.Usage, error) {
+	if err := s.sees(ctx, dbName); err != nil {
+		return nil, err
+	}
+	usage, err := s.dbMgr.Usage(dbName)
+	if err != nil {
+		return nil, err
+	}
+	usage.Branches = slices.DeleteFunc(usage.Branches, func(b database.BranchUsage) bool {
+		return s.allow(ctx, auth.PermRead, dbName, b.Branch) != nil
+	})
+	return usage, nil
+}
+
+func (s *Server) handleUsage(w http.ResponseWriter, r *http.Request) {
+	usage, err := s.usage(r.Context(), r.PathValue("db"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, usage)
+}
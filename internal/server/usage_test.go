@@ -0,0 +1,84 @@
+package server
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/bxrne/branchlore/internal/auth"
+	"github.com/bxrne/branchlore/internal/database"
+)
+
+func TestQuotaFor(t *testing.T) {
+	s := newTestServer(t, &Config{
+		DatabaseQuota: 1000,
+		BranchQuota:   100,
+		Databases: map[string]DatabaseConfig{
+			"big": {Quota: 5000, BranchQuota: 500, BranchQuotas: map[string]int64{"main": 2000}},
+		},
+	})
+	tests := []struct {
+		db, branch string
+		want       database.Quota
+	}{
+		{"db", "main", database.Quota{Database: 1000, Branch: 100}},
+		{"big", "dev", database.Quota{Database: 5000, Branch: 500}},
+		{"big", "main", database.Quota{Database: 5000, Branch: 2000}},
+	}
+	for _, tt := range tests {
+		if got := s.quota(tt.db, tt.branch); got != tt.want {
+			t.Errorf("quota(%s, %s) = %+v, want %+v", tt.db, tt.branch, got, tt.want)
+		}
+	}
+}
+
+func TestQuotaExceededResponse(t *testing.T) {
+	s := newTestServer(t, &Config{BranchQuota: 1})
+	newTestDatabase(t, s, "db")
+	h := s.handler()
+
+	// The new branch's file is empty, so the first write is still allowed.
+	if w := do(t, h, http.MethodPost, testQueryPath, queryRequest{Query: "CREATE TABLE t (a INTEGER)"}); w.Code != http.StatusOK {
+		t.Fatalf("first write: %d %s", w.Code, w.Body)
+	}
+	w := do(t, h, http.MethodPost, testQueryPath, queryRequest{Query: "INSERT INTO t VALUES (1)"})
+	if w.Code != http.StatusInsufficientStorage || errorCode(t, w) != "SQLITE_FULL" {
+		t.Errorf("write over the quota: %d %s, want 507 SQLITE_FULL", w.Code, w.Body)
+	}
+	if w := do(t, h, http.MethodPost, testQueryPath, queryRequest{Query: "SELECT 1"}); w.Code != http.StatusOK {
+		t.Errorf("read over the quota: %d %s", w.Code, w.Body)
+	}
+}
+
+func TestUsageRoute(t *testing.T) {
+	s := newTestServer(t, &Config{Auth: true, DatabaseQuota: 1 << 30, BranchQuota: 1 << 20})
+	newTestDatabase(t, s, "db")
+	admin := newTestToken(t, s, "admin", auth.ScopeAdmin)
+	h := s.handler()
+	if w := doAs(t, h, admin, http.MethodPost, "/v1/databases/db/branches", createBranchRequest{Name: "dev"}); w.Code != http.StatusCreated {
+		t.Fatalf("create dev: %d %s", w.Code, w.Body)
+	}
+	if err := s.tokens().Grant("main-only", auth.Grant{Database: "db", Branches: "main", Permissions: []auth.Permission{auth.PermRead}}); err != nil {
+		t.Fatal(err)
+	}
+	reader, _, err := s.tokens().Create("reader", nil, []string{"main-only"}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var usage database.Usage
+	decode(t, doAs(t, h, admin, http.MethodGet, "/v1/databases/db/usage", nil), &usage)
+	if usage.Database != "db" || usage.QuotaBytes != 1<<30 || len(usage.Branches) != 2 || usage.Branches[0].QuotaBytes != 1<<20 {
+		t.Errorf("usage for an admin: %+v", usage)
+	}
+	total := usage.TotalBytes
+
+	// Branches the caller cannot read are left out, but still counted.
+	usage = database.Usage{}
+	decode(t, doAs(t, h, reader, http.MethodGet, "/v1/databases/db/usage", nil), &usage)
+	if len(usage.Branches) != 1 || usage.Branches[0].Branch != "main" || usage.TotalBytes != total {
+		t.Errorf("usage for a reader of main: %+v, want only main and a total of %d", usage, total)
+	}
+	if w := doAs(t, h, reader, http.MethodGet, "/v1/databases/nope/usage", nil); w.Code != http.StatusForbidden {
+		t.Errorf("usage of a database without grants: %d, want 403", w.Code)
+	}
+}
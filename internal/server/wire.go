@@ -0,0 +1,16 @@
+package server
+
+import "strings"
+
+// wireFlushRows is how many data rows the wire protocol front ends buffer
+// before flushing them to the client.
+const wireFlushRows = 256
+
+// splitTarget splits a database name given to a wire protocol front end,
+// "db@branch", into its parts. The branch defaults to main.
+func splitTarget(target string) (dbName, branch string) {
+	if i := strings.Index(target, "@"); i >= 0 {
+		return target[:i], target[i+1:]
+	}
+	return target, "main"
+}
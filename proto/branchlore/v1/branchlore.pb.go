@@ -0,0 +1,2419 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.11
+// 	protoc        (unknown)
+// source: branchlore/v1/branchlore.proto
+
+package branchlorev1
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	durationpb "google.golang.org/protobuf/types/known/durationpb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// Value is a SQLite value. A Value with no kind set is NULL.
+type Value struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Types that are valid to be assigned to Kind:
+	//
+	//	*Value_Integer
+	//	*Value_Real
+	//	*Value_Text
+	//	*Value_Blob
+	Kind          isValue_Kind `protobuf_oneof:"kind"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Value) Reset() {
+	*x = Value{}
+	mi := &file_branchlore_v1_branchlore_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Value) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Value) ProtoMessage() {}
+
+func (x *Value) ProtoReflect() protoreflect.Message {
+	mi := &file_branchlore_v1_branchlore_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Value.ProtoReflect.Descriptor instead.
+func (*Value) Descriptor() ([]byte, []int) {
+	return file_branchlore_v1_branchlore_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *Value) GetKind() isValue_Kind {
+	if x != nil {
+		return x.Kind
+	}
+	return nil
+}
+
+func (x *Value) GetInteger() int64 {
+	if x != nil {
+		if x, ok := x.Kind.(*Value_Integer); ok {
+			return x.Integer
+		}
+	}
+	return 0
+}
+
+func (x *Value) GetReal() float64 {
+	if x != nil {
+		if x, ok := x.Kind.(*Value_Real); ok {
+			return x.Real
+		}
+	}
+	return 0
+}
+
+func (x *Value) GetText() string {
+	if x != nil {
+		if x, ok := x.Kind.(*Value_Text); ok {
+			return x.Text
+		}
+	}
+	return ""
+}
+
+func (x *Value) GetBlob() []byte {
+	if x != nil {
+		if x, ok := x.Kind.(*Value_Blob); ok {
+			return x.Blob
+		}
+	}
+	return nil
+}
+
+type isValue_Kind interface {
+	isValue_Kind()
+}
+
+type Value_Integer struct {
+	Integer int64 `protobuf:"varint,1,opt,name=integer,proto3,oneof"`
+}
+
+type Value_Real struct {
+	Real float64 `protobuf:"fixed64,2,opt,name=real,proto3,oneof"`
+}
+
+type Value_Text struct {
+	Text string `protobuf:"bytes,3,opt,name=text,proto3,oneof"`
+}
+
+type Value_Blob struct {
+	Blob []byte `protobuf:"bytes,4,opt,name=blob,proto3,oneof"`
+}
+
+func (*Value_Integer) isValue_Kind() {}
+
+func (*Value_Real) isValue_Kind() {}
+
+func (*Value_Text) isValue_Kind() {}
+
+func (*Value_Blob) isValue_Kind() {}
+
+type Database struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Name          string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Branches      []string               `protobuf:"bytes,2,rep,name=branches,proto3" json:"branches,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Database) Reset() {
+	*x = Database{}
+	mi := &file_branchlore_v1_branchlore_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Database) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Database) ProtoMessage() {}
+
+func (x *Database) ProtoReflect() protoreflect.Message {
+	mi := &file_branchlore_v1_branchlore_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Database.ProtoReflect.Descriptor instead.
+func (*Database) Descriptor() ([]byte, []int) {
+	return file_branchlore_v1_branchlore_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *Database) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *Database) GetBranches() []string {
+	if x != nil {
+		return x.Branches
+	}
+	return nil
+}
+
+type Branch struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Database      string                 `protobuf:"bytes,1,opt,name=database,proto3" json:"database,omitempty"`
+	Name          string                 `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Branch) Reset() {
+	*x = Branch{}
+	mi := &file_branchlore_v1_branchlore_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Branch) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Branch) ProtoMessage() {}
+
+func (x *Branch) ProtoReflect() protoreflect.Message {
+	mi := &file_branchlore_v1_branchlore_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Branch.ProtoReflect.Descriptor instead.
+func (*Branch) Descriptor() ([]byte, []int) {
+	return file_branchlore_v1_branchlore_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *Branch) GetDatabase() string {
+	if x != nil {
+		return x.Database
+	}
+	return ""
+}
+
+func (x *Branch) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+type ListDatabasesRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListDatabasesRequest) Reset() {
+	*x = ListDatabasesRequest{}
+	mi := &file_branchlore_v1_branchlore_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListDatabasesRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListDatabasesRequest) ProtoMessage() {}
+
+func (x *ListDatabasesRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_branchlore_v1_branchlore_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListDatabasesRequest.ProtoReflect.Descriptor instead.
+func (*ListDatabasesRequest) Descriptor() ([]byte, []int) {
+	return file_branchlore_v1_branchlore_proto_rawDescGZIP(), []int{3}
+}
+
+type ListDatabasesResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Databases     []string               `protobuf:"bytes,1,rep,name=databases,proto3" json:"databases,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListDatabasesResponse) Reset() {
+	*x = ListDatabasesResponse{}
+	mi := &file_branchlore_v1_branchlore_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListDatabasesResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListDatabasesResponse) ProtoMessage() {}
+
+func (x *ListDatabasesResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_branchlore_v1_branchlore_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListDatabasesResponse.ProtoReflect.Descriptor instead.
+func (*ListDatabasesResponse) Descriptor() ([]byte, []int) {
+	return file_branchlore_v1_branchlore_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *ListDatabasesResponse) GetDatabases() []string {
+	if x != nil {
+		return x.Databases
+	}
+	return nil
+}
+
+type CreateDatabaseRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Name          string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CreateDatabaseRequest) Reset() {
+	*x = CreateDatabaseRequest{}
+	mi := &file_branchlore_v1_branchlore_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CreateDatabaseRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CreateDatabaseRequest) ProtoMessage() {}
+
+func (x *CreateDatabaseRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_branchlore_v1_branchlore_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CreateDatabaseRequest.ProtoReflect.Descriptor instead.
+func (*CreateDatabaseRequest) Descriptor() ([]byte, []int) {
+	return file_branchlore_v1_branchlore_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *CreateDatabaseRequest) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+type GetDatabaseRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Name          string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetDatabaseRequest) Reset() {
+	*x = GetDatabaseRequest{}
+	mi := &file_branchlore_v1_branchlore_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetDatabaseRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetDatabaseRequest) ProtoMessage() {}
+
+func (x *GetDatabaseRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_branchlore_v1_branchlore_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetDatabaseRequest.ProtoReflect.Descriptor instead.
+func (*GetDatabaseRequest) Descriptor() ([]byte, []int) {
+	return file_branchlore_v1_branchlore_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *GetDatabaseRequest) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+type DeleteDatabaseRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Name          string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DeleteDatabaseRequest) Reset() {
+	*x = DeleteDatabaseRequest{}
+	mi := &file_branchlore_v1_branchlore_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DeleteDatabaseRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeleteDatabaseRequest) ProtoMessage() {}
+
+func (x *DeleteDatabaseRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_branchlore_v1_branchlore_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeleteDatabaseRequest.ProtoReflect.Descriptor instead.
+func (*DeleteDatabaseRequest) Descriptor() ([]byte, []int) {
+	return file_branchlore_v1_branchlore_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *DeleteDatabaseRequest) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+type DeleteDatabaseResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DeleteDatabaseResponse) Reset() {
+	*x = DeleteDatabaseResponse{}
+	mi := &file_branchlore_v1_branchlore_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DeleteDatabaseResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeleteDatabaseResponse) ProtoMessage() {}
+
+func (x *DeleteDatabaseResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_branchlore_v1_branchlore_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeleteDatabaseResponse.ProtoReflect.Descriptor instead.
+func (*DeleteDatabaseResponse) Descriptor() ([]byte, []int) {
+	return file_branchlore_v1_branchlore_proto_rawDescGZIP(), []int{8}
+}
+
+type ListBranchesRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Database      string                 `protobuf:"bytes,1,opt,name=database,proto3" json:"database,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListBranchesRequest) Reset() {
+	*x = ListBranchesRequest{}
+	mi := &file_branchlore_v1_branchlore_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListBranchesRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListBranchesRequest) ProtoMessage() {}
+
+func (x *ListBranchesRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_branchlore_v1_branchlore_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListBranchesRequest.ProtoReflect.Descriptor instead.
+func (*ListBranchesRequest) Descriptor() ([]byte, []int) {
+	return file_branchlore_v1_branchlore_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *ListBranchesRequest) GetDatabase() string {
+	if x != nil {
+		return x.Database
+	}
+	return ""
+}
+
+type ListBranchesResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Branches      []string               `protobuf:"bytes,1,rep,name=branches,proto3" json:"branches,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListBranchesResponse) Reset() {
+	*x = ListBranchesResponse{}
+	mi := &file_branchlore_v1_branchlore_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListBranchesResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListBranchesResponse) ProtoMessage() {}
+
+func (x *ListBranchesResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_branchlore_v1_branchlore_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListBranchesResponse.ProtoReflect.Descriptor instead.
+func (*ListBranchesResponse) Descriptor() ([]byte, []int) {
+	return file_branchlore_v1_branchlore_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *ListBranchesResponse) GetBranches() []string {
+	if x != nil {
+		return x.Branches
+	}
+	return nil
+}
+
+type CreateBranchRequest struct {
+	state    protoimpl.MessageState `protogen:"open.v1"`
+	Database string                 `protobuf:"bytes,1,opt,name=database,proto3" json:"database,omitempty"`
+	Name     string                 `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	// from is the branch to copy, main when empty.
+	From          string `protobuf:"bytes,3,opt,name=from,proto3" json:"from,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CreateBranchRequest) Reset() {
+	*x = CreateBranchRequest{}
+	mi := &file_branchlore_v1_branchlore_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CreateBranchRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CreateBranchRequest) ProtoMessage() {}
+
+func (x *CreateBranchRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_branchlore_v1_branchlore_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CreateBranchRequest.ProtoReflect.Descriptor instead.
+func (*CreateBranchRequest) Descriptor() ([]byte, []int) {
+	return file_branchlore_v1_branchlore_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *CreateBranchRequest) GetDatabase() string {
+	if x != nil {
+		return x.Database
+	}
+	return ""
+}
+
+func (x *CreateBranchRequest) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *CreateBranchRequest) GetFrom() string {
+	if x != nil {
+		return x.From
+	}
+	return ""
+}
+
+type GetBranchRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Database      string                 `protobuf:"bytes,1,opt,name=database,proto3" json:"database,omitempty"`
+	Name          string                 `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetBranchRequest) Reset() {
+	*x = GetBranchRequest{}
+	mi := &file_branchlore_v1_branchlore_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetBranchRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetBranchRequest) ProtoMessage() {}
+
+func (x *GetBranchRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_branchlore_v1_branchlore_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetBranchRequest.ProtoReflect.Descriptor instead.
+func (*GetBranchRequest) Descriptor() ([]byte, []int) {
+	return file_branchlore_v1_branchlore_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *GetBranchRequest) GetDatabase() string {
+	if x != nil {
+		return x.Database
+	}
+	return ""
+}
+
+func (x *GetBranchRequest) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+type DeleteBranchRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Database      string                 `protobuf:"bytes,1,opt,name=database,proto3" json:"database,omitempty"`
+	Name          string                 `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DeleteBranchRequest) Reset() {
+	*x = DeleteBranchRequest{}
+	mi := &file_branchlore_v1_branchlore_proto_msgTypes[13]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DeleteBranchRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeleteBranchRequest) ProtoMessage() {}
+
+func (x *DeleteBranchRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_branchlore_v1_branchlore_proto_msgTypes[13]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeleteBranchRequest.ProtoReflect.Descriptor instead.
+func (*DeleteBranchRequest) Descriptor() ([]byte, []int) {
+	return file_branchlore_v1_branchlore_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *DeleteBranchRequest) GetDatabase() string {
+	if x != nil {
+		return x.Database
+	}
+	return ""
+}
+
+func (x *DeleteBranchRequest) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+type DeleteBranchResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DeleteBranchResponse) Reset() {
+	*x = DeleteBranchResponse{}
+	mi := &file_branchlore_v1_branchlore_proto_msgTypes[14]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DeleteBranchResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeleteBranchResponse) ProtoMessage() {}
+
+func (x *DeleteBranchResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_branchlore_v1_branchlore_proto_msgTypes[14]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeleteBranchResponse.ProtoReflect.Descriptor instead.
+func (*DeleteBranchResponse) Descriptor() ([]byte, []int) {
+	return file_branchlore_v1_branchlore_proto_rawDescGZIP(), []int{14}
+}
+
+type CommitBranchRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Database      string                 `protobuf:"bytes,1,opt,name=database,proto3" json:"database,omitempty"`
+	Branch        string                 `protobuf:"bytes,2,opt,name=branch,proto3" json:"branch,omitempty"`
+	Message       string                 `protobuf:"bytes,3,opt,name=message,proto3" json:"message,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CommitBranchRequest) Reset() {
+	*x = CommitBranchRequest{}
+	mi := &file_branchlore_v1_branchlore_proto_msgTypes[15]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CommitBranchRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CommitBranchRequest) ProtoMessage() {}
+
+func (x *CommitBranchRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_branchlore_v1_branchlore_proto_msgTypes[15]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CommitBranchRequest.ProtoReflect.Descriptor instead.
+func (*CommitBranchRequest) Descriptor() ([]byte, []int) {
+	return file_branchlore_v1_branchlore_proto_rawDescGZIP(), []int{15}
+}
+
+func (x *CommitBranchRequest) GetDatabase() string {
+	if x != nil {
+		return x.Database
+	}
+	return ""
+}
+
+func (x *CommitBranchRequest) GetBranch() string {
+	if x != nil {
+		return x.Branch
+	}
+	return ""
+}
+
+func (x *CommitBranchRequest) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+type CommitBranchResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Hash          string                 `protobuf:"bytes,1,opt,name=hash,proto3" json:"hash,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CommitBranchResponse) Reset() {
+	*x = CommitBranchResponse{}
+	mi := &file_branchlore_v1_branchlore_proto_msgTypes[16]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CommitBranchResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CommitBranchResponse) ProtoMessage() {}
+
+func (x *CommitBranchResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_branchlore_v1_branchlore_proto_msgTypes[16]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CommitBranchResponse.ProtoReflect.Descriptor instead.
+func (*CommitBranchResponse) Descriptor() ([]byte, []int) {
+	return file_branchlore_v1_branchlore_proto_rawDescGZIP(), []int{16}
+}
+
+func (x *CommitBranchResponse) GetHash() string {
+	if x != nil {
+		return x.Hash
+	}
+	return ""
+}
+
+type MergeBranchRequest struct {
+	state    protoimpl.MessageState `protogen:"open.v1"`
+	Database string                 `protobuf:"bytes,1,opt,name=database,proto3" json:"database,omitempty"`
+	// branch is the branch merged into.
+	Branch        string `protobuf:"bytes,2,opt,name=branch,proto3" json:"branch,omitempty"`
+	From          string `protobuf:"bytes,3,opt,name=from,proto3" json:"from,omitempty"`
+	Message       string `protobuf:"bytes,4,opt,name=message,proto3" json:"message,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *MergeBranchRequest) Reset() {
+	*x = MergeBranchRequest{}
+	mi := &file_branchlore_v1_branchlore_proto_msgTypes[17]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *MergeBranchRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*MergeBranchRequest) ProtoMessage() {}
+
+func (x *MergeBranchRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_branchlore_v1_branchlore_proto_msgTypes[17]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use MergeBranchRequest.ProtoReflect.Descriptor instead.
+func (*MergeBranchRequest) Descriptor() ([]byte, []int) {
+	return file_branchlore_v1_branchlore_proto_rawDescGZIP(), []int{17}
+}
+
+func (x *MergeBranchRequest) GetDatabase() string {
+	if x != nil {
+		return x.Database
+	}
+	return ""
+}
+
+func (x *MergeBranchRequest) GetBranch() string {
+	if x != nil {
+		return x.Branch
+	}
+	return ""
+}
+
+func (x *MergeBranchRequest) GetFrom() string {
+	if x != nil {
+		return x.From
+	}
+	return ""
+}
+
+func (x *MergeBranchRequest) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+type MergeBranchResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Hash          string                 `protobuf:"bytes,1,opt,name=hash,proto3" json:"hash,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *MergeBranchResponse) Reset() {
+	*x = MergeBranchResponse{}
+	mi := &file_branchlore_v1_branchlore_proto_msgTypes[18]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *MergeBranchResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*MergeBranchResponse) ProtoMessage() {}
+
+func (x *MergeBranchResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_branchlore_v1_branchlore_proto_msgTypes[18]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use MergeBranchResponse.ProtoReflect.Descriptor instead.
+func (*MergeBranchResponse) Descriptor() ([]byte, []int) {
+	return file_branchlore_v1_branchlore_proto_rawDescGZIP(), []int{18}
+}
+
+func (x *MergeBranchResponse) GetHash() string {
+	if x != nil {
+		return x.Hash
+	}
+	return ""
+}
+
+type DiffBranchesRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Database      string                 `protobuf:"bytes,1,opt,name=database,proto3" json:"database,omitempty"`
+	Base          string                 `protobuf:"bytes,2,opt,name=base,proto3" json:"base,omitempty"`
+	Head          string                 `protobuf:"bytes,3,opt,name=head,proto3" json:"head,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DiffBranchesRequest) Reset() {
+	*x = DiffBranchesRequest{}
+	mi := &file_branchlore_v1_branchlore_proto_msgTypes[19]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DiffBranchesRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DiffBranchesRequest) ProtoMessage() {}
+
+func (x *DiffBranchesRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_branchlore_v1_branchlore_proto_msgTypes[19]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DiffBranchesRequest.ProtoReflect.Descriptor instead.
+func (*DiffBranchesRequest) Descriptor() ([]byte, []int) {
+	return file_branchlore_v1_branchlore_proto_rawDescGZIP(), []int{19}
+}
+
+func (x *DiffBranchesRequest) GetDatabase() string {
+	if x != nil {
+		return x.Database
+	}
+	return ""
+}
+
+func (x *DiffBranchesRequest) GetBase() string {
+	if x != nil {
+		return x.Base
+	}
+	return ""
+}
+
+func (x *DiffBranchesRequest) GetHead() string {
+	if x != nil {
+		return x.Head
+	}
+	return ""
+}
+
+type Diff struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Base          string                 `protobuf:"bytes,1,opt,name=base,proto3" json:"base,omitempty"`
+	Head          string                 `protobuf:"bytes,2,opt,name=head,proto3" json:"head,omitempty"`
+	Tables        []*TableDiff           `protobuf:"bytes,3,rep,name=tables,proto3" json:"tables,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Diff) Reset() {
+	*x = Diff{}
+	mi := &file_branchlore_v1_branchlore_proto_msgTypes[20]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Diff) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Diff) ProtoMessage() {}
+
+func (x *Diff) ProtoReflect() protoreflect.Message {
+	mi := &file_branchlore_v1_branchlore_proto_msgTypes[20]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Diff.ProtoReflect.Descriptor instead.
+func (*Diff) Descriptor() ([]byte, []int) {
+	return file_branchlore_v1_branchlore_proto_rawDescGZIP(), []int{20}
+}
+
+func (x *Diff) GetBase() string {
+	if x != nil {
+		return x.Base
+	}
+	return ""
+}
+
+func (x *Diff) GetHead() string {
+	if x != nil {
+		return x.Head
+	}
+	return ""
+}
+
+func (x *Diff) GetTables() []*TableDiff {
+	if x != nil {
+		return x.Tables
+	}
+	return nil
+}
+
+// TableDiff describes one table that differs between two branches. A
+// changed row counts as one removed and one added.
+type TableDiff struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Name  string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	// change is "added", "removed" or "schema_changed", or empty when only
+	// rows differ.
+	Change        string `protobuf:"bytes,2,opt,name=change,proto3" json:"change,omitempty"`
+	RowsAdded     int64  `protobuf:"varint,3,opt,name=rows_added,json=rowsAdded,proto3" json:"rows_added,omitempty"`
+	RowsRemoved   int64  `protobuf:"varint,4,opt,name=rows_removed,json=rowsRemoved,proto3" json:"rows_removed,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *TableDiff) Reset() {
+	*x = TableDiff{}
+	mi := &file_branchlore_v1_branchlore_proto_msgTypes[21]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *TableDiff) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TableDiff) ProtoMessage() {}
+
+func (x *TableDiff) ProtoReflect() protoreflect.Message {
+	mi := &file_branchlore_v1_branchlore_proto_msgTypes[21]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TableDiff.ProtoReflect.Descriptor instead.
+func (*TableDiff) Descriptor() ([]byte, []int) {
+	return file_branchlore_v1_branchlore_proto_rawDescGZIP(), []int{21}
+}
+
+func (x *TableDiff) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *TableDiff) GetChange() string {
+	if x != nil {
+		return x.Change
+	}
+	return ""
+}
+
+func (x *TableDiff) GetRowsAdded() int64 {
+	if x != nil {
+		return x.RowsAdded
+	}
+	return 0
+}
+
+func (x *TableDiff) GetRowsRemoved() int64 {
+	if x != nil {
+		return x.RowsRemoved
+	}
+	return 0
+}
+
+type QueryRequest struct {
+	state    protoimpl.MessageState `protogen:"open.v1"`
+	Database string                 `protobuf:"bytes,1,opt,name=database,proto3" json:"database,omitempty"`
+	// branch defaults to main.
+	Branch string   `protobuf:"bytes,2,opt,name=branch,proto3" json:"branch,omitempty"`
+	Query  string   `protobuf:"bytes,3,opt,name=query,proto3" json:"query,omitempty"`
+	Args   []*Value `protobuf:"bytes,4,rep,name=args,proto3" json:"args,omitempty"`
+	// timeout overrides the server's default query timeout.
+	Timeout       *durationpb.Duration `protobuf:"bytes,5,opt,name=timeout,proto3" json:"timeout,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *QueryRequest) Reset() {
+	*x = QueryRequest{}
+	mi := &file_branchlore_v1_branchlore_proto_msgTypes[22]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *QueryRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*QueryRequest) ProtoMessage() {}
+
+func (x *QueryRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_branchlore_v1_branchlore_proto_msgTypes[22]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use QueryRequest.ProtoReflect.Descriptor instead.
+func (*QueryRequest) Descriptor() ([]byte, []int) {
+	return file_branchlore_v1_branchlore_proto_rawDescGZIP(), []int{22}
+}
+
+func (x *QueryRequest) GetDatabase() string {
+	if x != nil {
+		return x.Database
+	}
+	return ""
+}
+
+func (x *QueryRequest) GetBranch() string {
+	if x != nil {
+		return x.Branch
+	}
+	return ""
+}
+
+func (x *QueryRequest) GetQuery() string {
+	if x != nil {
+		return x.Query
+	}
+	return ""
+}
+
+func (x *QueryRequest) GetArgs() []*Value {
+	if x != nil {
+		return x.Args
+	}
+	return nil
+}
+
+func (x *QueryRequest) GetTimeout() *durationpb.Duration {
+	if x != nil {
+		return x.Timeout
+	}
+	return nil
+}
+
+type Column struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Name  string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	// declared_type is the column's declared SQLite type, empty for
+	// expressions.
+	DeclaredType  string `protobuf:"bytes,2,opt,name=declared_type,json=declaredType,proto3" json:"declared_type,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Column) Reset() {
+	*x = Column{}
+	mi := &file_branchlore_v1_branchlore_proto_msgTypes[23]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Column) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Column) ProtoMessage() {}
+
+func (x *Column) ProtoReflect() protoreflect.Message {
+	mi := &file_branchlore_v1_branchlore_proto_msgTypes[23]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Column.ProtoReflect.Descriptor instead.
+func (*Column) Descriptor() ([]byte, []int) {
+	return file_branchlore_v1_branchlore_proto_rawDescGZIP(), []int{23}
+}
+
+func (x *Column) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *Column) GetDeclaredType() string {
+	if x != nil {
+		return x.DeclaredType
+	}
+	return ""
+}
+
+type Row struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Values        []*Value               `protobuf:"bytes,1,rep,name=values,proto3" json:"values,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Row) Reset() {
+	*x = Row{}
+	mi := &file_branchlore_v1_branchlore_proto_msgTypes[24]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Row) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Row) ProtoMessage() {}
+
+func (x *Row) ProtoReflect() protoreflect.Message {
+	mi := &file_branchlore_v1_branchlore_proto_msgTypes[24]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Row.ProtoReflect.Descriptor instead.
+func (*Row) Descriptor() ([]byte, []int) {
+	return file_branchlore_v1_branchlore_proto_rawDescGZIP(), []int{24}
+}
+
+func (x *Row) GetValues() []*Value {
+	if x != nil {
+		return x.Values
+	}
+	return nil
+}
+
+type QueryResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Columns       []*Column              `protobuf:"bytes,1,rep,name=columns,proto3" json:"columns,omitempty"`
+	Rows          []*Row                 `protobuf:"bytes,2,rep,name=rows,proto3" json:"rows,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *QueryResponse) Reset() {
+	*x = QueryResponse{}
+	mi := &file_branchlore_v1_branchlore_proto_msgTypes[25]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *QueryResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*QueryResponse) ProtoMessage() {}
+
+func (x *QueryResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_branchlore_v1_branchlore_proto_msgTypes[25]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use QueryResponse.ProtoReflect.Descriptor instead.
+func (*QueryResponse) Descriptor() ([]byte, []int) {
+	return file_branchlore_v1_branchlore_proto_rawDescGZIP(), []int{25}
+}
+
+func (x *QueryResponse) GetColumns() []*Column {
+	if x != nil {
+		return x.Columns
+	}
+	return nil
+}
+
+func (x *QueryResponse) GetRows() []*Row {
+	if x != nil {
+		return x.Rows
+	}
+	return nil
+}
+
+type ExecRequest struct {
+	state    protoimpl.MessageState `protogen:"open.v1"`
+	Database string                 `protobuf:"bytes,1,opt,name=database,proto3" json:"database,omitempty"`
+	// branch defaults to main.
+	Branch        string               `protobuf:"bytes,2,opt,name=branch,proto3" json:"branch,omitempty"`
+	Query         string               `protobuf:"bytes,3,opt,name=query,proto3" json:"query,omitempty"`
+	Args          []*Value             `protobuf:"bytes,4,rep,name=args,proto3" json:"args,omitempty"`
+	Timeout       *durationpb.Duration `protobuf:"bytes,5,opt,name=timeout,proto3" json:"timeout,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ExecRequest) Reset() {
+	*x = ExecRequest{}
+	mi := &file_branchlore_v1_branchlore_proto_msgTypes[26]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ExecRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ExecRequest) ProtoMessage() {}
+
+func (x *ExecRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_branchlore_v1_branchlore_proto_msgTypes[26]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ExecRequest.ProtoReflect.Descriptor instead.
+func (*ExecRequest) Descriptor() ([]byte, []int) {
+	return file_branchlore_v1_branchlore_proto_rawDescGZIP(), []int{26}
+}
+
+func (x *ExecRequest) GetDatabase() string {
+	if x != nil {
+		return x.Database
+	}
+	return ""
+}
+
+func (x *ExecRequest) GetBranch() string {
+	if x != nil {
+		return x.Branch
+	}
+	return ""
+}
+
+func (x *ExecRequest) GetQuery() string {
+	if x != nil {
+		return x.Query
+	}
+	return ""
+}
+
+func (x *ExecRequest) GetArgs() []*Value {
+	if x != nil {
+		return x.Args
+	}
+	return nil
+}
+
+func (x *ExecRequest) GetTimeout() *durationpb.Duration {
+	if x != nil {
+		return x.Timeout
+	}
+	return nil
+}
+
+type ExecResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	RowsAffected  int64                  `protobuf:"varint,1,opt,name=rows_affected,json=rowsAffected,proto3" json:"rows_affected,omitempty"`
+	LastInsertId  int64                  `protobuf:"varint,2,opt,name=last_insert_id,json=lastInsertId,proto3" json:"last_insert_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ExecResponse) Reset() {
+	*x = ExecResponse{}
+	mi := &file_branchlore_v1_branchlore_proto_msgTypes[27]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ExecResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ExecResponse) ProtoMessage() {}
+
+func (x *ExecResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_branchlore_v1_branchlore_proto_msgTypes[27]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ExecResponse.ProtoReflect.Descriptor instead.
+func (*ExecResponse) Descriptor() ([]byte, []int) {
+	return file_branchlore_v1_branchlore_proto_rawDescGZIP(), []int{27}
+}
+
+func (x *ExecResponse) GetRowsAffected() int64 {
+	if x != nil {
+		return x.RowsAffected
+	}
+	return 0
+}
+
+func (x *ExecResponse) GetLastInsertId() int64 {
+	if x != nil {
+		return x.LastInsertId
+	}
+	return 0
+}
+
+type TransactionRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Types that are valid to be assigned to Request:
+	//
+	//	*TransactionRequest_Begin_
+	//	*TransactionRequest_Statement_
+	//	*TransactionRequest_Commit_
+	//	*TransactionRequest_Rollback_
+	Request       isTransactionRequest_Request `protobuf_oneof:"request"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *TransactionRequest) Reset() {
+	*x = TransactionRequest{}
+	mi := &file_branchlore_v1_branchlore_proto_msgTypes[28]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *TransactionRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TransactionRequest) ProtoMessage() {}
+
+func (x *TransactionRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_branchlore_v1_branchlore_proto_msgTypes[28]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TransactionRequest.ProtoReflect.Descriptor instead.
+func (*TransactionRequest) Descriptor() ([]byte, []int) {
+	return file_branchlore_v1_branchlore_proto_rawDescGZIP(), []int{28}
+}
+
+func (x *TransactionRequest) GetRequest() isTransactionRequest_Request {
+	if x != nil {
+		return x.Request
+	}
+	return nil
+}
+
+func (x *TransactionRequest) GetBegin() *TransactionRequest_Begin {
+	if x != nil {
+		if x, ok := x.Request.(*TransactionRequest_Begin_); ok {
+			return x.Begin
+		}
+	}
+	return nil
+}
+
+func (x *TransactionRequest) GetStatement() *TransactionRequest_Statement {
+	if x != nil {
+		if x, ok := x.Request.(*TransactionRequest_Statement_); ok {
+			return x.Statement
+		}
+	}
+	return nil
+}
+
+func (x *TransactionRequest) GetCommit() *TransactionRequest_Commit {
+	if x != nil {
+		if x, ok := x.Request.(*TransactionRequest_Commit_); ok {
+			return x.Commit
+		}
+	}
+	return nil
+}
+
+func (x *TransactionRequest) GetRollback() *TransactionRequest_Rollback {
+	if x != nil {
+		if x, ok := x.Request.(*TransactionRequest_Rollback_); ok {
+			return x.Rollback
+		}
+	}
+	return nil
+}
+
+type isTransactionRequest_Request interface {
+	isTransactionRequest_Request()
+}
+
+type TransactionRequest_Begin_ struct {
+	Begin *TransactionRequest_Begin `protobuf:"bytes,1,opt,name=begin,proto3,oneof"`
+}
+
+type TransactionRequest_Statement_ struct {
+	Statement *TransactionRequest_Statement `protobuf:"bytes,2,opt,name=statement,proto3,oneof"`
+}
+
+type TransactionRequest_Commit_ struct {
+	Commit *TransactionRequest_Commit `protobuf:"bytes,3,opt,name=commit,proto3,oneof"`
+}
+
+type TransactionRequest_Rollback_ struct {
+	Rollback *TransactionRequest_Rollback `protobuf:"bytes,4,opt,name=rollback,proto3,oneof"`
+}
+
+func (*TransactionRequest_Begin_) isTransactionRequest_Request() {}
+
+func (*TransactionRequest_Statement_) isTransactionRequest_Request() {}
+
+func (*TransactionRequest_Commit_) isTransactionRequest_Request() {}
+
+func (*TransactionRequest_Rollback_) isTransactionRequest_Request() {}
+
+// TransactionResponse answers begin with started and commit or rollback
+// with finished. Each statement is answered with any rows, then done, or
+// with error, which leaves the transaction open.
+type TransactionResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Types that are valid to be assigned to Response:
+	//
+	//	*TransactionResponse_Started_
+	//	*TransactionResponse_Rows
+	//	*TransactionResponse_Done
+	//	*TransactionResponse_Error
+	//	*TransactionResponse_Finished_
+	Response      isTransactionResponse_Response `protobuf_oneof:"response"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *TransactionResponse) Reset() {
+	*x = TransactionResponse{}
+	mi := &file_branchlore_v1_branchlore_proto_msgTypes[29]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *TransactionResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TransactionResponse) ProtoMessage() {}
+
+func (x *TransactionResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_branchlore_v1_branchlore_proto_msgTypes[29]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TransactionResponse.ProtoReflect.Descriptor instead.
+func (*TransactionResponse) Descriptor() ([]byte, []int) {
+	return file_branchlore_v1_branchlore_proto_rawDescGZIP(), []int{29}
+}
+
+func (x *TransactionResponse) GetResponse() isTransactionResponse_Response {
+	if x != nil {
+		return x.Response
+	}
+	return nil
+}
+
+func (x *TransactionResponse) GetStarted() *TransactionResponse_Started {
+	if x != nil {
+		if x, ok := x.Response.(*TransactionResponse_Started_); ok {
+			return x.Started
+		}
+	}
+	return nil
+}
+
+func (x *TransactionResponse) GetRows() *QueryResponse {
+	if x != nil {
+		if x, ok := x.Response.(*TransactionResponse_Rows); ok {
+			return x.Rows
+		}
+	}
+	return nil
+}
+
+func (x *TransactionResponse) GetDone() *ExecResponse {
+	if x != nil {
+		if x, ok := x.Response.(*TransactionResponse_Done); ok {
+			return x.Done
+		}
+	}
+	return nil
+}
+
+func (x *TransactionResponse) GetError() *Error {
+	if x != nil {
+		if x, ok := x.Response.(*TransactionResponse_Error); ok {
+			return x.Error
+		}
+	}
+	return nil
+}
+
+func (x *TransactionResponse) GetFinished() *TransactionResponse_Finished {
+	if x != nil {
+		if x, ok := x.Response.(*TransactionResponse_Finished_); ok {
+			return x.Finished
+		}
+	}
+	return nil
+}
+
+type isTransactionResponse_Response interface {
+	isTransactionResponse_Response()
+}
+
+type TransactionResponse_Started_ struct {
+	Started *TransactionResponse_Started `protobuf:"bytes,1,opt,name=started,proto3,oneof"`
+}
+
+type TransactionResponse_Rows struct {
+	Rows *QueryResponse `protobuf:"bytes,2,opt,name=rows,proto3,oneof"`
+}
+
+type TransactionResponse_Done struct {
+	Done *ExecResponse `protobuf:"bytes,3,opt,name=done,proto3,oneof"`
+}
+
+type TransactionResponse_Error struct {
+	Error *Error `protobuf:"bytes,4,opt,name=error,proto3,oneof"`
+}
+
+type TransactionResponse_Finished_ struct {
+	Finished *TransactionResponse_Finished `protobuf:"bytes,5,opt,name=finished,proto3,oneof"`
+}
+
+func (*TransactionResponse_Started_) isTransactionResponse_Response() {}
+
+func (*TransactionResponse_Rows) isTransactionResponse_Response() {}
+
+func (*TransactionResponse_Done) isTransactionResponse_Response() {}
+
+func (*TransactionResponse_Error) isTransactionResponse_Response() {}
+
+func (*TransactionResponse_Finished_) isTransactionResponse_Response() {}
+
+// Error mirrors the HTTP API's error body.
+type Error struct {
+	state              protoimpl.MessageState `protogen:"open.v1"`
+	Code               string                 `protobuf:"bytes,1,opt,name=code,proto3" json:"code,omitempty"`
+	Message            string                 `protobuf:"bytes,2,opt,name=message,proto3" json:"message,omitempty"`
+	SqliteCode         int32                  `protobuf:"varint,3,opt,name=sqlite_code,json=sqliteCode,proto3" json:"sqlite_code,omitempty"`
+	SqliteExtendedCode int32                  `protobuf:"varint,4,opt,name=sqlite_extended_code,json=sqliteExtendedCode,proto3" json:"sqlite_extended_code,omitempty"`
+	unknownFields      protoimpl.UnknownFields
+	sizeCache          protoimpl.SizeCache
+}
+
+func (x *Error) Reset() {
+	*x = Error{}
+	mi := &file_branchlore_v1_branchlore_proto_msgTypes[30]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Error) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Error) ProtoMessage() {}
+
+func (x *Error) ProtoReflect() protoreflect.Message {
+	mi := &file_branchlore_v1_branchlore_proto_msgTypes[30]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Error.ProtoReflect.Descriptor instead.
+func (*Error) Descriptor() ([]byte, []int) {
+	return file_branchlore_v1_branchlore_proto_rawDescGZIP(), []int{30}
+}
+
+func (x *Error) GetCode() string {
+	if x != nil {
+		return x.Code
+	}
+	return ""
+}
+
+func (x *Error) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+func (x *Error) GetSqliteCode() int32 {
+	if x != nil {
+		return x.SqliteCode
+	}
+	return 0
+}
+
+func (x *Error) GetSqliteExtendedCode() int32 {
+	if x != nil {
+		return x.SqliteExtendedCode
+	}
+	return 0
+}
+
+type TransactionRequest_Begin struct {
+	state    protoimpl.MessageState `protogen:"open.v1"`
+	Database string                 `protobuf:"bytes,1,opt,name=database,proto3" json:"database,omitempty"`
+	// branch defaults to main.
+	Branch        string `protobuf:"bytes,2,opt,name=branch,proto3" json:"branch,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *TransactionRequest_Begin) Reset() {
+	*x = TransactionRequest_Begin{}
+	mi := &file_branchlore_v1_branchlore_proto_msgTypes[31]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *TransactionRequest_Begin) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TransactionRequest_Begin) ProtoMessage() {}
+
+func (x *TransactionRequest_Begin) ProtoReflect() protoreflect.Message {
+	mi := &file_branchlore_v1_branchlore_proto_msgTypes[31]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TransactionRequest_Begin.ProtoReflect.Descriptor instead.
+func (*TransactionRequest_Begin) Descriptor() ([]byte, []int) {
+	return file_branchlore_v1_branchlore_proto_rawDescGZIP(), []int{28, 0}
+}
+
+func (x *TransactionRequest_Begin) GetDatabase() string {
+	if x != nil {
+		return x.Database
+	}
+	return ""
+}
+
+func (x *TransactionRequest_Begin) GetBranch() string {
+	if x != nil {
+		return x.Branch
+	}
+	return ""
+}
+
+type TransactionRequest_Statement struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Query         string                 `protobuf:"bytes,1,opt,name=query,proto3" json:"query,omitempty"`
+	Args          []*Value               `protobuf:"bytes,2,rep,name=args,proto3" json:"args,omitempty"`
+	Timeout       *durationpb.Duration   `protobuf:"bytes,3,opt,name=timeout,proto3" json:"timeout,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *TransactionRequest_Statement) Reset() {
+	*x = TransactionRequest_Statement{}
+	mi := &file_branchlore_v1_branchlore_proto_msgTypes[32]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *TransactionRequest_Statement) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TransactionRequest_Statement) ProtoMessage() {}
+
+func (x *TransactionRequest_Statement) ProtoReflect() protoreflect.Message {
+	mi := &file_branchlore_v1_branchlore_proto_msgTypes[32]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TransactionRequest_Statement.ProtoReflect.Descriptor instead.
+func (*TransactionRequest_Statement) Descriptor() ([]byte, []int) {
+	return file_branchlore_v1_branchlore_proto_rawDescGZIP(), []int{28, 1}
+}
+
+func (x *TransactionRequest_Statement) GetQuery() string {
+	if x != nil {
+		return x.Query
+	}
+	return ""
+}
+
+func (x *TransactionRequest_Statement) GetArgs() []*Value {
+	if x != nil {
+		return x.Args
+	}
+	return nil
+}
+
+func (x *TransactionRequest_Statement) GetTimeout() *durationpb.Duration {
+	if x != nil {
+		return x.Timeout
+	}
+	return nil
+}
+
+type TransactionRequest_Commit struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *TransactionRequest_Commit) Reset() {
+	*x = TransactionRequest_Commit{}
+	mi := &file_branchlore_v1_branchlore_proto_msgTypes[33]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *TransactionRequest_Commit) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TransactionRequest_Commit) ProtoMessage() {}
+
+func (x *TransactionRequest_Commit) ProtoReflect() protoreflect.Message {
+	mi := &file_branchlore_v1_branchlore_proto_msgTypes[33]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TransactionRequest_Commit.ProtoReflect.Descriptor instead.
+func (*TransactionRequest_Commit) Descriptor() ([]byte, []int) {
+	return file_branchlore_v1_branchlore_proto_rawDescGZIP(), []int{28, 2}
+}
+
+type TransactionRequest_Rollback struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *TransactionRequest_Rollback) Reset() {
+	*x = TransactionRequest_Rollback{}
+	mi := &file_branchlore_v1_branchlore_proto_msgTypes[34]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *TransactionRequest_Rollback) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TransactionRequest_Rollback) ProtoMessage() {}
+
+func (x *TransactionRequest_Rollback) ProtoReflect() protoreflect.Message {
+	mi := &file_branchlore_v1_branchlore_proto_msgTypes[34]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TransactionRequest_Rollback.ProtoReflect.Descriptor instead.
+func (*TransactionRequest_Rollback) Descriptor() ([]byte, []int) {
+	return file_branchlore_v1_branchlore_proto_rawDescGZIP(), []int{28, 3}
+}
+
+type TransactionResponse_Started struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *TransactionResponse_Started) Reset() {
+	*x = TransactionResponse_Started{}
+	mi := &file_branchlore_v1_branchlore_proto_msgTypes[35]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *TransactionResponse_Started) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TransactionResponse_Started) ProtoMessage() {}
+
+func (x *TransactionResponse_Started) ProtoReflect() protoreflect.Message {
+	mi := &file_branchlore_v1_branchlore_proto_msgTypes[35]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TransactionResponse_Started.ProtoReflect.Descriptor instead.
+func (*TransactionResponse_Started) Descriptor() ([]byte, []int) {
+	return file_branchlore_v1_branchlore_proto_rawDescGZIP(), []int{29, 0}
+}
+
+type TransactionResponse_Finished struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Committed     bool                   `protobuf:"varint,1,opt,name=committed,proto3" json:"committed,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *TransactionResponse_Finished) Reset() {
+	*x = TransactionResponse_Finished{}
+	mi := &file_branchlore_v1_branchlore_proto_msgTypes[36]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *TransactionResponse_Finished) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TransactionResponse_Finished) ProtoMessage() {}
+
+func (x *TransactionResponse_Finished) ProtoReflect() protoreflect.Message {
+	mi := &file_branchlore_v1_branchlore_proto_msgTypes[36]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TransactionResponse_Finished.ProtoReflect.Descriptor instead.
+func (*TransactionResponse_Finished) Descriptor() ([]byte, []int) {
+	return file_branchlore_v1_branchlore_proto_rawDescGZIP(), []int{29, 1}
+}
+
+func (x *TransactionResponse_Finished) GetCommitted() bool {
+	if x != nil {
+		return x.Committed
+	}
+	return false
+}
+
+var File_branchlore_v1_branchlore_proto protoreflect.FileDescriptor
+
+const file_branchlore_v1_branchlore_proto_rawDesc = "" +
+	"\n" +
+	"\x1ebranchlore/v1/branchlore.proto\x12\rbranchlore.v1\x1a\x1egoogle/protobuf/duration.proto\"m\n" +
+	"\x05Value\x12\x1a\n" +
+	"\ainteger\x18\x01 \x01(\x03H\x00R\ainteger\x12\x14\n" +
+	"\x04real\x18\x02 \x01(\x01H\x00R\x04real\x12\x14\n" +
+	"\x04text\x18\x03 \x01(\tH\x00R\x04text\x12\x14\n" +
+	"\x04blob\x18\x04 \x01(\fH\x00R\x04blobB\x06\n" +
+	"\x04kind\":\n" +
+	"\bDatabase\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x12\x1a\n" +
+	"\bbranches\x18\x02 \x03(\tR\bbranches\"8\n" +
+	"\x06Branch\x12\x1a\n" +
+	"\bdatabase\x18\x01 \x01(\tR\bdatabase\x12\x12\n" +
+	"\x04name\x18\x02 \x01(\tR\x04name\"\x16\n" +
+	"\x14ListDatabasesRequest\"5\n" +
+	"\x15ListDatabasesResponse\x12\x1c\n" +
+	"\tdatabases\x18\x01 \x03(\tR\tdatabases\"+\n" +
+	"\x15CreateDatabaseRequest\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\"(\n" +
+	"\x12GetDatabaseRequest\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\"+\n" +
+	"\x15DeleteDatabaseRequest\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\"\x18\n" +
+	"\x16DeleteDatabaseResponse\"1\n" +
+	"\x13ListBranchesRequest\x12\x1a\n" +
+	"\bdatabase\x18\x01 \x01(\tR\bdatabase\"2\n" +
+	"\x14ListBranchesResponse\x12\x1a\n" +
+	"\bbranches\x18\x01 \x03(\tR\bbranches\"Y\n" +
+	"\x13CreateBranchRequest\x12\x1a\n" +
+	"\bdatabase\x18\x01 \x01(\tR\bdatabase\x12\x12\n" +
+	"\x04name\x18\x02 \x01(\tR\x04name\x12\x12\n" +
+	"\x04from\x18\x03 \x01(\tR\x04from\"B\n" +
+	"\x10GetBranchRequest\x12\x1a\n" +
+	"\bdatabase\x18\x01 \x01(\tR\bdatabase\x12\x12\n" +
+	"\x04name\x18\x02 \x01(\tR\x04name\"E\n" +
+	"\x13DeleteBranchRequest\x12\x1a\n" +
+	"\bdatabase\x18\x01 \x01(\tR\bdatabase\x12\x12\n" +
+	"\x04name\x18\x02 \x01(\tR\x04name\"\x16\n" +
+	"\x14DeleteBranchResponse\"c\n" +
+	"\x13CommitBranchRequest\x12\x1a\n" +
+	"\bdatabase\x18\x01 \x01(\tR\bdatabase\x12\x16\n" +
+	"\x06branch\x18\x02 \x01(\tR\x06branch\x12\x18\n" +
+	"\amessage\x18\x03 \x01(\tR\amessage\"*\n" +
+	"\x14CommitBranchResponse\x12\x12\n" +
+	"\x04hash\x18\x01 \x01(\tR\x04hash\"v\n" +
+	"\x12MergeBranchRequest\x12\x1a\n" +
+	"\bdatabase\x18\x01 \x01(\tR\bdatabase\x12\x16\n" +
+	"\x06branch\x18\x02 \x01(\tR\x06branch\x12\x12\n" +
+	"\x04from\x18\x03 \x01(\tR\x04from\x12\x18\n" +
+	"\amessage\x18\x04 \x01(\tR\amessage\")\n" +
+	"\x13MergeBranchResponse\x12\x12\n" +
+	"\x04hash\x18\x01 \x01(\tR\x04hash\"Y\n" +
+	"\x13DiffBranchesRequest\x12\x1a\n" +
+	"\bdatabase\x18\x01 \x01(\tR\bdatabase\x12\x12\n" +
+	"\x04base\x18\x02 \x01(\tR\x04base\x12\x12\n" +
+	"\x04head\x18\x03 \x01(\tR\x04head\"`\n" +
+	"\x04Diff\x12\x12\n" +
+	"\x04base\x18\x01 \x01(\tR\x04base\x12\x12\n" +
+	"\x04head\x18\x02 \x01(\tR\x04head\x120\n" +
+	"\x06tables\x18\x03 \x03(\v2\x18.branchlore.v1.TableDiffR\x06tables\"y\n" +
+	"\tTableDiff\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x12\x16\n" +
+	"\x06change\x18\x02 \x01(\tR\x06change\x12\x1d\n" +
+	"\n" +
+	"rows_added\x18\x03 \x01(\x03R\trowsAdded\x12!\n" +
+	"\frows_removed\x18\x04 \x01(\x03R\vrowsRemoved\"\xb7\x01\n" +
+	"\fQueryRequest\x12\x1a\n" +
+	"\bdatabase\x18\x01 \x01(\tR\bdatabase\x12\x16\n" +
+	"\x06branch\x18\x02 \x01(\tR\x06branch\x12\x14\n" +
+	"\x05query\x18\x03 \x01(\tR\x05query\x12(\n" +
+	"\x04args\x18\x04 \x03(\v2\x14.branchlore.v1.ValueR\x04args\x123\n" +
+	"\atimeout\x18\x05 \x01(\v2\x19.google.protobuf.DurationR\atimeout\"A\n" +
+	"\x06Column\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x12#\n" +
+	"\rdeclared_type\x18\x02 \x01(\tR\fdeclaredType\"3\n" +
+	"\x03Row\x12,\n" +
+	"\x06values\x18\x01 \x03(\v2\x14.branchlore.v1.ValueR\x06values\"h\n" +
+	"\rQueryResponse\x12/\n" +
+	"\acolumns\x18\x01 \x03(\v2\x15.branchlore.v1.ColumnR\acolumns\x12&\n" +
+	"\x04rows\x18\x02 \x03(\v2\x12.branchlore.v1.RowR\x04rows\"\xb6\x01\n" +
+	"\vExecRequest\x12\x1a\n" +
+	"\bdatabase\x18\x01 \x01(\tR\bdatabase\x12\x16\n" +
+	"\x06branch\x18\x02 \x01(\tR\x06branch\x12\x14\n" +
+	"\x05query\x18\x03 \x01(\tR\x05query\x12(\n" +
+	"\x04args\x18\x04 \x03(\v2\x14.branchlore.v1.ValueR\x04args\x123\n" +
+	"\atimeout\x18\x05 \x01(\v2\x19.google.protobuf.DurationR\atimeout\"Y\n" +
+	"\fExecResponse\x12#\n" +
+	"\rrows_affected\x18\x01 \x01(\x03R\frowsAffected\x12$\n" +
+	"\x0elast_insert_id\x18\x02 \x01(\x03R\flastInsertId\"\x91\x04\n" +
+	"\x12TransactionRequest\x12?\n" +
+	"\x05begin\x18\x01 \x01(\v2'.branchlore.v1.TransactionRequest.BeginH\x00R\x05begin\x12K\n" +
+	"\tstatement\x18\x02 \x01(\v2+.branchlore.v1.TransactionRequest.StatementH\x00R\tstatement\x12B\n" +
+	"\x06commit\x18\x03 \x01(\v2(.branchlore.v1.TransactionRequest.CommitH\x00R\x06commit\x12H\n" +
+	"\brollback\x18\x04 \x01(\v2*.branchlore.v1.TransactionRequest.RollbackH\x00R\brollback\x1a;\n" +
+	"\x05Begin\x12\x1a\n" +
+	"\bdatabase\x18\x01 \x01(\tR\bdatabase\x12\x16\n" +
+	"\x06branch\x18\x02 \x01(\tR\x06branch\x1a\x80\x01\n" +
+	"\tStatement\x12\x14\n" +
+	"\x05query\x18\x01 \x01(\tR\x05query\x12(\n" +
+	"\x04args\x18\x02 \x03(\v2\x14.branchlore.v1.ValueR\x04args\x123\n" +
+	"\atimeout\x18\x03 \x01(\v2\x19.google.protobuf.DurationR\atimeout\x1a\b\n" +
+	"\x06Commit\x1a\n" +
+	"\n" +
+	"\bRollbackB\t\n" +
+	"\arequest\"\xfe\x02\n" +
+	"\x13TransactionResponse\x12F\n" +
+	"\astarted\x18\x01 \x01(\v2*.branchlore.v1.TransactionResponse.StartedH\x00R\astarted\x122\n" +
+	"\x04rows\x18\x02 \x01(\v2\x1c.branchlore.v1.QueryResponseH\x00R\x04rows\x121\n" +
+	"\x04done\x18\x03 \x01(\v2\x1b.branchlore.v1.ExecResponseH\x00R\x04done\x12,\n" +
+	"\x05error\x18\x04 \x01(\v2\x14.branchlore.v1.ErrorH\x00R\x05error\x12I\n" +
+	"\bfinished\x18\x05 \x01(\v2+.branchlore.v1.TransactionResponse.FinishedH\x00R\bfinished\x1a\t\n" +
+	"\aStarted\x1a(\n" +
+	"\bFinished\x12\x1c\n" +
+	"\tcommitted\x18\x01 \x01(\bR\tcommittedB\n" +
+	"\n" +
+	"\bresponse\"\x88\x01\n" +
+	"\x05Error\x12\x12\n" +
+	"\x04code\x18\x01 \x01(\tR\x04code\x12\x18\n" +
+	"\amessage\x18\x02 \x01(\tR\amessage\x12\x1f\n" +
+	"\vsqlite_code\x18\x03 \x01(\x05R\n" +
+	"sqliteCode\x120\n" +
+	"\x14sqlite_extended_code\x18\x04 \x01(\x05R\x12sqliteExtendedCode2\xfe\b\n" +
+	"\n" +
+	"Branchlore\x12Z\n" +
+	"\rListDatabases\x12#.branchlore.v1.ListDatabasesRequest\x1a$.branchlore.v1.ListDatabasesResponse\x12O\n" +
+	"\x0eCreateDatabase\x12$.branchlore.v1.CreateDatabaseRequest\x1a\x17.branchlore.v1.Database\x12I\n" +
+	"\vGetDatabase\x12!.branchlore.v1.GetDatabaseRequest\x1a\x17.branchlore.v1.Database\x12]\n" +
+	"\x0eDeleteDatabase\x12$.branchlore.v1.DeleteDatabaseRequest\x1a%.branchlore.v1.DeleteDatabaseResponse\x12W\n" +
+	"\fListBranches\x12\".branchlore.v1.ListBranchesRequest\x1a#.branchlore.v1.ListBranchesResponse\x12I\n" +
+	"\fCreateBranch\x12\".branchlore.v1.CreateBranchRequest\x1a\x15.branchlore.v1.Branch\x12C\n" +
+	"\tGetBranch\x12\x1f.branchlore.v1.GetBranchRequest\x1a\x15.branchlore.v1.Branch\x12W\n" +
+	"\fDeleteBranch\x12\".branchlore.v1.DeleteBranchRequest\x1a#.branchlore.v1.DeleteBranchResponse\x12W\n" +
+	"\fCommitBranch\x12\".branchlore.v1.CommitBranchRequest\x1a#.branchlore.v1.CommitBranchResponse\x12T\n" +
+	"\vMergeBranch\x12!.branchlore.v1.MergeBranchRequest\x1a\".branchlore.v1.MergeBranchResponse\x12G\n" +
+	"\fDiffBranches\x12\".branchlore.v1.DiffBranchesRequest\x1a\x13.branchlore.v1.Diff\x12D\n" +
+	"\x05Query\x12\x1b.branchlore.v1.QueryRequest\x1a\x1c.branchlore.v1.QueryResponse0\x01\x12?\n" +
+	"\x04Exec\x12\x1a.branchlore.v1.ExecRequest\x1a\x1b.branchlore.v1.ExecResponse\x12X\n" +
+	"\vTransaction\x12!.branchlore.v1.TransactionRequest\x1a\".branchlore.v1.TransactionResponse(\x010\x01B>Z<github.com/bxrne/branchlore/proto/branchlore/v1;branchlorev1b\x06proto3"
+
+var (
+	file_branchlore_v1_branchlore_proto_rawDescOnce sync.Once
+	file_branchlore_v1_branchlore_proto_rawDescData []byte
+)
+
+func file_branchlore_v1_branchlore_proto_rawDescGZIP() []byte {
+	file_branchlore_v1_branchlore_proto_rawDescOnce.Do(func() {
+		file_branchlore_v1_branchlore_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_branchlore_v1_branchlore_proto_rawDesc), len(file_branchlore_v1_branchlore_proto_rawDesc)))
+	})
+	return file_branchlore_v1_branchlore_proto_rawDescData
+}
+
+var file_branchlore_v1_branchlore_proto_msgTypes = make([]protoimpl.MessageInfo, 37)
+var file_branchlore_v1_branchlore_proto_goTypes = []any{
+	(*Value)(nil),                        // 0: branchlore.v1.Value
+	(*Database)(nil),                     // 1: branchlore.v1.Database
+	(*Branch)(nil),                       // 2: branchlore.v1.Branch
+	(*ListDatabasesRequest)(nil),         // 3: branchlore.v1.ListDatabasesRequest
+	(*ListDatabasesResponse)(nil),        // 4: branchlore.v1.ListDatabasesResponse
+	(*CreateDatabaseRequest)(nil),        // 5: branchlore.v1.CreateDatabaseRequest
+	(*GetDatabaseRequest)(nil),           // 6: branchlore.v1.GetDatabaseRequest
+	(*DeleteDatabaseRequest)(nil),        // 7: branchlore.v1.DeleteDatabaseRequest
+	(*DeleteDatabaseResponse)(nil),       // 8: branchlore.v1.DeleteDatabaseResponse
+	(*ListBranchesRequest)(nil),          // 9: branchlore.v1.ListBranchesRequest
+	(*ListBranchesResponse)(nil),         // 10: branchlore.v1.ListBranchesResponse
+	(*CreateBranchRequest)(nil),          // 11: branchlore.v1.CreateBranchRequest
+	(*GetBranchRequest)(nil),             // 12: branchlore.v1.GetBranchRequest
+	(*DeleteBranchRequest)(nil),          // 13: branchlore.v1.DeleteBranchRequest
+	(*DeleteBranchResponse)(nil),         // 14: branchlore.v1.DeleteBranchResponse
+	(*CommitBranchRequest)(nil),          // 15: branchlore.v1.CommitBranchRequest
+	(*CommitBranchResponse)(nil),         // 16: branchlore.v1.CommitBranchResponse
+	(*MergeBranchRequest)(nil),           // 17: branchlore.v1.MergeBranchRequest
+	(*MergeBranchResponse)(nil),          // 18: branchlore.v1.MergeBranchResponse
+	(*DiffBranchesRequest)(nil),          // 19: branchlore.v1.DiffBranchesRequest
+	(*Diff)(nil),                         // 20: branchlore.v1.Diff
+	(*TableDiff)(nil),                    // 21: branchlore.v1.TableDiff
+	(*QueryRequest)(nil),                 // 22: branchlore.v1.QueryRequest
+	(*Column)(nil),                       // 23: branchlore.v1.Column
+	(*Row)(nil),                          // 24: branchlore.v1.Row
+	(*QueryResponse)(nil),                // 25: branchlore.v1.QueryResponse
+	(*ExecRequest)(nil),                  // 26: branchlore.v1.ExecRequest
+	(*ExecResponse)(nil),                 // 27: branchlore.v1.ExecResponse
+	(*TransactionRequest)(nil),           // 28: branchlore.v1.TransactionRequest
+	(*TransactionResponse)(nil),          // 29: branchlore.v1.TransactionResponse
+	(*Error)(nil),                        // 30: branchlore.v1.Error
+	(*TransactionRequest_Begin)(nil),     // 31: branchlore.v1.TransactionRequest.Begin
+	(*TransactionRequest_Statement)(nil), // 32: branchlore.v1.TransactionRequest.Statement
+	(*TransactionRequest_Commit)(nil),    // 33: branchlore.v1.TransactionRequest.Commit
+	(*TransactionRequest_Rollback)(nil),  // 34: branchlore.v1.TransactionRequest.Rollback
+	(*TransactionResponse_Started)(nil),  // 35: branchlore.v1.TransactionResponse.Started
+	(*TransactionResponse_Finished)(nil), // 36: branchlore.v1.TransactionResponse.Finished
+	(*durationpb.Duration)(nil),          // 37: google.protobuf.Duration
+}
+var file_branchlore_v1_branchlore_proto_depIdxs = []int32{
+	21, // 0: branchlore.v1.Diff.tables:type_name -> branchlore.v1.TableDiff
+	0,  // 1: branchlore.v1.QueryRequest.args:type_name -> branchlore.v1.Value
+	37, // 2: branchlore.v1.QueryRequest.timeout:type_name -> google.protobuf.Duration
+	0,  // 3: branchlore.v1.Row.values:type_name -> branchlore.v1.Value
+	23, // 4: branchlore.v1.QueryResponse.columns:type_name -> branchlore.v1.Column
+	24, // 5: branchlore.v1.QueryResponse.rows:type_name -> branchlore.v1.Row
+	0,  // 6: branchlore.v1.ExecRequest.args:type_name -> branchlore.v1.Value
+	37, // 7: branchlore.v1.ExecRequest.timeout:type_name -> google.protobuf.Duration
+	31, // 8: branchlore.v1.TransactionRequest.begin:type_name -> branchlore.v1.TransactionRequest.Begin
+	32, // 9: branchlore.v1.TransactionRequest.statement:type_name -> branchlore.v1.TransactionRequest.Statement
+	33, // 10: branchlore.v1.TransactionRequest.commit:type_name -> branchlore.v1.TransactionRequest.Commit
+	34, // 11: branchlore.v1.TransactionRequest.rollback:type_name -> branchlore.v1.TransactionRequest.Rollback
+	35, // 12: branchlore.v1.TransactionResponse.started:type_name -> branchlore.v1.TransactionResponse.Started
+	25, // 13: branchlore.v1.TransactionResponse.rows:type_name -> branchlore.v1.QueryResponse
+	27, // 14: branchlore.v1.TransactionResponse.done:type_name -> branchlore.v1.ExecResponse
+	30, // 15: branchlore.v1.TransactionResponse.error:type_name -> branchlore.v1.Error
+	36, // 16: branchlore.v1.TransactionResponse.finished:type_name -> branchlore.v1.TransactionResponse.Finished
+	0,  // 17: branchlore.v1.TransactionRequest.Statement.args:type_name -> branchlore.v1.Value
+	37, // 18: branchlore.v1.TransactionRequest.Statement.timeout:type_name -> google.protobuf.Duration
+	3,  // 19: branchlore.v1.Branchlore.ListDatabases:input_type -> branchlore.v1.ListDatabasesRequest
+	5,  // 20: branchlore.v1.Branchlore.CreateDatabase:input_type -> branchlore.v1.CreateDatabaseRequest
+	6,  // 21: branchlore.v1.Branchlore.GetDatabase:input_type -> branchlore.v1.GetDatabaseRequest
+	7,  // 22: branchlore.v1.Branchlore.DeleteDatabase:input_type -> branchlore.v1.DeleteDatabaseRequest
+	9,  // 23: branchlore.v1.Branchlore.ListBranches:input_type -> branchlore.v1.ListBranchesRequest
+	11, // 24: branchlore.v1.Branchlore.CreateBranch:input_type -> branchlore.v1.CreateBranchRequest
+	12, // 25: branchlore.v1.Branchlore.GetBranch:input_type -> branchlore.v1.GetBranchRequest
+	13, // 26: branchlore.v1.Branchlore.DeleteBranch:input_type -> branchlore.v1.DeleteBranchRequest
+	15, // 27: branchlore.v1.Branchlore.CommitBranch:input_type -> branchlore.v1.CommitBranchRequest
+	17, // 28: branchlore.v1.Branchlore.MergeBranch:input_type -> branchlore.v1.MergeBranchRequest
+	19, // 29: branchlore.v1.Branchlore.DiffBranches:input_type -> branchlore.v1.DiffBranchesRequest
+	22, // 30: branchlore.v1.Branchlore.Query:input_type -> branchlore.v1.QueryRequest
+	26, // 31: branchlore.v1.Branchlore.Exec:input_type -> branchlore.v1.ExecRequest
+	28, // 32: branchlore.v1.Branchlore.Transaction:input_type -> branchlore.v1.TransactionRequest
+	4,  // 33: branchlore.v1.Branchlore.ListDatabases:output_type -> branchlore.v1.ListDatabasesResponse
+	1,  // 34: branchlore.v1.Branchlore.CreateDatabase:output_type -> branchlore.v1.Database
+	1,  // 35: branchlore.v1.Branchlore.GetDatabase:output_type -> branchlore.v1.Database
+	8,  // 36: branchlore.v1.Branchlore.DeleteDatabase:output_type -> branchlore.v1.DeleteDatabaseResponse
+	10, // 37: branchlore.v1.Branchlore.ListBranches:output_type -> branchlore.v1.ListBranchesResponse
+	2,  // 38: branchlore.v1.Branchlore.CreateBranch:output_type -> branchlore.v1.Branch
+	2,  // 39: branchlore.v1.Branchlore.GetBranch:output_type -> branchlore.v1.Branch
+	14, // 40: branchlore.v1.Branchlore.DeleteBranch:output_type -> branchlore.v1.DeleteBranchResponse
+	16, // 41: branchlore.v1.Branchlore.CommitBranch:output_type -> branchlore.v1.CommitBranchResponse
+	18, // 42: branchlore.v1.Branchlore.MergeBranch:output_type -> branchlore.v1.MergeBranchResponse
+	20, // 43: branchlore.v1.Branchlore.DiffBranches:output_type -> branchlore.v1.Diff
+	25, // 44: branchlore.v1.Branchlore.Query:output_type -> branchlore.v1.QueryResponse
+	27, // 45: branchlore.v1.Branchlore.Exec:output_type -> branchlore.v1.ExecResponse
+	29, // 46: branchlore.v1.Branchlore.Transaction:output_type -> branchlore.v1.TransactionResponse
+	33, // [33:47] is the sub-list for method output_type
+	19, // [19:33] is the sub-list for method input_type
+	19, // [19:19] is the sub-list for extension type_name
+	19, // [19:19] is the sub-list for extension extendee
+	0,  // [0:19] is the sub-list for field type_name
+}
+
+func init() { file_branchlore_v1_branchlore_proto_init() }
+func file_branchlore_v1_branchlore_proto_init() {
+	if File_branchlore_v1_branchlore_proto != nil {
+		return
+	}
+	file_branchlore_v1_branchlore_proto_msgTypes[0].OneofWrappers = []any{
+		(*Value_Integer)(nil),
+		(*Value_Real)(nil),
+		(*Value_Text)(nil),
+		(*Value_Blob)(nil),
+	}
+	file_branchlore_v1_branchlore_proto_msgTypes[28].OneofWrappers = []any{
+		(*TransactionRequest_Begin_)(nil),
+		(*TransactionRequest_Statement_)(nil),
+		(*TransactionRequest_Commit_)(nil),
+		(*TransactionRequest_Rollback_)(nil),
+	}
+	file_branchlore_v1_branchlore_proto_msgTypes[29].OneofWrappers = []any{
+		(*TransactionResponse_Started_)(nil),
+		(*TransactionResponse_Rows)(nil),
+		(*TransactionResponse_Done)(nil),
+		(*TransactionResponse_Error)(nil),
+		(*TransactionResponse_Finished_)(nil),
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_branchlore_v1_branchlore_proto_rawDesc), len(file_branchlore_v1_branchlore_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   37,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_branchlore_v1_branchlore_proto_goTypes,
+		DependencyIndexes: file_branchlore_v1_branchlore_proto_depIdxs,
+		MessageInfos:      file_branchlore_v1_branchlore_proto_msgTypes,
+	}.Build()
+	File_branchlore_v1_branchlore_proto = out.File
+	file_branchlore_v1_branchlore_proto_goTypes = nil
+	file_branchlore_v1_branchlore_proto_depIdxs = nil
+}
@@ -0,0 +1,641 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             (unknown)
+// source: branchlore/v1/branchlore.proto
+
+package branchlorev1
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	Branchlore_ListDatabases_FullMethodName  = "/branchlore.v1.Branchlore/ListDatabases"
+	Branchlore_CreateDatabase_FullMethodName = "/branchlore.v1.Branchlore/CreateDatabase"
+	Branchlore_GetDatabase_FullMethodName    = "/branchlore.v1.Branchlore/GetDatabase"
+	Branchlore_DeleteDatabase_FullMethodName = "/branchlore.v1.Branchlore/DeleteDatabase"
+	Branchlore_ListBranches_FullMethodName   = "/branchlore.v1.Branchlore/ListBranches"
+	Branchlore_CreateBranch_FullMethodName   = "/branchlore.v1.Branchlore/CreateBranch"
+	Branchlore_GetBranch_FullMethodName      = "/branchlore.v1.Branchlore/GetBranch"
+	Branchlore_DeleteBranch_FullMethodName   = "/branchlore.v1.Branchlore/DeleteBranch"
+	Branchlore_CommitBranch_FullMethodName   = "/branchlore.v1.Branchlore/CommitBranch"
+	Branchlore_MergeBranch_FullMethodName    = "/branchlore.v1.Branchlore/MergeBranch"
+	Branchlore_DiffBranches_FullMethodName   = "/branchlore.v1.Branchlore/DiffBranches"
+	Branchlore_Query_FullMethodName          = "/branchlore.v1.Branchlore/Query"
+	Branchlore_Exec_FullMethodName           = "/branchlore.v1.Branchlore/Exec"
+	Branchlore_Transaction_FullMethodName    = "/branchlore.v1.Branchlore/Transaction"
+)
+
+// BranchloreClient is the client API for Branchlore service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// Branchlore exposes the same operations as the HTTP API. Failed calls carry
+// an ErrorInfo detail whose reason is the HTTP API's error code, such as
+// BRANCH_NOT_FOUND or SQLITE_CONSTRAINT.
+type BranchloreClient interface {
+	ListDatabases(ctx context.Context, in *ListDatabasesRequest, opts ...grpc.CallOption) (*ListDatabasesResponse, error)
+	CreateDatabase(ctx context.Context, in *CreateDatabaseRequest, opts ...grpc.CallOption) (*Database, error)
+	GetDatabase(ctx context.Context, in *GetDatabaseRequest, opts ...grpc.CallOption) (*Database, error)
+	DeleteDatabase(ctx context.Context, in *DeleteDatabaseRequest, opts ...grpc.CallOption) (*DeleteDatabaseResponse, error)
+	ListBranches(ctx context.Context, in *ListBranchesRequest, opts ...grpc.CallOption) (*ListBranchesResponse, error)
+	CreateBranch(ctx context.Context, in *CreateBranchRequest, opts ...grpc.CallOption) (*Branch, error)
+	GetBranch(ctx context.Context, in *GetBranchRequest, opts ...grpc.CallOption) (*Branch, error)
+	DeleteBranch(ctx context.Context, in *DeleteBranchRequest, opts ...grpc.CallOption) (*DeleteBranchResponse, error)
+	// CommitBranch records the branch's current state in its history.
+	CommitBranch(ctx context.Context, in *CommitBranchRequest, opts ...grpc.CallOption) (*CommitBranchResponse, error)
+	// MergeBranch merges from into branch. Protected branches only change
+	// this way, and only once their checks pass on the result.
+	MergeBranch(ctx context.Context, in *MergeBranchRequest, opts ...grpc.CallOption) (*MergeBranchResponse, error)
+	// DiffBranches reports the tables of head that differ from base.
+	DiffBranches(ctx context.Context, in *DiffBranchesRequest, opts ...grpc.CallOption) (*Diff, error)
+	// Query streams the rows of a statement. The first response carries the
+	// columns, later ones batches of rows.
+	Query(ctx context.Context, in *QueryRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[QueryResponse], error)
+	// Exec runs a statement that returns no rows.
+	Exec(ctx context.Context, in *ExecRequest, opts ...grpc.CallOption) (*ExecResponse, error)
+	// Transaction runs statements in one transaction for the life of the
+	// stream. The first request must be begin; the transaction ends with
+	// commit or rollback, and is rolled back if the stream ends first.
+	Transaction(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[TransactionRequest, TransactionResponse], error)
+}
+
+type branchloreClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewBranchloreClient(cc grpc.ClientConnInterface) BranchloreClient {
+	return &branchloreClient{cc}
+}
+
+func (c *branchloreClient) ListDatabases(ctx context.Context, in *ListDatabasesRequest, opts ...grpc.CallOption) (*ListDatabasesResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListDatabasesResponse)
+	err := c.cc.Invoke(ctx, Branchlore_ListDatabases_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *branchloreClient) CreateDatabase(ctx context.Context, in *CreateDatabaseRequest, opts ...grpc.CallOption) (*Database, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Database)
+	err := c.cc.Invoke(ctx, Branchlore_CreateDatabase_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *branchloreClient) GetDatabase(ctx context.Context, in *GetDatabaseRequest, opts ...grpc.CallOption) (*Database, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Database)
+	err := c.cc.Invoke(ctx, Branchlore_GetDatabase_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *branchloreClient) DeleteDatabase(ctx context.Context, in *DeleteDatabaseRequest, opts ...grpc.CallOption) (*DeleteDatabaseResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(DeleteDatabaseResponse)
+	err := c.cc.Invoke(ctx, Branchlore_DeleteDatabase_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *branchloreClient) ListBranches(ctx context.Context, in *ListBranchesRequest, opts ...grpc.CallOption) (*ListBranchesResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListBranchesResponse)
+	err := c.cc.Invoke(ctx, Branchlore_ListBranches_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *branchloreClient) CreateBranch(ctx context.Context, in *CreateBranchRequest, opts ...grpc.CallOption) (*Branch, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Branch)
+	err := c.cc.Invoke(ctx, Branchlore_CreateBranch_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *branchloreClient) GetBranch(ctx context.Context, in *GetBranchRequest, opts ...grpc.CallOption) (*Branch, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Branch)
+	err := c.cc.Invoke(ctx, Branchlore_GetBranch_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *branchloreClient) DeleteBranch(ctx context.Context, in *DeleteBranchRequest, opts ...grpc.CallOption) (*DeleteBranchResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(DeleteBranchResponse)
+	err := c.cc.Invoke(ctx, Branchlore_DeleteBranch_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *branchloreClient) CommitBranch(ctx context.Context, in *CommitBranchRequest, opts ...grpc.CallOption) (*CommitBranchResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(CommitBranchResponse)
+	err := c.cc.Invoke(ctx, Branchlore_CommitBranch_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *branchloreClient) MergeBranch(ctx context.Context, in *MergeBranchRequest, opts ...grpc.CallOption) (*MergeBranchResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(MergeBranchResponse)
+	err := c.cc.Invoke(ctx, Branchlore_MergeBranch_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *branchloreClient) DiffBranches(ctx context.Context, in *DiffBranchesRequest, opts ...grpc.CallOption) (*Diff, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Diff)
+	err := c.cc.Invoke(ctx, Branchlore_DiffBranches_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *branchloreClient) Query(ctx context.Context, in *QueryRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[QueryResponse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Branchlore_ServiceDesc.Streams[0], Branchlore_Query_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[QueryRequest, QueryResponse]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Branchlore_QueryClient = grpc.ServerStreamingClient[QueryResponse]
+
+func (c *branchloreClient) Exec(ctx context.Context, in *ExecRequest, opts ...grpc.CallOption) (*ExecResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ExecResponse)
+	err := c.cc.Invoke(ctx, Branchlore_Exec_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *branchloreClient) Transaction(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[TransactionRequest, TransactionResponse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Branchlore_ServiceDesc.Streams[1], Branchlore_Transaction_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[TransactionRequest, TransactionResponse]{ClientStream: stream}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Branchlore_TransactionClient = grpc.BidiStreamingClient[TransactionRequest, TransactionResponse]
+
+// BranchloreServer is the server API for Branchlore service.
+// All implementations must embed UnimplementedBranchloreServer
+// for forward compatibility.
+//
+// Branchlore exposes the same operations as the HTTP API. Failed calls carry
+// an ErrorInfo detail whose reason is the HTTP API's error code, such as
+// BRANCH_NOT_FOUND or SQLITE_CONSTRAINT.
+type BranchloreServer interface {
+	ListDatabases(context.Context, *ListDatabasesRequest) (*ListDatabasesResponse, error)
+	CreateDatabase(context.Context, *CreateDatabaseRequest) (*Database, error)
+	GetDatabase(context.Context, *GetDatabaseRequest) (*Database, error)
+	DeleteDatabase(context.Context, *DeleteDatabaseRequest) (*DeleteDatabaseResponse, error)
+	ListBranches(context.Context, *ListBranchesRequest) (*ListBranchesResponse, error)
+	CreateBranch(context.Context, *CreateBranchRequest) (*Branch, error)
+	GetBranch(context.Context, *GetBranchRequest) (*Branch, error)
+	DeleteBranch(context.Context, *DeleteBranchRequest) (*DeleteBranchResponse, error)
+	// CommitBranch records the branch's current state in its history.
+	CommitBranch(context.Context, *CommitBranchRequest) (*CommitBranchResponse, error)
+	// MergeBranch merges from into branch. Protected branches only change
+	// this way, and only once their checks pass on the result.
+	MergeBranch(context.Context, *MergeBranchRequest) (*MergeBranchResponse, error)
+	// DiffBranches reports the tables of head that differ from base.
+	DiffBranches(context.Context, *DiffBranchesRequest) (*Diff, error)
+	// Query streams the rows of a statement. The first response carries the
+	// columns, later ones batches of rows.
+	Query(*QueryRequest, grpc.ServerStreamingServer[QueryResponse]) error
+	// Exec runs a statement that returns no rows.
+	Exec(context.Context, *ExecRequest) (*ExecResponse, error)
+	// Transaction runs statements in one transaction for the life of the
+	// stream. The first request must be begin; the transaction ends with
+	// commit or rollback, and is rolled back if the stream ends first.
+	Transaction(grpc.BidiStreamingServer[TransactionRequest, TransactionResponse]) error
+	mustEmbedUnimplementedBranchloreServer()
+}
+
+// UnimplementedBranchloreServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedBranchloreServer struct{}
+
+func (UnimplementedBranchloreServer) ListDatabases(context.Context, *ListDatabasesRequest) (*ListDatabasesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListDatabases not implemented")
+}
+func (UnimplementedBranchloreServer) CreateDatabase(context.Context, *CreateDatabaseRequest) (*Database, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateDatabase not implemented")
+}
+func (UnimplementedBranchloreServer) GetDatabase(context.Context, *GetDatabaseRequest) (*Database, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetDatabase not implemented")
+}
+func (UnimplementedBranchloreServer) DeleteDatabase(context.Context, *DeleteDatabaseRequest) (*DeleteDatabaseResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteDatabase not implemented")
+}
+func (UnimplementedBranchloreServer) ListBranches(context.Context, *ListBranchesRequest) (*ListBranchesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListBranches not implemented")
+}
+func (UnimplementedBranchloreServer) CreateBranch(context.Context, *CreateBranchRequest) (*Branch, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateBranch not implemented")
+}
+func (UnimplementedBranchloreServer) GetBranch(context.Context, *GetBranchRequest) (*Branch, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetBranch not implemented")
+}
+func (UnimplementedBranchloreServer) DeleteBranch(context.Context, *DeleteBranchRequest) (*DeleteBranchResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteBranch not implemented")
+}
+func (UnimplementedBranchloreServer) CommitBranch(context.Context, *CommitBranchRequest) (*CommitBranchResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CommitBranch not implemented")
+}
+func (UnimplementedBranchloreServer) MergeBranch(context.Context, *MergeBranchRequest) (*MergeBranchResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method MergeBranch not implemented")
+}
+func (UnimplementedBranchloreServer) DiffBranches(context.Context, *DiffBranchesRequest) (*Diff, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DiffBranches not implemented")
+}
+func (UnimplementedBranchloreServer) Query(*QueryRequest, grpc.ServerStreamingServer[QueryResponse]) error {
+	return status.Errorf(codes.Unimplemented, "method Query not implemented")
+}
+func (UnimplementedBranchloreServer) Exec(context.Context, *ExecRequest) (*ExecResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Exec not implemented")
+}
+func (UnimplementedBranchloreServer) Transaction(grpc.BidiStreamingServer[TransactionRequest, TransactionResponse]) error {
+	return status.Errorf(codes.Unimplemented, "method Transaction not implemented")
+}
+func (UnimplementedBranchloreServer) mustEmbedUnimplementedBranchloreServer() {}
+func (UnimplementedBranchloreServer) testEmbeddedByValue()                    {}
+
+// UnsafeBranchloreServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to BranchloreServer will
+// result in compilation errors.
+type UnsafeBranchloreServer interface {
+	mustEmbedUnimplementedBranchloreServer()
+}
+
+func RegisterBranchloreServer(s grpc.ServiceRegistrar, srv BranchloreServer) {
+	// If the following call pancis, it indicates UnimplementedBranchloreServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&Branchlore_ServiceDesc, srv)
+}
+
+func _Branchlore_ListDatabases_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListDatabasesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BranchloreServer).ListDatabases(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Branchlore_ListDatabases_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BranchloreServer).ListDatabases(ctx, req.(*ListDatabasesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Branchlore_CreateDatabase_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateDatabaseRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BranchloreServer).CreateDatabase(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Branchlore_CreateDatabase_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BranchloreServer).CreateDatabase(ctx, req.(*CreateDatabaseRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Branchlore_GetDatabase_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetDatabaseRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BranchloreServer).GetDatabase(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Branchlore_GetDatabase_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BranchloreServer).GetDatabase(ctx, req.(*GetDatabaseRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Branchlore_DeleteDatabase_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteDatabaseRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BranchloreServer).DeleteDatabase(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Branchlore_DeleteDatabase_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BranchloreServer).DeleteDatabase(ctx, req.(*DeleteDatabaseRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Branchlore_ListBranches_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListBranchesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BranchloreServer).ListBranches(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Branchlore_ListBranches_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BranchloreServer).ListBranches(ctx, req.(*ListBranchesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Branchlore_CreateBranch_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateBranchRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BranchloreServer).CreateBranch(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Branchlore_CreateBranch_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BranchloreServer).CreateBranch(ctx, req.(*CreateBranchRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Branchlore_GetBranch_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetBranchRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BranchloreServer).GetBranch(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Branchlore_GetBranch_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BranchloreServer).GetBranch(ctx, req.(*GetBranchRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Branchlore_DeleteBranch_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteBranchRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BranchloreServer).DeleteBranch(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Branchlore_DeleteBranch_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BranchloreServer).DeleteBranch(ctx, req.(*DeleteBranchRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Branchlore_CommitBranch_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CommitBranchRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BranchloreServer).CommitBranch(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Branchlore_CommitBranch_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BranchloreServer).CommitBranch(ctx, req.(*CommitBranchRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Branchlore_MergeBranch_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(MergeBranchRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BranchloreServer).MergeBranch(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Branchlore_MergeBranch_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BranchloreServer).MergeBranch(ctx, req.(*MergeBranchRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Branchlore_DiffBranches_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DiffBranchesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BranchloreServer).DiffBranches(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Branchlore_DiffBranches_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BranchloreServer).DiffBranches(ctx, req.(*DiffBranchesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Branchlore_Query_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(QueryRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(BranchloreServer).Query(m, &grpc.GenericServerStream[QueryRequest, QueryResponse]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Branchlore_QueryServer = grpc.ServerStreamingServer[QueryResponse]
+
+func _Branchlore_Exec_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ExecRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BranchloreServer).Exec(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Branchlore_Exec_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BranchloreServer).Exec(ctx, req.(*ExecRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Branchlore_Transaction_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(BranchloreServer).Transaction(&grpc.GenericServerStream[TransactionRequest, TransactionResponse]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Branchlore_TransactionServer = grpc.BidiStreamingServer[TransactionRequest, TransactionResponse]
+
+// Branchlore_ServiceDesc is the grpc.ServiceDesc for Branchlore service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Branchlore_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "branchlore.v1.Branchlore",
+	HandlerType: (*BranchloreServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "ListDatabases",
+			Handler:    _Branchlore_ListDatabases_Handler,
+		},
+		{
+			MethodName: "CreateDatabase",
+			Handler:    _Branchlore_CreateDatabase_Handler,
+		},
+		{
+			MethodName: "GetDatabase",
+			Handler:    _Branchlore_GetDatabase_Handler,
+		},
+		{
+			MethodName: "DeleteDatabase",
+			Handler:    _Branchlore_DeleteDatabase_Handler,
+		},
+		{
+			MethodName: "ListBranches",
+			Handler:    _Branchlore_ListBranches_Handler,
+		},
+		{
+			MethodName: "CreateBranch",
+			Handler:    _Branchlore_CreateBranch_Handler,
+		},
+		{
+			MethodName: "GetBranch",
+			Handler:    _Branchlore_GetBranch_Handler,
+		},
+		{
+			MethodName: "DeleteBranch",
+			Handler:    _Branchlore_DeleteBranch_Handler,
+		},
+		{
+			MethodName: "CommitBranch",
+			Handler:    _Branchlore_CommitBranch_Handler,
+		},
+		{
+			MethodName: "MergeBranch",
+			Handler:    _Branchlore_MergeBranch_Handler,
+		},
+		{
+			MethodName: "DiffBranches",
+			Handler:    _Branchlore_DiffBranches_Handler,
+		},
+		{
+			MethodName: "Exec",
+			Handler:    _Branchlore_Exec_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Query",
+			Handler:       _Branchlore_Query_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "Transaction",
+			Handler:       _Branchlore_Transaction_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+	},
+	Metadata: "branchlore/v1/branchlore.proto",
+}
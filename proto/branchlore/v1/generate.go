@@ -0,0 +1,5 @@
+// Package branchlorev1 holds the gRPC service definition and generated code
+// for Branchlore's v1 API.
+package branchlorev1
+
+//go:generate protoc -I ../.. --go_out=../.. --go_opt=paths=source_relative --go-grpc_out=../.. --go-grpc_opt=paths=source_relative branchlore/v1/branchlore.proto
@@ -0,0 +1,250 @@
+// Package sqldriver registers a database/sql driver named "branchlore" that
+// runs statements against a branch over the HTTP API.
+//
+//	import _ "github.com/bxrne/branchlore/sqldriver"
+//
+//	db, err := sql.Open("branchlore", "http://localhost:8080/myapp@feature-x")
+package sqldriver
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+
+	"github.com/bxrne/branchlore/client"
+)
+
+func init() {
+	sql.Register("branchlore", &Driver{})
+}
+
+type Driver struct{}
+
+func (d *Driver) Open(dsn string) (driver.Conn, error) {
+	connector, err := d.OpenConnector(dsn)
+	if err != nil {
+		return nil, err
+	}
+	return connector.Connect(context.Background())
+}
+
+func (d *Driver) OpenConnector(dsn string) (driver.Connector, error) {
+	return NewConnector(dsn)
+}
+
+// NewConnector returns a connector for dsn for use with sql.OpenDB, passing
+// opts to the underlying client, e.g. client.WithHTTPClient.
+func NewConnector(dsn string, opts ...client.Option) (driver.Connector, error) {
+	cfg, err := parseDSN(dsn)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.timeout > 0 {
+		opts = append(opts, client.WithQueryTimeout(cfg.timeout))
+	}
+	if cfg.token != "" {
+		opts = append(opts, client.WithToken(cfg.token))
+	}
+	if cfg.ca != "" || cfg.cert != "" {
+		tlsConfig, err := client.LoadTLSConfig(cfg.ca, cfg.cert, cfg.key)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, client.WithTLSConfig(tlsConfig))
+	}
+
+	c, err := client.NewClient(cfg.serverURL, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &connector{cfg: cfg, client: c}, nil
+}
+
+type connector struct {
+	cfg    *config
+	client *client.Client
+}
+
+func (c *connector) Connect(ctx context.Context) (driver.Conn, error) {
+	return &conn{cfg: c.cfg, client: c.client}, nil
+}
+
+func (c *connector) Driver() driver.Driver {
+	return &Driver{}
+}
+
+// conn holds no server state of its own beyond an open transaction; every
+// statement is a separate request.
+type conn struct {
+	cfg    *config
+	client *client.Client
+	tx     *client.Tx
+}
+
+var errTxOpen = errors.New("a transaction is already open on this connection")
+
+func (c *conn) Prepare(query string) (driver.Stmt, error) {
+	return c.PrepareContext(context.Background(), query)
+}
+
+func (c *conn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	return &stmt{conn: c, query: query}, nil
+}
+
+func (c *conn) Close() error {
+	if c.tx == nil {
+		return nil
+	}
+	tx := c.tx
+	c.tx = nil
+	return tx.Rollback(context.Background())
+}
+
+func (c *conn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (c *conn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if c.tx != nil {
+		return nil, errTxOpen
+	}
+	if sql.IsolationLevel(opts.Isolation) != sql.LevelDefault {
+		return nil, errors.New("isolation levels are not supported")
+	}
+
+	tx, err := c.client.BeginTx(ctx, c.cfg.db, c.cfg.branch)
+	if err != nil {
+		return nil, err
+	}
+	c.tx = tx
+	return &txn{conn: c}, nil
+}
+
+func (c *conn) Ping(ctx context.Context) error {
+	_, err := c.client.ListBranches(ctx, c.cfg.db)
+	return err
+}
+
+func (c *conn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	values, err := positional(args)
+	if err != nil {
+		return nil, err
+	}
+
+	var res *client.Result
+	if c.tx != nil {
+		res, err = c.tx.Exec(ctx, query, values...)
+	} else {
+		res, err = c.client.Exec(ctx, c.cfg.db, c.cfg.branch, query, values...)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return result{res}, nil
+}
+
+// QueryContext pages through results with a server-side cursor. Inside a
+// transaction the server returns results whole, so a result cut off at the
+// server's row limit is an error rather than silently short.
+func (c *conn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	values, err := positional(args)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.tx == nil {
+		cursor, err := c.client.QueryPages(ctx, c.cfg.db, c.cfg.branch, c.cfg.pageSize, query, values...)
+		if err != nil {
+			return nil, err
+		}
+		return newRows(ctx, cursor)
+	}
+
+	page, err := c.tx.Query(ctx, query, values...)
+	if err != nil {
+		return nil, err
+	}
+	if page.Truncated {
+		return nil, errors.New("result exceeds the server's row limit; run the query outside the transaction to page it")
+	}
+	return &rows{ctx: ctx, page: page}, nil
+}
+
+func positional(args []driver.NamedValue) ([]interface{}, error) {
+	values := make([]interface{}, len(args))
+	for i, arg := range args {
+		if arg.Name != "" {
+			return nil, errors.New("named parameters are not supported")
+		}
+		values[i] = arg.Value
+	}
+	return values, nil
+}
+
+type txn struct {
+	conn *conn
+}
+
+func (t *txn) Commit() error {
+	tx := t.conn.tx
+	t.conn.tx = nil
+	return tx.Commit(context.Background())
+}
+
+func (t *txn) Rollback() error {
+	tx := t.conn.tx
+	t.conn.tx = nil
+	return tx.Rollback(context.Background())
+}
+
+// stmt is prepared client side only: the query text is sent with each
+// execution.
+type stmt struct {
+	conn  *conn
+	query string
+}
+
+func (s *stmt) Close() error {
+	return nil
+}
+
+func (s *stmt) NumInput() int {
+	return -1
+}
+
+func (s *stmt) Exec(args []driver.Value) (driver.Result, error) {
+	return s.ExecContext(context.Background(), named(args))
+}
+
+func (s *stmt) Query(args []driver.Value) (driver.Rows, error) {
+	return s.QueryContext(context.Background(), named(args))
+}
+
+func (s *stmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	return s.conn.ExecContext(ctx, s.query, args)
+}
+
+func (s *stmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	return s.conn.QueryContext(ctx, s.query, args)
+}
+
+func named(args []driver.Value) []driver.NamedValue {
+	nv := make([]driver.NamedValue, len(args))
+	for i, v := range args {
+		nv[i] = driver.NamedValue{Ordinal: i + 1, Value: v}
+	}
+	return nv
+}
+
+type result struct {
+	res *client.Result
+}
+
+func (r result) LastInsertId() (int64, error) {
+	return r.res.LastInsertID, nil
+}
+
+func (r result) RowsAffected() (int64, error) {
+	return r.res.RowsAffected, nil
+}
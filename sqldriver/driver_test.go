@@ -0,0 +1,172 @@
+package sqldriver_test
+
+import (
+	"context"
+	"database/sql"
+	"net"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/bxrne/branchlore/client"
+	"github.com/bxrne/branchlore/internal/server"
+	_ "github.com/bxrne/branchlore/sqldriver"
+)
+
+// openTestDB starts a server holding the database "db" on a free port and
+// opens its main branch, reading query results params pages at a time.
+func openTestDB(t *testing.T, params string) *sql.DB {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := strconv.Itoa(ln.Addr().(*net.TCPAddr).Port)
+	ln.Close()
+
+	srv, err := server.New(&server.Config{Port: port, DataDir: t.TempDir(), LogLevel: "error"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	go srv.Start()
+	t.Cleanup(func() { srv.Shutdown(context.Background()) })
+
+	url := "http://127.0.0.1:" + port
+	for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(10 * time.Millisecond) {
+		resp, err := http.Get(url + "/healthz")
+		if err == nil {
+			resp.Body.Close()
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("server did not start: %v", err)
+		}
+	}
+
+	c, err := client.NewClient(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.CreateDatabase(context.Background(), "db"); err != nil {
+		t.Fatal(err)
+	}
+	db, err := sql.Open("branchlore", url+"/db@main?"+params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestQueryAcrossPages(t *testing.T) {
+	db := openTestDB(t, "page_size=2")
+
+	if _, err := db.Exec("CREATE TABLE events (id INTEGER PRIMARY KEY, name TEXT, at DATETIME)"); err != nil {
+		t.Fatal(err)
+	}
+	stmt, err := db.Prepare("INSERT INTO events (name, at) VALUES (?, ?)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stmt.Close()
+	start := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	for i := range 5 {
+		if _, err := stmt.Exec("event "+strconv.Itoa(i), start.Add(time.Duration(i)*time.Hour)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	rows, err := db.Query("SELECT id, name, at FROM events ORDER BY id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	types, err := rows.ColumnTypes()
+	if err != nil || types[2].DatabaseTypeName() != "DATETIME" {
+		t.Fatalf("column types %v, %v", types, err)
+	}
+	n := 0
+	for rows.Next() {
+		var (
+			id   int64
+			name string
+			at   time.Time
+		)
+		if err := rows.Scan(&id, &name, &at); err != nil {
+			t.Fatal(err)
+		}
+		if want := start.Add(time.Duration(n) * time.Hour); id != int64(n+1) || !at.Equal(want) {
+			t.Errorf("row %d: id %d at %v, want %d at %v", n, id, at, n+1, want)
+		}
+		n++
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if n != 5 {
+		t.Fatalf("got %d rows, want 5", n)
+	}
+}
+
+func TestTransactions(t *testing.T) {
+	db := openTestDB(t, "")
+	db.Exec("CREATE TABLE t (a INTEGER)")
+
+	count := func() int {
+		t.Helper()
+		var n int
+		if err := db.QueryRow("SELECT count(*) FROM t").Scan(&n); err != nil {
+			t.Fatal(err)
+		}
+		return n
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx.Exec("INSERT INTO t VALUES (?)", 1)
+	var inside int
+	if err := tx.QueryRow("SELECT count(*) FROM t").Scan(&inside); err != nil || inside != 1 {
+		t.Fatalf("count inside the transaction = %d, %v, want 1", inside, err)
+	}
+	if err := tx.Rollback(); err != nil {
+		t.Fatal(err)
+	}
+	if n := count(); n != 0 {
+		t.Fatalf("%d rows after rollback", n)
+	}
+
+	tx, err = db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx.Exec("INSERT INTO t VALUES (1), (2)")
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if n := count(); n != 2 {
+		t.Fatalf("%d rows after commit, want 2", n)
+	}
+
+	if _, err := db.BeginTx(context.Background(), &sql.TxOptions{Isolation: sql.LevelSerializable}); err == nil {
+		t.Error("began a transaction with an isolation level")
+	}
+}
+
+func TestErrors(t *testing.T) {
+	db := openTestDB(t, "")
+
+	db.Exec("CREATE TABLE t (id INTEGER PRIMARY KEY)")
+	db.Exec("INSERT INTO t VALUES (1)")
+	if _, err := db.Exec("INSERT INTO t VALUES (1)"); !client.IsCode(err, client.CodeSQLiteConstraint) {
+		t.Errorf("duplicate key: %v, want %s", err, client.CodeSQLiteConstraint)
+	}
+	if _, err := db.Exec("INSERT INTO t VALUES (:id)", sql.Named("id", 2)); err == nil {
+		t.Error("named parameter accepted")
+	}
+	if err := db.Ping(); err != nil {
+		t.Errorf("ping: %v", err)
+	}
+}
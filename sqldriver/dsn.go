@@ -0,0 +1,72 @@
+package sqldriver
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const defaultPageSize = 1000
+
+// config is a parsed data source name of the form
+//
+//	http://host:8080/database@branch?timeout=5s&page_size=500&token=blt_...
+//
+// The branch defaults to main. Any path before the database name is kept as
+// part of the server URL, for servers behind a path prefix. Over https, ca
+// names a PEM CA file to trust and cert and key a client certificate.
+type config struct {
+	serverURL string
+	db        string
+	branch    string
+	timeout   time.Duration
+	pageSize  int
+	token     string
+	ca        string
+	cert      string
+	key       string
+}
+
+func parseDSN(dsn string) (*config, error) {
+	u, err := url.Parse(dsn)
+	if err != nil {
+		return nil, fmt.Errorf("invalid DSN: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("invalid DSN %q: scheme must be http or https", dsn)
+	}
+
+	path := strings.TrimSuffix(u.Path, "/")
+	target := path
+	branch := "main"
+	if i := strings.Index(path, "@"); i >= 0 {
+		target, branch = path[:i], path[i+1:]
+	}
+	slash := strings.LastIndex(target, "/")
+	prefix, db := target[:slash+1], target[slash+1:]
+	if db == "" || branch == "" {
+		return nil, fmt.Errorf("invalid DSN %q: expected database@branch after the server address", dsn)
+	}
+
+	cfg := &config{db: db, branch: branch, pageSize: defaultPageSize}
+
+	query := u.Query()
+	cfg.token = query.Get("token")
+	cfg.ca, cfg.cert, cfg.key = query.Get("ca"), query.Get("cert"), query.Get("key")
+	if v := query.Get("timeout"); v != "" {
+		if cfg.timeout, err = time.ParseDuration(v); err != nil || cfg.timeout <= 0 {
+			return nil, fmt.Errorf("invalid DSN timeout %q", v)
+		}
+	}
+	if v := query.Get("page_size"); v != "" {
+		if cfg.pageSize, err = strconv.Atoi(v); err != nil || cfg.pageSize <= 0 {
+			return nil, fmt.Errorf("invalid DSN page_size %q", v)
+		}
+	}
+
+	server := url.URL{Scheme: u.Scheme, User: u.User, Host: u.Host, Path: strings.TrimSuffix(prefix, "/")}
+	cfg.serverURL = server.String()
+	return cfg, nil
+}
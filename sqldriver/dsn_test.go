@@ -0,0 +1,49 @@
+package sqldriver
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseDSN(t *testing.T) {
+	for _, tt := range []struct {
+		dsn  string
+		want config
+	}{
+		{"http://localhost:8080/shop", config{serverURL: "http://localhost:8080", db: "shop", branch: "main", pageSize: defaultPageSize}},
+		{"http://localhost:8080/shop@dev/anna/", config{serverURL: "http://localhost:8080", db: "shop", branch: "dev/anna", pageSize: defaultPageSize}},
+		{"https://example.com/api/branchlore/shop@dev", config{serverURL: "https://example.com/api/branchlore", db: "shop", branch: "dev", pageSize: defaultPageSize}},
+		{
+			"https://example.com/shop@main?timeout=5s&page_size=50&token=blt_x&ca=ca.pem&cert=c.pem&key=k.pem",
+			config{serverURL: "https://example.com", db: "shop", branch: "main", timeout: 5 * time.Second, pageSize: 50,
+				token: "blt_x", ca: "ca.pem", cert: "c.pem", key: "k.pem"},
+		},
+	} {
+		got, err := parseDSN(tt.dsn)
+		if err != nil {
+			t.Errorf("parseDSN(%q): %v", tt.dsn, err)
+			continue
+		}
+		if *got != tt.want {
+			t.Errorf("parseDSN(%q) = %+v, want %+v", tt.dsn, *got, tt.want)
+		}
+	}
+}
+
+func TestParseDSNRejectsInvalid(t *testing.T) {
+	for _, dsn := range []string{
+		"localhost:8080/shop",
+		"ftp://localhost/shop",
+		"http://localhost:8080",
+		"http://localhost:8080/",
+		"http://localhost:8080/shop@",
+		"http://localhost:8080/@main",
+		"http://localhost:8080/shop?timeout=soon",
+		"http://localhost:8080/shop?timeout=-1s",
+		"http://localhost:8080/shop?page_size=0",
+	} {
+		if cfg, err := parseDSN(dsn); err == nil {
+			t.Errorf("parseDSN(%q) = %+v, want an error", dsn, *cfg)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package sqldriver
+
+import (
+	"context"
+	"database/sql/driver"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/bxrne/branchlore/client"
+)
+
+// timestampFormats are the layouts SQLite drivers write DATE, DATETIME and
+// TIMESTAMP values in.
+var timestampFormats = []string{
+	"2006-01-02 15:04:05.999999999-07:00",
+	"2006-01-02T15:04:05.999999999-07:00",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02T15:04",
+	"2006-01-02",
+}
+
+type rows struct {
+	ctx    context.Context
+	cursor *client.Cursor
+	page   *client.Rows
+	next   int
+}
+
+// newRows fetches the first page so that column names are known before the
+// first call to Next.
+func newRows(ctx context.Context, cursor *client.Cursor) (*rows, error) {
+	page, err := cursor.Next(ctx)
+	if err != nil {
+		cursor.Close(context.Background())
+		return nil, err
+	}
+	return &rows{ctx: ctx, cursor: cursor, page: page}, nil
+}
+
+func (r *rows) Columns() []string {
+	return r.page.Columns
+}
+
+func (r *rows) ColumnTypeDatabaseTypeName(index int) string {
+	if index < len(r.page.ColumnTypes) {
+		return strings.ToUpper(r.page.ColumnTypes[index])
+	}
+	return ""
+}
+
+func (r *rows) Close() error {
+	if r.cursor == nil {
+		return nil
+	}
+	return r.cursor.Close(context.Background())
+}
+
+func (r *rows) Next(dest []driver.Value) error {
+	for r.next >= len(r.page.Values) {
+		if r.cursor == nil {
+			return io.EOF
+		}
+		page, err := r.cursor.Next(r.ctx)
+		if err != nil {
+			return err
+		}
+		r.page.Values, r.next = page.Values, 0
+	}
+
+	row := r.page.Values[r.next]
+	r.next++
+	for i, v := range row {
+		dest[i] = r.convert(i, v)
+	}
+	return nil
+}
+
+// convert turns text in date and time columns into time.Time, as SQLite
+// drivers do, so such columns scan into time.Time values.
+func (r *rows) convert(index int, v interface{}) driver.Value {
+	s, ok := v.(string)
+	if !ok {
+		return v
+	}
+
+	switch r.ColumnTypeDatabaseTypeName(index) {
+	case "DATE", "DATETIME", "TIMESTAMP":
+		s = strings.TrimSuffix(s, "Z")
+		for _, layout := range timestampFormats {
+			if t, err := time.ParseInLocation(layout, s, time.UTC); err == nil {
+				return t
+			}
+		}
+	}
+	return v
+}